@@ -0,0 +1,124 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// log is for logging in this package.
+var approvallog = logf.Log.WithName("approval-check")
+
+// approvalWebhookPath is the path of the webhook which checks the approval of a change of a
+// sensitive PermsRoleBinding or PermsClusterRoleBinding
+const approvalWebhookPath = "/validate-perms-infra-mgmt-io-v1-approval"
+
+// SetupApprovalWebhookWithManager registers the webhook which checks the approver of a change
+func SetupApprovalWebhookWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(approvalWebhookPath, &webhook.Admission{Handler: &ApprovalValidator{Client: mgr.GetClient()}})
+	return nil
+}
+
+//+kubebuilder:webhook:path=/validate-perms-infra-mgmt-io-v1-approval,mutating=false,failurePolicy=fail,sideEffects=None,groups=perms.infra-mgmt.io,resources=permsrolebindings;permsclusterrolebindings,verbs=create;update,versions=v1,name=vpermsapproval.kb.io,admissionReviewVersions=v1
+
+//+kubebuilder:object:generate=false
+
+// ApprovalValidator rejects approvals of PermsRoleBindings and PermsClusterRoleBindings by an
+// author of the change, by users without the approve permission on the resource and approvals of
+// another generation
+type ApprovalValidator struct {
+	Client  client.Client
+	decoder *admission.Decoder
+}
+
+var _ admission.DecoderInjector = &ApprovalValidator{}
+
+// InjectDecoder implements admission.DecoderInjector
+func (v *ApprovalValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+// Handle checks a new value of the approve annotation
+func (v *ApprovalValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	var obj, old client.Object
+	resource := "permsrolebindings"
+	switch req.Kind.Kind {
+	case "PermsRoleBinding":
+		obj, old = &PermsRoleBinding{}, &PermsRoleBinding{}
+	case "PermsClusterRoleBinding":
+		obj, old = &PermsClusterRoleBinding{}, &PermsClusterRoleBinding{}
+		resource = "permsclusterrolebindings"
+	default:
+		return admission.Allowed("")
+	}
+	if err := v.decoder.Decode(req, obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	approve, ok := obj.GetAnnotations()[ApproveAnnotation]
+	if !ok {
+		return admission.Allowed("")
+	}
+	if len(req.OldObject.Raw) > 0 {
+		if err := v.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if old.GetAnnotations()[ApproveAnnotation] == approve {
+			return admission.Allowed("")
+		}
+	}
+
+	user := req.UserInfo
+	author := obj.GetAnnotations()[AuthorAnnotation]
+	if user.Username == author {
+		return forbiddenResponse(fmt.Sprintf("user %s authored the spec and may not approve it", user.Username))
+	}
+	if containsString(ChangeAuthors(obj.GetAnnotations()), user.Username) {
+		return forbiddenResponse(fmt.Sprintf("user %s changed the spec since it was last approved and may not approve it", user.Username))
+	}
+	if approve != strconv.FormatInt(obj.GetGeneration(), 10) {
+		return forbiddenResponse(fmt.Sprintf("%s must be the generation %d of the approved spec", ApproveAnnotation, obj.GetGeneration()))
+	}
+	allowed, err := (&EscalationValidator{Client: v.Client}).allowed(ctx, user, &authorizationv1.ResourceAttributes{
+		Namespace: obj.GetNamespace(),
+		Verb:      "approve",
+		Group:     GroupVersion.Group,
+		Resource:  resource,
+		Name:      obj.GetName(),
+	}, nil)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if !allowed && obj.GetNamespace() == "" {
+		return forbiddenResponse(fmt.Sprintf("user %s may not approve %ss", user.Username, req.Kind.Kind))
+	}
+	if !allowed {
+		return forbiddenResponse(fmt.Sprintf("user %s may not approve %ss in namespace %s", user.Username, req.Kind.Kind, obj.GetNamespace()))
+	}
+	approvallog.Info("approved", "kind", req.Kind.Kind, "name", obj.GetName(), "namespace", obj.GetNamespace(), "generation", obj.GetGeneration(), "approver", user.Username, "author", author)
+	return admission.Allowed("")
+}
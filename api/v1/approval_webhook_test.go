@@ -0,0 +1,253 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var _ = Describe("approval webhook", func() {
+	newPermsRoleBinding := func(annotations map[string]string) *PermsRoleBinding {
+		return &PermsRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "admins", Namespace: "prod", Generation: 3, Annotations: annotations},
+			Spec:       PermsRoleBindingSpec{Kind: "ClusterRole", Role: "admin", Users: []string{"user1"}},
+		}
+	}
+	policies := []PermsPolicy{{Spec: PermsPolicySpec{TwoPersonApproval: &ApprovalPolicy{
+		Roles:      []RolePattern{{Kind: "ClusterRole", Name: "admin"}, {Name: "*-admin"}},
+		Namespaces: []string{"prod*"},
+	}}}}
+
+	Context("flagging sensitive PermsRoleBindings", func() {
+
+		It("should require an approval if a role and the namespace match", func() {
+			prb := newPermsRoleBinding(nil)
+			Expect(RequiresApproval(policies, prb.PolicyRequest())).To(BeTrue())
+			prb.Spec.Role = "view"
+			prb.Spec.Roles = []RoleSpec{{Kind: "Role", Name: "db-admin"}}
+			Expect(RequiresApproval(policies, prb.PolicyRequest())).To(BeTrue())
+		})
+
+		It("should not require an approval for other roles and namespaces", func() {
+			prb := newPermsRoleBinding(nil)
+			prb.Spec.Role = "view"
+			Expect(RequiresApproval(policies, prb.PolicyRequest())).To(BeFalse())
+			prb = newPermsRoleBinding(nil)
+			prb.Namespace = "testing"
+			Expect(RequiresApproval(policies, prb.PolicyRequest())).To(BeFalse())
+			Expect(RequiresApproval([]PermsPolicy{{}}, newPermsRoleBinding(nil).PolicyRequest())).To(BeFalse())
+		})
+
+		It("should match every namespace for a cluster-wide PermsClusterRoleBinding", func() {
+			pcrb := &PermsClusterRoleBinding{Spec: PermsClusterRoleBindingSpec{Role: "admin", Users: []string{"user1"}}}
+			Expect(RequiresApproval(policies, pcrb.PolicyRequest())).To(BeTrue())
+			pcrb.Spec.Namespaces = []string{"testing"}
+			Expect(RequiresApproval(policies, pcrb.PolicyRequest())).To(BeFalse())
+			pcrb.Spec.Namespaces = []string{"testing", "prod-eu"}
+			Expect(RequiresApproval(policies, pcrb.PolicyRequest())).To(BeTrue())
+		})
+	})
+
+	Context("summarizing a change", func() {
+
+		It("should list the whole spec if nothing was approved", func() {
+			Expect(SpecChanges(nil, newPermsRoleBinding(nil).Spec)).To(Equal([]string{
+				"role none -> ClusterRole/admin",
+				"users added: user1",
+			}))
+		})
+
+		It("should list added and removed subjects and changed fields", func() {
+			approved := newPermsRoleBinding(nil).Spec
+			spec := *approved.DeepCopy()
+			spec.Users = []string{"user2"}
+			spec.Serviceaccounts = []Serviceaccount{{Namespace: "prod", Name: "deployer"}}
+			spec.ImmutableRoleRef = true
+			Expect(SpecChanges(&approved, spec)).To(Equal([]string{
+				"users added: user2",
+				"users removed: user1",
+				"serviceaccounts added: prod/deployer",
+				"immutableRoleRef false -> true",
+			}))
+			Expect(SpecChanges(&approved, approved)).To(BeEmpty())
+		})
+
+		It("should list the changes of a PermsClusterRoleBinding", func() {
+			approved := PermsClusterRoleBindingSpec{Role: "admin", Users: []string{"user1"}}
+			spec := *approved.DeepCopy()
+			spec.Role = "cluster-admin"
+			spec.Namespaces = []string{"prod"}
+			Expect(ClusterSpecChanges(&approved, spec)).To(Equal([]string{
+				"role ClusterRole/admin -> ClusterRole/cluster-admin",
+				"namespaces added: prod",
+			}))
+			Expect(ClusterSpecChanges(nil, approved)).To(Equal([]string{
+				"role none -> ClusterRole/admin",
+				"users added: user1",
+			}))
+		})
+	})
+
+	Context("reading the approver", func() {
+
+		It("should return the approver of the current generation", func() {
+			Expect(newPermsRoleBinding(map[string]string{
+				AuthorAnnotation: "alice", ApproveAnnotation: "3", ApprovedByAnnotation: "bob",
+			}).Approver()).To(Equal("bob"))
+		})
+
+		It("should ignore approvals of another generation and by the author", func() {
+			Expect(newPermsRoleBinding(map[string]string{
+				AuthorAnnotation: "alice", ApproveAnnotation: "2", ApprovedByAnnotation: "bob",
+			}).Approver()).To(BeEmpty())
+			Expect(newPermsRoleBinding(map[string]string{
+				AuthorAnnotation: "alice", ApproveAnnotation: "3", ApprovedByAnnotation: "alice",
+			}).Approver()).To(BeEmpty())
+		})
+
+		It("should ignore approvals by an author of an earlier change which is not approved yet", func() {
+			Expect(newPermsRoleBinding(map[string]string{
+				AuthorAnnotation: "bob", ChangeAuthorsAnnotation: `["alice","bob"]`, ApproveAnnotation: "3", ApprovedByAnnotation: "alice",
+			}).Approver()).To(BeEmpty())
+			Expect(newPermsRoleBinding(map[string]string{
+				AuthorAnnotation: "bob", ChangeAuthorsAnnotation: `["alice","bob"]`, ApproveAnnotation: "3", ApprovedByAnnotation: "carol",
+			}).Approver()).To(Equal("carol"))
+		})
+	})
+
+	Context("validating an approval", func() {
+		newValidator := func(permissions map[string]bool) *ApprovalValidator {
+			scheme := runtime.NewScheme()
+			Expect(AddToScheme(scheme)).To(Succeed())
+			Expect(rbacv1.AddToScheme(scheme)).To(Succeed())
+			decoder, err := admission.NewDecoder(scheme)
+			Expect(err).NotTo(HaveOccurred())
+			v := &ApprovalValidator{Client: &sarClient{
+				Client:      fake.NewClientBuilder().WithScheme(scheme).Build(),
+				permissions: permissions,
+			}}
+			Expect(v.InjectDecoder(decoder)).To(Succeed())
+			return v
+		}
+		approvers := map[string]bool{"prod:approve permsrolebindings.perms.infra-mgmt.io admins": true}
+		approve := func(v *ApprovalValidator, username string, approval string) admission.Response {
+			old := newPermsRoleBinding(map[string]string{AuthorAnnotation: "alice"})
+			prb := newPermsRoleBinding(map[string]string{AuthorAnnotation: "alice", ApproveAnnotation: approval})
+			req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Update,
+				Kind:      metav1.GroupVersionKind{Group: GroupVersion.Group, Version: GroupVersion.Version, Kind: "PermsRoleBinding"},
+				Name:      prb.Name,
+				Namespace: prb.Namespace,
+				UserInfo:  authenticationv1.UserInfo{Username: username},
+			}}
+			raw, err := json.Marshal(prb)
+			Expect(err).NotTo(HaveOccurred())
+			req.Object = runtime.RawExtension{Raw: raw}
+			raw, err = json.Marshal(old)
+			Expect(err).NotTo(HaveOccurred())
+			req.OldObject = runtime.RawExtension{Raw: raw}
+			return v.Handle(context.Background(), req)
+		}
+
+		It("should allow the approval of the current generation by an authorised user", func() {
+			Expect(approve(newValidator(approvers), "bob", "3").Allowed).To(BeTrue())
+		})
+
+		It("should reject the approval by the author", func() {
+			response := approve(newValidator(approvers), "alice", "3")
+			Expect(response.Allowed).To(BeFalse())
+			Expect(response.Result.Message).To(Equal("user alice authored the spec and may not approve it"))
+		})
+
+		It("should reject the approval by the author of a change which another user changed again", func() {
+			// alice changes the spec of the applied generation 1
+			applied := newPermsRoleBinding(map[string]string{AuthorAnnotation: "carol"})
+			applied.Generation = 1
+			applied.Status.ObservedGeneration = 1
+			changed := applied.DeepCopy()
+			changed.Generation = 2
+			changed.Spec.Users = []string{"user1", "user2"}
+			changed.Annotations = authorAnnotations(authenticationv1.UserInfo{Username: "alice"}, admissionv1.Update, changed, applied)
+			changed.Status.ObservedGeneration = 2
+			changed.Status.PendingChange = &PendingChange{Generation: 2, Author: "alice"}
+
+			// bob makes a trivial change, alice is no longer the author
+			reordered := changed.DeepCopy()
+			reordered.Generation = 3
+			reordered.Spec.Users = []string{"user2", "user1"}
+			reordered.Annotations = authorAnnotations(authenticationv1.UserInfo{Username: "bob"}, admissionv1.Update, reordered, changed)
+			Expect(reordered.Annotations).To(HaveKeyWithValue(AuthorAnnotation, "bob"))
+
+			approval := func(username string) (admission.Response, string) {
+				approved := reordered.DeepCopy()
+				approved.Annotations[ApproveAnnotation] = "3"
+				approved.Annotations = authorAnnotations(authenticationv1.UserInfo{Username: username}, admissionv1.Update, approved, reordered)
+				req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: admissionv1.Update,
+					Kind:      metav1.GroupVersionKind{Group: GroupVersion.Group, Version: GroupVersion.Version, Kind: "PermsRoleBinding"},
+					Name:      approved.Name,
+					Namespace: approved.Namespace,
+					UserInfo:  authenticationv1.UserInfo{Username: username},
+				}}
+				raw, err := json.Marshal(approved)
+				Expect(err).NotTo(HaveOccurred())
+				req.Object = runtime.RawExtension{Raw: raw}
+				raw, err = json.Marshal(reordered)
+				Expect(err).NotTo(HaveOccurred())
+				req.OldObject = runtime.RawExtension{Raw: raw}
+				return newValidator(approvers).Handle(context.Background(), req), approved.Approver()
+			}
+			response, approver := approval("alice")
+			Expect(response.Allowed).To(BeFalse())
+			Expect(response.Result.Message).To(Equal("user alice changed the spec since it was last approved and may not approve it"))
+			Expect(approver).To(BeEmpty())
+
+			response, approver = approval("carol")
+			Expect(response.Allowed).To(BeTrue())
+			Expect(approver).To(Equal("carol"))
+		})
+
+		It("should reject the approval of another generation", func() {
+			response := approve(newValidator(approvers), "bob", "2")
+			Expect(response.Allowed).To(BeFalse())
+			Expect(response.Result.Message).To(Equal("perms.infra-mgmt.io/approve must be the generation 3 of the approved spec"))
+		})
+
+		It("should reject the approval by a user without the approve permission", func() {
+			response := approve(newValidator(nil), "bob", "3")
+			Expect(response.Allowed).To(BeFalse())
+			Expect(response.Result.Message).To(Equal("user bob may not approve PermsRoleBindings in namespace prod"))
+		})
+
+		It("should check the approval of a PermsClusterRoleBinding cluster-wide", func() {
+			pcrb := &PermsClusterRoleBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: "admins", Generation: 2, Annotations: map[string]string{AuthorAnnotation: "alice", ApproveAnnotation: "2"}},
+				Spec:       PermsClusterRoleBindingSpec{Role: "admin", Users: []string{"user1"}},
+			}
+			raw, err := json.Marshal(pcrb)
+			Expect(err).NotTo(HaveOccurred())
+			req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Create,
+				Kind:      metav1.GroupVersionKind{Group: GroupVersion.Group, Version: GroupVersion.Version, Kind: "PermsClusterRoleBinding"},
+				Name:      pcrb.Name,
+				UserInfo:  authenticationv1.UserInfo{Username: "bob"},
+				Object:    runtime.RawExtension{Raw: raw},
+			}}
+			response := newValidator(approvers).Handle(context.Background(), req)
+			Expect(response.Allowed).To(BeFalse())
+			Expect(response.Result.Message).To(Equal("user bob may not approve PermsClusterRoleBindings"))
+
+			v := newValidator(map[string]bool{":approve permsclusterrolebindings.perms.infra-mgmt.io admins": true})
+			Expect(v.Handle(context.Background(), req).Allowed).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,159 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// AuthorAnnotation records the user who created or last changed the spec of a PermsRoleBinding
+// or PermsClusterRoleBinding
+const AuthorAnnotation = "perms.infra-mgmt.io/author"
+
+// AuthorGroupsAnnotation records the groups of the author as JSON list
+const AuthorGroupsAnnotation = "perms.infra-mgmt.io/author-groups"
+
+// ChangeAuthorsAnnotation records the users who changed the spec since it was last approved or
+// applied without a pending approval as JSON list, none of them may approve the change
+const ChangeAuthorsAnnotation = "perms.infra-mgmt.io/change-authors"
+
+// authorWebhookPath is the path of the webhook which records the author
+const authorWebhookPath = "/mutate-perms-infra-mgmt-io-v1-author"
+
+// SetupAuthorWebhookWithManager registers the webhook which records the author of
+// PermsRoleBindings and PermsClusterRoleBindings
+func SetupAuthorWebhookWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(authorWebhookPath, &webhook.Admission{Handler: &AuthorAnnotator{}})
+	return nil
+}
+
+//+kubebuilder:webhook:path=/mutate-perms-infra-mgmt-io-v1-author,mutating=true,failurePolicy=fail,sideEffects=None,groups=perms.infra-mgmt.io,resources=permsrolebindings;permsclusterrolebindings,verbs=create;update,versions=v1,name=mpermsauthor.kb.io,admissionReviewVersions=v1
+
+//+kubebuilder:object:generate=false
+
+// AuthorAnnotator sets the author annotations to the requesting user. An update which does not
+// change the spec, e.g. the storage migration of the operator, keeps the author of the last change,
+// the annotations can not be set by the user. Likewise the approved-by annotation is set to the user
+// who set the approve annotation.
+type AuthorAnnotator struct{}
+
+// Handle sets the author annotations of the object
+func (a *AuthorAnnotator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	obj := map[string]interface{}{}
+	if err := json.Unmarshal(req.Object.Raw, &obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	metadata, _ := obj["metadata"].(map[string]interface{})
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+		obj["metadata"] = metadata
+	}
+	annotations, _ := metadata["annotations"].(map[string]interface{})
+	if annotations == nil {
+		annotations = map[string]interface{}{}
+	}
+
+	groups := req.UserInfo.Groups
+	if groups == nil {
+		groups = []string{}
+	}
+	rawGroups, err := json.Marshal(groups)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	author, authorGroups := interface{}(req.UserInfo.Username), interface{}(string(rawGroups))
+	changeAuthors := []string{req.UserInfo.Username}
+	var oldAnnotations map[string]interface{}
+	if req.Operation == admissionv1.Update {
+		old := map[string]interface{}{}
+		if err := json.Unmarshal(req.OldObject.Raw, &old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		oldMetadata, _ := old["metadata"].(map[string]interface{})
+		oldAnnotations, _ = oldMetadata["annotations"].(map[string]interface{})
+		if equality.Semantic.DeepEqual(old["spec"], obj["spec"]) {
+			author, authorGroups = oldAnnotations[AuthorAnnotation], oldAnnotations[AuthorGroupsAnnotation]
+			changeAuthors = nil
+		} else if changePending(old) {
+			// the authors of a change which is not approved yet may not approve it after another
+			// user changed the spec again
+			oldAuthor, _ := oldAnnotations[AuthorAnnotation].(string)
+			oldChangeAuthors, _ := oldAnnotations[ChangeAuthorsAnnotation].(string)
+			changeAuthors = ChangeAuthors(map[string]string{AuthorAnnotation: oldAuthor, ChangeAuthorsAnnotation: oldChangeAuthors})
+			if !containsString(changeAuthors, req.UserInfo.Username) {
+				changeAuthors = append(changeAuthors, req.UserInfo.Username)
+			}
+		}
+	}
+	setAnnotation(annotations, AuthorAnnotation, author)
+	setAnnotation(annotations, AuthorGroupsAnnotation, authorGroups)
+	if changeAuthors == nil {
+		setAnnotation(annotations, ChangeAuthorsAnnotation, oldAnnotations[ChangeAuthorsAnnotation])
+	} else {
+		rawChangeAuthors, err := json.Marshal(changeAuthors)
+		if err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+		setAnnotation(annotations, ChangeAuthorsAnnotation, string(rawChangeAuthors))
+	}
+
+	// the approver is the user who set the approve annotation
+	approvedBy := oldAnnotations[ApprovedByAnnotation]
+	if approve, ok := annotations[ApproveAnnotation]; approve != oldAnnotations[ApproveAnnotation] {
+		approvedBy = nil
+		if ok {
+			approvedBy = req.UserInfo.Username
+		}
+	}
+	setAnnotation(annotations, ApprovedByAnnotation, approvedBy)
+	if len(annotations) > 0 {
+		metadata["annotations"] = annotations
+	} else {
+		delete(metadata, "annotations")
+	}
+
+	marshaled, err := json.Marshal(obj)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
+
+// setAnnotation sets or, for a missing value, removes an annotation
+func setAnnotation(annotations map[string]interface{}, key string, value interface{}) {
+	if value == nil {
+		delete(annotations, key)
+		return
+	}
+	annotations[key] = value
+}
+
+// changePending returns true if the operator did not apply the spec of the object yet or holds a
+// change which waits for an approval
+func changePending(obj map[string]interface{}) bool {
+	metadata, _ := obj["metadata"].(map[string]interface{})
+	status, _ := obj["status"].(map[string]interface{})
+	return status["pendingChange"] != nil || status["observedGeneration"] != metadata["generation"]
+}
@@ -0,0 +1,142 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var _ = Describe("author webhook", func() {
+	newPermsRoleBinding := func(annotations map[string]string) *PermsRoleBinding {
+		return &PermsRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "testing", Annotations: annotations},
+			Spec:       PermsRoleBindingSpec{Kind: "ClusterRole", Role: "view", Users: []string{"user1"}},
+		}
+	}
+	// annotate applies the patches of the webhook for alice and returns the annotations of the result
+	annotate := func(operation admissionv1.Operation, prb *PermsRoleBinding, old *PermsRoleBinding) map[string]string {
+		return authorAnnotations(authenticationv1.UserInfo{Username: "alice", Groups: []string{"team-a", "system:authenticated"}}, operation, prb, old)
+	}
+
+	It("should record the author of a new resource", func() {
+		Expect(annotate(admissionv1.Create, newPermsRoleBinding(map[string]string{AuthorAnnotation: "mallory"}), nil)).To(Equal(map[string]string{
+			AuthorAnnotation:        "alice",
+			AuthorGroupsAnnotation:  `["team-a","system:authenticated"]`,
+			ChangeAuthorsAnnotation: `["alice"]`,
+		}))
+	})
+
+	It("should add the author to the authors of a change which is not applied yet", func() {
+		old := newPermsRoleBinding(map[string]string{AuthorAnnotation: "bob", ChangeAuthorsAnnotation: `["carol","bob"]`})
+		old.Generation = 2
+		old.Status.ObservedGeneration = 2
+		old.Status.PendingChange = &PendingChange{Generation: 2, Author: "bob"}
+		prb := newPermsRoleBinding(map[string]string{AuthorAnnotation: "bob", ChangeAuthorsAnnotation: `[]`})
+		prb.Spec.Users = []string{"user1", "user2"}
+		Expect(annotate(admissionv1.Update, prb, old)).To(HaveKeyWithValue(ChangeAuthorsAnnotation, `["carol","bob","alice"]`))
+
+		old.Status.PendingChange = nil
+		old.Status.ObservedGeneration = 1
+		Expect(annotate(admissionv1.Update, prb, old)).To(HaveKeyWithValue(ChangeAuthorsAnnotation, `["carol","bob","alice"]`))
+	})
+
+	It("should start the authors of a change again after the spec was applied", func() {
+		old := newPermsRoleBinding(map[string]string{AuthorAnnotation: "bob", ChangeAuthorsAnnotation: `["carol","bob"]`})
+		old.Generation = 2
+		old.Status.ObservedGeneration = 2
+		prb := newPermsRoleBinding(old.Annotations)
+		prb.Spec.Users = []string{"user1", "user2"}
+		Expect(annotate(admissionv1.Update, prb, old)).To(HaveKeyWithValue(ChangeAuthorsAnnotation, `["alice"]`))
+
+		// the authors can not be changed without a change of the spec
+		prb = newPermsRoleBinding(map[string]string{AuthorAnnotation: "bob", ChangeAuthorsAnnotation: `[]`})
+		Expect(annotate(admissionv1.Update, prb, old)).To(HaveKeyWithValue(ChangeAuthorsAnnotation, `["carol","bob"]`))
+	})
+
+	It("should record the author of a changed spec", func() {
+		old := newPermsRoleBinding(map[string]string{AuthorAnnotation: "bob", AuthorGroupsAnnotation: `[]`})
+		prb := newPermsRoleBinding(old.Annotations)
+		prb.Spec.Users = []string{"user1", "user2"}
+		Expect(annotate(admissionv1.Update, prb, old)).To(HaveKeyWithValue(AuthorAnnotation, "alice"))
+	})
+
+	It("should keep the author of the last change if the spec is unchanged", func() {
+		old := newPermsRoleBinding(map[string]string{AuthorAnnotation: "bob", AuthorGroupsAnnotation: `[]`})
+		prb := newPermsRoleBinding(map[string]string{AuthorAnnotation: "cluster-admin", "team": "a"})
+		Expect(annotate(admissionv1.Update, prb, old)).To(Equal(map[string]string{
+			AuthorAnnotation:       "bob",
+			AuthorGroupsAnnotation: `[]`,
+			"team":                 "a",
+		}))
+	})
+
+	It("should record the user who set the approve annotation", func() {
+		old := newPermsRoleBinding(map[string]string{AuthorAnnotation: "bob", AuthorGroupsAnnotation: `[]`})
+		prb := newPermsRoleBinding(map[string]string{AuthorAnnotation: "bob", AuthorGroupsAnnotation: `[]`, ApproveAnnotation: "1", ApprovedByAnnotation: "mallory"})
+		Expect(annotate(admissionv1.Update, prb, old)).To(Equal(map[string]string{
+			AuthorAnnotation:       "bob",
+			AuthorGroupsAnnotation: `[]`,
+			ApproveAnnotation:      "1",
+			ApprovedByAnnotation:   "alice",
+		}))
+
+		approved := newPermsRoleBinding(map[string]string{AuthorAnnotation: "bob", AuthorGroupsAnnotation: `[]`, ApproveAnnotation: "1", ApprovedByAnnotation: "carol"})
+		prb = newPermsRoleBinding(map[string]string{AuthorAnnotation: "bob", ApproveAnnotation: "1", ApprovedByAnnotation: "alice"})
+		Expect(annotate(admissionv1.Update, prb, approved)).To(HaveKeyWithValue(ApprovedByAnnotation, "carol"))
+	})
+})
+
+// authorAnnotations applies the patches of the author webhook for the user and returns the
+// annotations of the result
+func authorAnnotations(user authenticationv1.UserInfo, operation admissionv1.Operation, prb *PermsRoleBinding, old *PermsRoleBinding) map[string]string {
+	raw := func(prb *PermsRoleBinding) runtime.RawExtension {
+		data, err := json.Marshal(prb)
+		Expect(err).NotTo(HaveOccurred())
+		return runtime.RawExtension{Raw: data}
+	}
+	req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: operation,
+		Kind:      metav1.GroupVersionKind{Group: GroupVersion.Group, Version: GroupVersion.Version, Kind: "PermsRoleBinding"},
+		UserInfo:  user,
+		Object:    raw(prb),
+	}}
+	if old != nil {
+		req.OldObject = raw(old)
+	}
+	response := (&AuthorAnnotator{}).Handle(context.Background(), req)
+	Expect(response.Allowed).To(BeTrue())
+	annotations := map[string]string{}
+	for k, v := range prb.Annotations {
+		annotations[k] = v
+	}
+	for _, patch := range response.Patches {
+		switch patch.Operation {
+		case "add", "replace":
+			if values, ok := patch.Value.(map[string]interface{}); ok {
+				annotations = map[string]string{}
+				for k, v := range values {
+					annotations[k] = v.(string)
+				}
+			} else {
+				annotations[unescapePointer(patch.Path)] = patch.Value.(string)
+			}
+		case "remove":
+			delete(annotations, unescapePointer(patch.Path))
+		}
+	}
+	return annotations
+}
+
+// unescapePointer returns the annotation key of a JSON patch path
+func unescapePointer(path string) string {
+	key := strings.TrimPrefix(path, "/metadata/annotations/")
+	return strings.NewReplacer("~1", "/", "~0", "~").Replace(key)
+}
@@ -0,0 +1,347 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// log is for logging in this package.
+var escalationlog = logf.Log.WithName("escalation-check")
+
+// escalationWebhookPath is the path of the webhook which checks that the author of a
+// PermsRoleBinding or PermsClusterRoleBinding may bind its roles
+const escalationWebhookPath = "/validate-perms-infra-mgmt-io-v1-escalation"
+
+// SetupEscalationWebhookWithManager registers the webhook which prevents privilege escalation
+// through the bind permission of the operator
+func SetupEscalationWebhookWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(escalationWebhookPath, &webhook.Admission{Handler: &EscalationValidator{Client: mgr.GetClient()}})
+	return nil
+}
+
+//+kubebuilder:webhook:path=/validate-perms-infra-mgmt-io-v1-escalation,mutating=false,failurePolicy=fail,sideEffects=None,groups=perms.infra-mgmt.io,resources=permsrolebindings;permsclusterrolebindings;permsdelegations;permsbreakglasses,verbs=create;update,versions=v1,name=vpermsescalation.kb.io,admissionReviewVersions=v1
+//+kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+//+kubebuilder:object:generate=false
+
+// EscalationValidator rejects PermsRoleBindings and PermsClusterRoleBindings whose author could
+// not have created the bindings directly. Like the escalation prevention of Kubernetes, the author
+// needs the bind permission on a role or all permissions the role grants. The author of a
+// PermsDelegation needs the same permissions for each delegated role, since its admins may bind
+// the roles without them. The author of the role and subjects of a PermsBreakGlass needs the
+// permission to bind the ClusterRole cluster-wide, which its activators do without it.
+type EscalationValidator struct {
+	Client  client.Client
+	decoder *admission.Decoder
+}
+
+var _ admission.DecoderInjector = &EscalationValidator{}
+
+// InjectDecoder implements admission.DecoderInjector
+func (v *EscalationValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+// roleBinding is a role which gets bound in a namespace, cluster-wide if the namespace is empty
+type roleBinding struct {
+	Namespace string
+	Role      RoleReference
+}
+
+// Handle checks the roles of the object with SubjectAccessReviews for the requesting user
+func (v *EscalationValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if finalizersOnly(req) {
+		return admission.Allowed("")
+	}
+	var bindings []roleBinding
+	var denied []string
+	switch req.Kind.Kind {
+	case "PermsRoleBinding":
+		prb := &PermsRoleBinding{}
+		if err := v.decoder.Decode(req, prb); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		// the admins of a PermsDelegation may grant its roles to its subjects without the bind
+		// permission on the roles
+		delegations := &PermsDelegationList{}
+		if err := v.Client.List(ctx, delegations, client.InNamespace(prb.Namespace)); err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+		if delegation := DelegatedBy(delegations.Items, prb.PolicyRequest(), req.UserInfo); delegation != nil {
+			escalationlog.Info("delegated", "name", req.Name, "namespace", req.Namespace, "user", req.UserInfo.Username, "delegation", delegation.Name)
+			return admission.Allowed("")
+		}
+		for _, role := range prb.PolicyRequest().Roles {
+			bindings = append(bindings, roleBinding{Namespace: prb.Namespace, Role: role})
+		}
+	case "PermsClusterRoleBinding":
+		pcrb := &PermsClusterRoleBinding{}
+		if err := v.decoder.Decode(req, pcrb); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		role := RoleReference{Kind: "ClusterRole", Name: pcrb.Spec.Role}
+		// namespaces which match the selector later get the role bound as well, a selector
+		// needs the permission in all namespaces
+		if pcrb.Spec.NamespaceSelector != nil || len(pcrb.Spec.Namespaces) == 0 {
+			bindings = append(bindings, roleBinding{Role: role})
+		} else {
+			for _, namespace := range pcrb.Spec.Namespaces {
+				bindings = append(bindings, roleBinding{Namespace: namespace, Role: role})
+			}
+		}
+	case "PermsBreakGlass":
+		bg := &PermsBreakGlass{}
+		if err := v.decoder.Decode(req, bg); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		// the activators bind the ClusterRole cluster-wide later, the author of the role and the
+		// subjects needs the permission to bind it
+		if req.Operation == admissionv1.Update {
+			old := &PermsBreakGlass{}
+			if err := v.decoder.DecodeRaw(req.OldObject, old); err != nil {
+				return admission.Errored(http.StatusBadRequest, err)
+			}
+			if old.Spec.Role == bg.Spec.Role && equality.Semantic.DeepEqual(old.Spec.Users, bg.Spec.Users) &&
+				equality.Semantic.DeepEqual(old.Spec.Groups, bg.Spec.Groups) {
+				return admission.Allowed("")
+			}
+		}
+		bindings = append(bindings, roleBinding{Role: RoleReference{Kind: "ClusterRole", Name: bg.Spec.Role}})
+	case "PermsDelegation":
+		delegation := &PermsDelegation{}
+		if err := v.decoder.Decode(req, delegation); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		for _, pattern := range delegation.Spec.Roles {
+			kinds := []string{pattern.Kind}
+			if pattern.Kind == "" {
+				kinds = []string{"ClusterRole", "Role"}
+			}
+			for _, kind := range kinds {
+				role := RoleReference{Kind: kind, Name: pattern.Name}
+				if !isPattern(pattern.Name) {
+					bindings = append(bindings, roleBinding{Namespace: delegation.Namespace, Role: role})
+					continue
+				}
+				reason, err := v.patternEscalation(ctx, req.UserInfo, delegation.Namespace, role)
+				if err != nil {
+					return admission.Errored(http.StatusInternalServerError, err)
+				}
+				if reason != "" {
+					denied = append(denied, reason)
+				}
+			}
+		}
+	default:
+		return admission.Allowed("")
+	}
+
+	for _, binding := range bindings {
+		reason, err := v.escalation(ctx, req.UserInfo, binding)
+		if err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+		if reason != "" {
+			denied = append(denied, reason)
+		}
+	}
+	if len(denied) > 0 {
+		escalationlog.Info("privilege escalation", "kind", req.Kind.Kind, "name", req.Name, "namespace", req.Namespace, "user", req.UserInfo.Username, "denied", denied)
+		// admission.Denied only sets the reason, the API server shows the message to the client
+		return admission.Response{AdmissionResponse: admissionv1.AdmissionResponse{
+			Allowed: false,
+			Result: &metav1.Status{
+				Code:    http.StatusForbidden,
+				Reason:  metav1.StatusReasonForbidden,
+				Message: strings.Join(denied, "; "),
+			},
+		}}
+	}
+	return admission.Allowed("")
+}
+
+// escalation returns why the user may not bind the role, empty if the user has the bind
+// permission on the role or all permissions of its rules
+func (v *EscalationValidator) escalation(ctx context.Context, user authenticationv1.UserInfo, binding roleBinding) (string, error) {
+	where := "cluster-wide"
+	if binding.Namespace != "" {
+		where = "in namespace " + binding.Namespace
+	}
+	prefix := fmt.Sprintf("user %s may not bind %s %s %s", user.Username, binding.Role.Kind, binding.Role.Name, where)
+
+	resource := "clusterroles"
+	if binding.Role.Kind == "Role" {
+		resource = "roles"
+	}
+	allowed, err := v.allowed(ctx, user, &authorizationv1.ResourceAttributes{
+		Namespace: binding.Namespace,
+		Verb:      "bind",
+		Group:     rbacv1.GroupName,
+		Resource:  resource,
+		Name:      binding.Role.Name,
+	}, nil)
+	if err != nil || allowed {
+		return "", err
+	}
+
+	var rules []rbacv1.PolicyRule
+	if binding.Role.Kind == "Role" {
+		role := &rbacv1.Role{}
+		err = v.Client.Get(ctx, client.ObjectKey{Namespace: binding.Namespace, Name: binding.Role.Name}, role)
+		rules = role.Rules
+	} else {
+		clusterRole := &rbacv1.ClusterRole{}
+		err = v.Client.Get(ctx, client.ObjectKey{Name: binding.Role.Name}, clusterRole)
+		rules = clusterRole.Rules
+	}
+	if apierrors.IsNotFound(err) {
+		return prefix + ", it has no bind permission and the role does not exist", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	for _, rule := range rules {
+		missing, err := v.missingPermission(ctx, user, binding.Namespace, rule)
+		if err != nil {
+			return "", err
+		}
+		if missing != "" {
+			return prefix + ", it has no bind permission and may not " + missing, nil
+		}
+	}
+	return "", nil
+}
+
+// patternEscalation returns why the user may not delegate the roles a pattern matches, empty if
+// the user has the bind permission on all of them. A pattern also matches roles which get created
+// later, so a ClusterRole pattern needs the bind permission on all ClusterRoles cluster-wide and a
+// Role pattern on all Roles of the namespace.
+func (v *EscalationValidator) patternEscalation(ctx context.Context, user authenticationv1.UserInfo, namespace string, role RoleReference) (string, error) {
+	resource, where := "clusterroles", "cluster-wide"
+	if role.Kind == "Role" {
+		resource, where = "roles", "in namespace "+namespace
+	} else {
+		namespace = ""
+	}
+	allowed, err := v.allowed(ctx, user, &authorizationv1.ResourceAttributes{
+		Namespace: namespace,
+		Verb:      "bind",
+		Group:     rbacv1.GroupName,
+		Resource:  resource,
+	}, nil)
+	if err != nil || allowed {
+		return "", err
+	}
+	return fmt.Sprintf("user %s may not delegate %s %s, the pattern needs the bind permission on all %s %s", user.Username, role.Kind, role.Name, resource, where), nil
+}
+
+// isPattern returns true if the name contains characters which path.Match interprets
+func isPattern(name string) bool {
+	return strings.ContainsAny(name, `*?[\`)
+}
+
+// missingPermission returns the first permission of the rule which the user does not have
+func (v *EscalationValidator) missingPermission(ctx context.Context, user authenticationv1.UserInfo, namespace string, rule rbacv1.PolicyRule) (string, error) {
+	for _, verb := range rule.Verbs {
+		for _, url := range rule.NonResourceURLs {
+			allowed, err := v.allowed(ctx, user, nil, &authorizationv1.NonResourceAttributes{Path: url, Verb: verb})
+			if err != nil || !allowed {
+				return verb + " " + url, err
+			}
+		}
+		names := rule.ResourceNames
+		if len(names) == 0 {
+			names = []string{""}
+		}
+		for _, group := range rule.APIGroups {
+			for _, resource := range rule.Resources {
+				subresource := ""
+				if i := strings.Index(resource, "/"); i >= 0 {
+					resource, subresource = resource[:i], resource[i+1:]
+				}
+				for _, name := range names {
+					allowed, err := v.allowed(ctx, user, &authorizationv1.ResourceAttributes{
+						Namespace:   namespace,
+						Verb:        verb,
+						Group:       group,
+						Resource:    resource,
+						Subresource: subresource,
+						Name:        name,
+					}, nil)
+					if err != nil || !allowed {
+						return permissionString(verb, group, resource, subresource, name), err
+					}
+				}
+			}
+		}
+	}
+	return "", nil
+}
+
+// allowed issues a SubjectAccessReview for the user
+func (v *EscalationValidator) allowed(ctx context.Context, user authenticationv1.UserInfo, resource *authorizationv1.ResourceAttributes, nonResource *authorizationv1.NonResourceAttributes) (bool, error) {
+	extra := map[string]authorizationv1.ExtraValue{}
+	for key, values := range user.Extra {
+		extra[key] = authorizationv1.ExtraValue(values)
+	}
+	sar := &authorizationv1.SubjectAccessReview{Spec: authorizationv1.SubjectAccessReviewSpec{
+		User:                  user.Username,
+		UID:                   user.UID,
+		Groups:                user.Groups,
+		Extra:                 extra,
+		ResourceAttributes:    resource,
+		NonResourceAttributes: nonResource,
+	}}
+	if err := v.Client.Create(ctx, sar); err != nil {
+		return false, err
+	}
+	return sar.Status.Allowed, nil
+}
+
+// permissionString returns a permission as verb resource.group/subresource name
+func permissionString(verb string, group string, resource string, subresource string, name string) string {
+	permission := verb + " " + resource
+	if group != "" {
+		permission += "." + group
+	}
+	if subresource != "" {
+		permission += "/" + subresource
+	}
+	if name != "" {
+		permission += " " + name
+	}
+	return permission
+}
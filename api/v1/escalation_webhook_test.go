@@ -0,0 +1,236 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// sarClient answers SubjectAccessReviews with the permissions of the test
+type sarClient struct {
+	client.Client
+	permissions map[string]bool
+}
+
+func (c *sarClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	sar, ok := obj.(*authorizationv1.SubjectAccessReview)
+	if !ok {
+		return c.Client.Create(ctx, obj, opts...)
+	}
+	if attrs := sar.Spec.ResourceAttributes; attrs != nil {
+		sar.Status.Allowed = c.permissions[attrs.Namespace+":"+permissionString(attrs.Verb, attrs.Group, attrs.Resource, attrs.Subresource, attrs.Name)]
+	}
+	return nil
+}
+
+var _ = Describe("escalation webhook", func() {
+	newValidator := func(permissions map[string]bool, objs ...runtime.Object) *EscalationValidator {
+		scheme := runtime.NewScheme()
+		Expect(AddToScheme(scheme)).To(Succeed())
+		Expect(rbacv1.AddToScheme(scheme)).To(Succeed())
+		decoder, err := admission.NewDecoder(scheme)
+		Expect(err).NotTo(HaveOccurred())
+		v := &EscalationValidator{Client: &sarClient{
+			Client:      fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objs...).Build(),
+			permissions: permissions,
+		}}
+		Expect(v.InjectDecoder(decoder)).To(Succeed())
+		return v
+	}
+	requestFor := func(obj runtime.Object, kind string) admission.Request {
+		raw, err := json.Marshal(obj)
+		Expect(err).NotTo(HaveOccurred())
+		return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Create,
+			Kind:      metav1.GroupVersionKind{Group: GroupVersion.Group, Version: GroupVersion.Version, Kind: kind},
+			UserInfo:  authenticationv1.UserInfo{Username: "alice"},
+			Object:    runtime.RawExtension{Raw: raw},
+		}}
+	}
+	prb := &PermsRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "testing"},
+		Spec:       PermsRoleBindingSpec{Kind: "ClusterRole", Role: "pod-reader", Users: []string{"bob"}},
+	}
+	podReader := &rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod-reader"},
+		Rules:      []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods", "pods/log"}, Verbs: []string{"get", "list"}}},
+	}
+
+	It("should allow an author with the bind permission on the role", func() {
+		v := newValidator(map[string]bool{"testing:bind clusterroles.rbac.authorization.k8s.io pod-reader": true})
+		Expect(v.Handle(context.Background(), requestFor(prb, "PermsRoleBinding")).Allowed).To(BeTrue())
+	})
+
+	It("should allow an author who holds all permissions of the role", func() {
+		v := newValidator(map[string]bool{
+			"testing:get pods": true, "testing:get pods/log": true,
+			"testing:list pods": true, "testing:list pods/log": true,
+		}, podReader)
+		Expect(v.Handle(context.Background(), requestFor(prb, "PermsRoleBinding")).Allowed).To(BeTrue())
+	})
+
+	It("should deny an author who lacks a permission of the role", func() {
+		v := newValidator(map[string]bool{"testing:get pods": true, "testing:get pods/log": true}, podReader)
+		response := v.Handle(context.Background(), requestFor(prb, "PermsRoleBinding"))
+		Expect(response.Allowed).To(BeFalse())
+		Expect(response.Result.Message).To(Equal("user alice may not bind ClusterRole pod-reader in namespace testing, it has no bind permission and may not list pods"))
+	})
+
+	It("should deny binding a role which does not exist without the bind permission", func() {
+		response := newValidator(nil).Handle(context.Background(), requestFor(prb, "PermsRoleBinding"))
+		Expect(response.Allowed).To(BeFalse())
+		Expect(response.Result.Message).To(ContainSubstring("the role does not exist"))
+	})
+
+	It("should allow an admin of a PermsDelegation which allows the role and subjects", func() {
+		delegation := &PermsDelegation{
+			ObjectMeta: metav1.ObjectMeta{Name: "team-a", Namespace: "testing"},
+			Spec: PermsDelegationSpec{
+				Admins: DelegationAdmins{Users: []string{"alice"}},
+				Roles:  []RolePattern{{Kind: "ClusterRole", Name: "pod-reader"}},
+				Users:  []string{"bob"},
+			},
+		}
+		Expect(newValidator(nil, podReader, delegation).Handle(context.Background(), requestFor(prb, "PermsRoleBinding")).Allowed).To(BeTrue())
+
+		delegation.Spec.Admins = DelegationAdmins{Users: []string{"carol"}}
+		Expect(newValidator(nil, podReader, delegation).Handle(context.Background(), requestFor(prb, "PermsRoleBinding")).Allowed).To(BeFalse())
+	})
+
+	It("should check a PermsClusterRoleBinding with a namespace selector cluster-wide", func() {
+		pcrb := &PermsClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "demo"},
+			Spec: PermsClusterRoleBindingSpec{
+				Role:              "pod-reader",
+				Users:             []string{"bob"},
+				NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}},
+			},
+		}
+		v := newValidator(map[string]bool{"testing:bind clusterroles.rbac.authorization.k8s.io pod-reader": true})
+		response := v.Handle(context.Background(), requestFor(pcrb, "PermsClusterRoleBinding"))
+		Expect(response.Allowed).To(BeFalse())
+		Expect(response.Result.Message).To(ContainSubstring("may not bind ClusterRole pod-reader cluster-wide"))
+
+		pcrb.Spec.NamespaceSelector = nil
+		pcrb.Spec.Namespaces = []string{"testing"}
+		Expect(v.Handle(context.Background(), requestFor(pcrb, "PermsClusterRoleBinding")).Allowed).To(BeTrue())
+	})
+
+	It("should deny delegating a role which the author may not bind", func() {
+		delegation := &PermsDelegation{
+			ObjectMeta: metav1.ObjectMeta{Name: "team-a", Namespace: "testing"},
+			Spec: PermsDelegationSpec{
+				Admins: DelegationAdmins{Users: []string{"alice"}},
+				Roles:  []RolePattern{{Kind: "ClusterRole", Name: "pod-reader"}},
+				Users:  []string{"bob"},
+			},
+		}
+		response := newValidator(nil, podReader).Handle(context.Background(), requestFor(delegation, "PermsDelegation"))
+		Expect(response.Allowed).To(BeFalse())
+		Expect(response.Result.Message).To(ContainSubstring("may not bind ClusterRole pod-reader in namespace testing"))
+
+		v := newValidator(map[string]bool{"testing:bind clusterroles.rbac.authorization.k8s.io pod-reader": true}, podReader)
+		Expect(v.Handle(context.Background(), requestFor(delegation, "PermsDelegation")).Allowed).To(BeTrue())
+	})
+
+	It("should require the bind permission on all roles for a delegated role pattern", func() {
+		delegation := &PermsDelegation{
+			ObjectMeta: metav1.ObjectMeta{Name: "team-a", Namespace: "testing"},
+			Spec: PermsDelegationSpec{
+				Admins: DelegationAdmins{Users: []string{"alice"}},
+				Roles:  []RolePattern{{Kind: "ClusterRole", Name: "*"}},
+				Users:  []string{"alice"},
+			},
+		}
+		v := newValidator(map[string]bool{"testing:bind clusterroles.rbac.authorization.k8s.io": true}, podReader)
+		response := v.Handle(context.Background(), requestFor(delegation, "PermsDelegation"))
+		Expect(response.Allowed).To(BeFalse())
+		Expect(response.Result.Message).To(ContainSubstring("may not delegate ClusterRole *, the pattern needs the bind permission on all clusterroles cluster-wide"))
+
+		v = newValidator(map[string]bool{":bind clusterroles.rbac.authorization.k8s.io": true}, podReader)
+		Expect(v.Handle(context.Background(), requestFor(delegation, "PermsDelegation")).Allowed).To(BeTrue())
+
+		delegation.Spec.Roles = []RolePattern{{Name: "edit-*"}}
+		response = v.Handle(context.Background(), requestFor(delegation, "PermsDelegation"))
+		Expect(response.Allowed).To(BeFalse())
+		Expect(response.Result.Message).To(ContainSubstring("may not delegate Role edit-*, the pattern needs the bind permission on all roles in namespace testing"))
+	})
+
+	It("should check the role and subjects of a PermsBreakGlass cluster-wide", func() {
+		bg := &PermsBreakGlass{
+			ObjectMeta: metav1.ObjectMeta{Name: "emergency"},
+			Spec: PermsBreakGlassSpec{
+				Role:        "cluster-admin",
+				Users:       []string{"mallory"},
+				Activators:  BreakGlassActivators{Users: []string{"mallory"}},
+				MaxDuration: metav1.Duration{Duration: time.Hour},
+			},
+		}
+		v := newValidator(map[string]bool{"testing:bind clusterroles.rbac.authorization.k8s.io cluster-admin": true})
+		response := v.Handle(context.Background(), requestFor(bg, "PermsBreakGlass"))
+		Expect(response.Allowed).To(BeFalse())
+		Expect(response.Result.Message).To(ContainSubstring("may not bind ClusterRole cluster-admin cluster-wide"))
+
+		v = newValidator(map[string]bool{":bind clusterroles.rbac.authorization.k8s.io cluster-admin": true})
+		Expect(v.Handle(context.Background(), requestFor(bg, "PermsBreakGlass")).Allowed).To(BeTrue())
+	})
+
+	It("should check an update of a PermsBreakGlass only if the role or subjects change", func() {
+		old := &PermsBreakGlass{
+			ObjectMeta: metav1.ObjectMeta{Name: "emergency"},
+			Spec: PermsBreakGlassSpec{
+				Role:        "view",
+				Groups:      []string{"oncall"},
+				Activators:  BreakGlassActivators{Groups: []string{"oncall"}},
+				MaxDuration: metav1.Duration{Duration: time.Hour},
+			},
+		}
+		update := func(bg *PermsBreakGlass) admission.Request {
+			req := requestFor(bg, "PermsBreakGlass")
+			req.Operation = admissionv1.Update
+			raw, err := json.Marshal(old)
+			Expect(err).NotTo(HaveOccurred())
+			req.OldObject = runtime.RawExtension{Raw: raw}
+			return req
+		}
+		v := newValidator(nil)
+		bg := old.DeepCopy()
+		bg.Spec.MaxDuration = metav1.Duration{Duration: 2 * time.Hour}
+		Expect(v.Handle(context.Background(), update(bg)).Allowed).To(BeTrue())
+
+		bg.Spec.Users = []string{"mallory"}
+		Expect(v.Handle(context.Background(), update(bg)).Allowed).To(BeFalse())
+		bg = old.DeepCopy()
+		bg.Spec.Role = "cluster-admin"
+		Expect(v.Handle(context.Background(), update(bg)).Allowed).To(BeFalse())
+	})
+
+	It("should not check an update which only changes the finalizers", func() {
+		old := prb.DeepCopy()
+		old.Finalizers = []string{"example.com/cleanup"}
+		req := requestFor(prb, "PermsRoleBinding")
+		req.Operation = admissionv1.Update
+		raw, err := json.Marshal(old)
+		Expect(err).NotTo(HaveOccurred())
+		req.OldObject = runtime.RawExtension{Raw: raw}
+		Expect(newValidator(nil).Handle(context.Background(), req).Allowed).To(BeTrue())
+
+		old.Spec.Role = "view"
+		raw, err = json.Marshal(old)
+		Expect(err).NotTo(HaveOccurred())
+		req.OldObject = runtime.RawExtension{Raw: raw}
+		Expect(newValidator(nil).Handle(context.Background(), req).Allowed).To(BeFalse())
+	})
+})
@@ -0,0 +1,150 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Phases of a PermsAccessRequest
+const (
+	AccessRequestPending  = "Pending"
+	AccessRequestApproved = "Approved"
+	AccessRequestDenied   = "Denied"
+	AccessRequestActive   = "Active"
+	AccessRequestExpired  = "Expired"
+)
+
+// PermsAccessRequestSpec defines the role a user requests for a limited time
+type PermsAccessRequestSpec struct {
+	// Kind of the requested role, Role or ClusterRole. Defaults to ClusterRole.
+	//+kubebuilder:default=ClusterRole
+	//+kubebuilder:validation:Enum=Role;ClusterRole
+	//+optional
+	Kind string `json:"kind,omitempty"`
+
+	// Role is the name of the requested role, it gets bound in the namespace of the request.
+	Role string `json:"role"`
+
+	// Duration for which the role is bound after the approval, e.g. 2h.
+	Duration metav1.Duration `json:"duration"`
+
+	// Justification explains why the access is needed.
+	Justification string `json:"justification"`
+
+	// Requester is the user who created the request and gets the role assigned, set by the webhook.
+	//+optional
+	Requester string `json:"requester,omitempty"`
+
+	// Approval is the decision on the request. It can be set once, by a user other than the
+	// requester with the approve permission on the request.
+	//+optional
+	Approval *AccessApproval `json:"approval,omitempty"`
+}
+
+// AccessApproval is the decision on a PermsAccessRequest
+type AccessApproval struct {
+	// Decision is Approved or Denied.
+	//+kubebuilder:validation:Enum=Approved;Denied
+	Decision string `json:"decision"`
+
+	// Comment of the approver.
+	//+optional
+	Comment string `json:"comment,omitempty"`
+
+	// Approver is the user who decided on the request, set by the webhook.
+	//+optional
+	Approver string `json:"approver,omitempty"`
+
+	// Time of the decision, set by the webhook.
+	//+optional
+	Time *metav1.Time `json:"time,omitempty"`
+}
+
+// PermsAccessRequestStatus defines the observed state of PermsAccessRequest
+type PermsAccessRequestStatus struct {
+	// Phase is Pending, Approved, Denied, Active or Expired.
+	//+optional
+	Phase string `json:"phase,omitempty"`
+
+	// RequestedAt is the time the request was created.
+	//+optional
+	RequestedAt *metav1.Time `json:"requestedAt,omitempty"`
+
+	// ApprovedAt is the time the request was approved.
+	//+optional
+	ApprovedAt *metav1.Time `json:"approvedAt,omitempty"`
+
+	// DeniedAt is the time the request was denied.
+	//+optional
+	DeniedAt *metav1.Time `json:"deniedAt,omitempty"`
+
+	// ActivatedAt is the time the role was bound.
+	//+optional
+	ActivatedAt *metav1.Time `json:"activatedAt,omitempty"`
+
+	// ExpiresAt is the time the role gets revoked.
+	//+optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// ExpiredAt is the time the role was revoked.
+	//+optional
+	ExpiredAt *metav1.Time `json:"expiredAt,omitempty"`
+
+	// PermsRoleBinding is the name of the PermsRoleBinding which binds the role.
+	//+optional
+	PermsRoleBinding string `json:"permsRoleBinding,omitempty"`
+
+	// ObservedGeneration is the generation the status was computed for.
+	//+optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	//+optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=par
+//+kubebuilder:printcolumn:name=Role,type=string,JSONPath=".spec.role"
+//+kubebuilder:printcolumn:name=Requester,type=string,JSONPath=".spec.requester"
+//+kubebuilder:printcolumn:name=Phase,type=string,JSONPath=".status.phase"
+//+kubebuilder:printcolumn:name=Expires,type=date,JSONPath=".status.expiresAt"
+//+kubebuilder:printcolumn:name=Age,type=date,JSONPath=".metadata.creationTimestamp"
+
+// PermsAccessRequest is the Schema for the permsaccessrequests API. A user requests a role for a
+// limited time, the role is bound after another user approved the request.
+type PermsAccessRequest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PermsAccessRequestSpec   `json:"spec,omitempty"`
+	Status PermsAccessRequestStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PermsAccessRequestList contains a list of PermsAccessRequest
+type PermsAccessRequestList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PermsAccessRequest `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PermsAccessRequest{}, &PermsAccessRequestList{})
+}
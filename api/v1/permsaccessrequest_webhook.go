@@ -0,0 +1,240 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// log is for logging in this package.
+var permsaccessrequestlog = logf.Log.WithName("permsaccessrequest-resource")
+
+// paths of the webhooks which record the requester and approver of a PermsAccessRequest and
+// check the approval
+const (
+	accessRequestMutatingWebhookPath   = "/mutate-perms-infra-mgmt-io-v1-permsaccessrequest"
+	accessRequestValidatingWebhookPath = "/validate-perms-infra-mgmt-io-v1-permsaccessrequest"
+)
+
+// SetupWebhookWithManager registers the webhooks of PermsAccessRequests, they need the requesting
+// user and are therefore no Defaulter and Validator
+func (r *PermsAccessRequest) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(accessRequestMutatingWebhookPath, &webhook.Admission{Handler: &AccessRequestDefaulter{}})
+	mgr.GetWebhookServer().Register(accessRequestValidatingWebhookPath, &webhook.Admission{Handler: &AccessRequestValidator{Client: mgr.GetClient()}})
+	return nil
+}
+
+//+kubebuilder:webhook:path=/mutate-perms-infra-mgmt-io-v1-permsaccessrequest,mutating=true,failurePolicy=fail,sideEffects=None,groups=perms.infra-mgmt.io,resources=permsaccessrequests,verbs=create;update,versions=v1,name=mpermsaccessrequest.kb.io,admissionReviewVersions=v1
+
+//+kubebuilder:object:generate=false
+
+// AccessRequestDefaulter sets the requester of a new PermsAccessRequest and the approver of a
+// decision to the requesting user, they can not be set by the user
+type AccessRequestDefaulter struct {
+	decoder *admission.Decoder
+}
+
+var _ admission.DecoderInjector = &AccessRequestDefaulter{}
+
+// InjectDecoder implements admission.DecoderInjector
+func (d *AccessRequestDefaulter) InjectDecoder(decoder *admission.Decoder) error {
+	d.decoder = decoder
+	return nil
+}
+
+// Handle sets the requester and approver of the PermsAccessRequest
+func (d *AccessRequestDefaulter) Handle(ctx context.Context, req admission.Request) admission.Response {
+	ar := &PermsAccessRequest{}
+	if err := d.decoder.Decode(req, ar); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	switch req.Operation {
+	case admissionv1.Create:
+		ar.Spec.Requester = req.UserInfo.Username
+	case admissionv1.Update:
+		old := &PermsAccessRequest{}
+		if err := d.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		ar.Spec.Requester = old.Spec.Requester
+		switch {
+		case ar.Spec.Approval == nil:
+		case old.Spec.Approval == nil:
+			now := metav1.Now()
+			ar.Spec.Approval.Approver = req.UserInfo.Username
+			ar.Spec.Approval.Time = &now
+		case ar.Spec.Approval.Decision == old.Spec.Approval.Decision && ar.Spec.Approval.Comment == old.Spec.Approval.Comment:
+			// an apply of the manifest without approver and time keeps the decision
+			ar.Spec.Approval = old.Spec.Approval
+		}
+	}
+
+	marshaled, err := json.Marshal(ar)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
+
+//+kubebuilder:webhook:path=/validate-perms-infra-mgmt-io-v1-permsaccessrequest,mutating=false,failurePolicy=fail,sideEffects=None,groups=perms.infra-mgmt.io,resources=permsaccessrequests,verbs=create;update,versions=v1,name=vpermsaccessrequest.kb.io,admissionReviewVersions=v1
+
+//+kubebuilder:object:generate=false
+
+// AccessRequestValidator rejects invalid PermsAccessRequests and decisions of users who are not
+// authorised. The approver may not be the requester, needs the approve permission on
+// permsaccessrequests and, to approve, the permission to bind the role.
+type AccessRequestValidator struct {
+	Client  client.Client
+	decoder *admission.Decoder
+}
+
+var _ admission.DecoderInjector = &AccessRequestValidator{}
+
+// InjectDecoder implements admission.DecoderInjector
+func (v *AccessRequestValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+// Handle validates the PermsAccessRequest and a new decision
+func (v *AccessRequestValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	ar := &PermsAccessRequest{}
+	if err := v.decoder.Decode(req, ar); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	var old *PermsAccessRequest
+	if req.Operation == admissionv1.Update {
+		old = &PermsAccessRequest{}
+		if err := v.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+	}
+	permsaccessrequestlog.Info("validate "+strings.ToLower(string(req.Operation)), "name", ar.Name, "namespace", ar.Namespace)
+
+	if err := ar.validatePermsAccessRequest(old); err != nil {
+		status := err.(apierrors.APIStatus).Status()
+		return admission.Response{AdmissionResponse: admissionv1.AdmissionResponse{Allowed: false, Result: &status}}
+	}
+	if old == nil || old.Spec.Approval != nil || ar.Spec.Approval == nil {
+		return admission.Allowed("")
+	}
+
+	user := req.UserInfo
+	if user.Username == ar.Spec.Requester {
+		return forbiddenResponse(fmt.Sprintf("user %s may not decide on its own PermsAccessRequest", user.Username))
+	}
+	escalation := &EscalationValidator{Client: v.Client}
+	allowed, err := escalation.allowed(ctx, user, &authorizationv1.ResourceAttributes{
+		Namespace: ar.Namespace,
+		Verb:      "approve",
+		Group:     GroupVersion.Group,
+		Resource:  "permsaccessrequests",
+		Name:      ar.Name,
+	}, nil)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if !allowed {
+		return forbiddenResponse(fmt.Sprintf("user %s may not approve PermsAccessRequests in namespace %s", user.Username, ar.Namespace))
+	}
+	if ar.Spec.Approval.Decision == AccessRequestApproved {
+		// the approver grants the role, like the author of a PermsRoleBinding it must be allowed to bind it
+		reason, err := escalation.escalation(ctx, user, roleBinding{Namespace: ar.Namespace, Role: RoleReference{Kind: ar.Spec.Kind, Name: ar.Spec.Role}})
+		if err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+		if reason != "" {
+			return forbiddenResponse(reason)
+		}
+	}
+	permsaccessrequestlog.Info("decision", "name", ar.Name, "namespace", ar.Namespace, "decision", ar.Spec.Approval.Decision, "approver", user.Username, "requester", ar.Spec.Requester)
+	return admission.Allowed("")
+}
+
+// validatePermsAccessRequest validates the spec and, on update, rejects changes of the request
+// and of a decision
+func (r *PermsAccessRequest) validatePermsAccessRequest(old *PermsAccessRequest) error {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	if r.Spec.Kind != "Role" && r.Spec.Kind != "ClusterRole" {
+		allErrs = append(allErrs, field.NotSupported(specPath.Child("kind"), r.Spec.Kind, []string{"Role", "ClusterRole"}))
+	}
+	if r.Spec.Role == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("role"), "role must not be empty"))
+	}
+	if r.Spec.Duration.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("duration"), r.Spec.Duration.String(), "duration must be positive"))
+	}
+	if strings.TrimSpace(r.Spec.Justification) == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("justification"), "justification must not be empty"))
+	}
+	if r.Spec.Approval != nil && r.Spec.Approval.Decision != AccessRequestApproved && r.Spec.Approval.Decision != AccessRequestDenied {
+		allErrs = append(allErrs, field.NotSupported(specPath.Child("approval", "decision"), r.Spec.Approval.Decision, []string{AccessRequestApproved, AccessRequestDenied}))
+	}
+
+	if old == nil {
+		if r.Spec.Approval != nil {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("approval"), "a request can not be created with a decision"))
+		}
+	} else {
+		if r.Spec.Kind != old.Spec.Kind || r.Spec.Role != old.Spec.Role || r.Spec.Duration != old.Spec.Duration ||
+			r.Spec.Justification != old.Spec.Justification || r.Spec.Requester != old.Spec.Requester {
+			allErrs = append(allErrs, field.Forbidden(specPath, "only the approval of a request can be set"))
+		}
+		if old.Spec.Approval != nil && !equality.Semantic.DeepEqual(r.Spec.Approval, old.Spec.Approval) {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("approval"), "the decision on a request can not be changed"))
+		}
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: GroupVersion.Group, Kind: "PermsAccessRequest"},
+		r.Name, allErrs)
+}
+
+// forbiddenResponse denies a request with a message, admission.Denied only sets the reason and the
+// API server shows the message to the client
+func forbiddenResponse(message string) admission.Response {
+	return admission.Response{AdmissionResponse: admissionv1.AdmissionResponse{
+		Allowed: false,
+		Result: &metav1.Status{
+			Code:    http.StatusForbidden,
+			Reason:  metav1.StatusReasonForbidden,
+			Message: message,
+		},
+	}}
+}
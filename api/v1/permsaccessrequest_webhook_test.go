@@ -0,0 +1,164 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var _ = Describe("permsaccessrequest webhook", func() {
+	newDecoder := func() *admission.Decoder {
+		scheme := runtime.NewScheme()
+		Expect(AddToScheme(scheme)).To(Succeed())
+		decoder, err := admission.NewDecoder(scheme)
+		Expect(err).NotTo(HaveOccurred())
+		return decoder
+	}
+	newPermsAccessRequest := func() *PermsAccessRequest {
+		return &PermsAccessRequest{
+			ObjectMeta: metav1.ObjectMeta{Name: "incident", Namespace: "testing"},
+			Spec: PermsAccessRequestSpec{
+				Kind:          "ClusterRole",
+				Role:          "edit",
+				Duration:      metav1.Duration{Duration: 2 * time.Hour},
+				Justification: "INC-1234",
+				Requester:     "alice",
+			},
+		}
+	}
+	requestFor := func(operation admissionv1.Operation, username string, ar *PermsAccessRequest, old *PermsAccessRequest) admission.Request {
+		req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: operation,
+			Kind:      metav1.GroupVersionKind{Group: GroupVersion.Group, Version: GroupVersion.Version, Kind: "PermsAccessRequest"},
+			Name:      ar.Name,
+			Namespace: ar.Namespace,
+			UserInfo:  authenticationv1.UserInfo{Username: username},
+		}}
+		raw, err := json.Marshal(ar)
+		Expect(err).NotTo(HaveOccurred())
+		req.Object = runtime.RawExtension{Raw: raw}
+		if old != nil {
+			raw, err = json.Marshal(old)
+			Expect(err).NotTo(HaveOccurred())
+			req.OldObject = runtime.RawExtension{Raw: raw}
+		}
+		return req
+	}
+	// mutate returns the values of the patches of the defaulting webhook by path
+	mutate := func(req admission.Request) map[string]interface{} {
+		d := &AccessRequestDefaulter{}
+		Expect(d.InjectDecoder(newDecoder())).To(Succeed())
+		response := d.Handle(context.Background(), req)
+		Expect(response.Allowed).To(BeTrue())
+		patches := map[string]interface{}{}
+		for _, patch := range response.Patches {
+			patches[patch.Path] = patch.Value
+		}
+		return patches
+	}
+	newValidator := func(permissions map[string]bool) *AccessRequestValidator {
+		scheme := runtime.NewScheme()
+		Expect(AddToScheme(scheme)).To(Succeed())
+		Expect(rbacv1.AddToScheme(scheme)).To(Succeed())
+		v := &AccessRequestValidator{Client: &sarClient{
+			Client:      fake.NewClientBuilder().WithScheme(scheme).Build(),
+			permissions: permissions,
+		}}
+		Expect(v.InjectDecoder(newDecoder())).To(Succeed())
+		return v
+	}
+	approvers := map[string]bool{
+		"testing:approve permsaccessrequests.perms.infra-mgmt.io incident": true,
+		"testing:bind clusterroles.rbac.authorization.k8s.io edit":         true,
+	}
+
+	Context("recording the requester and approver", func() {
+
+		It("should set the requester of a new request", func() {
+			ar := newPermsAccessRequest()
+			ar.Spec.Requester = "mallory"
+			Expect(mutate(requestFor(admissionv1.Create, "alice", ar, nil))).To(HaveKeyWithValue("/spec/requester", "alice"))
+		})
+
+		It("should set the approver of a decision and keep the requester", func() {
+			old := newPermsAccessRequest()
+			ar := newPermsAccessRequest()
+			ar.Spec.Requester = "bob"
+			ar.Spec.Approval = &AccessApproval{Decision: AccessRequestApproved, Approver: "alice"}
+			patches := mutate(requestFor(admissionv1.Update, "bob", ar, old))
+			Expect(patches).To(HaveKeyWithValue("/spec/requester", "alice"))
+			Expect(patches).To(HaveKeyWithValue("/spec/approval/approver", "bob"))
+			Expect(patches).To(HaveKey("/spec/approval/time"))
+		})
+	})
+
+	Context("validating a request", func() {
+
+		It("should reject a request without justification and with a decision", func() {
+			ar := newPermsAccessRequest()
+			ar.Spec.Justification = " "
+			ar.Spec.Approval = &AccessApproval{Decision: AccessRequestApproved}
+			response := newValidator(nil).Handle(context.Background(), requestFor(admissionv1.Create, "alice", ar, nil))
+			Expect(response.Allowed).To(BeFalse())
+			Expect(response.Result.Message).To(ContainSubstring("spec.justification: Required value"))
+			Expect(response.Result.Message).To(ContainSubstring("spec.approval: Forbidden"))
+		})
+
+		It("should allow the approval by an authorised user", func() {
+			ar := newPermsAccessRequest()
+			ar.Spec.Approval = &AccessApproval{Decision: AccessRequestApproved, Approver: "bob"}
+			response := newValidator(approvers).Handle(context.Background(), requestFor(admissionv1.Update, "bob", ar, newPermsAccessRequest()))
+			Expect(response.Allowed).To(BeTrue())
+		})
+
+		It("should reject the approval by the requester", func() {
+			ar := newPermsAccessRequest()
+			ar.Spec.Approval = &AccessApproval{Decision: AccessRequestApproved, Approver: "alice"}
+			response := newValidator(approvers).Handle(context.Background(), requestFor(admissionv1.Update, "alice", ar, newPermsAccessRequest()))
+			Expect(response.Allowed).To(BeFalse())
+			Expect(response.Result.Message).To(Equal("user alice may not decide on its own PermsAccessRequest"))
+		})
+
+		It("should reject the approval by a user without the approve permission", func() {
+			ar := newPermsAccessRequest()
+			ar.Spec.Approval = &AccessApproval{Decision: AccessRequestApproved, Approver: "bob"}
+			response := newValidator(nil).Handle(context.Background(), requestFor(admissionv1.Update, "bob", ar, newPermsAccessRequest()))
+			Expect(response.Allowed).To(BeFalse())
+			Expect(response.Result.Message).To(Equal("user bob may not approve PermsAccessRequests in namespace testing"))
+		})
+
+		It("should reject the approval of a role the approver may not bind, but allow to deny it", func() {
+			permissions := map[string]bool{"testing:approve permsaccessrequests.perms.infra-mgmt.io incident": true}
+			ar := newPermsAccessRequest()
+			ar.Spec.Approval = &AccessApproval{Decision: AccessRequestApproved, Approver: "bob"}
+			response := newValidator(permissions).Handle(context.Background(), requestFor(admissionv1.Update, "bob", ar, newPermsAccessRequest()))
+			Expect(response.Allowed).To(BeFalse())
+			Expect(response.Result.Message).To(ContainSubstring("user bob may not bind ClusterRole edit in namespace testing"))
+
+			ar.Spec.Approval.Decision = AccessRequestDenied
+			Expect(newValidator(permissions).Handle(context.Background(), requestFor(admissionv1.Update, "bob", ar, newPermsAccessRequest())).Allowed).To(BeTrue())
+		})
+
+		It("should reject changes of the request and of a decision", func() {
+			old := newPermsAccessRequest()
+			old.Spec.Approval = &AccessApproval{Decision: AccessRequestDenied, Approver: "bob"}
+			ar := old.DeepCopy()
+			ar.Spec.Duration = metav1.Duration{Duration: 8 * time.Hour}
+			ar.Spec.Approval.Decision = AccessRequestApproved
+			response := newValidator(approvers).Handle(context.Background(), requestFor(admissionv1.Update, "bob", ar, old))
+			Expect(response.Allowed).To(BeFalse())
+			Expect(response.Result.Message).To(ContainSubstring("only the approval of a request can be set"))
+			Expect(response.Result.Message).To(ContainSubstring("the decision on a request can not be changed"))
+		})
+	})
+})
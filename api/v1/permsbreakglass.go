@@ -0,0 +1,54 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+)
+
+// BreakGlassAnnotation activates a PermsBreakGlass, the value is the reason of the activation
+const BreakGlassAnnotation = "perms.infra-mgmt.io/break-glass"
+
+// BreakGlassActivatedByAnnotation records the user who activated a PermsBreakGlass, set by the webhook
+const BreakGlassActivatedByAnnotation = "perms.infra-mgmt.io/break-glass-activated-by"
+
+// MaxBreakGlassDuration is the longest time a PermsBreakGlass can be active
+const MaxBreakGlassDuration = 24 * time.Hour
+
+// Reason returns the reason of the activation, empty if the break-glass is not activated
+func (r *PermsBreakGlass) Reason() string {
+	return r.Annotations[BreakGlassAnnotation]
+}
+
+// MayActivate returns true if the user or one of its groups is an activator of the break-glass
+func (r *PermsBreakGlass) MayActivate(user authenticationv1.UserInfo) bool {
+	for _, activator := range r.Spec.Activators.Users {
+		if activator == user.Username {
+			return true
+		}
+	}
+	for _, activator := range r.Spec.Activators.Groups {
+		for _, group := range user.Groups {
+			if activator == group {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PermsBreakGlassSpec defines the emergency access which is bound while the break-glass is active
+type PermsBreakGlassSpec struct {
+	// Role is the name of the ClusterRole which is bound cluster-wide while the break-glass is active.
+	Role string `json:"role"`
+
+	// Users get the role assigned while the break-glass is active.
+	//+optional
+	Users []string `json:"users,omitempty"`
+
+	// Groups get the role assigned while the break-glass is active.
+	//+optional
+	Groups []string `json:"groups,omitempty"`
+
+	// Activators are the users and groups which may activate the break-glass.
+	Activators BreakGlassActivators `json:"activators"`
+
+	// MaxDuration is the time after which an activation ends, at most 24h. Defaults to 1h.
+	//+kubebuilder:default="1h"
+	//+optional
+	MaxDuration metav1.Duration `json:"maxDuration,omitempty"`
+}
+
+// BreakGlassActivators are the users and groups which may activate a PermsBreakGlass
+type BreakGlassActivators struct {
+	//+optional
+	Users []string `json:"users,omitempty"`
+
+	//+optional
+	Groups []string `json:"groups,omitempty"`
+}
+
+// PermsBreakGlassStatus defines the observed state of PermsBreakGlass
+type PermsBreakGlassStatus struct {
+	// Active is true while the ClusterRoleBinding exists.
+	//+optional
+	Active bool `json:"active,omitempty"`
+
+	// ActivatedBy is the user who activated the break-glass.
+	//+optional
+	ActivatedBy string `json:"activatedBy,omitempty"`
+
+	// Reason given for the activation.
+	//+optional
+	Reason string `json:"reason,omitempty"`
+
+	// ActivatedAt is the time of the last activation.
+	//+optional
+	ActivatedAt *metav1.Time `json:"activatedAt,omitempty"`
+
+	// ExpiresAt is the time the active break-glass gets deactivated.
+	//+optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// DeactivatedAt is the time of the last deactivation.
+	//+optional
+	DeactivatedAt *metav1.Time `json:"deactivatedAt,omitempty"`
+
+	// Activations counts the activations of the break-glass.
+	//+optional
+	Activations int32 `json:"activations,omitempty"`
+
+	// ClusterRoleBinding is the name of the ClusterRoleBinding which binds the role.
+	//+optional
+	ClusterRoleBinding string `json:"clusterRoleBinding,omitempty"`
+
+	// ObservedGeneration is the generation the status was computed for.
+	//+optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	//+optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster,shortName=pbg
+//+kubebuilder:printcolumn:name=Role,type=string,JSONPath=".spec.role"
+//+kubebuilder:printcolumn:name=Active,type=boolean,JSONPath=".status.active"
+//+kubebuilder:printcolumn:name=Activated By,type=string,JSONPath=".status.activatedBy"
+//+kubebuilder:printcolumn:name=Expires,type=date,JSONPath=".status.expiresAt"
+//+kubebuilder:printcolumn:name=Age,type=date,JSONPath=".metadata.creationTimestamp"
+
+// PermsBreakGlass is the Schema for the permsbreakglasses API. It is inert until one of its
+// activators sets the break-glass annotation, then its role is bound cluster-wide for at most
+// spec.maxDuration.
+type PermsBreakGlass struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PermsBreakGlassSpec   `json:"spec,omitempty"`
+	Status PermsBreakGlassStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PermsBreakGlassList contains a list of PermsBreakGlass
+type PermsBreakGlassList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PermsBreakGlass `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PermsBreakGlass{}, &PermsBreakGlassList{})
+}
@@ -0,0 +1,181 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// log is for logging in this package.
+var permsbreakglasslog = logf.Log.WithName("permsbreakglass-resource")
+
+// breakGlassMutatingWebhookPath is the path of the webhook which checks and records the activator
+const breakGlassMutatingWebhookPath = "/mutate-perms-infra-mgmt-io-v1-permsbreakglass"
+
+// SetupWebhookWithManager registers the validating webhook and the webhook which records the
+// activator, it needs the requesting user and is therefore no Defaulter
+func (r *PermsBreakGlass) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(breakGlassMutatingWebhookPath, &webhook.Admission{Handler: &BreakGlassActivator{}})
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-perms-infra-mgmt-io-v1-permsbreakglass,mutating=true,failurePolicy=fail,sideEffects=None,groups=perms.infra-mgmt.io,resources=permsbreakglasses,verbs=create;update,versions=v1,name=mpermsbreakglass.kb.io,admissionReviewVersions=v1
+
+//+kubebuilder:object:generate=false
+
+// BreakGlassActivator rejects activations of a PermsBreakGlass by users who are no activators and
+// activations on creation, and records the activator, the annotation can not be set by the user
+type BreakGlassActivator struct {
+	decoder *admission.Decoder
+}
+
+var _ admission.DecoderInjector = &BreakGlassActivator{}
+
+// InjectDecoder implements admission.DecoderInjector
+func (a *BreakGlassActivator) InjectDecoder(decoder *admission.Decoder) error {
+	a.decoder = decoder
+	return nil
+}
+
+// Handle checks a new activation and sets the activated-by annotation
+func (a *BreakGlassActivator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	bg := &PermsBreakGlass{}
+	if err := a.decoder.Decode(req, bg); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	old := &PermsBreakGlass{}
+	if req.Operation == admissionv1.Update {
+		if err := a.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+	}
+
+	activatedBy, activated := old.Annotations[BreakGlassActivatedByAnnotation], old.Annotations[BreakGlassActivatedByAnnotation] != ""
+	reason, ok := bg.Annotations[BreakGlassAnnotation]
+	if oldReason, oldOk := old.Annotations[BreakGlassAnnotation]; reason != oldReason || ok != oldOk {
+		activatedBy, activated = "", false
+		if ok {
+			// the activators of the old spec decide, an activation can not add its user. A new
+			// PermsBreakGlass has no activators yet, its author would choose them.
+			if req.Operation != admissionv1.Update {
+				return forbiddenResponse(fmt.Sprintf("PermsBreakGlass %s can not be activated on creation, create it first", bg.Name))
+			}
+			if !old.MayActivate(req.UserInfo) {
+				return forbiddenResponse(fmt.Sprintf("user %s may not activate PermsBreakGlass %s", req.UserInfo.Username, bg.Name))
+			}
+			activatedBy, activated = req.UserInfo.Username, true
+			permsbreakglasslog.Info("activate", "name", bg.Name, "user", req.UserInfo.Username, "reason", reason)
+		}
+	}
+	if activated {
+		if bg.Annotations == nil {
+			bg.Annotations = map[string]string{}
+		}
+		bg.Annotations[BreakGlassActivatedByAnnotation] = activatedBy
+	} else {
+		delete(bg.Annotations, BreakGlassActivatedByAnnotation)
+	}
+
+	marshaled, err := json.Marshal(bg)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
+
+//+kubebuilder:webhook:path=/validate-perms-infra-mgmt-io-v1-permsbreakglass,mutating=false,failurePolicy=fail,sideEffects=None,groups=perms.infra-mgmt.io,resources=permsbreakglasses,verbs=create;update,versions=v1,name=vpermsbreakglass.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &PermsBreakGlass{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *PermsBreakGlass) ValidateCreate() error {
+	permsbreakglasslog.Info("validate create", "name", r.Name)
+
+	return r.validatePermsBreakGlass()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *PermsBreakGlass) ValidateUpdate(old runtime.Object) error {
+	permsbreakglasslog.Info("validate update", "name", r.Name)
+
+	if oldBreakGlass, ok := old.(*PermsBreakGlass); ok && oldBreakGlass.Status.Active && !equality.Semantic.DeepEqual(oldBreakGlass.Spec, r.Spec) {
+		return apierrors.NewForbidden(schema.GroupResource{Group: GroupVersion.Group, Resource: "permsbreakglasses"},
+			r.Name, fmt.Errorf("the spec can not be changed while the break-glass is active"))
+	}
+	return r.validatePermsBreakGlass()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *PermsBreakGlass) ValidateDelete() error {
+	permsbreakglasslog.Info("validate delete", "name", r.Name)
+
+	// deleting a PermsBreakGlass is always allowed, its ClusterRoleBinding is garbage collected
+	return nil
+}
+
+// validatePermsBreakGlass rejects a break-glass without subjects or activators, a maximum duration
+// above MaxBreakGlassDuration and an activation without reason
+func (r *PermsBreakGlass) validatePermsBreakGlass() error {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	if r.Spec.Role == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("role"), "role is required"))
+	}
+	if len(r.Spec.Users) == 0 && len(r.Spec.Groups) == 0 {
+		allErrs = append(allErrs, field.Required(specPath, "users or groups are required"))
+	}
+	allErrs = append(allErrs, validateNames(specPath.Child("users"), r.Spec.Users)...)
+	allErrs = append(allErrs, validateNames(specPath.Child("groups"), r.Spec.Groups)...)
+	if len(r.Spec.Activators.Users) == 0 && len(r.Spec.Activators.Groups) == 0 {
+		allErrs = append(allErrs, field.Required(specPath.Child("activators"), "users or groups are required"))
+	}
+	allErrs = append(allErrs, validateNames(specPath.Child("activators", "users"), r.Spec.Activators.Users)...)
+	allErrs = append(allErrs, validateNames(specPath.Child("activators", "groups"), r.Spec.Activators.Groups)...)
+	if d := r.Spec.MaxDuration.Duration; d <= 0 || d > MaxBreakGlassDuration {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("maxDuration"), r.Spec.MaxDuration.String(),
+			fmt.Sprintf("must be positive and at most %s", MaxBreakGlassDuration)))
+	}
+	if reason, ok := r.Annotations[BreakGlassAnnotation]; ok && strings.TrimSpace(reason) == "" {
+		allErrs = append(allErrs, field.Required(field.NewPath("metadata", "annotations").Key(BreakGlassAnnotation),
+			"the reason of the activation is required"))
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: GroupVersion.Group, Kind: "PermsBreakGlass"},
+		r.Name, allErrs)
+}
@@ -0,0 +1,131 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var _ = Describe("permsbreakglass webhook", func() {
+	newPermsBreakGlass := func(annotations map[string]string) *PermsBreakGlass {
+		return &PermsBreakGlass{
+			ObjectMeta: metav1.ObjectMeta{Name: "emergency", Annotations: annotations},
+			Spec: PermsBreakGlassSpec{
+				Role:        "cluster-admin",
+				Groups:      []string{"oncall"},
+				Activators:  BreakGlassActivators{Users: []string{"alice"}, Groups: []string{"oncall"}},
+				MaxDuration: metav1.Duration{Duration: time.Hour},
+			},
+		}
+	}
+
+	Context("recording the activator", func() {
+		// activate returns the response and the patches of the activating webhook by path, a
+		// request without old object creates the PermsBreakGlass
+		activate := func(user authenticationv1.UserInfo, bg *PermsBreakGlass, old *PermsBreakGlass) (admission.Response, map[string]interface{}) {
+			scheme := runtime.NewScheme()
+			Expect(AddToScheme(scheme)).To(Succeed())
+			decoder, err := admission.NewDecoder(scheme)
+			Expect(err).NotTo(HaveOccurred())
+			a := &BreakGlassActivator{}
+			Expect(a.InjectDecoder(decoder)).To(Succeed())
+
+			req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Update,
+				Kind:      metav1.GroupVersionKind{Group: GroupVersion.Group, Version: GroupVersion.Version, Kind: "PermsBreakGlass"},
+				Name:      bg.Name,
+				UserInfo:  user,
+			}}
+			raw, err := json.Marshal(bg)
+			Expect(err).NotTo(HaveOccurred())
+			req.Object = runtime.RawExtension{Raw: raw}
+			if old == nil {
+				req.Operation = admissionv1.Create
+			} else {
+				raw, err = json.Marshal(old)
+				Expect(err).NotTo(HaveOccurred())
+				req.OldObject = runtime.RawExtension{Raw: raw}
+			}
+
+			response := a.Handle(context.Background(), req)
+			patches := map[string]interface{}{}
+			for _, patch := range response.Patches {
+				patches[unescapePointer(patch.Path)] = patch.Value
+			}
+			return response, patches
+		}
+
+		It("should record an activation by a member of an activator group", func() {
+			bg := newPermsBreakGlass(map[string]string{BreakGlassAnnotation: "INC-1234", BreakGlassActivatedByAnnotation: "mallory"})
+			response, patches := activate(authenticationv1.UserInfo{Username: "bob", Groups: []string{"oncall"}}, bg, newPermsBreakGlass(nil))
+			Expect(response.Allowed).To(BeTrue())
+			Expect(patches).To(HaveKeyWithValue(BreakGlassActivatedByAnnotation, "bob"))
+		})
+
+		It("should reject an activation by other users", func() {
+			bg := newPermsBreakGlass(map[string]string{BreakGlassAnnotation: "INC-1234"})
+			response, _ := activate(authenticationv1.UserInfo{Username: "mallory"}, bg, newPermsBreakGlass(nil))
+			Expect(response.Allowed).To(BeFalse())
+			Expect(response.Result.Message).To(Equal("user mallory may not activate PermsBreakGlass emergency"))
+		})
+
+		It("should reject an activation on creation", func() {
+			bg := newPermsBreakGlass(map[string]string{BreakGlassAnnotation: "INC-1234"})
+			bg.Spec.Activators = BreakGlassActivators{Users: []string{"mallory"}}
+			response, _ := activate(authenticationv1.UserInfo{Username: "mallory"}, bg, nil)
+			Expect(response.Allowed).To(BeFalse())
+			Expect(response.Result.Message).To(Equal("PermsBreakGlass emergency can not be activated on creation, create it first"))
+
+			response, _ = activate(authenticationv1.UserInfo{Username: "mallory"}, newPermsBreakGlass(nil), nil)
+			Expect(response.Allowed).To(BeTrue())
+		})
+
+		It("should keep the activator of an unchanged activation", func() {
+			old := newPermsBreakGlass(map[string]string{BreakGlassAnnotation: "INC-1234", BreakGlassActivatedByAnnotation: "alice"})
+			bg := newPermsBreakGlass(map[string]string{BreakGlassAnnotation: "INC-1234", BreakGlassActivatedByAnnotation: "mallory"})
+			response, patches := activate(authenticationv1.UserInfo{Username: "mallory"}, bg, old)
+			Expect(response.Allowed).To(BeTrue())
+			Expect(patches).To(HaveKeyWithValue(BreakGlassActivatedByAnnotation, "alice"))
+		})
+	})
+
+	Context("validating a PermsBreakGlass", func() {
+
+		It("should accept a valid break-glass", func() {
+			Expect(newPermsBreakGlass(nil).ValidateCreate()).To(Succeed())
+		})
+
+		It("should reject a break-glass without subjects and activators and a too long duration", func() {
+			bg := newPermsBreakGlass(map[string]string{BreakGlassAnnotation: " "})
+			bg.Spec.Groups = nil
+			bg.Spec.Activators = BreakGlassActivators{}
+			bg.Spec.MaxDuration = metav1.Duration{Duration: 48 * time.Hour}
+			err := bg.ValidateCreate()
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec: Required value"))
+			Expect(err.Error()).To(ContainSubstring("spec.activators: Required value"))
+			Expect(err.Error()).To(ContainSubstring("spec.maxDuration: Invalid value"))
+			Expect(err.Error()).To(ContainSubstring("the reason of the activation is required"))
+		})
+
+		It("should reject changes of the spec while the break-glass is active", func() {
+			old := newPermsBreakGlass(nil)
+			old.Status.Active = true
+			bg := newPermsBreakGlass(nil)
+			bg.Spec.Users = []string{"mallory"}
+			Expect(apierrors.IsForbidden(bg.ValidateUpdate(old))).To(BeTrue())
+
+			old.Status.Active = false
+			Expect(bg.ValidateUpdate(old)).To(Succeed())
+		})
+	})
+})
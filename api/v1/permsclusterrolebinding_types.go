@@ -0,0 +1,174 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PermsClusterRoleBindingSpec defines the desired state of PermsClusterRoleBinding
+type PermsClusterRoleBindingSpec struct {
+	// Role is the name of the referenced ClusterRole.
+	Role string `json:"role"`
+
+	// Groups get the referenced ClusterRole assigned.
+	//+optional
+	Groups []string `json:"groups,omitempty"`
+
+	// Users get the referenced ClusterRole assigned.
+	//+optional
+	Users []string `json:"users,omitempty"`
+
+	// Serviceaccounts get the referenced ClusterRole assigned.
+	//+optional
+	Serviceaccounts []Serviceaccount `json:"serviceaccounts,omitempty"`
+
+	// ImmutableRoleRef disables the role switch. A changed role is not applied and the
+	// resource is set to Degraded instead.
+	//+optional
+	ImmutableRoleRef bool `json:"immutableRoleRef,omitempty"`
+
+	// AdoptionPolicy defines how existing bindings which are not managed by the operator are
+	// handled. Never sets the Conflict condition and leaves them untouched, Adopt takes them
+	// over. Defaults to Never.
+	//+kubebuilder:validation:Enum=Never;Adopt
+	//+optional
+	AdoptionPolicy string `json:"adoptionPolicy,omitempty"`
+
+	// PinRoleRules holds the reconcile when the rules of a referenced role change until the
+	// change is acknowledged with the perms.infra-mgmt.io/role-rules-ack annotation.
+	//+optional
+	PinRoleRules bool `json:"pinRoleRules,omitempty"`
+
+	// NamespaceSelector selects the namespaces in which the ClusterRole gets bound by a
+	// RoleBinding. If it or namespaces is set, no ClusterRoleBinding is created.
+	//+optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// Namespaces in which the ClusterRole gets bound by a RoleBinding, in addition to
+	// the namespaces selected by namespaceSelector.
+	//+optional
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	Validity `json:",inline"`
+}
+
+// PermsClusterRoleBindingStatus defines the observed state of PermsClusterRoleBinding
+type PermsClusterRoleBindingStatus struct {
+	// Bindings are the ClusterRoleBinding or, with namespaceSelector or namespaces, the
+	// RoleBindings managed for this PermsClusterRoleBinding.
+	//+optional
+	Bindings []BindingReference `json:"bindings,omitempty"`
+
+	// Count is the number of subjects per subject kind.
+	//+optional
+	Count SubjectCount `json:"count,omitempty"`
+
+	// Expirations are the subjects with an expiry which have the ClusterRole assigned, the
+	// next expiration first.
+	//+optional
+	Expirations []SubjectExpiration `json:"expirations,omitempty"`
+
+	// NextTransitionTime is the next time the assigned subjects change.
+	//+optional
+	NextTransitionTime *metav1.Time `json:"nextTransitionTime,omitempty"`
+
+	// DriftCount is the number of times a managed binding was changed outside of the
+	// operator and restored.
+	//+optional
+	DriftCount int32 `json:"driftCount,omitempty"`
+
+	// LastDriftTime is the time the last change outside of the operator was restored.
+	//+optional
+	LastDriftTime *metav1.Time `json:"lastDriftTime,omitempty"`
+
+	// LastChange summarizes the subjects added and removed by the last change of the bindings.
+	//+optional
+	LastChange *SubjectChange `json:"lastChange,omitempty"`
+
+	// RoleRules summarizes the rules of the referenced roles, with spec.pinRoleRules the rules
+	// which were last acknowledged.
+	//+optional
+	RoleRules []RoleRulesSummary `json:"roleRules,omitempty"`
+
+	// RoleRulesHash is the hash of the current rules of all referenced roles, the value of the
+	// perms.infra-mgmt.io/role-rules-ack annotation which acknowledges a change.
+	//+optional
+	RoleRulesHash string `json:"roleRulesHash,omitempty"`
+
+	// PolicyViolations are the violations of the PermsPolicies, one per denied role or subject
+	// and violated rule.
+	//+optional
+	PolicyViolations []string `json:"policyViolations,omitempty"`
+
+	// ApprovedSpec is the last spec of a sensitive PermsClusterRoleBinding approved by a second
+	// user, it is applied while a change waits for the approval.
+	//+optional
+	ApprovedSpec *PermsClusterRoleBindingSpec `json:"approvedSpec,omitempty"`
+
+	// ApprovedGeneration is the generation of the approved spec.
+	//+optional
+	ApprovedGeneration int64 `json:"approvedGeneration,omitempty"`
+
+	// PendingChange is the change of the spec which waits for the approval.
+	//+optional
+	PendingChange *PendingChange `json:"pendingChange,omitempty"`
+
+	// Lockdown records the subjects removed by an active PermsLockdown.
+	//+optional
+	Lockdown *LockdownSnapshot `json:"lockdown,omitempty"`
+
+	// ObservedGeneration is the generation the status was computed for.
+	//+optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	//+optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:storageversion
+//+kubebuilder:resource:shortName=permscrb;pcrb,scope=Cluster
+//+kubebuilder:printcolumn:name=Users,type=integer,JSONPath=".status.count.users"
+//+kubebuilder:printcolumn:name=Groups,type=integer,JSONPath=".status.count.groups"
+//+kubebuilder:printcolumn:name=Serviceaccounts,type=integer,JSONPath=".status.count.serviceaccounts"
+//+kubebuilder:printcolumn:name=Available,type=string,JSONPath=".status.conditions[?(@.type==\"Available\")].status"
+//+kubebuilder:printcolumn:name=Progressing,type=string,JSONPath=".status.conditions[?(@.type==\"Progressing\")].status"
+//+kubebuilder:printcolumn:name=Degraded,type=string,JSONPath=".status.conditions[?(@.type==\"Degraded\")].status"
+
+// PermsClusterRoleBinding is the Schema for the permsclusterrolebindings API
+type PermsClusterRoleBinding struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PermsClusterRoleBindingSpec   `json:"spec,omitempty"`
+	Status PermsClusterRoleBindingStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PermsClusterRoleBindingList contains a list of PermsClusterRoleBinding
+type PermsClusterRoleBindingList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PermsClusterRoleBinding `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PermsClusterRoleBinding{}, &PermsClusterRoleBindingList{})
+}
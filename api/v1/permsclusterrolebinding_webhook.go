@@ -0,0 +1,90 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var permsclusterrolebindinglog = logf.Log.WithName("permsclusterrolebinding-resource")
+
+func (r *PermsClusterRoleBinding) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-perms-infra-mgmt-io-v1-permsclusterrolebinding,mutating=false,failurePolicy=fail,sideEffects=None,groups=perms.infra-mgmt.io,resources=permsclusterrolebindings,verbs=create;update,versions=v1,name=vpermsclusterrolebinding.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &PermsClusterRoleBinding{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *PermsClusterRoleBinding) ValidateCreate() error {
+	permsclusterrolebindinglog.Info("validate create", "name", r.Name)
+
+	return r.validatePermsClusterRoleBinding(nil)
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *PermsClusterRoleBinding) ValidateUpdate(old runtime.Object) error {
+	permsclusterrolebindinglog.Info("validate update", "name", r.Name)
+
+	return r.validatePermsClusterRoleBinding(old.(*PermsClusterRoleBinding))
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *PermsClusterRoleBinding) ValidateDelete() error {
+	permsclusterrolebindinglog.Info("validate delete", "name", r.Name)
+
+	// deleting a PermsClusterRoleBinding is always allowed
+	return nil
+}
+
+// validatePermsClusterRoleBinding validates the spec and, on update, the changes to the old object
+func (r *PermsClusterRoleBinding) validatePermsClusterRoleBinding(old *PermsClusterRoleBinding) error {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	if r.Spec.Role == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("role"), "role must not be empty"))
+	}
+	allErrs = append(allErrs, validateSubjects(specPath, r.Spec.Groups, r.Spec.Users, r.Spec.Serviceaccounts)...)
+	if r.Spec.NamespaceSelector != nil {
+		allErrs = append(allErrs, metav1validation.ValidateLabelSelector(r.Spec.NamespaceSelector, specPath.Child("namespaceSelector"))...)
+	}
+	allErrs = append(allErrs, validateNames(specPath.Child("namespaces"), r.Spec.Namespaces)...)
+	allErrs = append(allErrs, validateValidity(specPath, r.Spec.Validity, r.Spec.Groups, r.Spec.Users)...)
+
+	if old != nil && old.Spec.ImmutableRoleRef && r.Spec.Role != old.Spec.Role {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("role"), "role is immutable while spec.immutableRoleRef is set"))
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: GroupVersion.Group, Kind: "PermsClusterRoleBinding"},
+		r.Name, allErrs)
+}
@@ -0,0 +1,62 @@
+package v1
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("permsclusterrolebinding webhook", func() {
+	newPermsClusterRoleBinding := func() *PermsClusterRoleBinding {
+		return &PermsClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "demo"},
+			Spec: PermsClusterRoleBindingSpec{
+				Role:   "view",
+				Groups: []string{"group1"},
+				Users:  []string{"user1", "user2"},
+				Serviceaccounts: []Serviceaccount{
+					{Name: "default", Namespace: "testing"},
+				},
+			},
+		}
+	}
+
+	It("should accept a valid PermsClusterRoleBinding", func() {
+		Expect(newPermsClusterRoleBinding().ValidateCreate()).To(Succeed())
+	})
+
+	It("should reject duplicate users", func() {
+		pcrb := newPermsClusterRoleBinding()
+		pcrb.Spec.Users = append(pcrb.Spec.Users, "user2")
+		err := pcrb.ValidateCreate()
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.users[2]"))
+	})
+
+	It("should reject an invalid namespace selector", func() {
+		pcrb := newPermsClusterRoleBinding()
+		pcrb.Spec.NamespaceSelector = &metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "team", Operator: "Like"}},
+		}
+		err := pcrb.ValidateCreate()
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.namespaceSelector"))
+	})
+
+	It("should reject a role switch with an immutable role", func() {
+		old := newPermsClusterRoleBinding()
+		old.Spec.ImmutableRoleRef = true
+		pcrb := old.DeepCopy()
+		pcrb.Spec.Role = "edit"
+		err := pcrb.ValidateUpdate(old)
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("spec.role"))
+	})
+	It("should leave a role switch which sets the immutable role to the controller", func() {
+		pcrb := newPermsClusterRoleBinding()
+		pcrb.Spec.Role = "edit"
+		pcrb.Spec.ImmutableRoleRef = true
+		Expect(pcrb.ValidateUpdate(newPermsClusterRoleBinding())).To(Succeed())
+	})
+})
@@ -0,0 +1,166 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//+kubebuilder:object:generate=false
+
+// RoleAssignment is a role a user or group holds through a PermsRoleBinding or
+// PermsClusterRoleBinding
+type RoleAssignment struct {
+	// Kind of the subject, User or Group.
+	Kind string
+	Name string
+	Role RoleReference
+	// Source is the resource which assigns the role, as PermsRoleBinding/namespace/name or
+	// PermsClusterRoleBinding/name.
+	Source string
+}
+
+// ConstraintSource returns the PermsRoleBinding as source of role assignments
+func (r *PermsRoleBinding) ConstraintSource() string {
+	return "PermsRoleBinding/" + r.Namespace + "/" + r.Name
+}
+
+// RoleAssignments returns the roles the PermsRoleBinding assigns to the users and groups
+func (r *PermsRoleBinding) RoleAssignments(users []string, groups []string) []RoleAssignment {
+	roles := []RoleReference{{Kind: r.Spec.Kind, Name: r.Spec.Role}}
+	for _, role := range r.Spec.Roles {
+		roles = append(roles, RoleReference{Kind: role.Kind, Name: role.Name})
+	}
+	return roleAssignments(r.ConstraintSource(), roles, users, groups)
+}
+
+// ConstraintSource returns the PermsClusterRoleBinding as source of role assignments
+func (r *PermsClusterRoleBinding) ConstraintSource() string {
+	return "PermsClusterRoleBinding/" + r.Name
+}
+
+// RoleAssignments returns the roles the PermsClusterRoleBinding assigns to the users and groups
+func (r *PermsClusterRoleBinding) RoleAssignments(users []string, groups []string) []RoleAssignment {
+	return roleAssignments(r.ConstraintSource(), []RoleReference{{Kind: "ClusterRole", Name: r.Spec.Role}}, users, groups)
+}
+
+// roleAssignments returns an assignment of each role to each user and group
+func roleAssignments(source string, roles []RoleReference, users []string, groups []string) []RoleAssignment {
+	var assignments []RoleAssignment
+	for _, role := range roles {
+		for _, user := range users {
+			assignments = append(assignments, RoleAssignment{Kind: "User", Name: user, Role: role, Source: source})
+		}
+		for _, group := range groups {
+			assignments = append(assignments, RoleAssignment{Kind: "Group", Name: group, Role: role, Source: source})
+		}
+	}
+	return assignments
+}
+
+// Violations returns the users and groups which hold more than one role of a set, ordered by set,
+// kind and name
+func (c *PermsConstraint) Violations(assignments []RoleAssignment) []ConstraintViolation {
+	var violations []ConstraintViolation
+	for _, set := range c.Spec.ExclusiveRoles {
+		exclusive := map[RoleReference]bool{}
+		for _, role := range set.Roles {
+			exclusive[role] = true
+		}
+		roles := map[string]map[string]bool{}
+		sources := map[string]map[string]bool{}
+		for _, a := range assignments {
+			if !exclusive[a.Role] {
+				continue
+			}
+			subject := a.Kind + "/" + a.Name
+			if roles[subject] == nil {
+				roles[subject] = map[string]bool{}
+				sources[subject] = map[string]bool{}
+			}
+			roles[subject][a.Role.Kind+"/"+a.Role.Name] = true
+			sources[subject][a.Source] = true
+		}
+		for _, subject := range sortedSet(roles) {
+			if len(roles[subject]) < 2 {
+				continue
+			}
+			kind, name, _ := strings.Cut(subject, "/")
+			violations = append(violations, ConstraintViolation{
+				Set:     set.Name,
+				Kind:    kind,
+				Name:    name,
+				Roles:   setKeys(roles[subject]),
+				Sources: setKeys(sources[subject]),
+			})
+		}
+	}
+	return violations
+}
+
+// String returns the message of the violation
+func (v ConstraintViolation) String() string {
+	return fmt.Sprintf("%s %s holds the exclusive roles %s of set %s through %s",
+		v.Kind, v.Name, strings.Join(v.Roles, ", "), v.Set, strings.Join(v.Sources, ", "))
+}
+
+// CheckConstraints returns the violations of all constraints which involve the source, prefixed
+// with the name of the constraint
+func CheckConstraints(constraints []PermsConstraint, assignments []RoleAssignment, source string) []string {
+	var violations []string
+	for i := range constraints {
+		for _, violation := range constraints[i].Violations(assignments) {
+			if !containsString(violation.Sources, source) {
+				continue
+			}
+			violations = append(violations, "PermsConstraint "+constraints[i].Name+": "+violation.String())
+		}
+	}
+	return violations
+}
+
+// sortedSet returns the keys of a set of subjects in a stable order
+func sortedSet(set map[string]map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// setKeys returns the keys of a set in a stable order
+func setKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// containsString returns true if the list contains the value
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
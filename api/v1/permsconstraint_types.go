@@ -0,0 +1,95 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PermsConstraintSpec defines roles which may not be held by the same user or group
+type PermsConstraintSpec struct {
+	// ExclusiveRoles are sets of mutually exclusive roles, no user or group may hold more than
+	// one role of a set through PermsRoleBindings and PermsClusterRoleBindings.
+	//+kubebuilder:validation:MinItems=1
+	ExclusiveRoles []ExclusiveRoleSet `json:"exclusiveRoles"`
+}
+
+// ExclusiveRoleSet is a set of mutually exclusive roles
+type ExclusiveRoleSet struct {
+	// Name of the set, used in the violation messages.
+	Name string `json:"name"`
+
+	// Roles which are mutually exclusive. A Role matches the roles with its name in all namespaces.
+	//+kubebuilder:validation:MinItems=2
+	Roles []RoleReference `json:"roles"`
+}
+
+// PermsConstraintStatus defines the observed state of PermsConstraint
+type PermsConstraintStatus struct {
+	// Violations are the users and groups which hold more than one role of a set.
+	//+optional
+	Violations []ConstraintViolation `json:"violations,omitempty"`
+
+	// ObservedGeneration is the generation the status was computed for.
+	//+optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	//+optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// ConstraintViolation is a user or group which holds more than one role of a set
+type ConstraintViolation struct {
+	// Set is the name of the violated set.
+	Set string `json:"set"`
+	// Kind of the subject, User or Group.
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// Roles are the roles of the set the subject holds, as Kind/name.
+	Roles []string `json:"roles"`
+	// Sources are the PermsRoleBindings and PermsClusterRoleBindings which assign the roles, as
+	// PermsRoleBinding/namespace/name or PermsClusterRoleBinding/name.
+	Sources []string `json:"sources"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=pcon,scope=Cluster
+//+kubebuilder:printcolumn:name=Satisfied,type=string,JSONPath=".status.conditions[?(@.type==\"Satisfied\")].status"
+//+kubebuilder:printcolumn:name=Age,type=date,JSONPath=".metadata.creationTimestamp"
+
+// PermsConstraint is the Schema for the permsconstraints API
+type PermsConstraint struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PermsConstraintSpec   `json:"spec,omitempty"`
+	Status PermsConstraintStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PermsConstraintList contains a list of PermsConstraint
+type PermsConstraintList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PermsConstraint `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PermsConstraint{}, &PermsConstraintList{})
+}
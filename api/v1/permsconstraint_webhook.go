@@ -0,0 +1,216 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// log is for logging in this package.
+var permsconstraintlog = logf.Log.WithName("permsconstraint-resource")
+
+// constraintWebhookPath is the path of the webhook which checks PermsRoleBindings and
+// PermsClusterRoleBindings against the PermsConstraints
+const constraintWebhookPath = "/validate-perms-infra-mgmt-io-v1-constraint"
+
+func (r *PermsConstraint) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(constraintWebhookPath, &webhook.Admission{Handler: &ConstraintValidator{Client: mgr.GetClient()}})
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-perms-infra-mgmt-io-v1-permsconstraint,mutating=false,failurePolicy=fail,sideEffects=None,groups=perms.infra-mgmt.io,resources=permsconstraints,verbs=create;update,versions=v1,name=vpermsconstraint.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &PermsConstraint{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *PermsConstraint) ValidateCreate() error {
+	permsconstraintlog.Info("validate create", "name", r.Name)
+
+	return r.validatePermsConstraint()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *PermsConstraint) ValidateUpdate(old runtime.Object) error {
+	permsconstraintlog.Info("validate update", "name", r.Name)
+
+	return r.validatePermsConstraint()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *PermsConstraint) ValidateDelete() error {
+	permsconstraintlog.Info("validate delete", "name", r.Name)
+
+	// deleting a PermsConstraint is always allowed
+	return nil
+}
+
+// validatePermsConstraint rejects sets without name, duplicate sets and sets with less than two roles
+func (r *PermsConstraint) validatePermsConstraint() error {
+	var allErrs field.ErrorList
+	setsPath := field.NewPath("spec", "exclusiveRoles")
+
+	if len(r.Spec.ExclusiveRoles) == 0 {
+		allErrs = append(allErrs, field.Required(setsPath, "at least one set of exclusive roles is required"))
+	}
+	seen := map[string]bool{}
+	for i, set := range r.Spec.ExclusiveRoles {
+		idxPath := setsPath.Index(i)
+		if set.Name == "" {
+			allErrs = append(allErrs, field.Required(idxPath.Child("name"), "name must not be empty"))
+		} else if seen[set.Name] {
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("name"), set.Name))
+		}
+		seen[set.Name] = true
+
+		roles := map[RoleReference]bool{}
+		for j, role := range set.Roles {
+			rolePath := idxPath.Child("roles").Index(j)
+			if role.Kind != "Role" && role.Kind != "ClusterRole" {
+				allErrs = append(allErrs, field.NotSupported(rolePath.Child("kind"), role.Kind, []string{"Role", "ClusterRole"}))
+			}
+			if role.Name == "" {
+				allErrs = append(allErrs, field.Required(rolePath.Child("name"), "name must not be empty"))
+			} else if roles[role] {
+				allErrs = append(allErrs, field.Duplicate(rolePath, role.Kind+"/"+role.Name))
+			}
+			roles[role] = true
+		}
+		if len(roles) < 2 {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("roles"), len(set.Roles), "a set needs at least two roles"))
+		}
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: GroupVersion.Group, Kind: "PermsConstraint"},
+		r.Name, allErrs)
+}
+
+//+kubebuilder:webhook:path=/validate-perms-infra-mgmt-io-v1-constraint,mutating=false,failurePolicy=fail,sideEffects=None,groups=perms.infra-mgmt.io,resources=permsrolebindings;permsclusterrolebindings,verbs=create;update,versions=v1,name=vpermsconstraintenforcement.kb.io,admissionReviewVersions=v1
+
+//+kubebuilder:object:generate=false
+
+// ConstraintValidator rejects PermsRoleBindings and PermsClusterRoleBindings which assign a user or
+// group more than one role of a set of a PermsConstraint
+type ConstraintValidator struct {
+	Client  client.Reader
+	decoder *admission.Decoder
+}
+
+var _ admission.DecoderInjector = &ConstraintValidator{}
+
+// InjectDecoder implements admission.DecoderInjector
+func (v *ConstraintValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+// Handle checks the roles of the users and groups of the object together with the roles they hold
+// through all other PermsRoleBindings and PermsClusterRoleBindings against all PermsConstraints
+func (v *ConstraintValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if finalizersOnly(req) {
+		return admission.Allowed("")
+	}
+	var source string
+	var assignments []RoleAssignment
+	switch req.Kind.Kind {
+	case "PermsRoleBinding":
+		prb := &PermsRoleBinding{}
+		if err := v.decoder.Decode(req, prb); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		source = prb.ConstraintSource()
+		assignments = prb.RoleAssignments(prb.Spec.Users, prb.Spec.Groups)
+	case "PermsClusterRoleBinding":
+		pcrb := &PermsClusterRoleBinding{}
+		if err := v.decoder.Decode(req, pcrb); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		source = pcrb.ConstraintSource()
+		assignments = pcrb.RoleAssignments(pcrb.Spec.Users, pcrb.Spec.Groups)
+	default:
+		return admission.Allowed("")
+	}
+
+	constraints := &PermsConstraintList{}
+	if err := v.Client.List(ctx, constraints); err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if len(constraints.Items) == 0 {
+		return admission.Allowed("")
+	}
+	others, err := v.otherAssignments(ctx, source)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if violations := CheckConstraints(constraints.Items, append(assignments, others...), source); len(violations) > 0 {
+		permsconstraintlog.Info("constraint violation", "kind", req.Kind.Kind, "name", req.Name, "namespace", req.Namespace, "violations", violations)
+		// admission.Denied only sets the reason, the API server shows the message to the client
+		return admission.Response{AdmissionResponse: admissionv1.AdmissionResponse{
+			Allowed: false,
+			Result: &metav1.Status{
+				Code:    http.StatusForbidden,
+				Reason:  metav1.StatusReasonForbidden,
+				Message: strings.Join(violations, "; "),
+			},
+		}}
+	}
+	return admission.Allowed("")
+}
+
+// otherAssignments returns the roles the users and groups hold through all PermsRoleBindings and
+// PermsClusterRoleBindings except the source
+func (v *ConstraintValidator) otherAssignments(ctx context.Context, source string) ([]RoleAssignment, error) {
+	var assignments []RoleAssignment
+	prbs := &PermsRoleBindingList{}
+	if err := v.Client.List(ctx, prbs); err != nil {
+		return nil, err
+	}
+	for i := range prbs.Items {
+		if prbs.Items[i].ConstraintSource() != source {
+			assignments = append(assignments, prbs.Items[i].RoleAssignments(prbs.Items[i].Spec.Users, prbs.Items[i].Spec.Groups)...)
+		}
+	}
+	pcrbs := &PermsClusterRoleBindingList{}
+	if err := v.Client.List(ctx, pcrbs); err != nil {
+		return nil, err
+	}
+	for i := range pcrbs.Items {
+		if pcrbs.Items[i].ConstraintSource() != source {
+			assignments = append(assignments, pcrbs.Items[i].RoleAssignments(pcrbs.Items[i].Spec.Users, pcrbs.Items[i].Spec.Groups)...)
+		}
+	}
+	return assignments, nil
+}
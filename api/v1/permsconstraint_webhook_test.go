@@ -0,0 +1,141 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var _ = Describe("permsconstraint webhook", func() {
+	newPermsConstraint := func() *PermsConstraint {
+		return &PermsConstraint{
+			ObjectMeta: metav1.ObjectMeta{Name: "sod"},
+			Spec: PermsConstraintSpec{ExclusiveRoles: []ExclusiveRoleSet{{
+				Name:  "deploy-approve",
+				Roles: []RoleReference{{Kind: "ClusterRole", Name: "deployer"}, {Kind: "ClusterRole", Name: "approver"}},
+			}}},
+		}
+	}
+	newPermsRoleBinding := func(name string, role string, users ...string) *PermsRoleBinding {
+		return &PermsRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "testing"},
+			Spec:       PermsRoleBindingSpec{Kind: "ClusterRole", Role: role, Users: users, Groups: []string{"team-a"}},
+		}
+	}
+
+	Context("evaluating a PermsConstraint", func() {
+
+		It("should report users and groups which hold more than one role of a set", func() {
+			deployers := newPermsRoleBinding("deployers", "deployer", "user1", "user2")
+			approvers := &PermsClusterRoleBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: "approvers"},
+				Spec:       PermsClusterRoleBindingSpec{Role: "approver", Users: []string{"user2"}},
+			}
+			assignments := append(deployers.RoleAssignments(deployers.Spec.Users, deployers.Spec.Groups),
+				approvers.RoleAssignments(approvers.Spec.Users, approvers.Spec.Groups)...)
+			Expect(newPermsConstraint().Violations(assignments)).To(Equal([]ConstraintViolation{{
+				Set:     "deploy-approve",
+				Kind:    "User",
+				Name:    "user2",
+				Roles:   []string{"ClusterRole/approver", "ClusterRole/deployer"},
+				Sources: []string{"PermsClusterRoleBinding/approvers", "PermsRoleBinding/testing/deployers"},
+			}}))
+		})
+
+		It("should report a single PermsRoleBinding which assigns exclusive roles", func() {
+			prb := newPermsRoleBinding("both", "deployer", "user1")
+			prb.Spec.Roles = []RoleSpec{{Kind: "ClusterRole", Name: "approver"}}
+			violations := CheckConstraints([]PermsConstraint{*newPermsConstraint()}, prb.RoleAssignments(prb.Spec.Users, prb.Spec.Groups), prb.ConstraintSource())
+			Expect(violations).To(Equal([]string{
+				"PermsConstraint sod: Group team-a holds the exclusive roles ClusterRole/approver, ClusterRole/deployer of set deploy-approve through PermsRoleBinding/testing/both",
+				"PermsConstraint sod: User user1 holds the exclusive roles ClusterRole/approver, ClusterRole/deployer of set deploy-approve through PermsRoleBinding/testing/both",
+			}))
+		})
+	})
+
+	Context("validating a PermsConstraint", func() {
+
+		It("should accept a valid set", func() {
+			Expect(newPermsConstraint().ValidateCreate()).To(Succeed())
+		})
+
+		It("should reject sets without name and with less than two distinct roles", func() {
+			constraint := newPermsConstraint()
+			constraint.Spec.ExclusiveRoles = append(constraint.Spec.ExclusiveRoles, ExclusiveRoleSet{
+				Roles: []RoleReference{{Kind: "ClusterRole", Name: "admin"}, {Kind: "ClusterRole", Name: "admin"}},
+			})
+			err := constraint.ValidateUpdate(newPermsConstraint())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.exclusiveRoles[1].name"))
+			Expect(err.Error()).To(ContainSubstring("spec.exclusiveRoles[1].roles[1]"))
+			Expect(err.Error()).To(ContainSubstring("a set needs at least two roles"))
+		})
+	})
+
+	Context("enforcing the PermsConstraints at admission", func() {
+		newValidator := func(objs ...runtime.Object) *ConstraintValidator {
+			scheme := runtime.NewScheme()
+			Expect(AddToScheme(scheme)).To(Succeed())
+			decoder, err := admission.NewDecoder(scheme)
+			Expect(err).NotTo(HaveOccurred())
+			v := &ConstraintValidator{Client: fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objs...).Build()}
+			Expect(v.InjectDecoder(decoder)).To(Succeed())
+			return v
+		}
+		requestFor := func(prb *PermsRoleBinding) admission.Request {
+			raw, err := json.Marshal(prb)
+			Expect(err).NotTo(HaveOccurred())
+			return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Update,
+				Kind:      metav1.GroupVersionKind{Group: GroupVersion.Group, Version: GroupVersion.Version, Kind: "PermsRoleBinding"},
+				Name:      prb.Name,
+				Namespace: prb.Namespace,
+				Object:    runtime.RawExtension{Raw: raw},
+			}}
+		}
+
+		It("should deny a PermsRoleBinding which assigns an exclusive role to a holder of another one", func() {
+			deployers := newPermsRoleBinding("deployers", "deployer", "user1")
+			approvers := newPermsRoleBinding("approvers", "approver", "user2")
+			response := newValidator(newPermsConstraint(), deployers, approvers).Handle(context.Background(), requestFor(newPermsRoleBinding("approvers", "approver", "user1")))
+			Expect(response.Allowed).To(BeFalse())
+			Expect(response.Result.Message).To(ContainSubstring("User user1 holds the exclusive roles ClusterRole/approver, ClusterRole/deployer"))
+		})
+
+		It("should replace the stored version of the PermsRoleBinding", func() {
+			deployers := newPermsRoleBinding("deployers", "deployer", "user1")
+			deployers.Spec.Groups = nil
+			approvers := newPermsRoleBinding("approvers", "deployer", "user2")
+			// the stored version binds the deployer role, the update switches to the approver role
+			response := newValidator(newPermsConstraint(), deployers, approvers).Handle(context.Background(), requestFor(newPermsRoleBinding("approvers", "approver", "user2")))
+			Expect(response.Allowed).To(BeTrue())
+		})
+
+		It("should allow changes which are not involved in a violation", func() {
+			deployers := newPermsRoleBinding("deployers", "deployer", "user1")
+			approvers := newPermsRoleBinding("approvers", "approver", "user1")
+			response := newValidator(newPermsConstraint(), deployers, approvers).Handle(context.Background(), requestFor(newPermsRoleBinding("viewers", "view", "user1")))
+			Expect(response.Allowed).To(BeTrue())
+		})
+
+		It("should not check an update which only removes a finalizer", func() {
+			deployers := newPermsRoleBinding("deployers", "deployer", "user1")
+			approvers := newPermsRoleBinding("approvers", "approver", "user1")
+			old := approvers.DeepCopy()
+			old.Finalizers = []string{"example.com/cleanup"}
+			raw, err := json.Marshal(old)
+			Expect(err).NotTo(HaveOccurred())
+			req := requestFor(approvers)
+			req.OldObject = runtime.RawExtension{Raw: raw}
+			Expect(newValidator(newPermsConstraint(), deployers, old).Handle(context.Background(), req).Allowed).To(BeTrue())
+		})
+	})
+})
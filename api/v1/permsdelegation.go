@@ -0,0 +1,92 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+	"strings"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+)
+
+// Violations returns a message for each role and subject of the request which the delegation
+// does not allow
+func (d *PermsDelegation) Violations(req PolicyRequest) []string {
+	var violations []string
+	for _, role := range req.Roles {
+		if !matchesRole(d.Spec.Roles, role) {
+			violations = append(violations, fmt.Sprintf("%s %s is not delegated", role.Kind, role.Name))
+		}
+	}
+	violations = append(violations, delegatedViolations("user", d.Spec.Users, req.Users)...)
+	violations = append(violations, delegatedViolations("group", d.Spec.Groups, req.Groups)...)
+	violations = append(violations, delegatedViolations("serviceaccount", d.Spec.Serviceaccounts, req.Serviceaccounts)...)
+	return violations
+}
+
+// IsAdmin returns true if the user or one of its groups is an admin of the delegation
+func (d *PermsDelegation) IsAdmin(user authenticationv1.UserInfo) bool {
+	if containsString(d.Spec.Admins.Users, user.Username) {
+		return true
+	}
+	for _, group := range user.Groups {
+		if containsString(d.Spec.Admins.Groups, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckDelegations returns the violations of the request if none of the delegations of its
+// namespace allows all of its roles and subjects, nil without delegations. The roles and subjects
+// are not combined across delegations, each delegation grants its roles only to its subjects.
+func CheckDelegations(delegations []PermsDelegation, req PolicyRequest) []string {
+	if len(delegations) == 0 {
+		return nil
+	}
+	var violations []string
+	for i := range delegations {
+		delegationViolations := delegations[i].Violations(req)
+		if len(delegationViolations) == 0 {
+			return nil
+		}
+		violations = append(violations, "PermsDelegation "+delegations[i].Name+": "+strings.Join(delegationViolations, ", "))
+	}
+	return violations
+}
+
+// DelegatedBy returns the delegation which allows all roles and subjects of the request and has
+// the user as admin, nil if there is none
+func DelegatedBy(delegations []PermsDelegation, req PolicyRequest, user authenticationv1.UserInfo) *PermsDelegation {
+	for i := range delegations {
+		if delegations[i].IsAdmin(user) && len(delegations[i].Violations(req)) == 0 {
+			return &delegations[i]
+		}
+	}
+	return nil
+}
+
+// delegatedViolations returns a message for each name which no pattern matches
+func delegatedViolations(kind string, patterns []string, names []string) []string {
+	var violations []string
+	for _, name := range names {
+		if !matchesAny(patterns, name) {
+			violations = append(violations, fmt.Sprintf("%s %s is not delegated", kind, name))
+		}
+	}
+	return violations
+}
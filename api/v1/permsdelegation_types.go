@@ -0,0 +1,80 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PermsDelegationSpec defines the roles and subjects which the admins of a namespace may grant with
+// PermsRoleBindings
+type PermsDelegationSpec struct {
+	// Admins are the users and groups which may grant the roles to the subjects without the bind
+	// permission on the roles.
+	//+optional
+	Admins DelegationAdmins `json:"admins,omitempty"`
+
+	// Roles are the roles which may be granted in the namespace.
+	Roles []RolePattern `json:"roles"`
+
+	// Users are patterns of the users which may get a role assigned, none if empty.
+	//+optional
+	Users []string `json:"users,omitempty"`
+
+	// Groups are patterns of the groups which may get a role assigned, none if empty.
+	//+optional
+	Groups []string `json:"groups,omitempty"`
+
+	// Serviceaccounts are patterns of the serviceaccounts which may get a role assigned, the
+	// patterns match namespace/name. None if empty.
+	//+optional
+	Serviceaccounts []string `json:"serviceaccounts,omitempty"`
+}
+
+// DelegationAdmins are the users and groups a PermsDelegation delegates the administration to
+type DelegationAdmins struct {
+	//+optional
+	Users []string `json:"users,omitempty"`
+	//+optional
+	Groups []string `json:"groups,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:shortName=pdel
+//+kubebuilder:printcolumn:name=Age,type=date,JSONPath=".metadata.creationTimestamp"
+
+// PermsDelegation is the Schema for the permsdelegations API. The PermsRoleBindings of a namespace
+// with PermsDelegations may only grant the roles to the subjects one of them allows.
+type PermsDelegation struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PermsDelegationSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PermsDelegationList contains a list of PermsDelegation
+type PermsDelegationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PermsDelegation `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PermsDelegation{}, &PermsDelegationList{})
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var permsdelegationlog = logf.Log.WithName("permsdelegation-resource")
+
+func (r *PermsDelegation) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-perms-infra-mgmt-io-v1-permsdelegation,mutating=false,failurePolicy=fail,sideEffects=None,groups=perms.infra-mgmt.io,resources=permsdelegations,verbs=create;update,versions=v1,name=vpermsdelegation.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &PermsDelegation{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *PermsDelegation) ValidateCreate() error {
+	permsdelegationlog.Info("validate create", "name", r.Name, "namespace", r.Namespace)
+
+	return r.validatePermsDelegation()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *PermsDelegation) ValidateUpdate(old runtime.Object) error {
+	permsdelegationlog.Info("validate update", "name", r.Name, "namespace", r.Namespace)
+
+	return r.validatePermsDelegation()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *PermsDelegation) ValidateDelete() error {
+	permsdelegationlog.Info("validate delete", "name", r.Name, "namespace", r.Namespace)
+
+	// deleting a PermsDelegation is always allowed
+	return nil
+}
+
+// validatePermsDelegation rejects a delegation without roles, empty admins and invalid patterns
+func (r *PermsDelegation) validatePermsDelegation() error {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	if len(r.Spec.Roles) == 0 {
+		allErrs = append(allErrs, field.Required(specPath.Child("roles"), "at least one role is required"))
+	}
+	for i, pattern := range r.Spec.Roles {
+		allErrs = append(allErrs, validatePatterns(specPath.Child("roles").Index(i).Child("name"), []string{pattern.Name})...)
+	}
+	allErrs = append(allErrs, validateNames(specPath.Child("admins", "users"), r.Spec.Admins.Users)...)
+	allErrs = append(allErrs, validateNames(specPath.Child("admins", "groups"), r.Spec.Admins.Groups)...)
+	allErrs = append(allErrs, validatePatterns(specPath.Child("users"), r.Spec.Users)...)
+	allErrs = append(allErrs, validatePatterns(specPath.Child("groups"), r.Spec.Groups)...)
+	allErrs = append(allErrs, validatePatterns(specPath.Child("serviceaccounts"), r.Spec.Serviceaccounts)...)
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: GroupVersion.Group, Kind: "PermsDelegation"},
+		r.Name, allErrs)
+}
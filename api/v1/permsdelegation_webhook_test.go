@@ -0,0 +1,92 @@
+package v1
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("permsdelegation webhook", func() {
+	newPermsDelegation := func(name string, role string, users ...string) PermsDelegation {
+		return PermsDelegation{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "testing"},
+			Spec: PermsDelegationSpec{
+				Admins:          DelegationAdmins{Groups: []string{"testing-owners"}},
+				Roles:           []RolePattern{{Kind: "ClusterRole", Name: role}},
+				Users:           users,
+				Groups:          []string{"org:*"},
+				Serviceaccounts: []string{"testing/*"},
+			},
+		}
+	}
+	newPermsRoleBinding := func(role string, users ...string) *PermsRoleBinding {
+		return &PermsRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "testing"},
+			Spec: PermsRoleBindingSpec{
+				Kind:            "ClusterRole",
+				Role:            role,
+				Users:           users,
+				Groups:          []string{"org:dev"},
+				Serviceaccounts: []Serviceaccount{{Name: "ci", Namespace: "testing"}},
+			},
+		}
+	}
+
+	Context("evaluating PermsDelegations", func() {
+
+		It("should allow everything in a namespace without delegations", func() {
+			Expect(CheckDelegations(nil, newPermsRoleBinding("admin", "mallory").PolicyRequest())).To(BeEmpty())
+		})
+
+		It("should allow a PermsRoleBinding which one delegation covers", func() {
+			delegations := []PermsDelegation{newPermsDelegation("view", "view", "*@example.com"), newPermsDelegation("edit", "edit", "lead@example.com")}
+			Expect(CheckDelegations(delegations, newPermsRoleBinding("view", "dev@example.com").PolicyRequest())).To(BeEmpty())
+			Expect(CheckDelegations(delegations, newPermsRoleBinding("edit", "lead@example.com").PolicyRequest())).To(BeEmpty())
+		})
+
+		It("should not combine the roles and subjects of different delegations", func() {
+			delegations := []PermsDelegation{newPermsDelegation("view", "view", "*@example.com"), newPermsDelegation("edit", "edit", "lead@example.com")}
+			Expect(CheckDelegations(delegations, newPermsRoleBinding("edit", "dev@example.com").PolicyRequest())).To(Equal([]string{
+				"PermsDelegation view: ClusterRole edit is not delegated",
+				"PermsDelegation edit: user dev@example.com is not delegated",
+			}))
+		})
+
+		It("should deny subjects outside of the delegated patterns", func() {
+			prb := newPermsRoleBinding("view", "dev@example.com")
+			prb.Spec.Groups = []string{"system:authenticated"}
+			prb.Spec.Serviceaccounts = []Serviceaccount{{Name: "default", Namespace: "kube-system"}}
+			delegation := newPermsDelegation("view", "view", "*@example.com")
+			Expect(delegation.Violations(prb.PolicyRequest())).To(Equal([]string{
+				"group system:authenticated is not delegated",
+				"serviceaccount kube-system/default is not delegated",
+			}))
+		})
+
+		It("should find the delegation of an admin", func() {
+			delegations := []PermsDelegation{newPermsDelegation("view", "view", "*@example.com")}
+			req := newPermsRoleBinding("view", "dev@example.com").PolicyRequest()
+			Expect(DelegatedBy(delegations, req, authenticationv1.UserInfo{Username: "owner", Groups: []string{"testing-owners"}})).NotTo(BeNil())
+			Expect(DelegatedBy(delegations, req, authenticationv1.UserInfo{Username: "dev@example.com"})).To(BeNil())
+		})
+	})
+
+	Context("validating a PermsDelegation", func() {
+
+		It("should accept a valid delegation", func() {
+			delegation := newPermsDelegation("view", "view", "*@example.com")
+			Expect(delegation.ValidateCreate()).To(Succeed())
+		})
+
+		It("should reject a delegation without roles and with malformed patterns", func() {
+			delegation := newPermsDelegation("view", "view", "[")
+			delegation.Spec.Roles = nil
+			err := delegation.ValidateCreate()
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.roles: Required value"))
+			Expect(err.Error()).To(ContainSubstring("spec.users[0]: Invalid value"))
+		})
+	})
+})
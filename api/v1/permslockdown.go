@@ -0,0 +1,43 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"sort"
+)
+
+// ActiveLockdown returns the active lockdown which applies to a binding, the first by name. The
+// name of a PermsBreakGlass skips the lockdowns which exempt it, it is empty for other bindings.
+func ActiveLockdown(lockdowns []PermsLockdown, breakGlass string) *PermsLockdown {
+	sort.Slice(lockdowns, func(i, j int) bool { return lockdowns[i].Name < lockdowns[j].Name })
+	for i := range lockdowns {
+		if lockdowns[i].Spec.Active && (breakGlass == "" || !lockdowns[i].Exempts(breakGlass)) {
+			return &lockdowns[i]
+		}
+	}
+	return nil
+}
+
+// Exempts returns true if the PermsBreakGlass keeps its subjects during the lockdown
+func (l *PermsLockdown) Exempts(breakGlass string) bool {
+	for _, name := range l.Spec.ExemptBreakGlasses {
+		if name == breakGlass {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Phases of a PermsLockdown
+const (
+	LockdownInactive  = "Inactive"
+	LockdownLocking   = "Locking"
+	LockdownLocked    = "Locked"
+	LockdownRestoring = "Restoring"
+	LockdownRestored  = "Restored"
+)
+
+// PermsLockdownSpec defines whether the subjects of the managed bindings are removed
+type PermsLockdownSpec struct {
+	// Active removes the subjects of all bindings managed for PermsRoleBindings,
+	// PermsClusterRoleBindings and PermsBreakGlasses, setting it to false restores them.
+	Active bool `json:"active"`
+
+	// Reason of the lockdown.
+	//+optional
+	Reason string `json:"reason,omitempty"`
+
+	// ExemptBreakGlasses are the names of the PermsBreakGlasses which keep their subjects
+	// during the lockdown.
+	//+optional
+	ExemptBreakGlasses []string `json:"exemptBreakGlasses,omitempty"`
+}
+
+// PermsLockdownStatus defines the observed state of PermsLockdown
+type PermsLockdownStatus struct {
+	// Phase is Inactive, Locking, Locked, Restoring or Restored.
+	//+optional
+	Phase string `json:"phase,omitempty"`
+
+	// LockedAt is the time the lockdown was activated.
+	//+optional
+	LockedAt *metav1.Time `json:"lockedAt,omitempty"`
+
+	// LiftedAt is the time the lockdown was lifted.
+	//+optional
+	LiftedAt *metav1.Time `json:"liftedAt,omitempty"`
+
+	// Resources is the number of PermsRoleBindings and PermsClusterRoleBindings.
+	//+optional
+	Resources int32 `json:"resources,omitempty"`
+
+	// LockedResources is the number of PermsRoleBindings and PermsClusterRoleBindings whose
+	// subjects are removed by the lockdown.
+	//+optional
+	LockedResources int32 `json:"lockedResources,omitempty"`
+
+	// ObservedGeneration is the generation the status was computed for.
+	//+optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	//+optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// LockdownSnapshot records the subjects of a PermsRoleBinding or PermsClusterRoleBinding before
+// a lockdown removed them
+type LockdownSnapshot struct {
+	// Lockdown is the name of the PermsLockdown.
+	Lockdown string `json:"lockdown"`
+
+	// Since is the time the subjects were removed.
+	Since metav1.Time `json:"since"`
+
+	// Generation of the resource when the subjects were removed.
+	//+optional
+	Generation int64 `json:"generation,omitempty"`
+
+	// Subjects had the roles assigned before the lockdown, as Kind/name or
+	// ServiceAccount/namespace/name.
+	//+optional
+	Subjects []string `json:"subjects,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster,shortName=plock
+//+kubebuilder:printcolumn:name=Active,type=boolean,JSONPath=".spec.active"
+//+kubebuilder:printcolumn:name=Phase,type=string,JSONPath=".status.phase"
+//+kubebuilder:printcolumn:name=Locked,type=integer,JSONPath=".status.lockedResources"
+//+kubebuilder:printcolumn:name=Resources,type=integer,JSONPath=".status.resources"
+//+kubebuilder:printcolumn:name=Age,type=date,JSONPath=".metadata.creationTimestamp"
+
+// PermsLockdown is the Schema for the permslockdowns API. While it is active the operator removes
+// the subjects of all bindings it manages, except those of exempt PermsBreakGlasses, and restores
+// them when it is lifted.
+type PermsLockdown struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PermsLockdownSpec   `json:"spec,omitempty"`
+	Status PermsLockdownStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PermsLockdownList contains a list of PermsLockdown
+type PermsLockdownList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PermsLockdown `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PermsLockdown{}, &PermsLockdownList{})
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var permslockdownlog = logf.Log.WithName("permslockdown-resource")
+
+func (r *PermsLockdown) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-perms-infra-mgmt-io-v1-permslockdown,mutating=false,failurePolicy=fail,sideEffects=None,groups=perms.infra-mgmt.io,resources=permslockdowns,verbs=create;update,versions=v1,name=vpermslockdown.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &PermsLockdown{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *PermsLockdown) ValidateCreate() error {
+	permslockdownlog.Info("validate create", "name", r.Name)
+
+	return r.validatePermsLockdown()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *PermsLockdown) ValidateUpdate(old runtime.Object) error {
+	permslockdownlog.Info("validate update", "name", r.Name)
+
+	return r.validatePermsLockdown()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *PermsLockdown) ValidateDelete() error {
+	permslockdownlog.Info("validate delete", "name", r.Name)
+
+	// deleting a PermsLockdown lifts it, the subjects are restored
+	return nil
+}
+
+// validatePermsLockdown rejects empty and duplicate names of exempt break-glasses
+func (r *PermsLockdown) validatePermsLockdown() error {
+	allErrs := validateNames(field.NewPath("spec").Child("exemptBreakGlasses"), r.Spec.ExemptBreakGlasses)
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: GroupVersion.Group, Kind: "PermsLockdown"},
+		r.Name, allErrs)
+}
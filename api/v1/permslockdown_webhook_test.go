@@ -0,0 +1,49 @@
+package v1
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("permslockdown webhook", func() {
+	newPermsLockdown := func(name string, active bool, exempt ...string) PermsLockdown {
+		return PermsLockdown{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       PermsLockdownSpec{Active: active, ExemptBreakGlasses: exempt},
+		}
+	}
+
+	Context("selecting the active lockdown", func() {
+
+		It("should return the first active lockdown by name", func() {
+			lockdowns := []PermsLockdown{newPermsLockdown("c", true), newPermsLockdown("a", false), newPermsLockdown("b", true)}
+			Expect(ActiveLockdown(lockdowns, "").Name).To(Equal("b"))
+			Expect(ActiveLockdown([]PermsLockdown{newPermsLockdown("a", false)}, "")).To(BeNil())
+		})
+
+		It("should skip the lockdowns which exempt a break-glass", func() {
+			lockdowns := []PermsLockdown{newPermsLockdown("a", true, "emergency"), newPermsLockdown("b", true)}
+			Expect(ActiveLockdown(lockdowns, "emergency").Name).To(Equal("b"))
+			Expect(ActiveLockdown(lockdowns[:1], "emergency")).To(BeNil())
+			Expect(ActiveLockdown(lockdowns[:1], "other").Name).To(Equal("a"))
+		})
+	})
+
+	Context("validating a PermsLockdown", func() {
+
+		It("should accept a lockdown with exempt break-glasses", func() {
+			lockdown := newPermsLockdown("incident", true, "emergency")
+			Expect(lockdown.ValidateCreate()).To(Succeed())
+		})
+
+		It("should reject empty and duplicate exempt break-glasses", func() {
+			lockdown := newPermsLockdown("incident", true, "emergency", "", "emergency")
+			err := lockdown.ValidateCreate()
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.exemptBreakGlasses[1]: Required value"))
+			Expect(err.Error()).To(ContainSubstring("spec.exemptBreakGlasses[2]: Duplicate value"))
+		})
+	})
+})
@@ -0,0 +1,199 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+//+kubebuilder:object:generate=false
+
+// PolicyRequest are the roles and subjects of a PermsRoleBinding or PermsClusterRoleBinding which
+// are checked against the PermsPolicies
+type PolicyRequest struct {
+	// Namespaces the roles get bound in, empty for a ClusterRoleBinding.
+	Namespaces []string
+	Roles      []RoleReference
+	Users      []string
+	Groups     []string
+	// Serviceaccounts as namespace/name.
+	Serviceaccounts []string
+	// ClusterWide is true for a ClusterRoleBinding, the roles are granted in all namespaces.
+	ClusterWide bool
+	// Object is the PermsRoleBinding or PermsClusterRoleBinding the rules are evaluated for.
+	Object runtime.Object
+	// NamespaceLabels are the labels of the namespaces, added by the caller.
+	NamespaceLabels map[string]map[string]string
+	// User is the requesting user, nil when the operator reconciles.
+	User *authenticationv1.UserInfo
+}
+
+// PolicyRequest returns the roles and subjects of the PermsRoleBinding
+func (r *PermsRoleBinding) PolicyRequest() PolicyRequest {
+	req := PolicyRequest{
+		Namespaces: []string{r.Namespace},
+		Roles:      []RoleReference{{Kind: r.Spec.Kind, Name: r.Spec.Role}},
+		Users:      r.Spec.Users,
+		Groups:     r.Spec.Groups,
+		Object:     r,
+	}
+	for _, role := range r.Spec.Roles {
+		req.Roles = append(req.Roles, RoleReference{Kind: role.Kind, Name: role.Name})
+	}
+	for _, sa := range r.Spec.Serviceaccounts {
+		namespace := sa.Namespace
+		if namespace == "" {
+			namespace = r.Namespace
+		}
+		req.Serviceaccounts = append(req.Serviceaccounts, namespace+"/"+sa.Name)
+	}
+	return req
+}
+
+// PolicyRequest returns the roles and subjects of the PermsClusterRoleBinding, the namespaces
+// selected by spec.namespaceSelector are added by the caller. Without namespaces and selector the
+// request is cluster-wide.
+func (r *PermsClusterRoleBinding) PolicyRequest() PolicyRequest {
+	req := PolicyRequest{
+		Namespaces: r.Spec.Namespaces,
+		Roles:      []RoleReference{{Kind: "ClusterRole", Name: r.Spec.Role}},
+		Users:      r.Spec.Users,
+		Groups:     r.Spec.Groups,
+		Object:     r,
+	}
+	for _, sa := range r.Spec.Serviceaccounts {
+		req.Serviceaccounts = append(req.Serviceaccounts, sa.Namespace+"/"+sa.Name)
+	}
+	req.ClusterWide = r.Spec.NamespaceSelector == nil && len(r.Spec.Namespaces) == 0
+	return req
+}
+
+// Violations returns a message for each part of the request which the policy does not allow
+func (p *PermsPolicy) Violations(req PolicyRequest) []string {
+	var violations []string
+	for _, role := range req.Roles {
+		if matchesRole(p.Spec.Roles.Deny, role) {
+			violations = append(violations, fmt.Sprintf("%s %s is denied", role.Kind, role.Name))
+		} else if len(p.Spec.Roles.Allow) > 0 && !matchesRole(p.Spec.Roles.Allow, role) {
+			violations = append(violations, fmt.Sprintf("%s %s is not allowed", role.Kind, role.Name))
+		}
+	}
+	violations = append(violations, p.Spec.Users.violations("user", req.Users)...)
+	violations = append(violations, p.Spec.Groups.violations("group", req.Groups)...)
+	violations = append(violations, p.Spec.Serviceaccounts.violations("serviceaccount", req.Serviceaccounts)...)
+	for _, namespace := range req.Namespaces {
+		if matchesAny(p.Spec.ProtectedNamespaces, namespace) {
+			violations = append(violations, fmt.Sprintf("namespace %s is protected", namespace))
+		}
+	}
+	// a ClusterRoleBinding grants the roles in the protected namespaces as well
+	if req.ClusterWide && len(p.Spec.ProtectedNamespaces) > 0 {
+		violations = append(violations, fmt.Sprintf("namespaces %s are protected and a ClusterRoleBinding grants the roles in all namespaces",
+			strings.Join(p.Spec.ProtectedNamespaces, ", ")))
+	}
+	if subjects := len(req.Users) + len(req.Groups) + len(req.Serviceaccounts); p.Spec.MaxSubjects > 0 && subjects > int(p.Spec.MaxSubjects) {
+		violations = append(violations, fmt.Sprintf("%d subjects exceed the maximum of %d", subjects, p.Spec.MaxSubjects))
+	}
+	compiled := p.compiledRules()
+	for i, rule := range p.Spec.Rules {
+		if violation := rule.violation(compiled[i], req); violation != "" {
+			violations = append(violations, violation)
+		}
+	}
+	return violations
+}
+
+// CheckPolicies returns the violations of all policies, prefixed with the name of the policy. The
+// compiled rules of policies which are not in the list are removed from the cache.
+func CheckPolicies(policies []PermsPolicy, req PolicyRequest) []string {
+	forgetDeletedPolicies(policies)
+	var violations []string
+	for i := range policies {
+		for _, violation := range policies[i].Violations(req) {
+			violations = append(violations, "PermsPolicy "+policies[i].Name+": "+violation)
+		}
+	}
+	return violations
+}
+
+// RequiresApproval returns true if one of the policies flags the PermsRoleBinding or
+// PermsClusterRoleBinding of the request as sensitive
+func RequiresApproval(policies []PermsPolicy, req PolicyRequest) bool {
+	for i := range policies {
+		if policies[i].Spec.TwoPersonApproval.matches(req) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches returns true if a role and a namespace of the request match the policy, a cluster-wide
+// request matches all namespaces
+func (p *ApprovalPolicy) matches(req PolicyRequest) bool {
+	if p == nil || (len(p.Roles) == 0 && len(p.Namespaces) == 0) {
+		return false
+	}
+	roleMatches := len(p.Roles) == 0
+	for _, role := range req.Roles {
+		roleMatches = roleMatches || matchesRole(p.Roles, role)
+	}
+	namespaceMatches := len(p.Namespaces) == 0 || req.ClusterWide
+	for _, namespace := range req.Namespaces {
+		namespaceMatches = namespaceMatches || matchesAny(p.Namespaces, namespace)
+	}
+	return roleMatches && namespaceMatches
+}
+
+// violations returns a message for each name which is denied or not allowed
+func (p NamePolicy) violations(kind string, names []string) []string {
+	var violations []string
+	for _, name := range names {
+		if matchesAny(p.Deny, name) {
+			violations = append(violations, fmt.Sprintf("%s %s is denied", kind, name))
+		} else if len(p.Allow) > 0 && !matchesAny(p.Allow, name) {
+			violations = append(violations, fmt.Sprintf("%s %s is not allowed", kind, name))
+		}
+	}
+	return violations
+}
+
+// matchesRole returns true if a pattern matches the kind and name of the role
+func matchesRole(patterns []RolePattern, role RoleReference) bool {
+	for _, pattern := range patterns {
+		if (pattern.Kind == "" || pattern.Kind == role.Kind) && matchesAny([]string{pattern.Name}, role.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAny returns true if a pattern matches the name, * matches any sequence of characters
+// including /
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		// path.Match does not match / with *, names of serviceaccounts contain it
+		if ok, _ := path.Match(strings.ReplaceAll(pattern, "/", "\x00"), strings.ReplaceAll(name, "/", "\x00")); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/interpreter"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+// policyRuleCostLimit limits the cost of the evaluation of a rule, it is evaluated at admission
+// and for every reconcile
+const policyRuleCostLimit = 1000000
+
+// policyRuleEnv declares the variables of the rules of a PermsPolicy
+var policyRuleEnv, policyRuleEnvErr = cel.NewEnv(
+	cel.Variable("object", cel.DynType),
+	cel.Variable("namespaceObject", cel.DynType),
+	cel.Variable("user", cel.DynType),
+)
+
+// compiledPolicyRule is the program of a rule or the error of its compilation
+type compiledPolicyRule struct {
+	ast *cel.Ast
+	prg cel.Program
+	err error
+}
+
+// policyRuleCache caches the compiled rules of the PermsPolicies by UID, the rules of a policy are
+// compiled again when its generation changes and removed when the policy is deleted
+var policyRuleCache = struct {
+	sync.Mutex
+	policies map[k8stypes.UID]compiledPolicy
+}{policies: map[k8stypes.UID]compiledPolicy{}}
+
+// compiledPolicy are the compiled rules of a generation of a PermsPolicy
+type compiledPolicy struct {
+	generation int64
+	rules      []compiledPolicyRule
+}
+
+// compilePolicyRule compiles the expression of a rule, it must return a bool
+func compilePolicyRule(expression string) (*cel.Ast, cel.Program, error) {
+	if policyRuleEnvErr != nil {
+		return nil, nil, policyRuleEnvErr
+	}
+	ast, issues := policyRuleEnv.Compile(expression)
+	if issues.Err() != nil {
+		return nil, nil, issues.Err()
+	}
+	if !ast.OutputType().IsExactType(cel.BoolType) && !ast.OutputType().IsExactType(cel.DynType) {
+		return nil, nil, fmt.Errorf("expression must return a bool, not %s", ast.OutputType())
+	}
+	prg, err := policyRuleEnv.Program(ast, cel.EvalOptions(cel.OptPartialEval, cel.OptTrackState), cel.CostLimit(policyRuleCostLimit))
+	if err != nil {
+		return nil, nil, err
+	}
+	return ast, prg, nil
+}
+
+// compiledRules returns the compiled rules of the policy, a policy without UID is not cached
+func (p *PermsPolicy) compiledRules() []compiledPolicyRule {
+	if p.UID != "" {
+		policyRuleCache.Lock()
+		defer policyRuleCache.Unlock()
+		if cached, ok := policyRuleCache.policies[p.UID]; ok && cached.generation == p.Generation && len(cached.rules) == len(p.Spec.Rules) {
+			return cached.rules
+		}
+	}
+	rules := make([]compiledPolicyRule, 0, len(p.Spec.Rules))
+	for _, rule := range p.Spec.Rules {
+		ast, prg, err := compilePolicyRule(rule.Expression)
+		rules = append(rules, compiledPolicyRule{ast: ast, prg: prg, err: err})
+	}
+	if p.UID != "" {
+		policyRuleCache.policies[p.UID] = compiledPolicy{generation: p.Generation, rules: rules}
+	}
+	return rules
+}
+
+// forgetDeletedPolicies removes the compiled rules of the policies which are not in the list of
+// all PermsPolicies
+func forgetDeletedPolicies(policies []PermsPolicy) {
+	uids := map[k8stypes.UID]bool{}
+	for i := range policies {
+		uids[policies[i].UID] = true
+	}
+	policyRuleCache.Lock()
+	defer policyRuleCache.Unlock()
+	for uid := range policyRuleCache.policies {
+		if !uids[uid] {
+			delete(policyRuleCache.policies, uid)
+		}
+	}
+}
+
+// violation evaluates the rule for every namespace of the request and returns the message if it
+// is violated. A rule which can not be evaluated is violated, a rule which depends on the
+// requesting user is skipped if the user is not known. A cluster-wide request grants the roles in
+// all namespaces, a rule which depends on the namespace is violated.
+func (r PolicyRule) violation(compiled compiledPolicyRule, req PolicyRequest) string {
+	message := r.Message
+	if message == "" {
+		message = "expression " + r.Expression + " returned false"
+	}
+	if compiled.err != nil {
+		return fmt.Sprintf("rule %s: %v", r.Name, compiled.err)
+	}
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(req.Object)
+	if err != nil {
+		return fmt.Sprintf("rule %s: %v", r.Name, err)
+	}
+
+	namespaces := req.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{""}
+	}
+	for _, namespace := range namespaces {
+		labels := req.NamespaceLabels[namespace]
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		vars := map[string]interface{}{
+			"object": object,
+			"namespaceObject": map[string]interface{}{
+				"metadata": map[string]interface{}{"name": namespace, "labels": labels},
+			},
+		}
+		var unknowns []*interpreter.AttributePattern
+		if req.ClusterWide {
+			delete(vars, "namespaceObject")
+			unknowns = append(unknowns, cel.AttributePattern("namespaceObject"))
+		}
+		if req.User != nil {
+			extra := map[string][]string{}
+			for key, values := range req.User.Extra {
+				extra[key] = values
+			}
+			groups := req.User.Groups
+			if groups == nil {
+				groups = []string{}
+			}
+			vars["user"] = map[string]interface{}{
+				"username": req.User.Username,
+				"uid":      req.User.UID,
+				"groups":   groups,
+				"extra":    extra,
+			}
+		} else {
+			unknowns = append(unknowns, cel.AttributePattern("user"))
+		}
+		activation, err := cel.PartialVars(vars, unknowns...)
+		if err != nil {
+			return fmt.Sprintf("rule %s: %v", r.Name, err)
+		}
+
+		out, details, err := compiled.prg.Eval(activation)
+		if err != nil {
+			return fmt.Sprintf("rule %s: %v", r.Name, err)
+		}
+		if types.IsUnknown(out) {
+			if req.ClusterWide && dependsOn(compiled.ast, details, "namespaceObject") {
+				return fmt.Sprintf("rule %s: %s (all namespaces)", r.Name, message)
+			}
+			continue
+		}
+		if allowed, ok := out.Value().(bool); !ok || !allowed {
+			if len(namespaces) > 1 {
+				return fmt.Sprintf("rule %s: %s (namespace %s)", r.Name, message, namespace)
+			}
+			return fmt.Sprintf("rule %s: %s", r.Name, message)
+		}
+	}
+	return ""
+}
+
+// dependsOn returns true if the residual of a partially evaluated rule references the variable,
+// a residual which can not be computed depends on it
+func dependsOn(ast *cel.Ast, details *cel.EvalDetails, variable string) bool {
+	residual, err := policyRuleEnv.ResidualAst(ast, details)
+	if err != nil {
+		return true
+	}
+	expression, err := cel.AstToString(residual)
+	if err != nil {
+		return true
+	}
+	return strings.Contains(expression, variable)
+}
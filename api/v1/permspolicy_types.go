@@ -0,0 +1,155 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PermsPolicySpec defines the roles and subjects which may be bound by PermsRoleBindings and
+// PermsClusterRoleBindings
+type PermsPolicySpec struct {
+	// Roles restricts the roles which may be bound.
+	//+optional
+	Roles RolePolicy `json:"roles,omitempty"`
+
+	// Users restricts the users which may get a role assigned.
+	//+optional
+	Users NamePolicy `json:"users,omitempty"`
+
+	// Groups restricts the groups which may get a role assigned.
+	//+optional
+	Groups NamePolicy `json:"groups,omitempty"`
+
+	// Serviceaccounts restricts the serviceaccounts which may get a role assigned, the patterns
+	// match namespace/name.
+	//+optional
+	Serviceaccounts NamePolicy `json:"serviceaccounts,omitempty"`
+
+	// ProtectedNamespaces are patterns of namespaces in which no roles may be bound.
+	//+optional
+	ProtectedNamespaces []string `json:"protectedNamespaces,omitempty"`
+
+	// MaxSubjects is the maximum number of subjects per PermsRoleBinding or
+	// PermsClusterRoleBinding, unlimited if not set.
+	//+kubebuilder:validation:Minimum=1
+	//+optional
+	MaxSubjects int32 `json:"maxSubjects,omitempty"`
+
+	// Rules are CEL expressions which must evaluate to true for a PermsRoleBinding or
+	// PermsClusterRoleBinding to be allowed.
+	//+optional
+	Rules []PolicyRule `json:"rules,omitempty"`
+
+	// TwoPersonApproval flags PermsRoleBindings and PermsClusterRoleBindings as sensitive, a
+	// change of their spec is applied after a user other than its author approved it.
+	//+optional
+	TwoPersonApproval *ApprovalPolicy `json:"twoPersonApproval,omitempty"`
+}
+
+// ApprovalPolicy matches the PermsRoleBindings and PermsClusterRoleBindings which need a second
+// user to approve a change. They match if one of their roles matches the roles and one of their
+// namespaces the namespaces, a PermsClusterRoleBinding which binds its role cluster-wide or by a
+// namespace selector matches all namespaces.
+type ApprovalPolicy struct {
+	// Roles are the sensitive roles, all roles if empty.
+	//+optional
+	Roles []RolePattern `json:"roles,omitempty"`
+
+	// Namespaces are patterns of the sensitive namespaces, all namespaces if empty.
+	//+optional
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+// PolicyRule is a custom rule of a PermsPolicy written as CEL expression. The expression is
+// evaluated with the variables object, the PermsRoleBinding or PermsClusterRoleBinding,
+// namespaceObject, the metadata.name and metadata.labels of the namespace the roles get bound in,
+// and user, the username, uid, groups and extra of the requesting user.
+type PolicyRule struct {
+	// Name of the rule, used in the violation messages.
+	Name string `json:"name"`
+
+	// Expression is a CEL expression which returns true if the resource is allowed. A
+	// PermsClusterRoleBinding is checked once per namespace it binds the role in, with an empty
+	// namespace if it creates a ClusterRoleBinding. The requesting user is only known at admission,
+	// expressions which depend on it are skipped when the operator reconciles.
+	Expression string `json:"expression"`
+
+	// Message is reported if the expression returns false.
+	//+optional
+	Message string `json:"message,omitempty"`
+}
+
+// RolePolicy defines the roles which may be bound, a role which matches a deny pattern is never allowed
+type RolePolicy struct {
+	// Allow are the roles which may be bound, all roles if empty.
+	//+optional
+	Allow []RolePattern `json:"allow,omitempty"`
+
+	// Deny are the roles which may not be bound.
+	//+optional
+	Deny []RolePattern `json:"deny,omitempty"`
+}
+
+// RolePattern matches roles by kind and name
+type RolePattern struct {
+	// Kind of the role, Role or ClusterRole. Matches both kinds if not set.
+	//+kubebuilder:validation:Enum=Role;ClusterRole
+	//+optional
+	Kind string `json:"kind,omitempty"`
+
+	// Name is a pattern of the role name, * matches any sequence of characters.
+	Name string `json:"name"`
+}
+
+// NamePolicy defines the subjects which may get a role assigned, a subject which matches a deny
+// pattern is never allowed
+type NamePolicy struct {
+	// Allow are patterns of the subjects which may get a role assigned, all subjects if empty.
+	//+optional
+	Allow []string `json:"allow,omitempty"`
+
+	// Deny are patterns of the subjects which may not get a role assigned.
+	//+optional
+	Deny []string `json:"deny,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:shortName=ppol,scope=Cluster
+//+kubebuilder:printcolumn:name=Max Subjects,type=integer,JSONPath=".spec.maxSubjects"
+//+kubebuilder:printcolumn:name=Age,type=date,JSONPath=".metadata.creationTimestamp"
+
+// PermsPolicy is the Schema for the permspolicies API
+type PermsPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PermsPolicySpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PermsPolicyList contains a list of PermsPolicy
+type PermsPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PermsPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PermsPolicy{}, &PermsPolicyList{})
+}
@@ -0,0 +1,289 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// log is for logging in this package.
+var permspolicylog = logf.Log.WithName("permspolicy-resource")
+
+// policyWebhookPath is the path of the webhook which checks PermsRoleBindings and
+// PermsClusterRoleBindings against the PermsPolicies
+const policyWebhookPath = "/validate-perms-infra-mgmt-io-v1-policy"
+
+func (r *PermsPolicy) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(policyWebhookPath, &webhook.Admission{Handler: &PolicyValidator{Client: mgr.GetClient()}})
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-perms-infra-mgmt-io-v1-permspolicy,mutating=false,failurePolicy=fail,sideEffects=None,groups=perms.infra-mgmt.io,resources=permspolicies,verbs=create;update,versions=v1,name=vpermspolicy.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &PermsPolicy{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *PermsPolicy) ValidateCreate() error {
+	permspolicylog.Info("validate create", "name", r.Name)
+
+	return r.validatePermsPolicy()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *PermsPolicy) ValidateUpdate(old runtime.Object) error {
+	permspolicylog.Info("validate update", "name", r.Name)
+
+	return r.validatePermsPolicy()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *PermsPolicy) ValidateDelete() error {
+	permspolicylog.Info("validate delete", "name", r.Name)
+
+	// deleting a PermsPolicy is always allowed
+	return nil
+}
+
+// validatePermsPolicy rejects invalid patterns
+func (r *PermsPolicy) validatePermsPolicy() error {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	for i, pattern := range r.Spec.Roles.Allow {
+		allErrs = append(allErrs, validatePatterns(specPath.Child("roles", "allow").Index(i).Child("name"), []string{pattern.Name})...)
+	}
+	for i, pattern := range r.Spec.Roles.Deny {
+		allErrs = append(allErrs, validatePatterns(specPath.Child("roles", "deny").Index(i).Child("name"), []string{pattern.Name})...)
+	}
+	for name, policy := range map[string]NamePolicy{"users": r.Spec.Users, "groups": r.Spec.Groups, "serviceaccounts": r.Spec.Serviceaccounts} {
+		allErrs = append(allErrs, validatePatterns(specPath.Child(name, "allow"), policy.Allow)...)
+		allErrs = append(allErrs, validatePatterns(specPath.Child(name, "deny"), policy.Deny)...)
+	}
+	allErrs = append(allErrs, validatePatterns(specPath.Child("protectedNamespaces"), r.Spec.ProtectedNamespaces)...)
+	allErrs = append(allErrs, validateRules(specPath.Child("rules"), r.Spec.Rules)...)
+	if approval := r.Spec.TwoPersonApproval; approval != nil {
+		approvalPath := specPath.Child("twoPersonApproval")
+		if len(approval.Roles) == 0 && len(approval.Namespaces) == 0 {
+			allErrs = append(allErrs, field.Required(approvalPath, "roles or namespaces are required"))
+		}
+		for i, pattern := range approval.Roles {
+			allErrs = append(allErrs, validatePatterns(approvalPath.Child("roles").Index(i).Child("name"), []string{pattern.Name})...)
+		}
+		allErrs = append(allErrs, validatePatterns(approvalPath.Child("namespaces"), approval.Namespaces)...)
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: GroupVersion.Group, Kind: "PermsPolicy"},
+		r.Name, allErrs)
+}
+
+// validateRules rejects rules without or with duplicate names and expressions which do not compile
+func validateRules(fldPath *field.Path, rules []PolicyRule) field.ErrorList {
+	var allErrs field.ErrorList
+	seen := map[string]bool{}
+	for i, rule := range rules {
+		idxPath := fldPath.Index(i)
+		if rule.Name == "" {
+			allErrs = append(allErrs, field.Required(idxPath.Child("name"), "name must not be empty"))
+		} else if seen[rule.Name] {
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("name"), rule.Name))
+		}
+		seen[rule.Name] = true
+		if rule.Expression == "" {
+			allErrs = append(allErrs, field.Required(idxPath.Child("expression"), "expression must not be empty"))
+			continue
+		}
+		if _, _, err := compilePolicyRule(rule.Expression); err != nil {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("expression"), rule.Expression, err.Error()))
+		}
+	}
+	return allErrs
+}
+
+// validatePatterns rejects empty and malformed patterns
+func validatePatterns(fldPath *field.Path, patterns []string) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, pattern := range patterns {
+		if pattern == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Index(i), "pattern must not be empty"))
+			continue
+		}
+		if _, err := path.Match(strings.ReplaceAll(pattern, "/", "\x00"), ""); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Index(i), pattern, err.Error()))
+		}
+	}
+	return allErrs
+}
+
+//+kubebuilder:webhook:path=/validate-perms-infra-mgmt-io-v1-policy,mutating=false,failurePolicy=fail,sideEffects=None,groups=perms.infra-mgmt.io,resources=permsrolebindings;permsclusterrolebindings,verbs=create;update,versions=v1,name=vpermspolicyenforcement.kb.io,admissionReviewVersions=v1
+
+//+kubebuilder:object:generate=false
+
+// PolicyValidator rejects PermsRoleBindings and PermsClusterRoleBindings which violate a PermsPolicy
+// and PermsRoleBindings which no PermsDelegation of their namespace allows
+type PolicyValidator struct {
+	Client  client.Reader
+	decoder *admission.Decoder
+}
+
+var _ admission.DecoderInjector = &PolicyValidator{}
+
+// InjectDecoder implements admission.DecoderInjector
+func (v *PolicyValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+// Handle checks the roles and subjects of the object against all PermsPolicies
+func (v *PolicyValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if finalizersOnly(req) {
+		return admission.Allowed("")
+	}
+	var policyReq PolicyRequest
+	switch req.Kind.Kind {
+	case "PermsRoleBinding":
+		prb := &PermsRoleBinding{}
+		if err := v.decoder.Decode(req, prb); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		policyReq = prb.PolicyRequest()
+	case "PermsClusterRoleBinding":
+		pcrb := &PermsClusterRoleBinding{}
+		if err := v.decoder.Decode(req, pcrb); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		policyReq = pcrb.PolicyRequest()
+		if pcrb.Spec.NamespaceSelector != nil {
+			namespaces, err := v.selectedNamespaces(ctx, pcrb.Spec.NamespaceSelector)
+			if err != nil {
+				return admission.Errored(http.StatusInternalServerError, err)
+			}
+			policyReq.Namespaces = append(policyReq.Namespaces, namespaces...)
+		}
+	default:
+		return admission.Allowed("")
+	}
+
+	policies := &PermsPolicyList{}
+	if err := v.Client.List(ctx, policies); err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	labels, err := v.namespaceLabels(ctx, policyReq.Namespaces)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	policyReq.NamespaceLabels = labels
+	policyReq.User = &req.UserInfo
+	violations := CheckPolicies(policies.Items, policyReq)
+	if req.Kind.Kind == "PermsRoleBinding" {
+		delegations := &PermsDelegationList{}
+		if err := v.Client.List(ctx, delegations, client.InNamespace(req.Namespace)); err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+		violations = append(violations, CheckDelegations(delegations.Items, policyReq)...)
+	}
+	if len(violations) > 0 {
+		permspolicylog.Info("policy violation", "kind", req.Kind.Kind, "name", req.Name, "namespace", req.Namespace, "violations", violations)
+		// admission.Denied only sets the reason, the API server shows the message to the client
+		return admission.Response{AdmissionResponse: admissionv1.AdmissionResponse{
+			Allowed: false,
+			Result: &metav1.Status{
+				Code:    http.StatusForbidden,
+				Reason:  metav1.StatusReasonForbidden,
+				Message: strings.Join(violations, "; "),
+			},
+		}}
+	}
+	return admission.Allowed("")
+}
+
+// namespaceLabels returns the labels of the namespaces, namespaces which do not exist have no labels
+func (v *PolicyValidator) namespaceLabels(ctx context.Context, names []string) (map[string]map[string]string, error) {
+	labels := map[string]map[string]string{}
+	for _, name := range names {
+		ns := &corev1.Namespace{}
+		if err := v.Client.Get(ctx, client.ObjectKey{Name: name}, ns); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		labels[name] = ns.Labels
+	}
+	return labels, nil
+}
+
+// selectedNamespaces returns the names of the namespaces matched by a namespace selector
+func (v *PolicyValidator) selectedNamespaces(ctx context.Context, selector *metav1.LabelSelector) ([]string, error) {
+	sel, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	list := &corev1.NamespaceList{}
+	if err := v.Client.List(ctx, list, &client.ListOptions{LabelSelector: sel}); err != nil {
+		return nil, err
+	}
+	var namespaces []string
+	for _, ns := range list.Items {
+		namespaces = append(namespaces, ns.Name)
+	}
+	return namespaces, nil
+}
+
+// finalizersOnly returns true if an update changes nothing but the finalizers, controllers must be
+// able to remove their finalizers from resources which no longer pass the checks
+func finalizersOnly(req admission.Request) bool {
+	if req.Operation != admissionv1.Update {
+		return false
+	}
+	var obj, old map[string]interface{}
+	if json.Unmarshal(req.Object.Raw, &obj) != nil || json.Unmarshal(req.OldObject.Raw, &old) != nil {
+		return false
+	}
+	for _, o := range []map[string]interface{}{obj, old} {
+		delete(o, "status")
+		if metadata, ok := o["metadata"].(map[string]interface{}); ok {
+			for _, field := range []string{"finalizers", "managedFields", "resourceVersion", "generation"} {
+				delete(metadata, field)
+			}
+		}
+	}
+	return equality.Semantic.DeepEqual(obj, old)
+}
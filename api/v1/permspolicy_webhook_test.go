@@ -0,0 +1,308 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var _ = Describe("permspolicy webhook", func() {
+	newPermsPolicy := func() *PermsPolicy {
+		return &PermsPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "restricted"},
+			Spec: PermsPolicySpec{
+				Roles: RolePolicy{
+					Deny: []RolePattern{{Kind: "ClusterRole", Name: "cluster-admin"}, {Name: "system:*"}},
+				},
+				Groups:              NamePolicy{Allow: []string{"team-*"}},
+				Serviceaccounts:     NamePolicy{Deny: []string{"kube-system/*"}},
+				ProtectedNamespaces: []string{"kube-*"},
+				MaxSubjects:         3,
+			},
+		}
+	}
+	newPermsRoleBinding := func() *PermsRoleBinding {
+		return &PermsRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "testing"},
+			Spec: PermsRoleBindingSpec{
+				Kind:            "ClusterRole",
+				Role:            "view",
+				Groups:          []string{"team-a"},
+				Users:           []string{"user1"},
+				Serviceaccounts: []Serviceaccount{{Name: "default", Namespace: "testing"}},
+			},
+		}
+	}
+
+	Context("checking a PermsRoleBinding against a PermsPolicy", func() {
+
+		It("should allow roles and subjects which match the policy", func() {
+			Expect(newPermsPolicy().Violations(newPermsRoleBinding().PolicyRequest())).To(BeEmpty())
+		})
+
+		It("should report denied and not allowed roles and subjects", func() {
+			prb := newPermsRoleBinding()
+			prb.Spec.Role = "cluster-admin"
+			prb.Spec.Roles = []RoleSpec{{Kind: "Role", Name: "system:controller"}}
+			prb.Spec.Groups = []string{"admins"}
+			prb.Spec.Serviceaccounts = []Serviceaccount{{Name: "default", Namespace: "kube-system"}}
+			Expect(newPermsPolicy().Violations(prb.PolicyRequest())).To(Equal([]string{
+				"ClusterRole cluster-admin is denied",
+				"Role system:controller is denied",
+				"group admins is not allowed",
+				"serviceaccount kube-system/default is denied",
+			}))
+		})
+
+		It("should report protected namespaces and too many subjects", func() {
+			prb := newPermsRoleBinding()
+			prb.Namespace = "kube-public"
+			prb.Spec.Users = []string{"user1", "user2"}
+			Expect(CheckPolicies([]PermsPolicy{*newPermsPolicy()}, prb.PolicyRequest())).To(Equal([]string{
+				"PermsPolicy restricted: namespace kube-public is protected",
+				"PermsPolicy restricted: 4 subjects exceed the maximum of 3",
+			}))
+		})
+
+		It("should report protected namespaces for a cluster-wide PermsClusterRoleBinding", func() {
+			pcrb := &PermsClusterRoleBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: "demo"},
+				Spec:       PermsClusterRoleBindingSpec{Role: "view", Groups: []string{"team-a"}},
+			}
+			Expect(newPermsPolicy().Violations(pcrb.PolicyRequest())).To(Equal([]string{
+				"namespaces kube-* are protected and a ClusterRoleBinding grants the roles in all namespaces",
+			}))
+			pcrb.Spec.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}}
+			req := pcrb.PolicyRequest()
+			req.Namespaces = []string{"team-a", "kube-system"}
+			Expect(newPermsPolicy().Violations(req)).To(Equal([]string{"namespace kube-system is protected"}))
+		})
+	})
+
+	Context("evaluating the rules of a PermsPolicy", func() {
+		newRulePolicy := func() *PermsPolicy {
+			return &PermsPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "rules"},
+				Spec: PermsPolicySpec{Rules: []PolicyRule{
+					{
+						Name:       "team-groups",
+						Expression: "!('env' in namespaceObject.metadata.labels && namespaceObject.metadata.labels['env'] == 'prod') || !has(object.spec.groups) || object.spec.groups.all(g, g.startsWith('team-'))",
+						Message:    "groups in prod namespaces must start with team-",
+					},
+					{
+						Name:       "own-serviceaccounts",
+						Expression: "object.kind != 'PermsRoleBinding' || !has(object.spec.serviceaccounts) || object.spec.serviceaccounts.all(sa, sa.namespace == object.metadata.namespace)",
+					},
+					{
+						Name:       "no-self-binding",
+						Expression: "!has(object.spec.users) || !(user.username in object.spec.users)",
+						Message:    "users may not bind roles to themselves",
+					},
+				}},
+			}
+		}
+		newRuleRequest := func(prb *PermsRoleBinding) PolicyRequest {
+			prb.Kind = "PermsRoleBinding"
+			req := prb.PolicyRequest()
+			req.NamespaceLabels = map[string]map[string]string{"testing": {"env": "prod"}}
+			return req
+		}
+
+		It("should allow a PermsRoleBinding which satisfies the rules", func() {
+			Expect(newRulePolicy().Violations(newRuleRequest(newPermsRoleBinding()))).To(BeEmpty())
+		})
+
+		It("should report the message of each violated rule", func() {
+			prb := newPermsRoleBinding()
+			prb.Spec.Groups = []string{"admins"}
+			prb.Spec.Serviceaccounts = []Serviceaccount{{Name: "default", Namespace: "other"}}
+			Expect(newRulePolicy().Violations(newRuleRequest(prb))).To(Equal([]string{
+				"rule team-groups: groups in prod namespaces must start with team-",
+				"rule own-serviceaccounts: expression " + newRulePolicy().Spec.Rules[1].Expression + " returned false",
+			}))
+		})
+
+		It("should only evaluate rules which depend on the user at admission", func() {
+			req := newRuleRequest(newPermsRoleBinding())
+			Expect(newRulePolicy().Violations(req)).To(BeEmpty())
+			req.User = &authenticationv1.UserInfo{Username: "user1"}
+			Expect(newRulePolicy().Violations(req)).To(Equal([]string{"rule no-self-binding: users may not bind roles to themselves"}))
+		})
+
+		It("should report the namespace of a PermsClusterRoleBinding which violates a rule", func() {
+			pcrb := &PermsClusterRoleBinding{
+				TypeMeta:   metav1.TypeMeta{Kind: "PermsClusterRoleBinding"},
+				ObjectMeta: metav1.ObjectMeta{Name: "demo"},
+				Spec: PermsClusterRoleBindingSpec{
+					Role:       "view",
+					Groups:     []string{"admins"},
+					Namespaces: []string{"dev", "prod"},
+				},
+			}
+			req := pcrb.PolicyRequest()
+			req.NamespaceLabels = map[string]map[string]string{"dev": {"env": "dev"}, "prod": {"env": "prod"}}
+			Expect(newRulePolicy().Violations(req)).To(Equal([]string{
+				"rule team-groups: groups in prod namespaces must start with team- (namespace prod)",
+			}))
+		})
+
+		It("should report a rule which depends on the namespace for a cluster-wide PermsClusterRoleBinding", func() {
+			pcrb := &PermsClusterRoleBinding{
+				TypeMeta:   metav1.TypeMeta{Kind: "PermsClusterRoleBinding"},
+				ObjectMeta: metav1.ObjectMeta{Name: "demo"},
+				Spec:       PermsClusterRoleBindingSpec{Role: "view", Groups: []string{"admins"}},
+			}
+			Expect(newRulePolicy().Violations(pcrb.PolicyRequest())).To(Equal([]string{
+				"rule team-groups: groups in prod namespaces must start with team- (all namespaces)",
+			}))
+			pcrb.Spec.Groups = []string{"team-a"}
+			Expect(newRulePolicy().Violations(pcrb.PolicyRequest())).To(BeEmpty())
+		})
+
+		It("should compile the rules again for a new generation of the policy", func() {
+			policy := newRulePolicy()
+			policy.UID = "4a1f6c7e-rules"
+			policy.Generation = 1
+			Expect(policy.Violations(newRuleRequest(newPermsRoleBinding()))).To(BeEmpty())
+			policy.Spec.Rules = []PolicyRule{{Name: "deny", Expression: "false"}}
+			policy.Generation = 2
+			Expect(policy.Violations(newRuleRequest(newPermsRoleBinding()))).To(Equal([]string{
+				"rule deny: expression false returned false",
+			}))
+			Expect(policyRuleCache.policies[policy.UID].generation).To(Equal(int64(2)))
+			CheckPolicies(nil, newRuleRequest(newPermsRoleBinding()))
+			Expect(policyRuleCache.policies).NotTo(HaveKey(policy.UID))
+		})
+	})
+
+	Context("validating a PermsPolicy", func() {
+
+		It("should accept valid patterns", func() {
+			Expect(newPermsPolicy().ValidateCreate()).To(Succeed())
+		})
+
+		It("should reject empty and malformed patterns", func() {
+			policy := newPermsPolicy()
+			policy.Spec.Users.Deny = []string{"[user"}
+			policy.Spec.ProtectedNamespaces = []string{""}
+			err := policy.ValidateUpdate(newPermsPolicy())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.users.deny[0]"))
+			Expect(err.Error()).To(ContainSubstring("spec.protectedNamespaces[0]"))
+		})
+
+		It("should reject a two-person approval without roles and namespaces", func() {
+			policy := newPermsPolicy()
+			policy.Spec.TwoPersonApproval = &ApprovalPolicy{}
+			err := policy.ValidateCreate()
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.twoPersonApproval: Required value"))
+
+			policy.Spec.TwoPersonApproval.Roles = []RolePattern{{Name: "[admin"}}
+			Expect(policy.ValidateCreate().Error()).To(ContainSubstring("spec.twoPersonApproval.roles[0].name"))
+		})
+
+		It("should reject rules which do not compile or return no bool", func() {
+			policy := newPermsPolicy()
+			policy.Spec.Rules = []PolicyRule{
+				{Name: "syntax", Expression: "object.spec.groups.all(g,"},
+				{Name: "unknown", Expression: "subject.name == 'admin'"},
+				{Name: "string", Expression: "'team-a'"},
+				{Name: "string", Expression: "true"},
+			}
+			err := policy.ValidateCreate()
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.rules[0].expression"))
+			Expect(err.Error()).To(ContainSubstring("spec.rules[1].expression"))
+			Expect(err.Error()).To(ContainSubstring("spec.rules[2].expression"))
+			Expect(err.Error()).To(ContainSubstring("spec.rules[3].name"))
+		})
+	})
+
+	Context("enforcing the PermsPolicies at admission", func() {
+		newValidator := func(objs ...runtime.Object) *PolicyValidator {
+			scheme := runtime.NewScheme()
+			Expect(AddToScheme(scheme)).To(Succeed())
+			Expect(corev1.AddToScheme(scheme)).To(Succeed())
+			decoder, err := admission.NewDecoder(scheme)
+			Expect(err).NotTo(HaveOccurred())
+			v := &PolicyValidator{Client: fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objs...).Build()}
+			Expect(v.InjectDecoder(decoder)).To(Succeed())
+			return v
+		}
+		requestFor := func(prb *PermsRoleBinding) admission.Request {
+			raw, err := json.Marshal(prb)
+			Expect(err).NotTo(HaveOccurred())
+			return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Create,
+				Kind:      metav1.GroupVersionKind{Group: GroupVersion.Group, Version: GroupVersion.Version, Kind: "PermsRoleBinding"},
+				Name:      prb.Name,
+				Namespace: prb.Namespace,
+				Object:    runtime.RawExtension{Raw: raw},
+			}}
+		}
+
+		It("should allow a PermsRoleBinding without policies", func() {
+			prb := newPermsRoleBinding()
+			prb.Spec.Role = "cluster-admin"
+			Expect(newValidator().Handle(context.Background(), requestFor(prb)).Allowed).To(BeTrue())
+		})
+
+		It("should deny a PermsRoleBinding which violates a policy", func() {
+			prb := newPermsRoleBinding()
+			prb.Spec.Role = "cluster-admin"
+			response := newValidator(newPermsPolicy()).Handle(context.Background(), requestFor(prb))
+			Expect(response.Allowed).To(BeFalse())
+			Expect(response.Result.Message).To(ContainSubstring("PermsPolicy restricted: ClusterRole cluster-admin is denied"))
+		})
+
+		It("should evaluate the rules with the namespace labels and the requesting user", func() {
+			policy := &PermsPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "prod"},
+				Spec: PermsPolicySpec{Rules: []PolicyRule{{
+					Name:       "prod-admins",
+					Expression: "namespaceObject.metadata.labels['env'] != 'prod' || 'prod-admins' in user.groups",
+					Message:    "only prod-admins may bind roles in prod namespaces",
+				}}},
+			}
+			namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "testing", Labels: map[string]string{"env": "prod"}}}
+			req := requestFor(newPermsRoleBinding())
+			req.UserInfo = authenticationv1.UserInfo{Username: "user2", Groups: []string{"developers"}}
+			response := newValidator(policy, namespace).Handle(context.Background(), req)
+			Expect(response.Allowed).To(BeFalse())
+			Expect(response.Result.Message).To(Equal("PermsPolicy prod: rule prod-admins: only prod-admins may bind roles in prod namespaces"))
+
+			req.UserInfo.Groups = []string{"prod-admins"}
+			Expect(newValidator(policy, namespace).Handle(context.Background(), req).Allowed).To(BeTrue())
+		})
+
+		It("should not check an update which only removes a finalizer", func() {
+			prb := newPermsRoleBinding()
+			prb.Spec.Role = "cluster-admin"
+			old := prb.DeepCopy()
+			old.Finalizers = []string{"example.com/cleanup"}
+			raw, err := json.Marshal(old)
+			Expect(err).NotTo(HaveOccurred())
+			req := requestFor(prb)
+			req.Operation = admissionv1.Update
+			req.OldObject = runtime.RawExtension{Raw: raw}
+			Expect(newValidator(newPermsPolicy()).Handle(context.Background(), req).Allowed).To(BeTrue())
+
+			prb.Labels = map[string]string{"team": "platform"}
+			raw, err = json.Marshal(prb)
+			Expect(err).NotTo(HaveOccurred())
+			req.Object = runtime.RawExtension{Raw: raw}
+			Expect(newValidator(newPermsPolicy()).Handle(context.Background(), req).Allowed).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,191 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+)
+
+// ApproveAnnotation approves the change of a sensitive PermsRoleBinding or
+// PermsClusterRoleBinding, the value is the generation of the approved spec
+const ApproveAnnotation = "perms.infra-mgmt.io/approve"
+
+// ApprovedByAnnotation records the user who set the approve annotation, set by the webhook
+const ApprovedByAnnotation = "perms.infra-mgmt.io/approved-by"
+
+// Approver returns the user who approved the current spec, empty if the spec is not approved or
+// approved by its author
+func (r *PermsRoleBinding) Approver() string {
+	return approver(r.Annotations, r.Generation)
+}
+
+// Approver returns the user who approved the current spec, empty if the spec is not approved or
+// approved by its author
+func (r *PermsClusterRoleBinding) Approver() string {
+	return approver(r.Annotations, r.Generation)
+}
+
+// approver returns the user who approved the generation, empty if the annotations approve another
+// generation or an author of the change approved it
+func approver(annotations map[string]string, generation int64) string {
+	approver := annotations[ApprovedByAnnotation]
+	if annotations[ApproveAnnotation] != strconv.FormatInt(generation, 10) || approver == annotations[AuthorAnnotation] ||
+		containsString(ChangeAuthors(annotations), approver) {
+		return ""
+	}
+	return approver
+}
+
+// ChangeAuthors returns the users who changed the spec since it was last approved, the author of
+// the last change if the annotation is missing
+func ChangeAuthors(annotations map[string]string) []string {
+	var authors []string
+	if err := json.Unmarshal([]byte(annotations[ChangeAuthorsAnnotation]), &authors); err != nil || len(authors) == 0 {
+		return []string{annotations[AuthorAnnotation]}
+	}
+	return authors
+}
+
+// SpecChanges summarizes the differences of a spec to the approved spec, all of the spec if
+// nothing was approved yet
+func SpecChanges(approved *PermsRoleBindingSpec, spec PermsRoleBindingSpec) []string {
+	if approved == nil {
+		approved = &PermsRoleBindingSpec{}
+	}
+	var changes []string
+	if approved.Kind != spec.Kind || approved.Role != spec.Role {
+		changes = append(changes, fmt.Sprintf("role %s -> %s", roleString(approved.Kind, approved.Role), roleString(spec.Kind, spec.Role)))
+	}
+	changes = append(changes, nameChanges("roles", roleSpecNames(approved.Roles), roleSpecNames(spec.Roles))...)
+	changes = append(changes, nameChanges("users", approved.Users, spec.Users)...)
+	changes = append(changes, nameChanges("groups", approved.Groups, spec.Groups)...)
+	if serviceaccountChanges := nameChanges("serviceaccounts", serviceaccountNames(approved.Serviceaccounts), serviceaccountNames(spec.Serviceaccounts)); len(serviceaccountChanges) > 0 {
+		changes = append(changes, serviceaccountChanges...)
+	} else if !equality.Semantic.DeepEqual(approved.Serviceaccounts, spec.Serviceaccounts) {
+		changes = append(changes, "serviceaccount expiries changed")
+	}
+	if !equality.Semantic.DeepEqual(approved.Validity, spec.Validity) {
+		changes = append(changes, "validity changed")
+	}
+	if approved.ImmutableRoleRef != spec.ImmutableRoleRef {
+		changes = append(changes, fmt.Sprintf("immutableRoleRef %t -> %t", approved.ImmutableRoleRef, spec.ImmutableRoleRef))
+	}
+	if approved.AdoptionPolicy != spec.AdoptionPolicy {
+		changes = append(changes, fmt.Sprintf("adoptionPolicy %q -> %q", approved.AdoptionPolicy, spec.AdoptionPolicy))
+	}
+	if approved.PinRoleRules != spec.PinRoleRules {
+		changes = append(changes, fmt.Sprintf("pinRoleRules %t -> %t", approved.PinRoleRules, spec.PinRoleRules))
+	}
+	return changes
+}
+
+// ClusterSpecChanges summarizes the differences of a spec to the approved spec, all of the spec if
+// nothing was approved yet
+func ClusterSpecChanges(approved *PermsClusterRoleBindingSpec, spec PermsClusterRoleBindingSpec) []string {
+	if approved == nil {
+		approved = &PermsClusterRoleBindingSpec{}
+	}
+	var changes []string
+	if approved.Role != spec.Role {
+		changes = append(changes, fmt.Sprintf("role %s -> %s", roleString("ClusterRole", approved.Role), roleString("ClusterRole", spec.Role)))
+	}
+	changes = append(changes, nameChanges("users", approved.Users, spec.Users)...)
+	changes = append(changes, nameChanges("groups", approved.Groups, spec.Groups)...)
+	if serviceaccountChanges := nameChanges("serviceaccounts", serviceaccountNames(approved.Serviceaccounts), serviceaccountNames(spec.Serviceaccounts)); len(serviceaccountChanges) > 0 {
+		changes = append(changes, serviceaccountChanges...)
+	} else if !equality.Semantic.DeepEqual(approved.Serviceaccounts, spec.Serviceaccounts) {
+		changes = append(changes, "serviceaccount expiries changed")
+	}
+	changes = append(changes, nameChanges("namespaces", approved.Namespaces, spec.Namespaces)...)
+	if !equality.Semantic.DeepEqual(approved.NamespaceSelector, spec.NamespaceSelector) {
+		changes = append(changes, "namespaceSelector changed")
+	}
+	if !equality.Semantic.DeepEqual(approved.Validity, spec.Validity) {
+		changes = append(changes, "validity changed")
+	}
+	if approved.ImmutableRoleRef != spec.ImmutableRoleRef {
+		changes = append(changes, fmt.Sprintf("immutableRoleRef %t -> %t", approved.ImmutableRoleRef, spec.ImmutableRoleRef))
+	}
+	if approved.AdoptionPolicy != spec.AdoptionPolicy {
+		changes = append(changes, fmt.Sprintf("adoptionPolicy %q -> %q", approved.AdoptionPolicy, spec.AdoptionPolicy))
+	}
+	if approved.PinRoleRules != spec.PinRoleRules {
+		changes = append(changes, fmt.Sprintf("pinRoleRules %t -> %t", approved.PinRoleRules, spec.PinRoleRules))
+	}
+	return changes
+}
+
+// nameChanges returns the names added to and removed from a list
+func nameChanges(kind string, approved []string, names []string) []string {
+	previous := map[string]bool{}
+	for _, name := range approved {
+		previous[name] = true
+	}
+	current := map[string]bool{}
+	var added []string
+	for _, name := range names {
+		current[name] = true
+		if !previous[name] {
+			added = append(added, name)
+		}
+	}
+	var removed []string
+	for _, name := range approved {
+		if !current[name] {
+			removed = append(removed, name)
+		}
+	}
+	var changes []string
+	if len(added) > 0 {
+		changes = append(changes, kind+" added: "+strings.Join(added, ", "))
+	}
+	if len(removed) > 0 {
+		changes = append(changes, kind+" removed: "+strings.Join(removed, ", "))
+	}
+	return changes
+}
+
+// roleString returns a role as Kind/name
+func roleString(kind string, name string) string {
+	if name == "" {
+		return "none"
+	}
+	return kind + "/" + name
+}
+
+// roleSpecNames returns the additional roles as Kind/name
+func roleSpecNames(roles []RoleSpec) []string {
+	var names []string
+	for _, role := range roles {
+		names = append(names, roleString(role.Kind, role.Name))
+	}
+	return names
+}
+
+// serviceaccountNames returns the serviceaccounts as namespace/name
+func serviceaccountNames(serviceaccounts []Serviceaccount) []string {
+	var names []string
+	for _, sa := range serviceaccounts {
+		names = append(names, sa.Namespace+"/"+sa.Name)
+	}
+	return names
+}
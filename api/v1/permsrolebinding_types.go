@@ -0,0 +1,294 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PermsRoleBindingSpec defines the desired state of PermsRoleBinding
+type PermsRoleBindingSpec struct {
+	// Kind of the referenced role, Role or ClusterRole. Defaults to ClusterRole.
+	//+kubebuilder:default=ClusterRole
+	//+kubebuilder:validation:Enum=Role;ClusterRole
+	//+optional
+	Kind string `json:"kind,omitempty"`
+
+	// Role is the name of the referenced role.
+	Role string `json:"role"`
+
+	// Roles are additional roles which get assigned to the same subjects.
+	// Each entry produces its own RoleBinding.
+	//+optional
+	Roles []RoleSpec `json:"roles,omitempty"`
+
+	// Groups get the referenced role assigned.
+	//+optional
+	Groups []string `json:"groups,omitempty"`
+
+	// Users get the referenced role assigned.
+	//+optional
+	Users []string `json:"users,omitempty"`
+
+	// Serviceaccounts get the referenced role assigned.
+	//+optional
+	Serviceaccounts []Serviceaccount `json:"serviceaccounts,omitempty"`
+
+	// ImmutableRoleRef disables the role switch. A changed role is not applied and the
+	// resource is set to Degraded instead.
+	//+optional
+	ImmutableRoleRef bool `json:"immutableRoleRef,omitempty"`
+
+	// AdoptionPolicy defines how existing bindings which are not managed by the operator are
+	// handled. Never sets the Conflict condition and leaves them untouched, Adopt takes them
+	// over. Defaults to Never.
+	//+kubebuilder:validation:Enum=Never;Adopt
+	//+optional
+	AdoptionPolicy string `json:"adoptionPolicy,omitempty"`
+
+	// PinRoleRules holds the reconcile when the rules of a referenced role change until the
+	// change is acknowledged with the perms.infra-mgmt.io/role-rules-ack annotation.
+	//+optional
+	PinRoleRules bool `json:"pinRoleRules,omitempty"`
+
+	Validity `json:",inline"`
+}
+
+// RoleSpec references an additional role of a PermsRoleBinding
+type RoleSpec struct {
+	// Kind of the referenced role, Role or ClusterRole. Defaults to ClusterRole.
+	//+kubebuilder:default=ClusterRole
+	//+kubebuilder:validation:Enum=Role;ClusterRole
+	//+optional
+	Kind string `json:"kind,omitempty"`
+
+	// Name of the referenced role.
+	Name string `json:"name"`
+}
+
+// Serviceaccount references a serviceaccount subject
+type Serviceaccount struct {
+	Name string `json:"name"`
+	// Namespace of the serviceaccount. Defaults to the namespace of a PermsRoleBinding,
+	// required for a PermsClusterRoleBinding.
+	//+optional
+	Namespace string `json:"namespace,omitempty"`
+	// ExpiresAt is the time from which the serviceaccount loses the role.
+	//+optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+}
+
+// Validity limits the time in which the subjects get the role assigned
+type Validity struct {
+	// NotBefore is the time from which the subjects get the role assigned.
+	//+optional
+	NotBefore *metav1.Time `json:"notBefore,omitempty"`
+
+	// ExpiresAt is the time from which the subjects lose the role again.
+	//+optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// UserExpiry maps users to the time from which they lose the role.
+	//+optional
+	UserExpiry map[string]metav1.Time `json:"userExpiry,omitempty"`
+
+	// GroupExpiry maps groups to the time from which they lose the role.
+	//+optional
+	GroupExpiry map[string]metav1.Time `json:"groupExpiry,omitempty"`
+}
+
+// PermsRoleBindingStatus defines the observed state of PermsRoleBinding
+type PermsRoleBindingStatus struct {
+	// Bindings are the RoleBindings managed for this PermsRoleBinding, one per role.
+	//+optional
+	Bindings []BindingReference `json:"bindings,omitempty"`
+
+	// Count is the number of subjects per subject kind.
+	//+optional
+	Count SubjectCount `json:"count,omitempty"`
+
+	// Expirations are the subjects with an expiry which have the role assigned, the next
+	// expiration first.
+	//+optional
+	Expirations []SubjectExpiration `json:"expirations,omitempty"`
+
+	// NextTransitionTime is the next time the assigned subjects change.
+	//+optional
+	NextTransitionTime *metav1.Time `json:"nextTransitionTime,omitempty"`
+
+	// DriftCount is the number of times a managed binding was changed outside of the
+	// operator and restored.
+	//+optional
+	DriftCount int32 `json:"driftCount,omitempty"`
+
+	// LastDriftTime is the time the last change outside of the operator was restored.
+	//+optional
+	LastDriftTime *metav1.Time `json:"lastDriftTime,omitempty"`
+
+	// LastChange summarizes the subjects added and removed by the last change of the bindings.
+	//+optional
+	LastChange *SubjectChange `json:"lastChange,omitempty"`
+
+	// RoleRules summarizes the rules of the referenced roles, with spec.pinRoleRules the rules
+	// which were last acknowledged.
+	//+optional
+	RoleRules []RoleRulesSummary `json:"roleRules,omitempty"`
+
+	// RoleRulesHash is the hash of the current rules of all referenced roles, the value of the
+	// perms.infra-mgmt.io/role-rules-ack annotation which acknowledges a change.
+	//+optional
+	RoleRulesHash string `json:"roleRulesHash,omitempty"`
+
+	// PolicyViolations are the violations of the PermsPolicies, one per denied role or subject
+	// and violated rule.
+	//+optional
+	PolicyViolations []string `json:"policyViolations,omitempty"`
+
+	// ApprovedSpec is the last spec of a sensitive PermsRoleBinding approved by a second user, it
+	// is applied while a change waits for the approval.
+	//+optional
+	ApprovedSpec *PermsRoleBindingSpec `json:"approvedSpec,omitempty"`
+
+	// ApprovedGeneration is the generation of the approved spec.
+	//+optional
+	ApprovedGeneration int64 `json:"approvedGeneration,omitempty"`
+
+	// PendingChange is the change of the spec which waits for the approval.
+	//+optional
+	PendingChange *PendingChange `json:"pendingChange,omitempty"`
+
+	// Lockdown records the subjects removed by an active PermsLockdown.
+	//+optional
+	Lockdown *LockdownSnapshot `json:"lockdown,omitempty"`
+
+	// ObservedGeneration is the generation the status was computed for.
+	//+optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	//+optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// PendingChange is a change of the spec of a sensitive PermsRoleBinding which is not approved yet
+type PendingChange struct {
+	// Generation of the changed spec, the value of the perms.infra-mgmt.io/approve annotation which
+	// approves it.
+	Generation int64 `json:"generation"`
+	// Author of the change.
+	//+optional
+	Author string `json:"author,omitempty"`
+	// Changes summarizes the differences to the approved spec.
+	//+optional
+	Changes []string `json:"changes,omitempty"`
+}
+
+// BindingReference references a RoleBinding or ClusterRoleBinding managed by the operator
+type BindingReference struct {
+	// Kind of the binding, RoleBinding or ClusterRoleBinding.
+	Kind string `json:"kind"`
+	// Namespace of the binding, empty for a ClusterRoleBinding.
+	//+optional
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	// RoleRef is the role referenced by the binding.
+	RoleRef RoleReference `json:"roleRef"`
+}
+
+// RoleReference references a Role or ClusterRole
+type RoleReference struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+// SubjectExpiration is the expiry of a subject
+type SubjectExpiration struct {
+	// Kind of the subject, User, Group or ServiceAccount.
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	//+optional
+	Namespace string      `json:"namespace,omitempty"`
+	ExpiresAt metav1.Time `json:"expiresAt"`
+}
+
+// SubjectChange summarizes a change of the subjects which have the role assigned
+type SubjectChange struct {
+	// Time of the change.
+	Time metav1.Time `json:"time"`
+	// Added are the subjects which got the role assigned, as Kind/name or
+	// ServiceAccount/namespace/name.
+	//+optional
+	Added []string `json:"added,omitempty"`
+	// Removed are the subjects which lost the role.
+	//+optional
+	Removed []string `json:"removed,omitempty"`
+}
+
+// RoleRulesSummary summarizes the rules of a referenced role, the rules of an aggregated
+// ClusterRole include the rules of the aggregated ClusterRoles
+type RoleRulesSummary struct {
+	RoleRef RoleReference `json:"roleRef"`
+	// Hash of the rules.
+	Hash string `json:"hash"`
+	// Rules is the number of rules.
+	Rules int32 `json:"rules"`
+	// Resources are the resources and non-resource URLs the rules apply to, as resource.group.
+	//+optional
+	Resources []string `json:"resources,omitempty"`
+	// Verbs are the verbs the rules allow.
+	//+optional
+	Verbs []string `json:"verbs,omitempty"`
+}
+
+// SubjectCount is the number of subjects per subject kind
+type SubjectCount struct {
+	Users           int32 `json:"users"`
+	Groups          int32 `json:"groups"`
+	Serviceaccounts int32 `json:"serviceaccounts"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:storageversion
+//+kubebuilder:resource:path=permsrolebindings,shortName=permsrb;prb
+//+kubebuilder:printcolumn:name=Users,type=integer,JSONPath=".status.count.users"
+//+kubebuilder:printcolumn:name=Groups,type=integer,JSONPath=".status.count.groups"
+//+kubebuilder:printcolumn:name=Serviceaccounts,type=integer,JSONPath=".status.count.serviceaccounts"
+//+kubebuilder:printcolumn:name=Available,type=string,JSONPath=".status.conditions[?(@.type==\"Available\")].status"
+//+kubebuilder:printcolumn:name=Progressing,type=string,JSONPath=".status.conditions[?(@.type==\"Progressing\")].status"
+//+kubebuilder:printcolumn:name=Degraded,type=string,JSONPath=".status.conditions[?(@.type==\"Degraded\")].status"
+
+// PermsRoleBinding is the Schema for the permsrolebindings API
+type PermsRoleBinding struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PermsRoleBindingSpec   `json:"spec,omitempty"`
+	Status PermsRoleBindingStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PermsRoleBindingList contains a list of PermsRoleBinding
+type PermsRoleBindingList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PermsRoleBinding `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PermsRoleBinding{}, &PermsRoleBindingList{})
+}
@@ -0,0 +1,279 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"sort"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var permsrolebindinglog = logf.Log.WithName("permsrolebinding-resource")
+
+// ManagedByAnnotation marks resources which are managed by the Permission Operator
+const ManagedByAnnotation = "perms.infra-mgmt.io/managed-by"
+
+func (r *PermsRoleBinding) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-perms-infra-mgmt-io-v1-permsrolebinding,mutating=true,failurePolicy=fail,sideEffects=None,groups=perms.infra-mgmt.io,resources=permsrolebindings,verbs=create;update,versions=v1,name=mpermsrolebinding.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Defaulter = &PermsRoleBinding{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+func (r *PermsRoleBinding) Default() {
+	permsrolebindinglog.Info("default", "name", r.Name, "namespace", r.Namespace)
+
+	if r.Spec.Kind == "" {
+		r.Spec.Kind = "ClusterRole"
+	}
+	for i := range r.Spec.Roles {
+		if r.Spec.Roles[i].Kind == "" {
+			r.Spec.Roles[i].Kind = "ClusterRole"
+		}
+	}
+	for i := range r.Spec.Serviceaccounts {
+		if strings.TrimSpace(r.Spec.Serviceaccounts[i].Namespace) == "" {
+			r.Spec.Serviceaccounts[i].Namespace = r.Namespace
+		}
+	}
+	r.Spec.Groups = normalizeNames(r.Spec.Groups)
+	r.Spec.Users = normalizeNames(r.Spec.Users)
+	r.Spec.Serviceaccounts = normalizeServiceaccounts(r.Spec.Serviceaccounts)
+
+	if r.Annotations == nil {
+		r.Annotations = map[string]string{}
+	}
+	if _, ok := r.Annotations[ManagedByAnnotation]; !ok {
+		r.Annotations[ManagedByAnnotation] = "perms-operator"
+	}
+}
+
+//+kubebuilder:webhook:path=/validate-perms-infra-mgmt-io-v1-permsrolebinding,mutating=false,failurePolicy=fail,sideEffects=None,groups=perms.infra-mgmt.io,resources=permsrolebindings,verbs=create;update,versions=v1,name=vpermsrolebinding.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &PermsRoleBinding{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *PermsRoleBinding) ValidateCreate() error {
+	permsrolebindinglog.Info("validate create", "name", r.Name, "namespace", r.Namespace)
+
+	return r.validatePermsRoleBinding(nil)
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *PermsRoleBinding) ValidateUpdate(old runtime.Object) error {
+	permsrolebindinglog.Info("validate update", "name", r.Name, "namespace", r.Namespace)
+
+	return r.validatePermsRoleBinding(old.(*PermsRoleBinding))
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *PermsRoleBinding) ValidateDelete() error {
+	permsrolebindinglog.Info("validate delete", "name", r.Name, "namespace", r.Namespace)
+
+	// deleting a PermsRoleBinding is always allowed
+	return nil
+}
+
+// validatePermsRoleBinding validates the spec and, on update, the changes to the old object
+func (r *PermsRoleBinding) validatePermsRoleBinding(old *PermsRoleBinding) error {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	if r.Spec.Kind != "Role" && r.Spec.Kind != "ClusterRole" {
+		allErrs = append(allErrs, field.NotSupported(specPath.Child("kind"), r.Spec.Kind, []string{"Role", "ClusterRole"}))
+	}
+	if r.Spec.Role == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("role"), "role must not be empty"))
+	}
+	allErrs = append(allErrs, validateRoles(specPath, r.Spec.Kind, r.Spec.Role, r.Spec.Roles)...)
+	allErrs = append(allErrs, validateSubjects(specPath, r.Spec.Groups, r.Spec.Users, r.Spec.Serviceaccounts)...)
+	allErrs = append(allErrs, validateValidity(specPath, r.Spec.Validity, r.Spec.Groups, r.Spec.Users)...)
+
+	if old != nil && old.Spec.ImmutableRoleRef {
+		if r.Spec.Kind != old.Spec.Kind {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("kind"), "kind is immutable while spec.immutableRoleRef is set"))
+		}
+		if r.Spec.Role != old.Spec.Role {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("role"), "role is immutable while spec.immutableRoleRef is set"))
+		}
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: GroupVersion.Group, Kind: "PermsRoleBinding"},
+		r.Name, allErrs)
+}
+
+// validateRoles rejects invalid additional roles and roles which are already referenced
+func validateRoles(specPath *field.Path, kind string, role string, roles []RoleSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	seen := map[RoleSpec]bool{{Kind: kind, Name: role}: true}
+	for i, r := range roles {
+		idxPath := specPath.Child("roles").Index(i)
+		if r.Kind != "Role" && r.Kind != "ClusterRole" {
+			allErrs = append(allErrs, field.NotSupported(idxPath.Child("kind"), r.Kind, []string{"Role", "ClusterRole"}))
+		}
+		if r.Name == "" {
+			allErrs = append(allErrs, field.Required(idxPath.Child("name"), "name must not be empty"))
+			continue
+		}
+		if seen[r] {
+			allErrs = append(allErrs, field.Duplicate(idxPath, r.Kind+"/"+r.Name))
+		}
+		seen[r] = true
+	}
+	return allErrs
+}
+
+// validateSubjects rejects empty and duplicate subjects and serviceaccounts without a namespace
+func validateSubjects(specPath *field.Path, groups []string, users []string, serviceaccounts []Serviceaccount) field.ErrorList {
+	var allErrs field.ErrorList
+
+	allErrs = append(allErrs, validateNames(specPath.Child("groups"), groups)...)
+	allErrs = append(allErrs, validateNames(specPath.Child("users"), users)...)
+
+	seen := map[string]bool{}
+	for i, sa := range serviceaccounts {
+		saPath := specPath.Child("serviceaccounts").Index(i)
+		if sa.Name == "" {
+			allErrs = append(allErrs, field.Required(saPath.Child("name"), "serviceaccount name must not be empty"))
+		}
+		if sa.Namespace == "" {
+			allErrs = append(allErrs, field.Required(saPath.Child("namespace"), "serviceaccount namespace must not be empty"))
+		}
+		if seen[sa.Namespace+"/"+sa.Name] {
+			allErrs = append(allErrs, field.Duplicate(saPath, sa.Namespace+"/"+sa.Name))
+		}
+		seen[sa.Namespace+"/"+sa.Name] = true
+	}
+	return allErrs
+}
+
+// validateValidity rejects an empty validity window and expiries of unknown users and groups
+func validateValidity(specPath *field.Path, validity Validity, groups []string, users []string) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if validity.NotBefore != nil && validity.ExpiresAt != nil && !validity.ExpiresAt.After(validity.NotBefore.Time) {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("expiresAt"), validity.ExpiresAt, "expiresAt must be after notBefore"))
+	}
+	allErrs = append(allErrs, validateExpiry(specPath.Child("groupExpiry"), validity.GroupExpiry, groups)...)
+	allErrs = append(allErrs, validateExpiry(specPath.Child("userExpiry"), validity.UserExpiry, users)...)
+	return allErrs
+}
+
+// validateExpiry rejects expiries of subjects which are not in the subject list
+func validateExpiry(fldPath *field.Path, expiry map[string]metav1.Time, names []string) field.ErrorList {
+	var allErrs field.ErrorList
+
+	known := map[string]bool{}
+	for _, name := range names {
+		known[name] = true
+	}
+	for _, name := range sortedKeys(expiry) {
+		if !known[name] {
+			allErrs = append(allErrs, field.NotFound(fldPath.Key(name), name))
+		}
+	}
+	return allErrs
+}
+
+// sortedKeys returns the keys of an expiry map in a stable order
+func sortedKeys(expiry map[string]metav1.Time) []string {
+	keys := make([]string, 0, len(expiry))
+	for key := range expiry {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// validateNames rejects empty and duplicate entries of a subject list
+func validateNames(fldPath *field.Path, names []string) field.ErrorList {
+	var allErrs field.ErrorList
+
+	seen := map[string]bool{}
+	for i, name := range names {
+		if name == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Index(i), "name must not be empty"))
+			continue
+		}
+		if seen[name] {
+			allErrs = append(allErrs, field.Duplicate(fldPath.Index(i), name))
+		}
+		seen[name] = true
+	}
+	return allErrs
+}
+
+// normalizeNames trims, deduplicates and sorts the entries of a subject list
+func normalizeNames(names []string) []string {
+	if names == nil {
+		return nil
+	}
+	seen := map[string]bool{}
+	normalized := []string{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		normalized = append(normalized, name)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+// normalizeServiceaccounts trims, deduplicates and sorts serviceaccounts by namespace and name
+func normalizeServiceaccounts(serviceaccounts []Serviceaccount) []Serviceaccount {
+	if serviceaccounts == nil {
+		return nil
+	}
+	seen := map[string]bool{}
+	normalized := []Serviceaccount{}
+	for _, sa := range serviceaccounts {
+		sa.Name = strings.TrimSpace(sa.Name)
+		sa.Namespace = strings.TrimSpace(sa.Namespace)
+		if sa.Name == "" || seen[sa.Namespace+"/"+sa.Name] {
+			continue
+		}
+		seen[sa.Namespace+"/"+sa.Name] = true
+		normalized = append(normalized, sa)
+	}
+	sort.Slice(normalized, func(i, j int) bool {
+		if normalized[i].Namespace != normalized[j].Namespace {
+			return normalized[i].Namespace < normalized[j].Namespace
+		}
+		return normalized[i].Name < normalized[j].Name
+	})
+	return normalized
+}
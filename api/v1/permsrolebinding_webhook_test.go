@@ -0,0 +1,151 @@
+package v1
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("permsrolebinding webhook", func() {
+	newPermsRoleBinding := func() *PermsRoleBinding {
+		return &PermsRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "testing"},
+			Spec: PermsRoleBindingSpec{
+				Kind:   "ClusterRole",
+				Role:   "view",
+				Groups: []string{"group1", "group2"},
+				Users:  []string{"user1"},
+				Serviceaccounts: []Serviceaccount{
+					{Name: "default", Namespace: "testing"},
+				},
+			},
+		}
+	}
+
+	Context("defaulting a PermsRoleBinding", func() {
+
+		It("should default kinds and serviceaccount namespaces", func() {
+			prb := newPermsRoleBinding()
+			prb.Spec.Kind = ""
+			prb.Spec.Roles = []RoleSpec{{Name: "secret-reader"}}
+			prb.Spec.Serviceaccounts = []Serviceaccount{{Name: "builder"}}
+			prb.Default()
+			Expect(prb.Spec.Kind).To(Equal("ClusterRole"))
+			Expect(prb.Spec.Roles).To(Equal([]RoleSpec{{Kind: "ClusterRole", Name: "secret-reader"}}))
+			Expect(prb.Spec.Serviceaccounts).To(Equal([]Serviceaccount{{Name: "builder", Namespace: "testing"}}))
+			Expect(prb.Annotations).To(HaveKeyWithValue(ManagedByAnnotation, "perms-operator"))
+		})
+
+		It("should trim, deduplicate and sort the subjects", func() {
+			prb := newPermsRoleBinding()
+			prb.Spec.Groups = []string{" group2", "group1", "group2 ", ""}
+			prb.Spec.Serviceaccounts = []Serviceaccount{
+				{Name: "default", Namespace: "testing"},
+				{Name: "builder", Namespace: "testing"},
+				{Name: "default"},
+			}
+			prb.Default()
+			Expect(prb.Spec.Groups).To(Equal([]string{"group1", "group2"}))
+			Expect(prb.Spec.Serviceaccounts).To(Equal([]Serviceaccount{
+				{Name: "builder", Namespace: "testing"},
+				{Name: "default", Namespace: "testing"},
+			}))
+		})
+
+		It("should keep an existing managed-by annotation", func() {
+			prb := newPermsRoleBinding()
+			prb.Annotations = map[string]string{ManagedByAnnotation: "helm"}
+			prb.Default()
+			Expect(prb.Annotations).To(HaveKeyWithValue(ManagedByAnnotation, "helm"))
+		})
+	})
+
+	Context("validating a new PermsRoleBinding", func() {
+
+		It("should accept a valid PermsRoleBinding", func() {
+			Expect(newPermsRoleBinding().ValidateCreate()).To(Succeed())
+		})
+
+		It("should reject an unsupported kind", func() {
+			prb := newPermsRoleBinding()
+			prb.Spec.Kind = "Group"
+			err := prb.ValidateCreate()
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.kind"))
+		})
+
+		It("should reject an additional role which is already referenced", func() {
+			prb := newPermsRoleBinding()
+			prb.Spec.Roles = []RoleSpec{{Kind: "Role", Name: "secret-reader"}, {Kind: prb.Spec.Kind, Name: prb.Spec.Role}}
+			err := prb.ValidateCreate()
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.roles[1]"))
+		})
+
+		It("should reject a validity window which ends before it starts", func() {
+			prb := newPermsRoleBinding()
+			prb.Spec.NotBefore = &metav1.Time{Time: time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)}
+			prb.Spec.ExpiresAt = &metav1.Time{Time: time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC)}
+			err := prb.ValidateCreate()
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.expiresAt"))
+		})
+
+		It("should reject the expiry of an unknown user", func() {
+			prb := newPermsRoleBinding()
+			prb.Spec.UserExpiry = map[string]metav1.Time{"contractor": {Time: time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)}}
+			err := prb.ValidateCreate()
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.userExpiry[contractor]"))
+		})
+
+		It("should reject a serviceaccount without namespace", func() {
+			prb := newPermsRoleBinding()
+			prb.Spec.Serviceaccounts = append(prb.Spec.Serviceaccounts, Serviceaccount{Name: "builder"})
+			err := prb.ValidateCreate()
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.serviceaccounts[1].namespace"))
+		})
+
+		It("should reject duplicate subjects", func() {
+			prb := newPermsRoleBinding()
+			prb.Spec.Groups = append(prb.Spec.Groups, "group1")
+			prb.Spec.Serviceaccounts = append(prb.Spec.Serviceaccounts, Serviceaccount{Name: "default", Namespace: "testing"})
+			err := prb.ValidateCreate()
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.groups[2]"))
+			Expect(err.Error()).To(ContainSubstring("spec.serviceaccounts[1]"))
+		})
+	})
+
+	Context("validating an updated PermsRoleBinding", func() {
+
+		It("should accept a role switch", func() {
+			prb := newPermsRoleBinding()
+			prb.Spec.Role = "edit"
+			Expect(prb.ValidateUpdate(newPermsRoleBinding())).To(Succeed())
+		})
+
+		It("should reject a role switch with an immutable role", func() {
+			old := newPermsRoleBinding()
+			old.Spec.ImmutableRoleRef = true
+			prb := old.DeepCopy()
+			prb.Spec.Role = "edit"
+			prb.Spec.Kind = "Role"
+			err := prb.ValidateUpdate(old)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.role"))
+			Expect(err.Error()).To(ContainSubstring("spec.kind"))
+		})
+
+		It("should leave a role switch which sets the immutable role to the controller", func() {
+			prb := newPermsRoleBinding()
+			prb.Spec.Role = "edit"
+			prb.Spec.ImmutableRoleRef = true
+			Expect(prb.ValidateUpdate(newPermsRoleBinding())).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,1472 @@
+//go:build !ignore_autogenerated
+// +build !ignore_autogenerated
+
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by controller-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+)
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessApproval) DeepCopyInto(out *AccessApproval) {
+	*out = *in
+	if in.Time != nil {
+		in, out := &in.Time, &out.Time
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessApproval.
+func (in *AccessApproval) DeepCopy() *AccessApproval {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessApproval)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ApprovalPolicy) DeepCopyInto(out *ApprovalPolicy) {
+	*out = *in
+	if in.Roles != nil {
+		in, out := &in.Roles, &out.Roles
+		*out = make([]RolePattern, len(*in))
+		copy(*out, *in)
+	}
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApprovalPolicy.
+func (in *ApprovalPolicy) DeepCopy() *ApprovalPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ApprovalPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BindingReference) DeepCopyInto(out *BindingReference) {
+	*out = *in
+	out.RoleRef = in.RoleRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BindingReference.
+func (in *BindingReference) DeepCopy() *BindingReference {
+	if in == nil {
+		return nil
+	}
+	out := new(BindingReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BreakGlassActivators) DeepCopyInto(out *BreakGlassActivators) {
+	*out = *in
+	if in.Users != nil {
+		in, out := &in.Users, &out.Users
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BreakGlassActivators.
+func (in *BreakGlassActivators) DeepCopy() *BreakGlassActivators {
+	if in == nil {
+		return nil
+	}
+	out := new(BreakGlassActivators)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConstraintViolation) DeepCopyInto(out *ConstraintViolation) {
+	*out = *in
+	if in.Roles != nil {
+		in, out := &in.Roles, &out.Roles
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Sources != nil {
+		in, out := &in.Sources, &out.Sources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConstraintViolation.
+func (in *ConstraintViolation) DeepCopy() *ConstraintViolation {
+	if in == nil {
+		return nil
+	}
+	out := new(ConstraintViolation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DelegationAdmins) DeepCopyInto(out *DelegationAdmins) {
+	*out = *in
+	if in.Users != nil {
+		in, out := &in.Users, &out.Users
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DelegationAdmins.
+func (in *DelegationAdmins) DeepCopy() *DelegationAdmins {
+	if in == nil {
+		return nil
+	}
+	out := new(DelegationAdmins)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExclusiveRoleSet) DeepCopyInto(out *ExclusiveRoleSet) {
+	*out = *in
+	if in.Roles != nil {
+		in, out := &in.Roles, &out.Roles
+		*out = make([]RoleReference, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExclusiveRoleSet.
+func (in *ExclusiveRoleSet) DeepCopy() *ExclusiveRoleSet {
+	if in == nil {
+		return nil
+	}
+	out := new(ExclusiveRoleSet)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LockdownSnapshot) DeepCopyInto(out *LockdownSnapshot) {
+	*out = *in
+	in.Since.DeepCopyInto(&out.Since)
+	if in.Subjects != nil {
+		in, out := &in.Subjects, &out.Subjects
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LockdownSnapshot.
+func (in *LockdownSnapshot) DeepCopy() *LockdownSnapshot {
+	if in == nil {
+		return nil
+	}
+	out := new(LockdownSnapshot)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamePolicy) DeepCopyInto(out *NamePolicy) {
+	*out = *in
+	if in.Allow != nil {
+		in, out := &in.Allow, &out.Allow
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Deny != nil {
+		in, out := &in.Deny, &out.Deny
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamePolicy.
+func (in *NamePolicy) DeepCopy() *NamePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(NamePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PendingChange) DeepCopyInto(out *PendingChange) {
+	*out = *in
+	if in.Changes != nil {
+		in, out := &in.Changes, &out.Changes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PendingChange.
+func (in *PendingChange) DeepCopy() *PendingChange {
+	if in == nil {
+		return nil
+	}
+	out := new(PendingChange)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PermsAccessRequest) DeepCopyInto(out *PermsAccessRequest) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PermsAccessRequest.
+func (in *PermsAccessRequest) DeepCopy() *PermsAccessRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(PermsAccessRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PermsAccessRequest) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PermsAccessRequestList) DeepCopyInto(out *PermsAccessRequestList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PermsAccessRequest, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PermsAccessRequestList.
+func (in *PermsAccessRequestList) DeepCopy() *PermsAccessRequestList {
+	if in == nil {
+		return nil
+	}
+	out := new(PermsAccessRequestList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PermsAccessRequestList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PermsAccessRequestSpec) DeepCopyInto(out *PermsAccessRequestSpec) {
+	*out = *in
+	out.Duration = in.Duration
+	if in.Approval != nil {
+		in, out := &in.Approval, &out.Approval
+		*out = new(AccessApproval)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PermsAccessRequestSpec.
+func (in *PermsAccessRequestSpec) DeepCopy() *PermsAccessRequestSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PermsAccessRequestSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PermsAccessRequestStatus) DeepCopyInto(out *PermsAccessRequestStatus) {
+	*out = *in
+	if in.RequestedAt != nil {
+		in, out := &in.RequestedAt, &out.RequestedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.ApprovedAt != nil {
+		in, out := &in.ApprovedAt, &out.ApprovedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.DeniedAt != nil {
+		in, out := &in.DeniedAt, &out.DeniedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.ActivatedAt != nil {
+		in, out := &in.ActivatedAt, &out.ActivatedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = (*in).DeepCopy()
+	}
+	if in.ExpiredAt != nil {
+		in, out := &in.ExpiredAt, &out.ExpiredAt
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PermsAccessRequestStatus.
+func (in *PermsAccessRequestStatus) DeepCopy() *PermsAccessRequestStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PermsAccessRequestStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PermsBreakGlass) DeepCopyInto(out *PermsBreakGlass) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PermsBreakGlass.
+func (in *PermsBreakGlass) DeepCopy() *PermsBreakGlass {
+	if in == nil {
+		return nil
+	}
+	out := new(PermsBreakGlass)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PermsBreakGlass) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PermsBreakGlassList) DeepCopyInto(out *PermsBreakGlassList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PermsBreakGlass, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PermsBreakGlassList.
+func (in *PermsBreakGlassList) DeepCopy() *PermsBreakGlassList {
+	if in == nil {
+		return nil
+	}
+	out := new(PermsBreakGlassList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PermsBreakGlassList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PermsBreakGlassSpec) DeepCopyInto(out *PermsBreakGlassSpec) {
+	*out = *in
+	if in.Users != nil {
+		in, out := &in.Users, &out.Users
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.Activators.DeepCopyInto(&out.Activators)
+	out.MaxDuration = in.MaxDuration
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PermsBreakGlassSpec.
+func (in *PermsBreakGlassSpec) DeepCopy() *PermsBreakGlassSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PermsBreakGlassSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PermsBreakGlassStatus) DeepCopyInto(out *PermsBreakGlassStatus) {
+	*out = *in
+	if in.ActivatedAt != nil {
+		in, out := &in.ActivatedAt, &out.ActivatedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = (*in).DeepCopy()
+	}
+	if in.DeactivatedAt != nil {
+		in, out := &in.DeactivatedAt, &out.DeactivatedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PermsBreakGlassStatus.
+func (in *PermsBreakGlassStatus) DeepCopy() *PermsBreakGlassStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PermsBreakGlassStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PermsClusterRoleBinding) DeepCopyInto(out *PermsClusterRoleBinding) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PermsClusterRoleBinding.
+func (in *PermsClusterRoleBinding) DeepCopy() *PermsClusterRoleBinding {
+	if in == nil {
+		return nil
+	}
+	out := new(PermsClusterRoleBinding)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PermsClusterRoleBinding) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PermsClusterRoleBindingList) DeepCopyInto(out *PermsClusterRoleBindingList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PermsClusterRoleBinding, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PermsClusterRoleBindingList.
+func (in *PermsClusterRoleBindingList) DeepCopy() *PermsClusterRoleBindingList {
+	if in == nil {
+		return nil
+	}
+	out := new(PermsClusterRoleBindingList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PermsClusterRoleBindingList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PermsClusterRoleBindingSpec) DeepCopyInto(out *PermsClusterRoleBindingSpec) {
+	*out = *in
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Users != nil {
+		in, out := &in.Users, &out.Users
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Serviceaccounts != nil {
+		in, out := &in.Serviceaccounts, &out.Serviceaccounts
+		*out = make([]Serviceaccount, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.Validity.DeepCopyInto(&out.Validity)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PermsClusterRoleBindingSpec.
+func (in *PermsClusterRoleBindingSpec) DeepCopy() *PermsClusterRoleBindingSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PermsClusterRoleBindingSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PermsClusterRoleBindingStatus) DeepCopyInto(out *PermsClusterRoleBindingStatus) {
+	*out = *in
+	if in.Bindings != nil {
+		in, out := &in.Bindings, &out.Bindings
+		*out = make([]BindingReference, len(*in))
+		copy(*out, *in)
+	}
+	out.Count = in.Count
+	if in.Expirations != nil {
+		in, out := &in.Expirations, &out.Expirations
+		*out = make([]SubjectExpiration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NextTransitionTime != nil {
+		in, out := &in.NextTransitionTime, &out.NextTransitionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastDriftTime != nil {
+		in, out := &in.LastDriftTime, &out.LastDriftTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastChange != nil {
+		in, out := &in.LastChange, &out.LastChange
+		*out = new(SubjectChange)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RoleRules != nil {
+		in, out := &in.RoleRules, &out.RoleRules
+		*out = make([]RoleRulesSummary, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PolicyViolations != nil {
+		in, out := &in.PolicyViolations, &out.PolicyViolations
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ApprovedSpec != nil {
+		in, out := &in.ApprovedSpec, &out.ApprovedSpec
+		*out = new(PermsClusterRoleBindingSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PendingChange != nil {
+		in, out := &in.PendingChange, &out.PendingChange
+		*out = new(PendingChange)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Lockdown != nil {
+		in, out := &in.Lockdown, &out.Lockdown
+		*out = new(LockdownSnapshot)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PermsClusterRoleBindingStatus.
+func (in *PermsClusterRoleBindingStatus) DeepCopy() *PermsClusterRoleBindingStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PermsClusterRoleBindingStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PermsConstraint) DeepCopyInto(out *PermsConstraint) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PermsConstraint.
+func (in *PermsConstraint) DeepCopy() *PermsConstraint {
+	if in == nil {
+		return nil
+	}
+	out := new(PermsConstraint)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PermsConstraint) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PermsConstraintList) DeepCopyInto(out *PermsConstraintList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PermsConstraint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PermsConstraintList.
+func (in *PermsConstraintList) DeepCopy() *PermsConstraintList {
+	if in == nil {
+		return nil
+	}
+	out := new(PermsConstraintList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PermsConstraintList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PermsConstraintSpec) DeepCopyInto(out *PermsConstraintSpec) {
+	*out = *in
+	if in.ExclusiveRoles != nil {
+		in, out := &in.ExclusiveRoles, &out.ExclusiveRoles
+		*out = make([]ExclusiveRoleSet, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PermsConstraintSpec.
+func (in *PermsConstraintSpec) DeepCopy() *PermsConstraintSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PermsConstraintSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PermsConstraintStatus) DeepCopyInto(out *PermsConstraintStatus) {
+	*out = *in
+	if in.Violations != nil {
+		in, out := &in.Violations, &out.Violations
+		*out = make([]ConstraintViolation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PermsConstraintStatus.
+func (in *PermsConstraintStatus) DeepCopy() *PermsConstraintStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PermsConstraintStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PermsDelegation) DeepCopyInto(out *PermsDelegation) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PermsDelegation.
+func (in *PermsDelegation) DeepCopy() *PermsDelegation {
+	if in == nil {
+		return nil
+	}
+	out := new(PermsDelegation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PermsDelegation) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PermsDelegationList) DeepCopyInto(out *PermsDelegationList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PermsDelegation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PermsDelegationList.
+func (in *PermsDelegationList) DeepCopy() *PermsDelegationList {
+	if in == nil {
+		return nil
+	}
+	out := new(PermsDelegationList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PermsDelegationList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PermsDelegationSpec) DeepCopyInto(out *PermsDelegationSpec) {
+	*out = *in
+	in.Admins.DeepCopyInto(&out.Admins)
+	if in.Roles != nil {
+		in, out := &in.Roles, &out.Roles
+		*out = make([]RolePattern, len(*in))
+		copy(*out, *in)
+	}
+	if in.Users != nil {
+		in, out := &in.Users, &out.Users
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Serviceaccounts != nil {
+		in, out := &in.Serviceaccounts, &out.Serviceaccounts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PermsDelegationSpec.
+func (in *PermsDelegationSpec) DeepCopy() *PermsDelegationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PermsDelegationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PermsLockdown) DeepCopyInto(out *PermsLockdown) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PermsLockdown.
+func (in *PermsLockdown) DeepCopy() *PermsLockdown {
+	if in == nil {
+		return nil
+	}
+	out := new(PermsLockdown)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PermsLockdown) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PermsLockdownList) DeepCopyInto(out *PermsLockdownList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PermsLockdown, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PermsLockdownList.
+func (in *PermsLockdownList) DeepCopy() *PermsLockdownList {
+	if in == nil {
+		return nil
+	}
+	out := new(PermsLockdownList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PermsLockdownList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PermsLockdownSpec) DeepCopyInto(out *PermsLockdownSpec) {
+	*out = *in
+	if in.ExemptBreakGlasses != nil {
+		in, out := &in.ExemptBreakGlasses, &out.ExemptBreakGlasses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PermsLockdownSpec.
+func (in *PermsLockdownSpec) DeepCopy() *PermsLockdownSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PermsLockdownSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PermsLockdownStatus) DeepCopyInto(out *PermsLockdownStatus) {
+	*out = *in
+	if in.LockedAt != nil {
+		in, out := &in.LockedAt, &out.LockedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.LiftedAt != nil {
+		in, out := &in.LiftedAt, &out.LiftedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PermsLockdownStatus.
+func (in *PermsLockdownStatus) DeepCopy() *PermsLockdownStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PermsLockdownStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PermsPolicy) DeepCopyInto(out *PermsPolicy) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PermsPolicy.
+func (in *PermsPolicy) DeepCopy() *PermsPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(PermsPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PermsPolicy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PermsPolicyList) DeepCopyInto(out *PermsPolicyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PermsPolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PermsPolicyList.
+func (in *PermsPolicyList) DeepCopy() *PermsPolicyList {
+	if in == nil {
+		return nil
+	}
+	out := new(PermsPolicyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PermsPolicyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PermsPolicySpec) DeepCopyInto(out *PermsPolicySpec) {
+	*out = *in
+	in.Roles.DeepCopyInto(&out.Roles)
+	in.Users.DeepCopyInto(&out.Users)
+	in.Groups.DeepCopyInto(&out.Groups)
+	in.Serviceaccounts.DeepCopyInto(&out.Serviceaccounts)
+	if in.ProtectedNamespaces != nil {
+		in, out := &in.ProtectedNamespaces, &out.ProtectedNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Rules != nil {
+		in, out := &in.Rules, &out.Rules
+		*out = make([]PolicyRule, len(*in))
+		copy(*out, *in)
+	}
+	if in.TwoPersonApproval != nil {
+		in, out := &in.TwoPersonApproval, &out.TwoPersonApproval
+		*out = new(ApprovalPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PermsPolicySpec.
+func (in *PermsPolicySpec) DeepCopy() *PermsPolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PermsPolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PermsRoleBinding) DeepCopyInto(out *PermsRoleBinding) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PermsRoleBinding.
+func (in *PermsRoleBinding) DeepCopy() *PermsRoleBinding {
+	if in == nil {
+		return nil
+	}
+	out := new(PermsRoleBinding)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PermsRoleBinding) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PermsRoleBindingList) DeepCopyInto(out *PermsRoleBindingList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PermsRoleBinding, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PermsRoleBindingList.
+func (in *PermsRoleBindingList) DeepCopy() *PermsRoleBindingList {
+	if in == nil {
+		return nil
+	}
+	out := new(PermsRoleBindingList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PermsRoleBindingList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PermsRoleBindingSpec) DeepCopyInto(out *PermsRoleBindingSpec) {
+	*out = *in
+	if in.Roles != nil {
+		in, out := &in.Roles, &out.Roles
+		*out = make([]RoleSpec, len(*in))
+		copy(*out, *in)
+	}
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Users != nil {
+		in, out := &in.Users, &out.Users
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Serviceaccounts != nil {
+		in, out := &in.Serviceaccounts, &out.Serviceaccounts
+		*out = make([]Serviceaccount, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.Validity.DeepCopyInto(&out.Validity)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PermsRoleBindingSpec.
+func (in *PermsRoleBindingSpec) DeepCopy() *PermsRoleBindingSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PermsRoleBindingSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PermsRoleBindingStatus) DeepCopyInto(out *PermsRoleBindingStatus) {
+	*out = *in
+	if in.Bindings != nil {
+		in, out := &in.Bindings, &out.Bindings
+		*out = make([]BindingReference, len(*in))
+		copy(*out, *in)
+	}
+	out.Count = in.Count
+	if in.Expirations != nil {
+		in, out := &in.Expirations, &out.Expirations
+		*out = make([]SubjectExpiration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NextTransitionTime != nil {
+		in, out := &in.NextTransitionTime, &out.NextTransitionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastDriftTime != nil {
+		in, out := &in.LastDriftTime, &out.LastDriftTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastChange != nil {
+		in, out := &in.LastChange, &out.LastChange
+		*out = new(SubjectChange)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RoleRules != nil {
+		in, out := &in.RoleRules, &out.RoleRules
+		*out = make([]RoleRulesSummary, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PolicyViolations != nil {
+		in, out := &in.PolicyViolations, &out.PolicyViolations
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ApprovedSpec != nil {
+		in, out := &in.ApprovedSpec, &out.ApprovedSpec
+		*out = new(PermsRoleBindingSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PendingChange != nil {
+		in, out := &in.PendingChange, &out.PendingChange
+		*out = new(PendingChange)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Lockdown != nil {
+		in, out := &in.Lockdown, &out.Lockdown
+		*out = new(LockdownSnapshot)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PermsRoleBindingStatus.
+func (in *PermsRoleBindingStatus) DeepCopy() *PermsRoleBindingStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PermsRoleBindingStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PolicyRule) DeepCopyInto(out *PolicyRule) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicyRule.
+func (in *PolicyRule) DeepCopy() *PolicyRule {
+	if in == nil {
+		return nil
+	}
+	out := new(PolicyRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RolePattern) DeepCopyInto(out *RolePattern) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolePattern.
+func (in *RolePattern) DeepCopy() *RolePattern {
+	if in == nil {
+		return nil
+	}
+	out := new(RolePattern)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RolePolicy) DeepCopyInto(out *RolePolicy) {
+	*out = *in
+	if in.Allow != nil {
+		in, out := &in.Allow, &out.Allow
+		*out = make([]RolePattern, len(*in))
+		copy(*out, *in)
+	}
+	if in.Deny != nil {
+		in, out := &in.Deny, &out.Deny
+		*out = make([]RolePattern, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolePolicy.
+func (in *RolePolicy) DeepCopy() *RolePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(RolePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RoleReference) DeepCopyInto(out *RoleReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RoleReference.
+func (in *RoleReference) DeepCopy() *RoleReference {
+	if in == nil {
+		return nil
+	}
+	out := new(RoleReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RoleRulesSummary) DeepCopyInto(out *RoleRulesSummary) {
+	*out = *in
+	out.RoleRef = in.RoleRef
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Verbs != nil {
+		in, out := &in.Verbs, &out.Verbs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RoleRulesSummary.
+func (in *RoleRulesSummary) DeepCopy() *RoleRulesSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(RoleRulesSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RoleSpec) DeepCopyInto(out *RoleSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RoleSpec.
+func (in *RoleSpec) DeepCopy() *RoleSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RoleSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Serviceaccount) DeepCopyInto(out *Serviceaccount) {
+	*out = *in
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Serviceaccount.
+func (in *Serviceaccount) DeepCopy() *Serviceaccount {
+	if in == nil {
+		return nil
+	}
+	out := new(Serviceaccount)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SubjectChange) DeepCopyInto(out *SubjectChange) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	if in.Added != nil {
+		in, out := &in.Added, &out.Added
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Removed != nil {
+		in, out := &in.Removed, &out.Removed
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SubjectChange.
+func (in *SubjectChange) DeepCopy() *SubjectChange {
+	if in == nil {
+		return nil
+	}
+	out := new(SubjectChange)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SubjectCount) DeepCopyInto(out *SubjectCount) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SubjectCount.
+func (in *SubjectCount) DeepCopy() *SubjectCount {
+	if in == nil {
+		return nil
+	}
+	out := new(SubjectCount)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SubjectExpiration) DeepCopyInto(out *SubjectExpiration) {
+	*out = *in
+	in.ExpiresAt.DeepCopyInto(&out.ExpiresAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SubjectExpiration.
+func (in *SubjectExpiration) DeepCopy() *SubjectExpiration {
+	if in == nil {
+		return nil
+	}
+	out := new(SubjectExpiration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Validity) DeepCopyInto(out *Validity) {
+	*out = *in
+	if in.NotBefore != nil {
+		in, out := &in.NotBefore, &out.NotBefore
+		*out = (*in).DeepCopy()
+	}
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = (*in).DeepCopy()
+	}
+	if in.UserExpiry != nil {
+		in, out := &in.UserExpiry, &out.UserExpiry
+		*out = make(map[string]metav1.Time, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.GroupExpiry != nil {
+		in, out := &in.GroupExpiry, &out.GroupExpiry
+		*out = make(map[string]metav1.Time, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Validity.
+func (in *Validity) DeepCopy() *Validity {
+	if in == nil {
+		return nil
+	}
+	out := new(Validity)
+	in.DeepCopyInto(out)
+	return out
+}
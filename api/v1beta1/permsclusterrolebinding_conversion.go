@@ -0,0 +1,95 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"reflect"
+	"strconv"
+
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	permsv1 "github.com/infra-mgmt-io/perms/api/v1"
+)
+
+// ConvertTo converts this PermsClusterRoleBinding to the Hub version (v1).
+func (src *PermsClusterRoleBinding) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*permsv1.PermsClusterRoleBinding)
+
+	// start with the fields which were lost in an earlier conversion to v1beta1
+	restored := &permsv1.PermsClusterRoleBinding{}
+	if err := restoreConversionData(&src.ObjectMeta, restored); err != nil {
+		return err
+	}
+	src.ObjectMeta.DeepCopyInto(&dst.ObjectMeta)
+	removeConversionData(&dst.ObjectMeta)
+
+	dst.Spec = restored.Spec
+	dst.Spec.Role = src.Spec.Role
+	dst.Spec.Groups = src.Spec.Groups
+	dst.Spec.Users = src.Spec.Users
+	dst.Spec.Serviceaccounts = convertServiceaccountsToV1(src.Spec.Serviceaccounts, restored.Spec.Serviceaccounts)
+	dst.Spec.ImmutableRoleRef = src.Spec.ImmutableRoleRef
+
+	dst.Status = restored.Status
+	dst.Status.Bindings = convertBindingsToV1(src.Status.Bindings, restored.Status.Bindings)
+	dst.Status.Count = permsv1.SubjectCount{
+		Users:           atoi32(src.Status.Count.Users),
+		Groups:          atoi32(src.Status.Count.Groups),
+		Serviceaccounts: atoi32(src.Status.Count.Serviceaccounts),
+	}
+	dst.Status.Conditions = src.Status.Conditions
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1) to this version.
+func (dst *PermsClusterRoleBinding) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*permsv1.PermsClusterRoleBinding)
+
+	src.ObjectMeta.DeepCopyInto(&dst.ObjectMeta)
+	removeConversionData(&dst.ObjectMeta)
+
+	dst.Spec.Role = src.Spec.Role
+	dst.Spec.Groups = src.Spec.Groups
+	dst.Spec.Users = src.Spec.Users
+	dst.Spec.Serviceaccounts = convertServiceaccountsFromV1(src.Spec.Serviceaccounts)
+	dst.Spec.ImmutableRoleRef = src.Spec.ImmutableRoleRef
+
+	dst.Status.Bindings = convertBindingsFromV1(src.Status.Bindings)
+	dst.Status.Count = PCrbCount{
+		Users:           strconv.Itoa(int(src.Status.Count.Users)),
+		Groups:          strconv.Itoa(int(src.Status.Count.Groups)),
+		Serviceaccounts: strconv.Itoa(int(src.Status.Count.Serviceaccounts)),
+	}
+	dst.Status.Conditions = src.Status.Conditions
+
+	// keep the fields which got lost in the conversion
+	converted := &permsv1.PermsClusterRoleBinding{}
+	if err := dst.ConvertTo(converted); err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(converted.Spec, src.Spec) || !reflect.DeepEqual(converted.Status, src.Status) {
+		// the counts and conditions are converted
+		status := src.Status.DeepCopy()
+		status.Count = permsv1.SubjectCount{}
+		status.Conditions = nil
+		return storeConversionData(&dst.ObjectMeta, &permsv1.PermsClusterRoleBinding{
+			Spec:   src.Spec,
+			Status: *status,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	permsv1 "github.com/infra-mgmt-io/perms/api/v1"
+)
+
+var _ = Describe("PermsClusterRoleBinding conversion", func() {
+	newHub := func() *permsv1.PermsClusterRoleBinding {
+		return &permsv1.PermsClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "testing"},
+			Spec: permsv1.PermsClusterRoleBindingSpec{
+				Role:   "view",
+				Groups: []string{"group1"},
+				NamespaceSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"team": "testing"},
+				},
+				Users: []string{"user1", "user2"},
+				Serviceaccounts: []permsv1.Serviceaccount{
+					{Name: "default", Namespace: "testing"},
+				},
+			},
+			Status: permsv1.PermsClusterRoleBindingStatus{
+				Bindings: []permsv1.BindingReference{{
+					Kind:    "ClusterRoleBinding",
+					Name:    "testing",
+					RoleRef: permsv1.RoleReference{Kind: "ClusterRole", Name: "view"},
+				}},
+				Count: permsv1.SubjectCount{Users: 2, Groups: 1, Serviceaccounts: 1},
+			},
+		}
+	}
+
+	It("should convert the renamed and retyped fields", func() {
+		pcrb := &PermsClusterRoleBinding{}
+		Expect(pcrb.ConvertFrom(newHub())).To(Succeed())
+		Expect(pcrb.Spec.Users).To(Equal([]string{"user1", "user2"}))
+		Expect(pcrb.Status.Count).To(Equal(PCrbCount{Users: "2", Groups: "1", Serviceaccounts: "1"}))
+		Expect(pcrb.Status.Bindings).To(Equal([]string{"ClusterRoleBinding/testing"}))
+	})
+
+	It("should round-trip v1 through v1beta1", func() {
+		hub := newHub()
+		pcrb := &PermsClusterRoleBinding{}
+		Expect(pcrb.ConvertFrom(hub)).To(Succeed())
+		converted := &permsv1.PermsClusterRoleBinding{}
+		Expect(pcrb.ConvertTo(converted)).To(Succeed())
+		Expect(converted).To(Equal(hub))
+	})
+
+	It("should round-trip v1beta1 through v1", func() {
+		pcrb := &PermsClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "testing"},
+			Spec: PermsClusterRoleBindingSpec{
+				Role:  "view",
+				Users: []string{"user1"},
+			},
+			Status: PermsClusterRoleBindingStatus{
+				Count: PCrbCount{Users: "1", Groups: "0", Serviceaccounts: "0"},
+			},
+		}
+		hub := &permsv1.PermsClusterRoleBinding{}
+		Expect(pcrb.ConvertTo(hub)).To(Succeed())
+		converted := &PermsClusterRoleBinding{}
+		Expect(converted.ConvertFrom(hub)).To(Succeed())
+		Expect(converted).To(Equal(pcrb))
+	})
+})
@@ -33,6 +33,10 @@ type PermsClusterRoleBindingSpec struct {
 	Groups          []string         `json:"groups,omitempty"`
 	Users           []string         `json:"user,omitempty"`
 	Serviceaccounts []Serviceaccount `json:"serviceaccounts,omitempty"`
+
+	// ImmutableRoleRef disables the role switch. A changed role is not applied and the
+	// resource is set to Degraded instead.
+	ImmutableRoleRef bool `json:"immutableRoleRef,omitempty"`
 }
 
 // PermsClusterRoleBindingStatus defines the observed state of PermsClusterRoleBinding
@@ -50,6 +54,7 @@ type PCrbCount struct {
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:deprecatedversion:warning="perms.infra-mgmt.io/v1beta1 is deprecated, use perms.infra-mgmt.io/v1"
 //+kubebuilder:resource:shortName=permscrb;pcrb,scope=Cluster
 //+kubebuilder:printcolumn:name=Users,type="string",JSONPath=".status.count.users"
 //+kubebuilder:printcolumn:name=Groups,type=string,JSONPath=".status.count.groups"
@@ -0,0 +1,217 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	permsv1 "github.com/infra-mgmt-io/perms/api/v1"
+)
+
+// ConversionDataAnnotation keeps the fields of a v1 object which can not be represented in
+// v1beta1, so they survive a round trip through a v1beta1 client.
+const ConversionDataAnnotation = "perms.infra-mgmt.io/conversion-data"
+
+// ConvertTo converts this PermsRoleBinding to the Hub version (v1).
+func (src *PermsRoleBinding) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*permsv1.PermsRoleBinding)
+
+	// start with the fields which were lost in an earlier conversion to v1beta1
+	restored := &permsv1.PermsRoleBinding{}
+	if err := restoreConversionData(&src.ObjectMeta, restored); err != nil {
+		return err
+	}
+	src.ObjectMeta.DeepCopyInto(&dst.ObjectMeta)
+	removeConversionData(&dst.ObjectMeta)
+
+	dst.Spec = restored.Spec
+	dst.Spec.Kind = src.Spec.Kind
+	dst.Spec.Role = src.Spec.Role
+	dst.Spec.Groups = src.Spec.Groups
+	dst.Spec.Users = src.Spec.Users
+	dst.Spec.Serviceaccounts = convertServiceaccountsToV1(src.Spec.Serviceaccounts, restored.Spec.Serviceaccounts)
+	dst.Spec.ImmutableRoleRef = src.Spec.ImmutableRoleRef
+
+	dst.Status = restored.Status
+	dst.Status.Bindings = convertBindingsToV1(src.Status.Bindings, restored.Status.Bindings)
+	dst.Status.Count = permsv1.SubjectCount{
+		Users:           atoi32(src.Status.Count.Users),
+		Groups:          atoi32(src.Status.Count.Groups),
+		Serviceaccounts: atoi32(src.Status.Count.Serviceaccounts),
+	}
+	dst.Status.Conditions = src.Status.Conditions
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1) to this version.
+func (dst *PermsRoleBinding) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*permsv1.PermsRoleBinding)
+
+	src.ObjectMeta.DeepCopyInto(&dst.ObjectMeta)
+	removeConversionData(&dst.ObjectMeta)
+
+	dst.Spec.Kind = src.Spec.Kind
+	dst.Spec.Role = src.Spec.Role
+	dst.Spec.Groups = src.Spec.Groups
+	dst.Spec.Users = src.Spec.Users
+	dst.Spec.Serviceaccounts = convertServiceaccountsFromV1(src.Spec.Serviceaccounts)
+	dst.Spec.ImmutableRoleRef = src.Spec.ImmutableRoleRef
+
+	dst.Status.Bindings = convertBindingsFromV1(src.Status.Bindings)
+	dst.Status.Count = PrbCount{
+		Users:           strconv.Itoa(int(src.Status.Count.Users)),
+		Groups:          strconv.Itoa(int(src.Status.Count.Groups)),
+		Serviceaccounts: strconv.Itoa(int(src.Status.Count.Serviceaccounts)),
+	}
+	dst.Status.Conditions = src.Status.Conditions
+
+	// keep the fields which got lost in the conversion
+	converted := &permsv1.PermsRoleBinding{}
+	if err := dst.ConvertTo(converted); err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(converted.Spec, src.Spec) || !reflect.DeepEqual(converted.Status, src.Status) {
+		// the counts and conditions are converted
+		status := src.Status.DeepCopy()
+		status.Count = permsv1.SubjectCount{}
+		status.Conditions = nil
+		return storeConversionData(&dst.ObjectMeta, &permsv1.PermsRoleBinding{
+			Spec:   src.Spec,
+			Status: *status,
+		})
+	}
+	return nil
+}
+
+// storeConversionData stores the object in the conversion data annotation
+func storeConversionData(meta *metav1.ObjectMeta, obj interface{}) error {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	if meta.Annotations == nil {
+		meta.Annotations = map[string]string{}
+	}
+	meta.Annotations[ConversionDataAnnotation] = string(data)
+	return nil
+}
+
+// restoreConversionData restores the object from the conversion data annotation if it exists
+func restoreConversionData(meta *metav1.ObjectMeta, obj interface{}) error {
+	data, ok := meta.Annotations[ConversionDataAnnotation]
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal([]byte(data), obj)
+}
+
+// removeConversionData removes the conversion data annotation
+func removeConversionData(meta *metav1.ObjectMeta) {
+	delete(meta.Annotations, ConversionDataAnnotation)
+	if len(meta.Annotations) == 0 {
+		meta.Annotations = nil
+	}
+}
+
+// convertServiceaccountsToV1 converts the serviceaccounts, the expiries are taken from the
+// restored serviceaccounts
+func convertServiceaccountsToV1(serviceaccounts []Serviceaccount, restored []permsv1.Serviceaccount) []permsv1.Serviceaccount {
+	if serviceaccounts == nil {
+		return nil
+	}
+	converted := make([]permsv1.Serviceaccount, len(serviceaccounts))
+	for i, sa := range serviceaccounts {
+		converted[i] = permsv1.Serviceaccount{Name: sa.Name, Namespace: sa.Namespace}
+		for _, r := range restored {
+			if r.Name == sa.Name && r.Namespace == sa.Namespace {
+				converted[i].ExpiresAt = r.ExpiresAt
+			}
+		}
+	}
+	return converted
+}
+
+func convertServiceaccountsFromV1(serviceaccounts []permsv1.Serviceaccount) []Serviceaccount {
+	if serviceaccounts == nil {
+		return nil
+	}
+	converted := make([]Serviceaccount, len(serviceaccounts))
+	for i, sa := range serviceaccounts {
+		converted[i] = Serviceaccount{Name: sa.Name, Namespace: sa.Namespace}
+	}
+	return converted
+}
+
+// convertBindingsToV1 parses the v1beta1 bindings "<kind>/<namespace>/<name>" and
+// "<kind>/<name>", the role references are taken from the restored bindings.
+func convertBindingsToV1(bindings []string, restored []permsv1.BindingReference) []permsv1.BindingReference {
+	if bindings == nil {
+		return nil
+	}
+	converted := make([]permsv1.BindingReference, 0, len(bindings))
+	for _, binding := range bindings {
+		parts := strings.SplitN(binding, "/", 3)
+		ref := permsv1.BindingReference{Kind: parts[0]}
+		switch len(parts) {
+		case 1:
+			ref = permsv1.BindingReference{Name: parts[0]}
+		case 2:
+			ref.Name = parts[1]
+		default:
+			ref.Namespace = parts[1]
+			ref.Name = parts[2]
+		}
+		for _, r := range restored {
+			if r.Kind == ref.Kind && r.Namespace == ref.Namespace && r.Name == ref.Name {
+				ref.RoleRef = r.RoleRef
+			}
+		}
+		converted = append(converted, ref)
+	}
+	return converted
+}
+
+// convertBindingsFromV1 formats the bindings as "<kind>/<namespace>/<name>" and "<kind>/<name>"
+func convertBindingsFromV1(bindings []permsv1.BindingReference) []string {
+	if bindings == nil {
+		return nil
+	}
+	converted := make([]string, len(bindings))
+	for i, binding := range bindings {
+		if binding.Namespace == "" {
+			converted[i] = binding.Kind + "/" + binding.Name
+			continue
+		}
+		converted[i] = binding.Kind + "/" + binding.Namespace + "/" + binding.Name
+	}
+	return converted
+}
+
+// atoi32 converts the v1beta1 string counts, invalid counts are converted to 0
+func atoi32(s string) int32 {
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return 0
+	}
+	return int32(i)
+}
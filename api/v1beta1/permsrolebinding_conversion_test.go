@@ -0,0 +1,101 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	permsv1 "github.com/infra-mgmt-io/perms/api/v1"
+)
+
+var _ = Describe("PermsRoleBinding conversion", func() {
+	// metav1.Time is decoded in the local time zone
+	expiresAt := metav1.NewTime(time.Date(2022, 6, 1, 0, 0, 0, 0, time.Local))
+
+	newHub := func() *permsv1.PermsRoleBinding {
+		return &permsv1.PermsRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "testing", Namespace: "testing"},
+			Spec: permsv1.PermsRoleBindingSpec{
+				Kind:   "ClusterRole",
+				Role:   "view",
+				Roles:  []permsv1.RoleSpec{{Kind: "Role", Name: "secret-reader"}},
+				Groups: []string{"group1"},
+				Users:  []string{"user1", "user2"},
+				Serviceaccounts: []permsv1.Serviceaccount{
+					{Name: "default", Namespace: "testing", ExpiresAt: &expiresAt},
+				},
+				Validity: permsv1.Validity{
+					UserExpiry: map[string]metav1.Time{"user2": expiresAt},
+				},
+			},
+			Status: permsv1.PermsRoleBindingStatus{
+				Expirations: []permsv1.SubjectExpiration{
+					{Kind: "ServiceAccount", Name: "default", Namespace: "testing", ExpiresAt: expiresAt},
+					{Kind: "User", Name: "user2", ExpiresAt: expiresAt},
+				},
+				NextTransitionTime: &expiresAt,
+				Bindings: []permsv1.BindingReference{{
+					Kind:      "RoleBinding",
+					Namespace: "testing",
+					Name:      "testing",
+					RoleRef:   permsv1.RoleReference{Kind: "ClusterRole", Name: "view"},
+				}},
+				Count: permsv1.SubjectCount{Users: 2, Groups: 1, Serviceaccounts: 1},
+			},
+		}
+	}
+
+	It("should convert the renamed and retyped fields", func() {
+		prb := &PermsRoleBinding{}
+		Expect(prb.ConvertFrom(newHub())).To(Succeed())
+		Expect(prb.Spec.Users).To(Equal([]string{"user1", "user2"}))
+		Expect(prb.Status.Count).To(Equal(PrbCount{Users: "2", Groups: "1", Serviceaccounts: "1"}))
+		Expect(prb.Status.Bindings).To(Equal([]string{"RoleBinding/testing/testing"}))
+	})
+
+	It("should round-trip v1 through v1beta1", func() {
+		hub := newHub()
+		prb := &PermsRoleBinding{}
+		Expect(prb.ConvertFrom(hub)).To(Succeed())
+		converted := &permsv1.PermsRoleBinding{}
+		Expect(prb.ConvertTo(converted)).To(Succeed())
+		Expect(converted).To(Equal(hub))
+	})
+
+	It("should round-trip v1beta1 through v1", func() {
+		prb := &PermsRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "testing", Namespace: "testing"},
+			Spec: PermsRoleBindingSpec{
+				Kind:  "Role",
+				Role:  "reader",
+				Users: []string{"user1"},
+			},
+			Status: PermsRoleBindingStatus{
+				Count: PrbCount{Users: "1", Groups: "0", Serviceaccounts: "0"},
+			},
+		}
+		hub := &permsv1.PermsRoleBinding{}
+		Expect(prb.ConvertTo(hub)).To(Succeed())
+		converted := &PermsRoleBinding{}
+		Expect(converted.ConvertFrom(hub)).To(Succeed())
+		Expect(converted).To(Equal(prb))
+	})
+})
@@ -28,17 +28,27 @@ type PermsRoleBindingSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
-	// Foo is an example field of PermsRoleBinding. Edit permsrolebinding_types.go to remove/update
-	Kind            string           `json:"kind"`
+	// Kind of the referenced role, Role or ClusterRole. Defaults to ClusterRole.
+	//+kubebuilder:default=ClusterRole
+	//+optional
+	Kind string `json:"kind,omitempty"`
+
 	Role            string           `json:"role"`
 	Groups          []string         `json:"groups,omitempty"`
 	Users           []string         `json:"user,omitempty"`
 	Serviceaccounts []Serviceaccount `json:"serviceaccounts,omitempty"`
+
+	// ImmutableRoleRef disables the role switch. A changed role is not applied and the
+	// resource is set to Degraded instead.
+	ImmutableRoleRef bool `json:"immutableRoleRef,omitempty"`
 }
 
 type Serviceaccount struct {
-	Name      string `json:"name"`
-	Namespace string `json:"namespace"`
+	Name string `json:"name"`
+	// Namespace of the serviceaccount. Defaults to the namespace of a PermsRoleBinding,
+	// required for a PermsClusterRoleBinding.
+	//+optional
+	Namespace string `json:"namespace,omitempty"`
 }
 
 // PermsRoleBindingStatus defines the observed state of PermsRoleBinding
@@ -56,6 +66,7 @@ type PrbCount struct {
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:deprecatedversion:warning="perms.infra-mgmt.io/v1beta1 is deprecated, use perms.infra-mgmt.io/v1"
 //+kubebuilder:resource:path=permsrolebindings,shortName=permsrb;prb
 //+kubebuilder:printcolumn:name=Users,type="string",JSONPath=".status.count.users"
 //+kubebuilder:printcolumn:name=Groups,type=string,JSONPath=".status.count.groups"
@@ -0,0 +1,193 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	permsv1 "github.com/infra-mgmt-io/perms/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// requiresApproval returns true if a PermsPolicy flags the spec or the last approved spec of the
+// PermsRoleBinding as sensitive, a change away from a sensitive role needs an approval as well
+func requiresApproval(ctx context.Context, c client.Reader, prb *permsv1.PermsRoleBinding) (bool, error) {
+	policies := &permsv1.PermsPolicyList{}
+	if err := c.List(ctx, policies); err != nil {
+		return false, err
+	}
+	if permsv1.RequiresApproval(policies.Items, prb.PolicyRequest()) {
+		return true, nil
+	}
+	if prb.Status.ApprovedSpec == nil {
+		return false, nil
+	}
+	approved := prb.DeepCopy()
+	approved.Spec = *prb.Status.ApprovedSpec
+	return permsv1.RequiresApproval(policies.Items, approved.PolicyRequest()), nil
+}
+
+// reconcileApproval replaces the spec of a sensitive PermsRoleBinding with the last approved spec
+// while a change waits for the approval of a second user and records the pending change. It
+// returns true if nothing was approved yet and the reconcile has to wait.
+func (r *PermsRoleBindingReconciler) reconcileApproval(ctx context.Context, p *permsv1.PermsRoleBinding) (bool, error) {
+	sensitive, err := requiresApproval(ctx, r.Client, p)
+	if err != nil {
+		return false, err
+	}
+	if !sensitive {
+		p.Status.ApprovedSpec = nil
+		p.Status.ApprovedGeneration = 0
+		p.Status.PendingChange = nil
+		meta.RemoveStatusCondition(&p.Status.Conditions, "ApprovalPending")
+		return false, nil
+	}
+
+	// without the webhooks anyone who may edit the resource can set the approver annotations
+	approver := ""
+	if r.WebhooksEnabled {
+		approver = p.Approver()
+	}
+	if approver != "" || (p.Status.ApprovedSpec != nil && equality.Semantic.DeepEqual(*p.Status.ApprovedSpec, p.Spec)) {
+		if approver != "" && p.Status.ApprovedGeneration != p.Generation {
+			recordEvent(r.Recorder, p, nil, corev1.EventTypeNormal, reasonChangeApproved, "%s approved generation %d authored by %s",
+				approver, p.Generation, p.Annotations[permsv1.AuthorAnnotation])
+			p.Status.ApprovedSpec = p.Spec.DeepCopy()
+			p.Status.ApprovedGeneration = p.Generation
+		}
+		p.Status.PendingChange = nil
+		setApprovalPendingStatus(ctx, &p.Status.Conditions, nil, r.WebhooksEnabled)
+		return false, nil
+	}
+
+	changes := permsv1.SpecChanges(p.Status.ApprovedSpec, p.Spec)
+	if p.Status.PendingChange == nil || p.Status.PendingChange.Generation != p.Generation {
+		recordEvent(r.Recorder, p, nil, corev1.EventTypeWarning, reasonApprovalPending, "Generation %d by %s waits for the approval of a second user: %s",
+			p.Generation, p.Annotations[permsv1.AuthorAnnotation], strings.Join(changes, "; "))
+	}
+	p.Status.PendingChange = &permsv1.PendingChange{
+		Generation: p.Generation,
+		Author:     p.Annotations[permsv1.AuthorAnnotation],
+		Changes:    changes,
+	}
+	setApprovalPendingStatus(ctx, &p.Status.Conditions, p.Status.PendingChange, r.WebhooksEnabled)
+	if p.Status.ApprovedSpec == nil {
+		return true, nil
+	}
+	p.Spec = *p.Status.ApprovedSpec.DeepCopy()
+	return false, nil
+}
+
+// requiresClusterApproval returns true if a PermsPolicy flags the spec or the last approved spec
+// of the PermsClusterRoleBinding as sensitive. A namespace selector may select a sensitive
+// namespace at any time, it matches all namespaces like a ClusterRoleBinding.
+func requiresClusterApproval(ctx context.Context, c client.Reader, pcrb *permsv1.PermsClusterRoleBinding) (bool, error) {
+	policies := &permsv1.PermsPolicyList{}
+	if err := c.List(ctx, policies); err != nil {
+		return false, err
+	}
+	specs := []permsv1.PermsClusterRoleBindingSpec{pcrb.Spec}
+	if pcrb.Status.ApprovedSpec != nil {
+		specs = append(specs, *pcrb.Status.ApprovedSpec)
+	}
+	for _, spec := range specs {
+		p := pcrb.DeepCopy()
+		p.Spec = spec
+		req := p.PolicyRequest()
+		req.ClusterWide = req.ClusterWide || spec.NamespaceSelector != nil
+		if permsv1.RequiresApproval(policies.Items, req) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// reconcileApproval replaces the spec of a sensitive PermsClusterRoleBinding with the last
+// approved spec while a change waits for the approval of a second user and records the pending
+// change. It returns true if nothing was approved yet and the reconcile has to wait.
+func (r *PermsClusterRoleBindingReconciler) reconcileApproval(ctx context.Context, p *permsv1.PermsClusterRoleBinding) (bool, error) {
+	sensitive, err := requiresClusterApproval(ctx, r.Client, p)
+	if err != nil {
+		return false, err
+	}
+	if !sensitive {
+		p.Status.ApprovedSpec = nil
+		p.Status.ApprovedGeneration = 0
+		p.Status.PendingChange = nil
+		meta.RemoveStatusCondition(&p.Status.Conditions, "ApprovalPending")
+		return false, nil
+	}
+
+	// without the webhooks anyone who may edit the resource can set the approver annotations
+	approver := ""
+	if r.WebhooksEnabled {
+		approver = p.Approver()
+	}
+	if approver != "" || (p.Status.ApprovedSpec != nil && equality.Semantic.DeepEqual(*p.Status.ApprovedSpec, p.Spec)) {
+		if approver != "" && p.Status.ApprovedGeneration != p.Generation {
+			recordEvent(r.Recorder, p, nil, corev1.EventTypeNormal, reasonChangeApproved, "%s approved generation %d authored by %s",
+				approver, p.Generation, p.Annotations[permsv1.AuthorAnnotation])
+			p.Status.ApprovedSpec = p.Spec.DeepCopy()
+			p.Status.ApprovedGeneration = p.Generation
+		}
+		p.Status.PendingChange = nil
+		setApprovalPendingStatus(ctx, &p.Status.Conditions, nil, r.WebhooksEnabled)
+		return false, nil
+	}
+
+	changes := permsv1.ClusterSpecChanges(p.Status.ApprovedSpec, p.Spec)
+	if p.Status.PendingChange == nil || p.Status.PendingChange.Generation != p.Generation {
+		recordEvent(r.Recorder, p, nil, corev1.EventTypeWarning, reasonApprovalPending, "Generation %d by %s waits for the approval of a second user: %s",
+			p.Generation, p.Annotations[permsv1.AuthorAnnotation], strings.Join(changes, "; "))
+	}
+	p.Status.PendingChange = &permsv1.PendingChange{
+		Generation: p.Generation,
+		Author:     p.Annotations[permsv1.AuthorAnnotation],
+		Changes:    changes,
+	}
+	setApprovalPendingStatus(ctx, &p.Status.Conditions, p.Status.PendingChange, r.WebhooksEnabled)
+	if p.Status.ApprovedSpec == nil {
+		return true, nil
+	}
+	p.Spec = *p.Status.ApprovedSpec.DeepCopy()
+	return false, nil
+}
+
+// helper to set the "ApprovalPending" status of a sensitive PermsRoleBinding or
+// PermsClusterRoleBinding
+func setApprovalPendingStatus(ctx context.Context, conditions *[]metav1.Condition, pending *permsv1.PendingChange, webhooksEnabled bool) {
+	condition := metav1.Condition{
+		Type:    "ApprovalPending",
+		Status:  metav1.ConditionFalse,
+		Reason:  "Approved",
+		Message: "The spec is approved by a second user",
+	}
+	if pending != nil {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = reasonApprovalPending
+		condition.Message = "Set the annotation " + permsv1.ApproveAnnotation + " to the generation to approve: " + strings.Join(pending.Changes, "; ")
+		if !webhooksEnabled {
+			condition.Message = "Approvals require --enable-webhooks, the webhooks record the authors and the approver: " + strings.Join(pending.Changes, "; ")
+		}
+	}
+	meta.SetStatusCondition(conditions, condition)
+}
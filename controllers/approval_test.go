@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	permsv1 "github.com/infra-mgmt-io/perms/api/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcileApprovalRequiresWebhooks(t *testing.T) {
+	policy := &permsv1.PermsPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "prod"},
+		Spec:       permsv1.PermsPolicySpec{TwoPersonApproval: &permsv1.ApprovalPolicy{Namespaces: []string{"prod"}}},
+	}
+	tests := []struct {
+		name            string
+		webhooksEnabled bool
+		wantHeld        bool
+	}{
+		{"approval recorded by the webhooks", true, false},
+		{"approval without webhooks", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// without the webhooks alice can write the annotations of an approval by bob herself
+			prb := &permsv1.PermsRoleBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: "admins", Namespace: "prod", Generation: 1, Annotations: map[string]string{
+					permsv1.AuthorAnnotation: "alice", permsv1.ApproveAnnotation: "1", permsv1.ApprovedByAnnotation: "bob",
+				}},
+				Spec: permsv1.PermsRoleBindingSpec{Kind: "ClusterRole", Role: "admin", Users: []string{"alice"}},
+			}
+			r := &PermsRoleBindingReconciler{
+				Client:          fake.NewClientBuilder().WithScheme(newLockdownScheme(t)).WithObjects(policy).Build(),
+				Recorder:        record.NewFakeRecorder(10),
+				WebhooksEnabled: tt.webhooksEnabled,
+			}
+			held, err := r.reconcileApproval(context.Background(), prb)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if held != tt.wantHeld {
+				t.Errorf("held = %t, want %t", held, tt.wantHeld)
+			}
+			if got := prb.Status.ApprovedGeneration == 1; got == tt.wantHeld {
+				t.Errorf("approved generation = %d", prb.Status.ApprovedGeneration)
+			}
+			condition := meta.FindStatusCondition(prb.Status.Conditions, "ApprovalPending")
+			if got := condition != nil && strings.Contains(condition.Message, "--enable-webhooks"); got != tt.wantHeld {
+				t.Errorf("ApprovalPending condition = %v", condition)
+			}
+		})
+	}
+}
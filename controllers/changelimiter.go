@@ -0,0 +1,443 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	permsv1 "github.com/infra-mgmt-io/perms/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// changesReleaseAnnotation releases a change of the subjects which is held by the ChangeLimiter,
+// its value is the generation of the resource
+const changesReleaseAnnotation = "perms.infra-mgmt.io/release-changes"
+
+// changesReleaseAllAnnotation releases all held changes and resets the window, it is set on the
+// ConfigMap changeLimitConfigMap to a time after the limit was exceeded
+const changesReleaseAllAnnotation = "perms.infra-mgmt.io/release-all-changes"
+
+// changeLimitConfigMap is the ConfigMap in the namespace of the operator which keeps the counted
+// changes and the hold of the ChangeLimiter across restarts
+const changeLimitConfigMap = "perms-change-limit"
+
+// changeLimitStateKey is the key of the ConfigMap changeLimitConfigMap with the state of the
+// ChangeLimiter
+const changeLimitStateKey = "state"
+
+// changeLimitFinalizer holds the deletion of a PermsRoleBinding or PermsClusterRoleBinding until
+// the removal of its subjects is allowed by the ChangeLimiter, the bindings are garbage collected
+// afterwards
+const changeLimitFinalizer = "perms.infra-mgmt.io/change-limit"
+
+// changesHeldRequeueInterval is the interval in which held changes are checked for a release of
+// all changes, the ConfigMap with the annotation is not watched
+const changesHeldRequeueInterval = time.Minute
+
+// ChangeLimiter limits the subjects which are added to and removed from the bindings of all
+// PermsRoleBindings and PermsClusterRoleBindings within a window, including the subjects of
+// deleted resources. Once a limit is exceeded all further changes of the subjects are held until
+// they are released one by one with the annotation perms.infra-mgmt.io/release-changes, or all at
+// once with the annotation perms.infra-mgmt.io/release-all-changes on the ConfigMap
+// perms-change-limit. The ConfigMap keeps the counted changes and the hold when the operator is
+// restarted.
+type ChangeLimiter struct {
+	// MaxAdditions is the number of subjects which may be added within the window, 0 disables the limit
+	MaxAdditions int
+	// MaxRemovals is the number of subjects which may be removed within the window, 0 disables the limit
+	MaxRemovals int
+	// Window is the time in which the added and removed subjects are counted
+	Window time.Duration
+	// Client writes the ConfigMap perms-change-limit, without it the changes are only counted in memory
+	Client client.Client
+	// APIReader reads the ConfigMap directly from the API server, the ConfigMaps are not cached by
+	// the manager
+	APIReader client.Reader
+	// Namespace is the namespace of the operator and of the ConfigMap
+	Namespace string
+
+	mu sync.Mutex
+	// saveMu orders the writes of the ConfigMap, a write never overwrites a newer state
+	saveMu sync.Mutex
+	// loaded is set once the state was read from the ConfigMap
+	loaded bool
+	// dirty is set when the state changed since it was written to the ConfigMap
+	dirty bool
+	changeLimitState
+}
+
+// changeLimitState is the state of the ChangeLimiter which is kept in the ConfigMap
+type changeLimitState struct {
+	Changes []subjectChanges `json:"changes,omitempty"`
+	// Held is the reason why the changes are held, empty until a limit is exceeded
+	Held string `json:"held,omitempty"`
+	// HeldSince is the time the limit was exceeded
+	HeldSince time.Time `json:"heldSince,omitempty"`
+}
+
+// subjectChanges are the subjects added and removed by a reconcile of a resource
+type subjectChanges struct {
+	Time time.Time `json:"time"`
+	// Key identifies the resource and its change, a change which is retried is counted once
+	Key       string `json:"key"`
+	Additions int    `json:"additions,omitempty"`
+	Removals  int    `json:"removals,omitempty"`
+}
+
+// enabled returns true if a limit is configured
+func (l *ChangeLimiter) enabled() bool {
+	return l != nil && (l.MaxAdditions > 0 || l.MaxRemovals > 0)
+}
+
+// allow records a change of the subjects and returns an empty string if it may be applied,
+// otherwise the reason why it is held. A released change is applied and counted even if it
+// exceeds a limit.
+func (l *ChangeLimiter) allow(now time.Time, key string, additions int, removals int, released bool) string {
+	if additions == 0 && removals == 0 {
+		return ""
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// forget the changes which left the window
+	i := 0
+	for i < len(l.Changes) && now.Sub(l.Changes[i].Time) >= l.Window {
+		i++
+	}
+	if i > 0 {
+		l.Changes, l.dirty = l.Changes[i:], true
+	}
+	for _, c := range l.Changes {
+		if c.Key == key {
+			return ""
+		}
+	}
+
+	if !released {
+		if l.Held != "" {
+			return l.Held
+		}
+		added, removed := additions, removals
+		for _, c := range l.Changes {
+			added += c.Additions
+			removed += c.Removals
+		}
+		switch {
+		case l.MaxRemovals > 0 && removed > l.MaxRemovals:
+			l.Held = fmt.Sprintf("%d subjects would be removed within %s, more than the limit of %d", removed, l.Window, l.MaxRemovals)
+		case l.MaxAdditions > 0 && added > l.MaxAdditions:
+			l.Held = fmt.Sprintf("%d subjects would be added within %s, more than the limit of %d", added, l.Window, l.MaxAdditions)
+		}
+		if l.Held != "" {
+			l.Held += " at " + now.UTC().Format(time.RFC3339)
+			l.HeldSince, l.dirty = now, true
+			return l.Held
+		}
+	}
+	l.Changes = append(l.Changes, subjectChanges{Time: now, Key: key, Additions: additions, Removals: removals})
+	l.dirty = true
+	return ""
+}
+
+// release releases all held changes and forgets the changes in the window if the value of the
+// release annotation is a time after the limit was exceeded, an older release does not release a
+// later hold. It returns true if the changes were held and are released.
+func (l *ChangeLimiter) release(value string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.Held == "" {
+		return false
+	}
+	releasedAt, err := time.Parse(time.RFC3339, value)
+	if err != nil || releasedAt.Before(l.HeldSince.Truncate(time.Second)) {
+		return false
+	}
+	l.changeLimitState, l.dirty = changeLimitState{}, true
+	return true
+}
+
+// sync restores the counted changes and the hold from the ConfigMap perms-change-limit once, and
+// releases all held changes when its annotation perms.infra-mgmt.io/release-all-changes is set
+// to a time after the limit was exceeded
+func (l *ChangeLimiter) sync(ctx context.Context) error {
+	if l.Client == nil {
+		return nil
+	}
+	l.mu.Lock()
+	loaded, held := l.loaded, l.Held != ""
+	l.mu.Unlock()
+	if loaded && !held {
+		return nil
+	}
+	key := types.NamespacedName{Namespace: l.Namespace, Name: changeLimitConfigMap}
+	configMap := &corev1.ConfigMap{}
+	if err := l.APIReader.Get(ctx, key, configMap); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if !loaded {
+		if err := l.restore(configMap.Data[changeLimitStateKey]); err != nil {
+			return fmt.Errorf("reading the state of the change limit from ConfigMap %s: %w", key, err)
+		}
+	}
+	if value := configMap.Annotations[changesReleaseAllAnnotation]; l.release(value) {
+		log.FromContext(ctx).Info("Released all held changes of the subjects", "ConfigMap.Namespace", key.Namespace, "ConfigMap.Name", key.Name, "ReleasedAt", value)
+	}
+	return l.save(ctx)
+}
+
+// restore sets the state which was read from the ConfigMap
+func (l *ChangeLimiter) restore(data string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.loaded {
+		return nil
+	}
+	state := changeLimitState{}
+	if data != "" {
+		if err := json.Unmarshal([]byte(data), &state); err != nil {
+			return err
+		}
+	}
+	l.changeLimitState, l.loaded = state, true
+	return nil
+}
+
+// save writes the state to the ConfigMap perms-change-limit if it changed, a change of the
+// subjects is only applied after it was saved
+func (l *ChangeLimiter) save(ctx context.Context) error {
+	if l.Client == nil {
+		return nil
+	}
+	l.saveMu.Lock()
+	defer l.saveMu.Unlock()
+	l.mu.Lock()
+	if !l.dirty {
+		l.mu.Unlock()
+		return nil
+	}
+	data, err := json.Marshal(l.changeLimitState)
+	l.dirty = false
+	l.mu.Unlock()
+	if err == nil {
+		key := types.NamespacedName{Namespace: l.Namespace, Name: changeLimitConfigMap}
+		err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			configMap := &corev1.ConfigMap{}
+			if err := l.APIReader.Get(ctx, key, configMap); errors.IsNotFound(err) {
+				configMap = &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+					Data:       map[string]string{changeLimitStateKey: string(data)},
+				}
+				return l.Client.Create(ctx, configMap)
+			} else if err != nil {
+				return err
+			}
+			if configMap.Data == nil {
+				configMap.Data = map[string]string{}
+			}
+			configMap.Data[changeLimitStateKey] = string(data)
+			return l.Client.Update(ctx, configMap)
+		})
+	}
+	if err != nil {
+		l.mu.Lock()
+		l.dirty = true
+		l.mu.Unlock()
+	}
+	return err
+}
+
+// limitChanges checks the subjects which a reconcile adds to and removes from the bindings of a
+// PermsRoleBinding or PermsClusterRoleBinding against the limiter. Each subject is counted once
+// for every binding: the existing desired bindings are compared with the subjects to apply, a
+// desired binding which does not exist adds all subjects, and the bindings controlled by the
+// resource which are not desired anymore remove all of theirs. It returns true if the change is
+// held, the bindings are not updated until it is released.
+func limitChanges(ctx context.Context, c client.Reader, recorder record.EventRecorder, limiter *ChangeLimiter, kind string, obj client.Object,
+	labels map[string]string, desired []permsv1.BindingReference, subjects []rbacv1.Subject, conditions *[]metav1.Condition, now time.Time) (bool, error) {
+	if !limiter.enabled() {
+		return false, nil
+	}
+	if err := limiter.sync(ctx); err != nil {
+		return false, err
+	}
+	added, removed, err := bindingSubjectChanges(ctx, c, obj, labels, desired, subjects)
+	if err != nil {
+		return false, err
+	}
+	sum := sha256.Sum256([]byte("+" + strings.Join(added, ",") + "-" + strings.Join(removed, ",")))
+	key := kind + "/" + client.ObjectKeyFromObject(obj).String() + ":" + hex.EncodeToString(sum[:8])
+	released := obj.GetAnnotations()[changesReleaseAnnotation] == strconv.FormatInt(obj.GetGeneration(), 10)
+	condition := meta.FindStatusCondition(*conditions, "ChangesHeld")
+
+	reason := limiter.allow(now, key, len(added), len(removed), released)
+	if err := limiter.save(ctx); err != nil {
+		return false, err
+	}
+	if reason != "" {
+		message := fmt.Sprintf("Changes of the subjects are held: %s. Adding %d and removing %d subjects waits for the release with the annotation %s=%d,"+
+			" or of all changes with the annotation %s set to the current time on the ConfigMap %s/%s",
+			reason, len(added), len(removed), changesReleaseAnnotation, obj.GetGeneration(), changesReleaseAllAnnotation, limiter.Namespace, changeLimitConfigMap)
+		if condition == nil || condition.Status != metav1.ConditionTrue || condition.Message != message {
+			recordEvent(recorder, obj, nil, corev1.EventTypeWarning, reasonChangesHeld, "%s", message)
+			changesHeld.WithLabelValues(kind).Inc()
+		}
+		setChangesHeldStatus(ctx, conditions, message)
+		return true, nil
+	}
+	if condition != nil && condition.Status == metav1.ConditionTrue {
+		recordEvent(recorder, obj, nil, corev1.EventTypeNormal, reasonChangesReleased,
+			"Releasing the held change of the subjects, adding %d and removing %d subjects", len(added), len(removed))
+		meta.SetStatusCondition(conditions, metav1.Condition{
+			Type:    "ChangesHeld",
+			Status:  metav1.ConditionFalse,
+			Reason:  reasonChangesReleased,
+			Message: "The held change of the subjects was released",
+		})
+	}
+	return false, nil
+}
+
+// limitDeletion counts the subjects of all bindings controlled by a deleted PermsRoleBinding or
+// PermsClusterRoleBinding as removed and removes the finalizer once the removal is allowed, the
+// bindings are then garbage collected. It returns true if the removal is held and the resource
+// has to wait.
+func limitDeletion(ctx context.Context, c client.Client, recorder record.EventRecorder, limiter *ChangeLimiter, kind string, obj client.Object,
+	labels map[string]string, conditions *[]metav1.Condition, now time.Time) (bool, error) {
+	if !controllerutil.ContainsFinalizer(obj, changeLimitFinalizer) {
+		return false, nil
+	}
+	held, err := limitChanges(ctx, c, recorder, limiter, kind, obj, labels, nil, nil, conditions, now)
+	if err != nil || held {
+		return held, err
+	}
+	controllerutil.RemoveFinalizer(obj, changeLimitFinalizer)
+	return false, c.Update(ctx, obj)
+}
+
+// addChangeLimitFinalizer adds the finalizer which counts the removal of the subjects of a
+// deleted resource while a limit is configured
+func addChangeLimitFinalizer(ctx context.Context, c client.Client, limiter *ChangeLimiter, obj client.Object) error {
+	if !limiter.enabled() || controllerutil.ContainsFinalizer(obj, changeLimitFinalizer) {
+		return nil
+	}
+	controllerutil.AddFinalizer(obj, changeLimitFinalizer)
+	return c.Update(ctx, obj)
+}
+
+// bindingSubjectChanges returns the subjects added to and removed from the desired bindings and
+// the removed subjects of the bindings with the labels which are controlled by the resource and
+// not desired anymore, each as the binding and the subject. The temporary binding of a role
+// switch is not counted.
+func bindingSubjectChanges(ctx context.Context, c client.Reader, obj client.Object, labels map[string]string,
+	desired []permsv1.BindingReference, subjects []rbacv1.Subject) ([]string, []string, error) {
+	var added, removed []string
+	wanted := map[string]bool{}
+	for _, b := range desired {
+		name := b.Kind + "/" + types.NamespacedName{Namespace: b.Namespace, Name: b.Name}.String()
+		wanted[name] = true
+		applied, err := boundSubjects(ctx, c, b)
+		if err != nil {
+			return nil, nil, err
+		}
+		a, r := diffSubjects(applied, subjects)
+		added = append(added, bindingSubjects(name, a)...)
+		removed = append(removed, bindingSubjects(name, r)...)
+	}
+
+	roleBindings := &rbacv1.RoleBindingList{}
+	if err := c.List(ctx, roleBindings, client.MatchingLabels(labels)); err != nil {
+		return nil, nil, err
+	}
+	clusterRoleBindings := &rbacv1.ClusterRoleBindingList{}
+	if err := c.List(ctx, clusterRoleBindings, client.MatchingLabels(labels)); err != nil {
+		return nil, nil, err
+	}
+	var owned []client.Object
+	for i := range roleBindings.Items {
+		owned = append(owned, &roleBindings.Items[i])
+	}
+	for i := range clusterRoleBindings.Items {
+		owned = append(owned, &clusterRoleBindings.Items[i])
+	}
+	for _, binding := range owned {
+		name := bindingKind(binding) + "/" + client.ObjectKeyFromObject(binding).String()
+		if wanted[name] || binding.GetName() == roleSwitchBindingName(obj.GetName()) || !metav1.IsControlledBy(binding, obj) {
+			continue
+		}
+		_, bound := bindingContent(binding)
+		removed = append(removed, bindingSubjects(name, subjectNames(*bound))...)
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed, nil
+}
+
+// bindingSubjects returns the names of the subjects prefixed with the binding
+func bindingSubjects(binding string, names []string) []string {
+	prefixed := make([]string, 0, len(names))
+	for _, name := range names {
+		prefixed = append(prefixed, binding+":"+name)
+	}
+	return prefixed
+}
+
+// boundSubjects returns the subjects of a binding, none if it does not exist
+func boundSubjects(ctx context.Context, c client.Reader, b permsv1.BindingReference) ([]rbacv1.Subject, error) {
+	var binding client.Object
+	switch b.Kind {
+	case "ClusterRoleBinding":
+		binding = &rbacv1.ClusterRoleBinding{}
+	case "RoleBinding":
+		binding = &rbacv1.RoleBinding{}
+	default:
+		return nil, nil
+	}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: b.Namespace, Name: b.Name}, binding); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	_, subjects := bindingContent(binding)
+	return *subjects, nil
+}
+
+// helper to set the "ChangesHeld" status of a resource whose change of the subjects is held
+func setChangesHeldStatus(ctx context.Context, conditions *[]metav1.Condition, message string) {
+	meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:    "ChangesHeld",
+		Status:  metav1.ConditionTrue,
+		Reason:  reasonChangesHeld,
+		Message: message,
+	})
+}
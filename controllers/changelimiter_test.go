@@ -0,0 +1,269 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	permsv1 "github.com/infra-mgmt-io/perms/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func TestChangeLimiterAllow(t *testing.T) {
+	start := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+	// change is a change of the subjects at minutes after the start
+	type change struct {
+		minutes   int
+		key       string
+		additions int
+		removals  int
+		released  bool
+		wantHeld  bool
+	}
+	tests := []struct {
+		name    string
+		changes []change
+	}{
+		{"changes within the limits", []change{
+			{0, "a", 2, 1, false, false},
+			{1, "b", 1, 2, false, false},
+		}},
+		{"no change", []change{
+			{0, "a", 0, 4, false, false},
+			{1, "b", 0, 0, false, false},
+		}},
+		{"removals above the limit", []change{
+			{0, "a", 0, 3, false, false},
+			{1, "b", 0, 2, false, true},
+		}},
+		{"additions above the limit", []change{
+			{0, "a", 3, 0, false, false},
+			{1, "b", 1, 0, false, true},
+		}},
+		{"all changes are held after a limit was exceeded", []change{
+			{0, "a", 0, 5, false, true},
+			{1, "b", 1, 0, false, true},
+			{20, "c", 1, 0, false, true},
+		}},
+		{"retried change is counted once", []change{
+			{0, "a", 0, 3, false, false},
+			{1, "a", 0, 3, false, false},
+			{2, "b", 0, 1, false, false},
+		}},
+		{"changes which left the window are forgotten", []change{
+			{0, "a", 0, 4, false, false},
+			{10, "b", 0, 4, false, false},
+		}},
+		{"released change exceeds the limit", []change{
+			{0, "a", 0, 3, false, false},
+			{1, "b", 0, 3, false, true},
+			{2, "b", 0, 3, true, false},
+			{3, "c", 0, 1, false, true},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &ChangeLimiter{MaxAdditions: 3, MaxRemovals: 4, Window: 10 * time.Minute}
+			for i, c := range tt.changes {
+				now := start.Add(time.Duration(c.minutes) * time.Minute)
+				if got := l.allow(now, c.key, c.additions, c.removals, c.released) != ""; got != c.wantHeld {
+					t.Errorf("change %d: held = %t, want %t", i, got, c.wantHeld)
+				}
+			}
+		})
+	}
+}
+
+func TestChangeLimiterRelease(t *testing.T) {
+	start := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		held        bool
+		value       string
+		wantRelease bool
+	}{
+		{"nothing held", false, "2022-06-01T11:00:00Z", false},
+		{"no release", true, "", false},
+		{"invalid time", true, "yes", false},
+		{"release before the hold", true, "2022-06-01T10:00:59Z", false},
+		{"release at the time of the hold", true, "2022-06-01T10:01:00Z", true},
+		{"release after the hold", true, "2022-06-01T10:05:00Z", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &ChangeLimiter{MaxRemovals: 4, Window: 10 * time.Minute}
+			l.allow(start, "a", 0, 4, false)
+			if tt.held && l.allow(start.Add(time.Minute+time.Millisecond), "b", 0, 1, false) == "" {
+				t.Fatal("change was not held")
+			}
+			if got := l.release(tt.value); got != tt.wantRelease {
+				t.Fatalf("release() = %t, want %t", got, tt.wantRelease)
+			}
+			// a release resets the window, without it the removals exceed the limit
+			if got := l.allow(start.Add(2*time.Minute), "c", 0, 4, false) != ""; got == tt.wantRelease {
+				t.Errorf("held after the release = %t, want %t", got, !tt.wantRelease)
+			}
+		})
+	}
+}
+
+// ownedBinding returns a RoleBinding controlled by the PermsRoleBinding with the users as subjects
+func ownedBinding(t *testing.T, scheme *runtime.Scheme, owner *permsv1.PermsRoleBinding, name string, users ...string) *rbacv1.RoleBinding {
+	rb := &rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: owner.Namespace, Labels: labelsForPermsRoleBindings(owner.Name)}}
+	for _, user := range users {
+		rb.Subjects = append(rb.Subjects, rbacv1.Subject{APIGroup: rbacv1.GroupName, Kind: "User", Name: user})
+	}
+	if err := controllerutil.SetControllerReference(owner, rb, scheme); err != nil {
+		t.Fatal(err)
+	}
+	return rb
+}
+
+func TestLimitChangesCountsAllBindings(t *testing.T) {
+	now := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+	scheme := newLockdownScheme(t)
+	prb := &permsv1.PermsRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "testing", UID: "demo-uid", Generation: 2}}
+	other := &permsv1.PermsRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "other", UID: "other-uid"}}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(prb,
+		ownedBinding(t, scheme, prb, "demo", "user1", "user2"),
+		ownedBinding(t, scheme, prb, "demo-clusterrole-view", "user1", "user2"),
+		// the rolebinding of a removed role is deleted with its subjects
+		ownedBinding(t, scheme, prb, "demo-clusterrole-edit", "user1", "user2"),
+		// the temporary rolebinding of a role switch and the rolebindings of other resources are not counted
+		ownedBinding(t, scheme, prb, roleSwitchBindingName("demo"), "user1", "user2"),
+		ownedBinding(t, scheme, other, "demo", "user1", "user2"),
+	).Build()
+	desired := []permsv1.BindingReference{
+		{Kind: "RoleBinding", Namespace: "testing", Name: "demo"},
+		{Kind: "RoleBinding", Namespace: "testing", Name: "demo-clusterrole-view"},
+		{Kind: "RoleBinding", Namespace: "testing", Name: "demo-role-admin"},
+	}
+	subjects := []rbacv1.Subject{
+		{APIGroup: rbacv1.GroupName, Kind: "User", Name: "user1"},
+		{APIGroup: rbacv1.GroupName, Kind: "User", Name: "user3"},
+	}
+
+	added, removed, err := bindingSubjectChanges(context.Background(), c, prb, labelsForPermsRoleBindings(prb.Name), desired, subjects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// user3 is added to the two existing rolebindings and to the new one with user1
+	if len(added) != 4 {
+		t.Errorf("added = %v, want 4 subjects", added)
+	}
+	// user2 is removed from the two existing rolebindings and with user1 from the deleted one
+	if len(removed) != 4 {
+		t.Errorf("removed = %v, want 4 subjects", removed)
+	}
+
+	l := &ChangeLimiter{MaxAdditions: 3, Window: 10 * time.Minute}
+	held, err := limitChanges(context.Background(), c, record.NewFakeRecorder(10), l, "PermsRoleBinding", prb,
+		labelsForPermsRoleBindings(prb.Name), desired, subjects, &prb.Status.Conditions, now)
+	if err != nil || !held {
+		t.Errorf("held = %t, err = %v, want the additions to all rolebindings to exceed the limit", held, err)
+	}
+}
+
+func TestChangeLimiterPersists(t *testing.T) {
+	now := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+	c := fake.NewClientBuilder().WithScheme(newLockdownScheme(t)).Build()
+	limiter := func() *ChangeLimiter {
+		return &ChangeLimiter{MaxRemovals: 4, Window: 10 * time.Minute, Client: c, APIReader: c, Namespace: "perms-system"}
+	}
+	allow := func(l *ChangeLimiter, now time.Time, key string, removals int) string {
+		if err := l.sync(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		reason := l.allow(now, key, 0, removals, false)
+		if err := l.save(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		return reason
+	}
+
+	if reason := allow(limiter(), now, "a", 3); reason != "" {
+		t.Fatalf("first change held: %s", reason)
+	}
+	// a restarted operator counts the changes in the window
+	restarted := limiter()
+	if reason := allow(restarted, now.Add(time.Minute), "b", 2); reason == "" {
+		t.Fatal("change was not held after the restart")
+	}
+	// and keeps holding the changes
+	if reason := allow(limiter(), now.Add(20*time.Minute), "c", 1); reason == "" {
+		t.Fatal("hold was not kept after the restart")
+	}
+
+	// an annotation of the ConfigMap before the hold does not release it
+	configMap := &corev1.ConfigMap{}
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: "perms-system", Name: changeLimitConfigMap}, configMap); err != nil {
+		t.Fatal(err)
+	}
+	configMap.Annotations = map[string]string{changesReleaseAllAnnotation: now.Format(time.RFC3339)}
+	if err := c.Update(context.Background(), configMap); err != nil {
+		t.Fatal(err)
+	}
+	if reason := allow(restarted, now.Add(21*time.Minute), "c", 1); reason == "" {
+		t.Fatal("hold was released by an annotation before the hold")
+	}
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap); err != nil {
+		t.Fatal(err)
+	}
+	configMap.Annotations[changesReleaseAllAnnotation] = now.Add(21 * time.Minute).Format(time.RFC3339)
+	if err := c.Update(context.Background(), configMap); err != nil {
+		t.Fatal(err)
+	}
+	if reason := allow(restarted, now.Add(22*time.Minute), "c", 1); reason != "" {
+		t.Fatalf("change held after the release: %s", reason)
+	}
+	// the release is kept after a restart
+	if reason := allow(limiter(), now.Add(23*time.Minute), "d", 3); reason != "" {
+		t.Fatalf("change held after the release and a restart: %s", reason)
+	}
+}
+
+func TestLimitDeletion(t *testing.T) {
+	now := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+	scheme := newLockdownScheme(t)
+	prb := func(name string) *permsv1.PermsRoleBinding {
+		return &permsv1.PermsRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "testing", UID: types.UID(name), Generation: 2,
+			Finalizers: []string{changeLimitFinalizer}}}
+	}
+	first, second := prb("first"), prb("second")
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: changeLimitConfigMap, Namespace: "perms-system"}}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(first, second, configMap,
+		ownedBinding(t, scheme, first, "first", "user1"), ownedBinding(t, scheme, first, "first-clusterrole-view", "user1"),
+		ownedBinding(t, scheme, second, "second", "user3", "user4")).Build()
+	l := &ChangeLimiter{MaxRemovals: 3, Window: 10 * time.Minute, Client: c, APIReader: c, Namespace: "perms-system"}
+	recorder := record.NewFakeRecorder(10)
+
+	held, err := limitDeletion(context.Background(), c, recorder, l, "PermsRoleBinding", first, labelsForPermsRoleBindings("first"), &first.Status.Conditions, now)
+	if err != nil || held || controllerutil.ContainsFinalizer(first, changeLimitFinalizer) {
+		t.Fatalf("first deletion: held = %t, err = %v, finalizers = %v", held, err, first.Finalizers)
+	}
+	// the removal of user3 and user4 after user1 of both rolebindings of first exceeds the limit
+	held, err = limitDeletion(context.Background(), c, recorder, l, "PermsRoleBinding", second, labelsForPermsRoleBindings("second"), &second.Status.Conditions, now)
+	if err != nil || !held || !controllerutil.ContainsFinalizer(second, changeLimitFinalizer) {
+		t.Fatalf("second deletion: held = %t, err = %v, finalizers = %v", held, err, second.Finalizers)
+	}
+
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap); err != nil {
+		t.Fatal(err)
+	}
+	configMap.Annotations = map[string]string{changesReleaseAllAnnotation: now.Format(time.RFC3339)}
+	if err := c.Update(context.Background(), configMap); err != nil {
+		t.Fatal(err)
+	}
+	held, err = limitDeletion(context.Background(), c, recorder, l, "PermsRoleBinding", second, labelsForPermsRoleBindings("second"), &second.Status.Conditions, now.Add(time.Minute))
+	if err != nil || held || controllerutil.ContainsFinalizer(second, changeLimitFinalizer) {
+		t.Fatalf("released deletion: held = %t, err = %v, finalizers = %v", held, err, second.Finalizers)
+	}
+}
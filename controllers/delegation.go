@@ -0,0 +1,53 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	permsv1 "github.com/infra-mgmt-io/perms/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+//+kubebuilder:rbac:groups=perms.infra-mgmt.io,resources=permsdelegations,verbs=get;list;watch
+
+// delegationViolations returns the violations of a PermsRoleBinding which none of the
+// PermsDelegations of its namespace allows, nil if the namespace has no delegations
+func delegationViolations(ctx context.Context, c client.Reader, prb *permsv1.PermsRoleBinding) ([]string, error) {
+	delegations := &permsv1.PermsDelegationList{}
+	if err := c.List(ctx, delegations, client.InNamespace(prb.Namespace)); err != nil {
+		return nil, err
+	}
+	return permsv1.CheckDelegations(delegations.Items, prb.PolicyRequest()), nil
+}
+
+// permsRoleBindingsForDelegation maps a PermsDelegation to the PermsRoleBindings of its namespace
+func (r *PermsRoleBindingReconciler) permsRoleBindingsForDelegation(obj client.Object) []reconcile.Request {
+	ctx := context.Background()
+	list := &permsv1.PermsRoleBindingList{}
+	if err := r.List(ctx, list, client.InNamespace(obj.GetNamespace())); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list PermsRoleBindings", "PermsDelegation", obj.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range list.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&list.Items[i])})
+	}
+	return requests
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reasons of the events emitted on PermsRoleBindings, PermsClusterRoleBindings, their bindings,
+// PermsConstraints, PermsAccessRequests, PermsBreakGlasses and PermsLockdowns
+const (
+	reasonCreated             = "Created"
+	reasonDeleted             = "Deleted"
+	reasonAdopted             = "Adopted"
+	reasonSubjectsAdded       = "SubjectsAdded"
+	reasonSubjectsRemoved     = "SubjectsRemoved"
+	reasonRoleSwitched        = "RoleSwitched"
+	reasonImmutableRoleRef    = "ImmutableRoleRef"
+	reasonRoleNotFound        = "RoleNotFound"
+	reasonRoleRulesChanged    = "RoleRulesChanged"
+	reasonPolicyViolation     = "PolicyViolation"
+	reasonConstraintViolation = "ConstraintViolation"
+	reasonDriftReverted       = "DriftReverted"
+	reasonConflict            = "Conflict"
+	reasonEscalationDenied    = "EscalationDenied"
+	reasonAuthorUnknown       = "AuthorUnknown"
+	reasonAccessApproved      = "AccessApproved"
+	reasonAccessDenied        = "AccessDenied"
+	reasonAccessActivated     = "AccessActivated"
+	reasonAccessExpired       = "AccessExpired"
+	reasonApprovalPending     = "ApprovalPending"
+	reasonChangeApproved      = "ChangeApproved"
+	reasonBreakGlassActivated = "BreakGlassActivated"
+	reasonBreakGlassActive    = "BreakGlassActive"
+	reasonBreakGlassEnded     = "BreakGlassEnded"
+	reasonLockedDown          = "LockedDown"
+	reasonLockdownLifted      = "LockdownLifted"
+	reasonLockdownChangesHeld = "LockdownChangesHeld"
+	reasonChangesHeld         = "ChangesHeld"
+	reasonChangesReleased     = "ChangesReleased"
+	reasonAPIError            = "APIError"
+)
+
+// recordEvent emits an event on the resource and, if given, on the binding
+func recordEvent(recorder record.EventRecorder, obj client.Object, binding client.Object, eventtype string, reason string, messageFmt string, args ...interface{}) {
+	if recorder == nil {
+		return
+	}
+	recorder.Eventf(obj, eventtype, reason, messageFmt, args...)
+	if binding != nil && binding.GetUID() != "" {
+		recorder.Eventf(binding, eventtype, reason, messageFmt, args...)
+	}
+}
+
+// recordError emits a warning for an error of the API or an ownership conflict
+func recordError(recorder record.EventRecorder, obj client.Object, binding client.Object, action string, err error) {
+	recordEvent(recorder, obj, binding, corev1.EventTypeWarning, errorReason(err), "%s failed: %v", action, err)
+}
+
+// errorReason returns Conflict for an ownership conflict, EscalationDenied or AuthorUnknown for
+// a binding which can not be written as the author, otherwise APIError
+func errorReason(err error) string {
+	var conflict *bindingConflict
+	if errors.As(err, &conflict) {
+		return reasonConflict
+	}
+	var author *authorError
+	if errors.As(err, &author) {
+		return authorReason(author)
+	}
+	return reasonAPIError
+}
+
+// recordSubjectChanges emits events for the subjects added to and removed from a binding and
+// returns them
+func recordSubjectChanges(recorder record.EventRecorder, obj client.Object, binding client.Object, old []rbacv1.Subject, subjects []rbacv1.Subject) ([]string, []string) {
+	added, removed := diffSubjects(old, subjects)
+	if len(added) > 0 {
+		recordEvent(recorder, obj, binding, corev1.EventTypeNormal, reasonSubjectsAdded,
+			"Added %s to %s %s", strings.Join(added, ", "), bindingKind(binding), binding.GetName())
+	}
+	if len(removed) > 0 {
+		recordEvent(recorder, obj, binding, corev1.EventTypeNormal, reasonSubjectsRemoved,
+			"Removed %s from %s %s", strings.Join(removed, ", "), bindingKind(binding), binding.GetName())
+	}
+	return added, removed
+}
+
+// diffSubjects returns the subjects which are only in subjects and only in old
+func diffSubjects(old []rbacv1.Subject, subjects []rbacv1.Subject) (added []string, removed []string) {
+	before := map[string]bool{}
+	for _, name := range subjectNames(old) {
+		before[name] = true
+	}
+	after := map[string]bool{}
+	for _, name := range subjectNames(subjects) {
+		after[name] = true
+		if !before[name] {
+			added = append(added, name)
+		}
+	}
+	for name := range before {
+		if !after[name] {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+// subjectNames returns the subjects as Kind/name, serviceaccounts as ServiceAccount/namespace/name
+func subjectNames(subjects []rbacv1.Subject) []string {
+	names := make([]string, 0, len(subjects))
+	for _, s := range subjects {
+		if s.Namespace != "" {
+			names = append(names, fmt.Sprintf("%s/%s/%s", s.Kind, s.Namespace, s.Name))
+			continue
+		}
+		names = append(names, s.Kind+"/"+s.Name)
+	}
+	return names
+}
+
+// bindingKind returns the kind of a RoleBinding or ClusterRoleBinding
+func bindingKind(binding client.Object) string {
+	if _, ok := binding.(*rbacv1.ClusterRoleBinding); ok {
+		return "ClusterRoleBinding"
+	}
+	return "RoleBinding"
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	permsv1 "github.com/infra-mgmt-io/perms/api/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// authorContextKey is the context key of the annotations of the reconciled resource
+type authorContextKey struct{}
+
+// withAuthor returns a context in which an ImpersonatingClient writes the bindings as the author
+// of the resource
+func withAuthor(ctx context.Context, obj client.Object) context.Context {
+	return context.WithValue(ctx, authorContextKey{}, obj.GetAnnotations())
+}
+
+// authorError is returned for a binding which can not be written as the author of the resource
+type authorError struct {
+	author string
+	err    error
+}
+
+func (e *authorError) Error() string {
+	if e.author == "" {
+		return fmt.Sprintf("the author of the resource is unknown, the %s annotation is set by the webhook when the spec changes", permsv1.AuthorAnnotation)
+	}
+	return fmt.Sprintf("writing the binding as %s failed: %v", e.author, e.err)
+}
+
+func (e *authorError) Unwrap() error {
+	return e.err
+}
+
+// authorReason returns EscalationDenied if the API server denied the binding to the author,
+// AuthorUnknown without author
+func authorReason(err *authorError) string {
+	if err.author == "" {
+		return reasonAuthorUnknown
+	}
+	if apierrors.IsForbidden(err.err) {
+		return reasonEscalationDenied
+	}
+	return reasonAPIError
+}
+
+// ImpersonatingClient creates and updates RoleBindings and ClusterRoleBindings as the author of
+// the reconciled resource, the API server then prevents the escalation of privileges. All other
+// requests use the client of the operator.
+type ImpersonatingClient struct {
+	client.Client
+	config  *rest.Config
+	options client.Options
+
+	mu      sync.Mutex
+	clients map[string]client.Client
+}
+
+// NewImpersonatingClient returns a client which writes bindings as the author of the resource
+func NewImpersonatingClient(c client.Client, config *rest.Config, options client.Options) *ImpersonatingClient {
+	return &ImpersonatingClient{
+		Client:  c,
+		config:  config,
+		options: options,
+		clients: map[string]client.Client{},
+	}
+}
+
+// Create implements client.Writer
+func (c *ImpersonatingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if !isBindingObject(obj) {
+		return c.Client.Create(ctx, obj, opts...)
+	}
+	author, authorClient, err := c.authorClient(ctx)
+	if err != nil {
+		return err
+	}
+	if err := authorClient.Create(ctx, obj, opts...); err != nil {
+		return &authorError{author: author, err: err}
+	}
+	return nil
+}
+
+// Update implements client.Writer
+func (c *ImpersonatingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if !isBindingObject(obj) {
+		return c.Client.Update(ctx, obj, opts...)
+	}
+	author, authorClient, err := c.authorClient(ctx)
+	if err != nil {
+		return err
+	}
+	if err := authorClient.Update(ctx, obj, opts...); err != nil {
+		return &authorError{author: author, err: err}
+	}
+	return nil
+}
+
+// Patch implements client.Writer
+func (c *ImpersonatingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if !isBindingObject(obj) {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	}
+	author, authorClient, err := c.authorClient(ctx)
+	if err != nil {
+		return err
+	}
+	if err := authorClient.Patch(ctx, obj, patch, opts...); err != nil {
+		return &authorError{author: author, err: err}
+	}
+	return nil
+}
+
+// authorClient returns a client which impersonates the author recorded in the context
+func (c *ImpersonatingClient) authorClient(ctx context.Context) (string, client.Client, error) {
+	annotations, _ := ctx.Value(authorContextKey{}).(map[string]string)
+	author := annotations[permsv1.AuthorAnnotation]
+	if author == "" {
+		return "", nil, &authorError{}
+	}
+	var groups []string
+	if raw := annotations[permsv1.AuthorGroupsAnnotation]; raw != "" {
+		if err := json.Unmarshal([]byte(raw), &groups); err != nil {
+			return author, nil, &authorError{author: author, err: err}
+		}
+	}
+
+	key := author + "\x00" + strings.Join(groups, "\x00")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if authorClient, ok := c.clients[key]; ok {
+		return author, authorClient, nil
+	}
+	config := rest.CopyConfig(c.config)
+	config.Impersonate = rest.ImpersonationConfig{UserName: author, Groups: groups}
+	authorClient, err := client.New(config, c.options)
+	if err != nil {
+		return author, nil, &authorError{author: author, err: err}
+	}
+	c.clients[key] = authorClient
+	return author, authorClient, nil
+}
+
+// isBindingObject returns true for RoleBindings and ClusterRoleBindings
+func isBindingObject(obj client.Object) bool {
+	switch obj.(type) {
+	case *rbacv1.RoleBinding, *rbacv1.ClusterRoleBinding:
+		return true
+	}
+	return false
+}
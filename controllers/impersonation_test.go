@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	permsv1 "github.com/infra-mgmt-io/perms/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestAuthorReason(t *testing.T) {
+	forbidden := apierrors.NewForbidden(rbacv1.Resource("rolebindings"), "demo", errors.New("escalation"))
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"unknown author", &authorError{}, reasonAuthorUnknown},
+		{"escalation denied", &authorError{author: "alice", err: forbidden}, reasonEscalationDenied},
+		{"other error", &authorError{author: "alice", err: apierrors.NewServiceUnavailable("down")}, reasonAPIError},
+		{"wrapped", fmt.Errorf("creating: %w", &authorError{author: "alice", err: forbidden}), reasonEscalationDenied},
+		{"not an author error", forbidden, reasonAPIError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorReason(tt.err); got != tt.want {
+				t.Errorf("errorReason() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImpersonatingClient(t *testing.T) {
+	var mu sync.Mutex
+	var users, groups []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		users = append(users, r.Header.Values("Impersonate-User")...)
+		groups = append(groups, r.Header.Values("Impersonate-Group")...)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"Forbidden","message":"escalation","code":403}`)
+	}))
+	defer server.Close()
+
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := rbacv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{rbacv1.SchemeGroupVersion})
+	mapper.Add(rbacv1.SchemeGroupVersion.WithKind("RoleBinding"), meta.RESTScopeNamespace)
+	operator := fake.NewClientBuilder().WithScheme(scheme).Build()
+	c := NewImpersonatingClient(operator, &rest.Config{Host: server.URL}, client.Options{Scheme: scheme, Mapper: mapper})
+
+	binding := func() *rbacv1.RoleBinding {
+		return &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "testing"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "view"},
+		}
+	}
+
+	// other objects are written by the operator
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "testing"}}
+	if err := c.Create(context.Background(), configMap); err != nil {
+		t.Fatalf("Create() of a ConfigMap = %v", err)
+	}
+
+	err := c.Create(context.Background(), binding())
+	if errorReason(err) != reasonAuthorUnknown {
+		t.Errorf("Create() without author = %v, want AuthorUnknown", err)
+	}
+
+	author := &permsv1.PermsRoleBinding{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+		permsv1.AuthorAnnotation:       "alice",
+		permsv1.AuthorGroupsAnnotation: `["team-a","system:authenticated"]`,
+	}}}
+	ctx := withAuthor(context.Background(), author)
+	if err := c.Create(ctx, binding()); errorReason(err) != reasonEscalationDenied {
+		t.Errorf("Create() as the author = %v, want EscalationDenied", err)
+	}
+	if err := c.Update(ctx, binding()); errorReason(err) != reasonEscalationDenied {
+		t.Errorf("Update() as the author = %v, want EscalationDenied", err)
+	}
+	if !reflect.DeepEqual(users, []string{"alice", "alice"}) {
+		t.Errorf("impersonated users = %v", users)
+	}
+	if !reflect.DeepEqual(groups, []string{"team-a", "system:authenticated", "team-a", "system:authenticated"}) {
+		t.Errorf("impersonated groups = %v", groups)
+	}
+	if len(c.clients) != 1 {
+		t.Errorf("%d clients for one author, want 1", len(c.clients))
+	}
+
+	author.Annotations[permsv1.AuthorGroupsAnnotation] = "team-a"
+	if err := c.Create(withAuthor(context.Background(), author), binding()); errorReason(err) != reasonAPIError {
+		t.Errorf("Create() with malformed groups = %v, want APIError", err)
+	}
+}
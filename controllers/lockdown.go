@@ -0,0 +1,195 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	permsv1 "github.com/infra-mgmt-io/perms/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+//+kubebuilder:rbac:groups=perms.infra-mgmt.io,resources=permslockdowns,verbs=get;list;watch
+
+// lockdownAckAnnotation acknowledges a change of the spec made during a lockdown, its value is the
+// generation of the spec which may be applied when the lockdown is lifted
+const lockdownAckAnnotation = "perms.infra-mgmt.io/lockdown-ack"
+
+// activeLockdown returns the active PermsLockdown which applies to a binding, nil if there is
+// none. breakGlass is the name of a PermsBreakGlass, empty for other bindings.
+func activeLockdown(ctx context.Context, c client.Reader, breakGlass string) (*permsv1.PermsLockdown, error) {
+	lockdowns := &permsv1.PermsLockdownList{}
+	if err := c.List(ctx, lockdowns); err != nil {
+		return nil, err
+	}
+	return permsv1.ActiveLockdown(lockdowns.Items, breakGlass), nil
+}
+
+// reconcileLockdown records the subjects of a PermsRoleBinding or PermsClusterRoleBinding in the
+// snapshot when a lockdown starts and clears it when the lockdown is lifted. The bindings are
+// built without subjects while the snapshot is set, see lockedSubjects. A spec changed during the
+// lockdown keeps the snapshot after the lockdown is lifted until the change is acknowledged with
+// the lockdown-ack annotation, otherwise the change would be applied without review.
+func reconcileLockdown(ctx context.Context, c client.Reader, recorder record.EventRecorder, obj client.Object, snapshot **permsv1.LockdownSnapshot,
+	subjects []rbacv1.Subject, conditions *[]metav1.Condition, now time.Time) error {
+	lockdown, err := activeLockdown(ctx, c, "")
+	if err != nil {
+		return err
+	}
+	if lockdown != nil {
+		if *snapshot == nil {
+			*snapshot = &permsv1.LockdownSnapshot{
+				Lockdown:   lockdown.Name,
+				Since:      metav1.NewTime(now),
+				Generation: obj.GetGeneration(),
+				Subjects:   subjectNames(subjects),
+			}
+			recordEvent(recorder, obj, nil, corev1.EventTypeWarning, reasonLockedDown, "Lockdown %s removes the subjects %s",
+				lockdown.Name, strings.Join((*snapshot).Subjects, ", "))
+		}
+		// a lockdown which is lifted while another one is active hands the snapshot over
+		(*snapshot).Lockdown = lockdown.Name
+		setLockedDownStatus(ctx, conditions, lockdown.Name)
+		return nil
+	}
+
+	if *snapshot != nil {
+		if generation := obj.GetGeneration(); generation != (*snapshot).Generation && obj.GetAnnotations()[lockdownAckAnnotation] != strconv.FormatInt(generation, 10) {
+			if setLockdownChangesHeldStatus(ctx, conditions, *snapshot, generation) {
+				recordEvent(recorder, obj, nil, corev1.EventTypeWarning, reasonLockdownChangesHeld, "Lockdown %s lifted, the spec changed during the lockdown and is not applied until it is acknowledged",
+					(*snapshot).Lockdown)
+			}
+			return nil
+		}
+		restored := subjectNames(subjects)
+		var missing []string
+		for _, name := range (*snapshot).Subjects {
+			if !containsName(restored, name) {
+				missing = append(missing, name)
+			}
+		}
+		message := "Lockdown " + (*snapshot).Lockdown + " lifted, restoring the subjects " + strings.Join(restored, ", ")
+		if len(missing) > 0 {
+			message += "; not restored as they were removed from the spec or expired during the lockdown: " + strings.Join(missing, ", ")
+		}
+		recordEvent(recorder, obj, nil, corev1.EventTypeNormal, reasonLockdownLifted, "%s", message)
+		*snapshot = nil
+	}
+	meta.RemoveStatusCondition(conditions, "LockedDown")
+	return nil
+}
+
+// lockedSubjects returns no subjects while a lockdown snapshot is set, otherwise the subjects
+func lockedSubjects(snapshot *permsv1.LockdownSnapshot, subjects []rbacv1.Subject) []rbacv1.Subject {
+	if snapshot != nil {
+		return nil
+	}
+	return subjects
+}
+
+// containsName returns true if the list contains the name
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// helper to set the "LockedDown" status of a resource whose subjects are removed by a lockdown
+func setLockedDownStatus(ctx context.Context, conditions *[]metav1.Condition, lockdown string) {
+	meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:    "LockedDown",
+		Status:  metav1.ConditionTrue,
+		Reason:  reasonLockedDown,
+		Message: "The subjects are removed by lockdown " + lockdown + " and restored when it is lifted",
+	})
+}
+
+// helper to set the "LockedDown" status of a resource whose spec changed during a lifted lockdown,
+// it returns true if the condition changed
+func setLockdownChangesHeldStatus(ctx context.Context, conditions *[]metav1.Condition, snapshot *permsv1.LockdownSnapshot, generation int64) bool {
+	condition := metav1.Condition{
+		Type:   "LockedDown",
+		Status: metav1.ConditionTrue,
+		Reason: reasonLockdownChangesHeld,
+		Message: fmt.Sprintf("Lockdown %s is lifted, the spec changed from generation %d to %d during the lockdown. Set the annotation %s=%d to restore the subjects of the changed spec",
+			snapshot.Lockdown, snapshot.Generation, generation, lockdownAckAnnotation, generation),
+	}
+	current := meta.FindStatusCondition(*conditions, condition.Type)
+	changed := current == nil || current.Reason != condition.Reason || current.Message != condition.Message
+	meta.SetStatusCondition(conditions, condition)
+	return changed
+}
+
+// permsRoleBindingsForLockdown maps a PermsLockdown to all PermsRoleBindings
+func (r *PermsRoleBindingReconciler) permsRoleBindingsForLockdown(obj client.Object) []reconcile.Request {
+	ctx := context.Background()
+	list := &permsv1.PermsRoleBindingList{}
+	if err := r.List(ctx, list); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list PermsRoleBindings", "PermsLockdown", obj.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range list.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&list.Items[i])})
+	}
+	return requests
+}
+
+// permsClusterRoleBindingsForLockdown maps a PermsLockdown to all PermsClusterRoleBindings
+func (r *PermsClusterRoleBindingReconciler) permsClusterRoleBindingsForLockdown(obj client.Object) []reconcile.Request {
+	ctx := context.Background()
+	list := &permsv1.PermsClusterRoleBindingList{}
+	if err := r.List(ctx, list); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list PermsClusterRoleBindings", "PermsLockdown", obj.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range list.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: list.Items[i].Name}})
+	}
+	return requests
+}
+
+// permsBreakGlassesForLockdown maps a PermsLockdown to all PermsBreakGlasses
+func (r *PermsBreakGlassReconciler) permsBreakGlassesForLockdown(obj client.Object) []reconcile.Request {
+	ctx := context.Background()
+	list := &permsv1.PermsBreakGlassList{}
+	if err := r.List(ctx, list); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list PermsBreakGlasses", "PermsLockdown", obj.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range list.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: list.Items[i].Name}})
+	}
+	return requests
+}
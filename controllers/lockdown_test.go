@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	permsv1 "github.com/infra-mgmt-io/perms/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// newLockdownScheme returns a scheme with the perms and rbac types
+func newLockdownScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	if err := permsv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := rbacv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return scheme
+}
+
+func TestReconcileLockdownClusterRoleBinding(t *testing.T) {
+	now := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+	lockdown := &permsv1.PermsLockdown{
+		ObjectMeta: metav1.ObjectMeta{Name: "incident"},
+		Spec:       permsv1.PermsLockdownSpec{Active: true, Reason: "testing"},
+	}
+	tests := []struct {
+		name         string
+		lifted       bool
+		generation   int64
+		ack          string
+		wantSnapshot bool
+		wantReason   string
+	}{
+		{"active lockdown", false, 1, "", true, reasonLockedDown},
+		{"lifted without changes", true, 1, "", false, ""},
+		{"lifted after a change of the spec", true, 2, "", true, reasonLockdownChangesHeld},
+		{"acknowledged another generation", true, 3, "2", true, reasonLockdownChangesHeld},
+		{"acknowledged change", true, 2, "2", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pcrb := &permsv1.PermsClusterRoleBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: "admins", Generation: 1},
+				Spec:       permsv1.PermsClusterRoleBindingSpec{Role: "admin", Users: []string{"user1", "user2"}},
+			}
+			c := fake.NewClientBuilder().WithScheme(newLockdownScheme(t)).WithObjects(lockdown.DeepCopy()).Build()
+			if err := reconcileLockdown(context.Background(), c, record.NewFakeRecorder(10), pcrb, &pcrb.Status.Lockdown,
+				subsForPermsClusterRoleBindings(pcrb, now), &pcrb.Status.Conditions, now); err != nil {
+				t.Fatal(err)
+			}
+			if got := subjectNames(lockedSubjects(pcrb.Status.Lockdown, subsForPermsClusterRoleBindings(pcrb, now))); len(got) != 0 {
+				t.Errorf("lockedSubjects() = %v during the lockdown, want none", got)
+			}
+
+			if tt.lifted {
+				c = fake.NewClientBuilder().WithScheme(newLockdownScheme(t)).Build()
+			}
+			pcrb.Generation = tt.generation
+			if tt.ack != "" {
+				pcrb.Annotations = map[string]string{lockdownAckAnnotation: tt.ack}
+			}
+			if err := reconcileLockdown(context.Background(), c, record.NewFakeRecorder(10), pcrb, &pcrb.Status.Lockdown,
+				subsForPermsClusterRoleBindings(pcrb, now), &pcrb.Status.Conditions, now.Add(time.Minute)); err != nil {
+				t.Fatal(err)
+			}
+			if got := pcrb.Status.Lockdown != nil; got != tt.wantSnapshot {
+				t.Fatalf("snapshot set = %t, want %t", got, tt.wantSnapshot)
+			}
+			if tt.wantSnapshot && !reflect.DeepEqual(pcrb.Status.Lockdown.Subjects, []string{"User/user1", "User/user2"}) {
+				t.Errorf("snapshot subjects = %v", pcrb.Status.Lockdown.Subjects)
+			}
+			condition := meta.FindStatusCondition(pcrb.Status.Conditions, "LockedDown")
+			switch {
+			case tt.wantReason == "" && condition != nil:
+				t.Errorf("LockedDown condition = %v, want none", condition)
+			case tt.wantReason != "" && (condition == nil || condition.Reason != tt.wantReason):
+				t.Errorf("LockedDown condition = %v, want reason %s", condition, tt.wantReason)
+			}
+			wantSubjects := 2
+			if tt.wantSnapshot {
+				wantSubjects = 0
+			}
+			if got := lockedSubjects(pcrb.Status.Lockdown, subsForPermsClusterRoleBindings(pcrb, now)); len(got) != wantSubjects {
+				t.Errorf("lockedSubjects() = %v, want %d subjects", got, wantSubjects)
+			}
+		})
+	}
+}
+
+func TestBreakGlassLockdown(t *testing.T) {
+	now := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		lockdown     *permsv1.PermsLockdownSpec
+		wantSubjects int
+		wantLocked   bool
+	}{
+		{"no lockdown", nil, 2, false},
+		{"active lockdown", &permsv1.PermsLockdownSpec{Active: true, Reason: "testing"}, 0, true},
+		{"inactive lockdown", &permsv1.PermsLockdownSpec{Reason: "testing"}, 2, false},
+		{"lockdown which exempts the break-glass", &permsv1.PermsLockdownSpec{Active: true, Reason: "testing", ExemptBreakGlasses: []string{"emergency"}}, 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expiresAt := metav1.NewTime(now.Add(time.Hour))
+			bg := &permsv1.PermsBreakGlass{
+				ObjectMeta: metav1.ObjectMeta{Name: "emergency", UID: "1234", Annotations: map[string]string{
+					permsv1.BreakGlassAnnotation: "INC-1234", permsv1.BreakGlassActivatedByAnnotation: "alice",
+				}},
+				Spec: permsv1.PermsBreakGlassSpec{Role: "cluster-admin", Users: []string{"bob"}, Groups: []string{"oncall"},
+					MaxDuration: metav1.Duration{Duration: time.Hour}},
+				Status: permsv1.PermsBreakGlassStatus{Active: true, ActivatedBy: "alice", Reason: "INC-1234", ExpiresAt: &expiresAt},
+			}
+			scheme := newLockdownScheme(t)
+			builder := fake.NewClientBuilder().WithScheme(scheme)
+			if tt.lockdown != nil {
+				builder = builder.WithObjects(&permsv1.PermsLockdown{ObjectMeta: metav1.ObjectMeta{Name: "incident"}, Spec: *tt.lockdown})
+			}
+			r := &PermsBreakGlassReconciler{Client: builder.Build(), Scheme: scheme, Recorder: record.NewFakeRecorder(10)}
+			if _, err := r.reconcileActivation(context.Background(), bg, now); err != nil {
+				t.Fatal(err)
+			}
+
+			crb := &rbacv1.ClusterRoleBinding{}
+			if err := r.Get(context.Background(), types.NamespacedName{Name: breakGlassBindingName(bg.Name)}, crb); err != nil {
+				t.Fatal(err)
+			}
+			if len(crb.Subjects) != tt.wantSubjects {
+				t.Errorf("subjects = %v, want %d", crb.Subjects, tt.wantSubjects)
+			}
+			if got := meta.IsStatusConditionTrue(bg.Status.Conditions, "LockedDown"); got != tt.wantLocked {
+				t.Errorf("LockedDown = %t, want %t", got, tt.wantLocked)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	permsv1 "github.com/infra-mgmt-io/perms/api/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// driftRepairs counts the reverted changes of managed bindings made outside of the operator
+	driftRepairs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "perms_drift_repairs_total",
+		Help: "Number of managed bindings which were changed outside of the operator and restored.",
+	}, []string{"kind"})
+
+	// immutableViolations counts the role switches denied by spec.immutableRoleRef
+	immutableViolations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "perms_immutable_violations_total",
+		Help: "Number of role changes which were not applied because spec.immutableRoleRef is set.",
+	}, []string{"kind"})
+
+	// changesHeld counts the changes of the subjects held by the ChangeLimiter
+	changesHeld = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "perms_changes_held_total",
+		Help: "Number of changes of the subjects which were held because too many subjects were added or removed.",
+	}, []string{"kind"})
+
+	// applyDuration observes the time from a change of the generation to the applied bindings
+	applyDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "perms_binding_apply_duration_seconds",
+		Help:    "Time from a change of a resource to the bindings being applied.",
+		Buckets: []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 300},
+	}, []string{"kind"})
+
+	managedBindingsDesc = prometheus.NewDesc("perms_managed_bindings",
+		"Number of bindings managed by the operator.",
+		[]string{"kind", "namespace"}, nil)
+	subjectsDesc = prometheus.NewDesc("perms_subjects",
+		"Number of subjects assigned by the operator.",
+		[]string{"kind", "subject_kind"}, nil)
+	degradedDesc = prometheus.NewDesc("perms_degraded_resources",
+		"Number of resources with the Degraded condition.",
+		[]string{"kind"}, nil)
+)
+
+func init() {
+	metrics.Registry.MustRegister(driftRepairs, immutableViolations, changesHeld, applyDuration)
+}
+
+// FieldManager is the user agent of the operator, the API server records it as the manager of
+// the fields the operator writes
+const FieldManager = "perms-operator"
+
+// appliedGenerations are the generations for which the apply duration was observed
+var appliedGenerations = struct {
+	sync.Mutex
+	generations map[string]int64
+}{generations: map[string]int64{}}
+
+// observeApplied observes the apply duration once per generation of a resource. The change is
+// taken from the creation or the last update of the resource by a client.
+func observeApplied(kind string, obj client.Object, now time.Time) {
+	key := kind + "/" + client.ObjectKeyFromObject(obj).String()
+	appliedGenerations.Lock()
+	defer appliedGenerations.Unlock()
+	if appliedGenerations.generations[key] == obj.GetGeneration() {
+		return
+	}
+	appliedGenerations.generations[key] = obj.GetGeneration()
+
+	applyDuration.WithLabelValues(kind).Observe(now.Sub(lastChange(obj)).Seconds())
+}
+
+// lastChange returns the creation or the last update of a resource by a client other than the
+// operator, the updates of the status and the finalizers and annotations set by the operator
+// are not a change
+func lastChange(obj client.Object) time.Time {
+	changed := obj.GetCreationTimestamp().Time
+	if obj.GetGeneration() > 1 {
+		for _, entry := range obj.GetManagedFields() {
+			if entry.Subresource == "" && entry.Manager != FieldManager && entry.Time != nil && entry.Time.After(changed) {
+				changed = entry.Time.Time
+			}
+		}
+	}
+	return changed
+}
+
+// forgetApplied removes a deleted resource from the observed generations
+func forgetApplied(kind string, key types.NamespacedName) {
+	appliedGenerations.Lock()
+	defer appliedGenerations.Unlock()
+	delete(appliedGenerations.generations, kind+"/"+key.String())
+}
+
+// MetricsCollector collects the managed bindings, subjects and degraded resources from the
+// PermsRoleBindings and PermsClusterRoleBindings at each scrape
+type MetricsCollector struct {
+	Reader client.Reader
+}
+
+var _ prometheus.Collector = &MetricsCollector{}
+
+// Describe implements prometheus.Collector
+func (c *MetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- managedBindingsDesc
+	ch <- subjectsDesc
+	ch <- degradedDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *MetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	bindings := map[[2]string]int{}
+	subjects := map[[2]string]int32{}
+	degraded := map[string]int{}
+	count := func(kind string, status []permsv1.BindingReference, n permsv1.SubjectCount, conditions []metav1.Condition) {
+		for _, b := range status {
+			bindings[[2]string{b.Kind, b.Namespace}]++
+		}
+		subjects[[2]string{kind, "user"}] += n.Users
+		subjects[[2]string{kind, "group"}] += n.Groups
+		subjects[[2]string{kind, "serviceaccount"}] += n.Serviceaccounts
+		if meta.IsStatusConditionTrue(conditions, "Degraded") {
+			degraded[kind]++
+		}
+	}
+
+	prbs := &permsv1.PermsRoleBindingList{}
+	if err := c.Reader.List(ctx, prbs); err == nil {
+		degraded["PermsRoleBinding"] = 0
+		for _, p := range prbs.Items {
+			count("PermsRoleBinding", p.Status.Bindings, p.Status.Count, p.Status.Conditions)
+		}
+	}
+	pcrbs := &permsv1.PermsClusterRoleBindingList{}
+	if err := c.Reader.List(ctx, pcrbs); err == nil {
+		degraded["PermsClusterRoleBinding"] = 0
+		for _, p := range pcrbs.Items {
+			count("PermsClusterRoleBinding", p.Status.Bindings, p.Status.Count, p.Status.Conditions)
+		}
+	}
+
+	for key, value := range bindings {
+		ch <- prometheus.MustNewConstMetric(managedBindingsDesc, prometheus.GaugeValue, float64(value), key[0], key[1])
+	}
+	for key, value := range subjects {
+		ch <- prometheus.MustNewConstMetric(subjectsDesc, prometheus.GaugeValue, float64(value), key[0], key[1])
+	}
+	for kind, value := range degraded {
+		ch <- prometheus.MustNewConstMetric(degradedDesc, prometheus.GaugeValue, float64(value), kind)
+	}
+}
@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	permsv1 "github.com/infra-mgmt-io/perms/api/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestLastChange(t *testing.T) {
+	created := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+	entry := func(manager string, subresource string, minutes int) metav1.ManagedFieldsEntry {
+		return metav1.ManagedFieldsEntry{Manager: manager, Subresource: subresource,
+			Time: &metav1.Time{Time: created.Add(time.Duration(minutes) * time.Minute)}}
+	}
+	tests := []struct {
+		name       string
+		generation int64
+		entries    []metav1.ManagedFieldsEntry
+		want       time.Time
+	}{
+		{"first generation", 1, []metav1.ManagedFieldsEntry{entry("kubectl", "", 5)}, created},
+		{"update by a client", 2, []metav1.ManagedFieldsEntry{entry("kubectl", "", 5), entry("helm", "", 3)}, created.Add(5 * time.Minute)},
+		{"status update", 2, []metav1.ManagedFieldsEntry{entry("kubectl", "", 5), entry(FieldManager, "status", 9)}, created.Add(5 * time.Minute)},
+		{"update by the operator", 2, []metav1.ManagedFieldsEntry{entry("kubectl", "", 5), entry(FieldManager, "", 9)}, created.Add(5 * time.Minute)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := &permsv1.PermsRoleBinding{ObjectMeta: metav1.ObjectMeta{
+				Name: "demo", Namespace: "testing", Generation: tt.generation,
+				CreationTimestamp: metav1.Time{Time: created}, ManagedFields: tt.entries,
+			}}
+			if got := lastChange(obj); !got.Equal(tt.want) {
+				t.Errorf("lastChange() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestObserveApplied(t *testing.T) {
+	kind := "TestObserveApplied"
+	created := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+	obj := &permsv1.PermsRoleBinding{ObjectMeta: metav1.ObjectMeta{
+		Name: "demo", Namespace: "testing", Generation: 1, CreationTimestamp: metav1.Time{Time: created},
+	}}
+	defer forgetApplied(kind, types.NamespacedName{Name: "demo", Namespace: "testing"})
+
+	observeApplied(kind, obj, created.Add(2*time.Second))
+	// a generation is observed once
+	observeApplied(kind, obj, created.Add(time.Minute))
+
+	metric := &dto.Metric{}
+	if err := applyDuration.WithLabelValues(kind).(prometheus.Histogram).Write(metric); err != nil {
+		t.Fatal(err)
+	}
+	if count := metric.GetHistogram().GetSampleCount(); count != 1 {
+		t.Errorf("sample count = %d, want 1", count)
+	}
+	if sum := metric.GetHistogram().GetSampleSum(); sum != 2 {
+		t.Errorf("sample sum = %v, want 2", sum)
+	}
+}
+
+func TestMetricsCollector(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := permsv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&permsv1.PermsRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "testing"},
+			Status: permsv1.PermsRoleBindingStatus{
+				Bindings: []permsv1.BindingReference{
+					{Kind: "RoleBinding", Namespace: "testing", Name: "demo"},
+					{Kind: "RoleBinding", Namespace: "testing", Name: "demo-role-reader"},
+				},
+				Count:      permsv1.SubjectCount{Users: 2, Groups: 1},
+				Conditions: []metav1.Condition{{Type: "Degraded", Status: metav1.ConditionTrue}},
+			},
+		},
+		&permsv1.PermsClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "demo"},
+			Status: permsv1.PermsClusterRoleBindingStatus{
+				Bindings: []permsv1.BindingReference{{Kind: "ClusterRoleBinding", Name: "demo"}},
+				Count:    permsv1.SubjectCount{Serviceaccounts: 3},
+			},
+		},
+	).Build()
+
+	expected := `
+# HELP perms_degraded_resources Number of resources with the Degraded condition.
+# TYPE perms_degraded_resources gauge
+perms_degraded_resources{kind="PermsClusterRoleBinding"} 0
+perms_degraded_resources{kind="PermsRoleBinding"} 1
+# HELP perms_managed_bindings Number of bindings managed by the operator.
+# TYPE perms_managed_bindings gauge
+perms_managed_bindings{kind="ClusterRoleBinding",namespace=""} 1
+perms_managed_bindings{kind="RoleBinding",namespace="testing"} 2
+# HELP perms_subjects Number of subjects assigned by the operator.
+# TYPE perms_subjects gauge
+perms_subjects{kind="PermsClusterRoleBinding",subject_kind="group"} 0
+perms_subjects{kind="PermsClusterRoleBinding",subject_kind="serviceaccount"} 3
+perms_subjects{kind="PermsClusterRoleBinding",subject_kind="user"} 0
+perms_subjects{kind="PermsRoleBinding",subject_kind="group"} 1
+perms_subjects{kind="PermsRoleBinding",subject_kind="serviceaccount"} 0
+perms_subjects{kind="PermsRoleBinding",subject_kind="user"} 2
+`
+	if err := testutil.CollectAndCompare(&MetricsCollector{Reader: reader}, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,227 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	permsv1 "github.com/infra-mgmt-io/perms/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// PermsAccessRequestReconciler binds the role of an approved PermsAccessRequest to the requester
+// with a PermsRoleBinding and revokes it when the request expires
+type PermsAccessRequestReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// WebhooksEnabled is set with --enable-webhooks, only the webhook verifies the requester and
+	// the approver of a request
+	WebhooksEnabled bool
+}
+
+//+kubebuilder:rbac:groups=perms.infra-mgmt.io,resources=permsaccessrequests,verbs=get;list;watch
+//+kubebuilder:rbac:groups=perms.infra-mgmt.io,resources=permsaccessrequests/status,verbs=get;update;patch
+
+// Reconcile moves the PermsAccessRequest through the phases Pending, Approved or Denied, Active
+// and Expired
+func (r *PermsAccessRequestReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	now := time.Now()
+
+	ar := &permsv1.PermsAccessRequest{}
+	if err := r.Get(ctx, req.NamespacedName, ar); err != nil {
+		if errors.IsNotFound(err) {
+			logger.Info("Resource PermsAccessRequest not found.")
+			return ctrl.Result{}, nil
+		}
+		logger.Error(err, "Failed to get PermsAccessRequest")
+		return ctrl.Result{}, err
+	}
+	if ar.Status.RequestedAt == nil {
+		requestedAt := ar.CreationTimestamp
+		ar.Status.RequestedAt = &requestedAt
+	}
+
+	result, err := r.reconcilePhase(ctx, ar, now)
+	if err != nil {
+		logger.Error(err, "Failed to reconcile PermsAccessRequest", "PermsAccessRequest.Namespace", ar.Namespace, "PermsAccessRequest.Name", ar.Name)
+		recordError(r.Recorder, ar, nil, "Binding the requested role", err)
+		setErrorStatus(ctx, &ar.Status.Conditions, err)
+	} else {
+		setEverythingIsFineStatus(ctx, &ar.Status.Conditions)
+	}
+
+	ar.Status.ObservedGeneration = ar.Generation
+	setObservedGeneration(ar.Status.Conditions, ar.Generation)
+	if updateErr := r.Status().Update(ctx, ar); updateErr != nil {
+		logger.Error(updateErr, "Update access request status failed")
+		if err == nil {
+			err = updateErr
+		}
+	}
+	return result, err
+}
+
+// reconcilePhase sets the phase of the PermsAccessRequest and creates or deletes its PermsRoleBinding
+func (r *PermsAccessRequestReconciler) reconcilePhase(ctx context.Context, ar *permsv1.PermsAccessRequest, now time.Time) (ctrl.Result, error) {
+	approval := ar.Spec.Approval
+	if approval == nil {
+		ar.Status.Phase = permsv1.AccessRequestPending
+		return ctrl.Result{}, nil
+	}
+	decidedAt := metav1.NewTime(now)
+	if approval.Time != nil {
+		decidedAt = *approval.Time
+	}
+
+	if approval.Decision == permsv1.AccessRequestDenied {
+		if ar.Status.DeniedAt == nil {
+			ar.Status.DeniedAt = &decidedAt
+			recordEvent(r.Recorder, ar, nil, corev1.EventTypeNormal, reasonAccessDenied, "%s denied %s %s for %s: %s",
+				approval.Approver, ar.Spec.Kind, ar.Spec.Role, ar.Spec.Requester, approval.Comment)
+		}
+		ar.Status.Phase = permsv1.AccessRequestDenied
+		return ctrl.Result{}, nil
+	}
+
+	if ar.Status.ApprovedAt == nil {
+		ar.Status.ApprovedAt = &decidedAt
+		recordEvent(r.Recorder, ar, nil, corev1.EventTypeNormal, reasonAccessApproved, "%s approved %s %s for %s for %s: %s",
+			approval.Approver, ar.Spec.Kind, ar.Spec.Role, ar.Spec.Requester, ar.Spec.Duration.Duration, approval.Comment)
+	}
+	if ar.Status.Phase == permsv1.AccessRequestExpired || (ar.Status.ExpiresAt != nil && !now.Before(ar.Status.ExpiresAt.Time)) {
+		return ctrl.Result{}, r.expire(ctx, ar, now)
+	}
+	if ar.Spec.Requester == "" {
+		ar.Status.Phase = permsv1.AccessRequestApproved
+		return ctrl.Result{}, fmt.Errorf("the requester is unknown, it is set by the webhook when the request is created")
+	}
+
+	if ar.Status.ActivatedAt == nil {
+		activatedAt := metav1.NewTime(now)
+		expiresAt := metav1.NewTime(now.Add(ar.Spec.Duration.Duration))
+		ar.Status.ActivatedAt, ar.Status.ExpiresAt = &activatedAt, &expiresAt
+	}
+	if err := r.bindRole(ctx, ar); err != nil {
+		ar.Status.Phase = permsv1.AccessRequestApproved
+		return ctrl.Result{}, err
+	}
+	if ar.Status.Phase != permsv1.AccessRequestActive {
+		recordEvent(r.Recorder, ar, nil, corev1.EventTypeNormal, reasonAccessActivated, "Bound %s %s to %s until %s",
+			ar.Spec.Kind, ar.Spec.Role, ar.Spec.Requester, ar.Status.ExpiresAt.UTC().Format(time.RFC3339))
+	}
+	ar.Status.Phase = permsv1.AccessRequestActive
+	return ctrl.Result{RequeueAfter: ar.Status.ExpiresAt.Sub(now)}, nil
+}
+
+// bindRole creates or updates the PermsRoleBinding which binds the role to the requester, the
+// PermsRoleBinding revokes the role at the expiry itself
+func (r *PermsAccessRequestReconciler) bindRole(ctx context.Context, ar *permsv1.PermsAccessRequest) error {
+	desired := r.permsRoleBindingForAccessRequest(ar)
+	if err := ctrl.SetControllerReference(ar, desired, r.Scheme); err != nil {
+		return err
+	}
+	ar.Status.PermsRoleBinding = desired.Name
+
+	current := &permsv1.PermsRoleBinding{}
+	if err := r.Get(ctx, types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, current); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		log.FromContext(ctx).Info("Creating a new PermsRoleBinding", "PermsRoleBinding.Namespace", desired.Namespace, "PermsRoleBinding.Name", desired.Name)
+		return r.Create(ctx, desired)
+	}
+	if !metav1.IsControlledBy(current, ar) {
+		return fmt.Errorf("PermsRoleBinding %s exists and is not managed by the PermsAccessRequest", client.ObjectKeyFromObject(current))
+	}
+	if equality.Semantic.DeepEqual(current.Spec, desired.Spec) {
+		return nil
+	}
+	current.Spec = desired.Spec
+	return r.Update(ctx, current)
+}
+
+// expire deletes the PermsRoleBinding of an expired request
+func (r *PermsAccessRequestReconciler) expire(ctx context.Context, ar *permsv1.PermsAccessRequest, now time.Time) error {
+	if ar.Status.PermsRoleBinding != "" {
+		prb := &permsv1.PermsRoleBinding{}
+		err := r.Get(ctx, types.NamespacedName{Name: ar.Status.PermsRoleBinding, Namespace: ar.Namespace}, prb)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		if err == nil && metav1.IsControlledBy(prb, ar) {
+			if err := r.Delete(ctx, prb); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+	if ar.Status.ExpiredAt == nil {
+		expiredAt := metav1.NewTime(now)
+		ar.Status.ExpiredAt = &expiredAt
+		recordEvent(r.Recorder, ar, nil, corev1.EventTypeNormal, reasonAccessExpired, "Revoked %s %s from %s",
+			ar.Spec.Kind, ar.Spec.Role, ar.Spec.Requester)
+	}
+	ar.Status.Phase = permsv1.AccessRequestExpired
+	return nil
+}
+
+// permsRoleBindingForAccessRequest returns the PermsRoleBinding which binds the requested role
+// to the requester until the request expires
+func (r *PermsAccessRequestReconciler) permsRoleBindingForAccessRequest(ar *permsv1.PermsAccessRequest) *permsv1.PermsRoleBinding {
+	return &permsv1.PermsRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      accessRequestBindingName(ar.Name),
+			Namespace: ar.Namespace,
+			Labels:    map[string]string{"crd": "PermsAccessRequest", "permsaccessrequest_cr": ar.Name},
+		},
+		Spec: permsv1.PermsRoleBindingSpec{
+			Kind:     ar.Spec.Kind,
+			Role:     ar.Spec.Role,
+			Users:    []string{ar.Spec.Requester},
+			Validity: permsv1.Validity{ExpiresAt: ar.Status.ExpiresAt},
+		},
+	}
+}
+
+// accessRequestBindingName returns the name of the PermsRoleBinding of an access request
+func accessRequestBindingName(name string) string {
+	return "accessrequest-" + name
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PermsAccessRequestReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// without the webhook anyone who may edit a request can approve it
+	if !r.WebhooksEnabled {
+		return fmt.Errorf("PermsAccessRequests require --enable-webhooks, the webhook verifies the requester and the approver")
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&permsv1.PermsAccessRequest{}).
+		Owns(&permsv1.PermsRoleBinding{}).
+		Complete(r)
+}
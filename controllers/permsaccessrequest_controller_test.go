@@ -0,0 +1,14 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAccessRequestRequiresWebhooks(t *testing.T) {
+	// the manager is not used when the setup fails
+	err := (&PermsAccessRequestReconciler{}).SetupWithManager(nil)
+	if err == nil || !strings.Contains(err.Error(), "--enable-webhooks") {
+		t.Fatalf("SetupWithManager() = %v, want an error without the webhooks", err)
+	}
+}
@@ -0,0 +1,315 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	permsv1 "github.com/infra-mgmt-io/perms/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// auditLog writes the audit records of break-glass activations
+var auditLog = ctrl.Log.WithName("audit")
+
+// breakGlassReminderInterval is the interval of the warnings while a break-glass is active
+const breakGlassReminderInterval = 5 * time.Minute
+
+// PermsBreakGlassReconciler binds the role of an activated PermsBreakGlass with a ClusterRoleBinding
+// and deactivates it after spec.maxDuration
+type PermsBreakGlassReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// WebhooksEnabled is set with --enable-webhooks, only the webhook verifies the activator of a
+	// break-glass
+	WebhooksEnabled bool
+}
+
+//+kubebuilder:rbac:groups=perms.infra-mgmt.io,resources=permsbreakglasses,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=perms.infra-mgmt.io,resources=permsbreakglasses/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=perms.infra-mgmt.io,resources=permsbreakglasses/finalizers,verbs=update
+
+// Reconcile activates and deactivates the PermsBreakGlass, each reconcile of an active break-glass
+// emits a warning and an audit record
+func (r *PermsBreakGlassReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	now := time.Now()
+
+	bg := &permsv1.PermsBreakGlass{}
+	if err := r.Get(ctx, req.NamespacedName, bg); err != nil {
+		if errors.IsNotFound(err) {
+			logger.Info("Resource PermsBreakGlass not found.")
+			return ctrl.Result{}, nil
+		}
+		logger.Error(err, "Failed to get PermsBreakGlass")
+		return ctrl.Result{}, err
+	}
+
+	result, err := r.reconcileActivation(ctx, bg, now)
+	if err != nil {
+		logger.Error(err, "Failed to reconcile PermsBreakGlass", "PermsBreakGlass.Name", bg.Name)
+		recordError(r.Recorder, bg, nil, "Breaking the glass", err)
+		setErrorStatus(ctx, &bg.Status.Conditions, err)
+	} else {
+		setEverythingIsFineStatus(ctx, &bg.Status.Conditions)
+	}
+	setBreakGlassActiveStatus(ctx, &bg.Status.Conditions, bg)
+
+	bg.Status.ObservedGeneration = bg.Generation
+	setObservedGeneration(bg.Status.Conditions, bg.Generation)
+	if updateErr := r.Status().Update(ctx, bg); updateErr != nil {
+		logger.Error(updateErr, "Update break-glass status failed")
+		if err == nil {
+			err = updateErr
+		}
+	}
+	return result, err
+}
+
+// reconcileActivation binds the role while the break-glass annotation is set and the activation
+// has not expired, otherwise it removes the ClusterRoleBinding and the annotation
+func (r *PermsBreakGlassReconciler) reconcileActivation(ctx context.Context, bg *permsv1.PermsBreakGlass, now time.Time) (ctrl.Result, error) {
+	if bg.Reason() == "" {
+		return ctrl.Result{}, r.deactivate(ctx, bg, now, "the break-glass annotation was removed")
+	}
+
+	if !bg.Status.Active {
+		activatedBy := bg.Annotations[permsv1.BreakGlassActivatedByAnnotation]
+		if activatedBy == "" {
+			return ctrl.Result{}, fmt.Errorf("the activator is unknown, it is recorded by the webhook when the break-glass annotation is set")
+		}
+		activatedAt := metav1.NewTime(now)
+		expiresAt := metav1.NewTime(now.Add(bg.Spec.MaxDuration.Duration))
+		bg.Status.Active = true
+		bg.Status.ActivatedBy = activatedBy
+		bg.Status.Reason = bg.Reason()
+		bg.Status.ActivatedAt, bg.Status.ExpiresAt = &activatedAt, &expiresAt
+		bg.Status.Activations++
+		r.audit(bg, corev1.EventTypeWarning, reasonBreakGlassActivated, "activated")
+	}
+
+	if !now.Before(bg.Status.ExpiresAt.Time) {
+		// the annotation is removed first, so a failed deactivation is retried instead of
+		// activating the break-glass again
+		if err := r.removeActivation(ctx, bg); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, r.deactivate(ctx, bg, now, "the activation expired")
+	}
+	// a lockdown which does not exempt the break-glass removes its subjects
+	lockdown, err := activeLockdown(ctx, r.Client, bg.Name)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.bindRole(ctx, bg, lockdown != nil); err != nil {
+		return ctrl.Result{}, err
+	}
+	if lockdown != nil {
+		setLockedDownStatus(ctx, &bg.Status.Conditions, lockdown.Name)
+		r.audit(bg, corev1.EventTypeWarning, reasonBreakGlassActive, "active, its subjects are removed by lockdown "+lockdown.Name)
+	} else {
+		meta.RemoveStatusCondition(&bg.Status.Conditions, "LockedDown")
+		r.audit(bg, corev1.EventTypeWarning, reasonBreakGlassActive, "active")
+	}
+
+	requeueAfter := bg.Status.ExpiresAt.Sub(now)
+	if requeueAfter > breakGlassReminderInterval {
+		requeueAfter = breakGlassReminderInterval
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// bindRole creates or updates the ClusterRoleBinding of the break-glass, without subjects if it is
+// locked down
+func (r *PermsBreakGlassReconciler) bindRole(ctx context.Context, bg *permsv1.PermsBreakGlass, locked bool) error {
+	desired := clusterRoleBindingForBreakGlass(bg, locked)
+	if err := ctrl.SetControllerReference(bg, desired, r.Scheme); err != nil {
+		return err
+	}
+	bg.Status.ClusterRoleBinding = desired.Name
+
+	current := &rbacv1.ClusterRoleBinding{}
+	if err := r.Get(ctx, types.NamespacedName{Name: desired.Name}, current); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		log.FromContext(ctx).Info("Creating a new ClusterRolebinding", "ClusterRolebinding.Name", desired.Name)
+		return r.Create(ctx, desired)
+	}
+	if !metav1.IsControlledBy(current, bg) {
+		return fmt.Errorf("ClusterRoleBinding %s exists and is not managed by the PermsBreakGlass", current.Name)
+	}
+	if current.RoleRef != desired.RoleRef {
+		// the roleRef of a binding is immutable
+		if err := r.Delete(ctx, current); err != nil {
+			return err
+		}
+		return r.Create(ctx, desired)
+	}
+	if update, _ := syncBinding(current, desired); !update {
+		return nil
+	}
+	return r.Update(ctx, current)
+}
+
+// deactivate deletes the ClusterRoleBinding and records the deactivation of an active break-glass
+func (r *PermsBreakGlassReconciler) deactivate(ctx context.Context, bg *permsv1.PermsBreakGlass, now time.Time, why string) error {
+	crb := &rbacv1.ClusterRoleBinding{}
+	err := r.Get(ctx, types.NamespacedName{Name: breakGlassBindingName(bg.Name)}, crb)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if err == nil && metav1.IsControlledBy(crb, bg) {
+		if err := r.Delete(ctx, crb); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	if bg.Status.Active {
+		deactivatedAt := metav1.NewTime(now)
+		bg.Status.Active = false
+		bg.Status.DeactivatedAt = &deactivatedAt
+		r.audit(bg, corev1.EventTypeWarning, reasonBreakGlassEnded, "deactivated, "+why)
+	}
+	bg.Status.ClusterRoleBinding = ""
+	meta.RemoveStatusCondition(&bg.Status.Conditions, "LockedDown")
+	return nil
+}
+
+// removeActivation removes the break-glass annotation of an expired activation, the next
+// activation needs a new annotation
+func (r *PermsBreakGlassReconciler) removeActivation(ctx context.Context, bg *permsv1.PermsBreakGlass) error {
+	status := bg.Status.DeepCopy()
+	delete(bg.Annotations, permsv1.BreakGlassAnnotation)
+	delete(bg.Annotations, permsv1.BreakGlassActivatedByAnnotation)
+	err := r.Update(ctx, bg)
+	bg.Status = *status
+	return err
+}
+
+// audit emits an event and writes an audit record for a state of the break-glass
+func (r *PermsBreakGlassReconciler) audit(bg *permsv1.PermsBreakGlass, eventtype string, reason string, action string) {
+	expiresAt := ""
+	if bg.Status.ExpiresAt != nil {
+		expiresAt = bg.Status.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	recordEvent(r.Recorder, bg, nil, eventtype, reason, "Break-glass %s %s: ClusterRole %s for %s, activated by %s until %s: %s",
+		bg.Name, action, bg.Spec.Role, strings.Join(breakGlassSubjectNames(bg), ", "), bg.Status.ActivatedBy, expiresAt, bg.Status.Reason)
+	auditLog.Info("break-glass "+action,
+		"PermsBreakGlass.Name", bg.Name,
+		"role", bg.Spec.Role,
+		"users", bg.Spec.Users,
+		"groups", bg.Spec.Groups,
+		"activatedBy", bg.Status.ActivatedBy,
+		"reason", bg.Status.Reason,
+		"activatedAt", bg.Status.ActivatedAt,
+		"expiresAt", expiresAt,
+		"activations", bg.Status.Activations)
+}
+
+// helper to set the "Active" status of a PermsBreakGlass
+func setBreakGlassActiveStatus(ctx context.Context, conditions *[]metav1.Condition, bg *permsv1.PermsBreakGlass) {
+	condition := metav1.Condition{
+		Type:    "Active",
+		Status:  metav1.ConditionFalse,
+		Reason:  "Inactive",
+		Message: "Set the annotation " + permsv1.BreakGlassAnnotation + " to the reason to activate the break-glass",
+	}
+	if bg.Status.Active {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = reasonBreakGlassActivated
+		condition.Message = fmt.Sprintf("Activated by %s until %s: %s", bg.Status.ActivatedBy,
+			bg.Status.ExpiresAt.UTC().Format(time.RFC3339), bg.Status.Reason)
+	}
+	meta.SetStatusCondition(conditions, condition)
+}
+
+// clusterRoleBindingForBreakGlass returns the ClusterRoleBinding of an active break-glass, a
+// locked down break-glass binds no subjects
+func clusterRoleBindingForBreakGlass(bg *permsv1.PermsBreakGlass, locked bool) *rbacv1.ClusterRoleBinding {
+	crb := &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   breakGlassBindingName(bg.Name),
+			Labels: map[string]string{"crd": "PermsBreakGlass", "permsbreakglass_cr": bg.Name},
+			Annotations: map[string]string{
+				"infra-mgmt.io/perms":                   "operator-created",
+				permsv1.BreakGlassAnnotation:            bg.Status.Reason,
+				permsv1.BreakGlassActivatedByAnnotation: bg.Status.ActivatedBy,
+			},
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: "rbac.authorization.k8s.io",
+			Kind:     "ClusterRole",
+			Name:     bg.Spec.Role,
+		},
+	}
+	for _, group := range bg.Spec.Groups {
+		crb.Subjects = append(crb.Subjects, rbacv1.Subject{APIGroup: "rbac.authorization.k8s.io", Kind: "Group", Name: group})
+	}
+	for _, user := range bg.Spec.Users {
+		crb.Subjects = append(crb.Subjects, rbacv1.Subject{APIGroup: "rbac.authorization.k8s.io", Kind: "User", Name: user})
+	}
+	if locked {
+		crb.Subjects = nil
+	}
+	setAppliedHash(crb, crb.RoleRef, crb.Subjects)
+	return crb
+}
+
+// breakGlassSubjectNames returns the users and groups of a break-glass for messages
+func breakGlassSubjectNames(bg *permsv1.PermsBreakGlass) []string {
+	var names []string
+	for _, group := range bg.Spec.Groups {
+		names = append(names, "group "+group)
+	}
+	for _, user := range bg.Spec.Users {
+		names = append(names, "user "+user)
+	}
+	return names
+}
+
+// breakGlassBindingName returns the name of the ClusterRoleBinding of a break-glass
+func breakGlassBindingName(name string) string {
+	return "breakglass-" + name
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PermsBreakGlassReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if !r.WebhooksEnabled {
+		return fmt.Errorf("PermsBreakGlasses require --enable-webhooks, the webhook verifies the activator")
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&permsv1.PermsBreakGlass{}).
+		Owns(&rbacv1.ClusterRoleBinding{}).
+		Watches(&source.Kind{Type: &permsv1.PermsLockdown{}}, handler.EnqueueRequestsFromMapFunc(r.permsBreakGlassesForLockdown)).
+		Complete(r)
+}
@@ -18,27 +18,40 @@ package controllers
 
 import (
 	"context"
-	"reflect"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
-	permsv1beta1 "github.com/infra-mgmt-io/perms/api/v1beta1"
+	permsv1 "github.com/infra-mgmt-io/perms/api/v1"
+	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 // PermsClusterRoleBindingReconciler reconciles a Perms object
 type PermsClusterRoleBindingReconciler struct {
 	client.Client
-	Log    logr.Logger
-	Scheme *runtime.Scheme
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// ResyncInterval is the interval in which the managed bindings are checked for changes
+	// outside of the operator, disabled with 0
+	ResyncInterval time.Duration
+	// ChangeLimiter holds the changes of the subjects when too many subjects are added or removed
+	// across all resources, disabled with nil
+	ChangeLimiter *ChangeLimiter
+	// WebhooksEnabled is set with --enable-webhooks, approvals of sensitive changes are only
+	// honoured if the webhooks record the authors and the approver
+	WebhooksEnabled bool
 }
 
 //var logger logr.Logger
@@ -53,75 +66,330 @@ type PermsClusterRoleBindingReconciler struct {
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.11.2/pkg/reconcile
 func (r *PermsClusterRoleBindingReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger = log.FromContext(ctx)
+	// the status is computed at the reconcile start, a grant which ends while the
+	// bindings are updated is requeued right away
+	now := time.Now()
 
 	// "Verify if a CRD of Permissions exists"
-	permsclusterrolebinding := &permsv1beta1.PermsClusterRoleBinding{}
+	permsclusterrolebinding := &permsv1.PermsClusterRoleBinding{}
 	err := r.Get(ctx, req.NamespacedName, permsclusterrolebinding)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			logger.Info("Resource PermsClusterRoleBinding not found.")
+			forgetApplied("PermsClusterRoleBinding", req.NamespacedName)
 			return ctrl.Result{}, nil
 		}
 		logger.Error(err, "Failed to get PermsClusterRoleBinding")
 		return ctrl.Result{}, err
 	}
+	// Count the subjects of a deleted PermsClusterRoleBinding as removed, its bindings are garbage
+	// collected once the finalizer is removed
+	if !permsclusterrolebinding.DeletionTimestamp.IsZero() {
+		held, err := limitDeletion(ctx, r.Client, r.Recorder, r.ChangeLimiter, "PermsClusterRoleBinding", permsclusterrolebinding,
+			labelsForPermsClusterRoleBindings(permsclusterrolebinding.Name), &permsclusterrolebinding.Status.Conditions, now)
+		if err != nil {
+			logger.Error(err, "Failed to check the removal of the subjects of the deleted PermsClusterRoleBinding", "PermsClusterRoleBinding.Name", permsclusterrolebinding.Name)
+			return ctrl.Result{}, err
+		}
+		if held {
+			logger.Info("Removal of the subjects is held, waiting for the release", "PermsClusterRoleBinding.Name", permsclusterrolebinding.Name)
+			if updateErr := r.updateStatus(ctx, permsclusterrolebinding); updateErr != nil {
+				logger.Error(updateErr, "Update rolebinding status failed")
+			}
+			return ctrl.Result{RequeueAfter: changesHeldRequeueInterval}, nil
+		}
+		return ctrl.Result{}, nil
+	}
+	if err = addChangeLimitFinalizer(ctx, r.Client, r.ChangeLimiter, permsclusterrolebinding); err != nil {
+		logger.Error(err, "Failed to add the finalizer", "PermsClusterRoleBinding.Name", permsclusterrolebinding.Name)
+		return ctrl.Result{}, err
+	}
+	// with --impersonate-authors the bindings are written as the author of the resource
+	ctx = withAuthor(ctx, permsclusterrolebinding)
+
+	// Apply the last approved spec while a change of a sensitive PermsClusterRoleBinding waits for
+	// the approval of a second user
+	hold, err := r.reconcileApproval(ctx, permsclusterrolebinding)
+	if err != nil {
+		logger.Error(err, "Failed to check the approval of the PermsClusterRoleBinding")
+		return ctrl.Result{}, err
+	}
+
+	// Remove the subjects of the bindings while a PermsLockdown is active, a
+	// PermsClusterRoleBinding which waits for its first approval has none
+	locked := permsclusterrolebinding.Status.Lockdown != nil
+	var subjects []rbacv1.Subject
+	if !hold {
+		subjects = subsForPermsClusterRoleBindings(permsclusterrolebinding, now)
+	}
+	if err = reconcileLockdown(ctx, r.Client, r.Recorder, permsclusterrolebinding, &permsclusterrolebinding.Status.Lockdown,
+		subjects, &permsclusterrolebinding.Status.Conditions, now); err != nil {
+		logger.Error(err, "Failed to check the PermsLockdowns")
+		return ctrl.Result{}, err
+	}
+	if hold {
+		logger.Info("PermsClusterRoleBinding waits for the approval", "PermsClusterRoleBinding.Name", permsclusterrolebinding.Name)
+		if updateErr := r.updateStatus(ctx, permsclusterrolebinding); updateErr != nil {
+			logger.Error(updateErr, "Update rolebinding status failed")
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// Revoke the bindings of a PermsClusterRoleBinding which violates a PermsPolicy, it may have
+	// been created before the policy
+	policyReq := permsclusterrolebinding.PolicyRequest()
+	if bindsNamespaces(permsclusterrolebinding) {
+		namespaces, err := r.namespacesForPerms(ctx, permsclusterrolebinding)
+		if err != nil {
+			logger.Error(err, "Failed to list the namespaces of the PermsClusterRoleBinding")
+			return ctrl.Result{}, err
+		}
+		policyReq.Namespaces = namespaces
+	}
+	violations, err := policyViolations(ctx, r.Client, policyReq)
+	if err != nil {
+		logger.Error(err, "Failed to check the PermsPolicies")
+		return ctrl.Result{}, err
+	}
+	permsclusterrolebinding.Status.PolicyViolations = violations
+	if setPolicyViolationStatus(ctx, &permsclusterrolebinding.Status.Conditions, violations) && len(violations) > 0 {
+		recordEvent(r.Recorder, permsclusterrolebinding, nil, corev1.EventTypeWarning, reasonPolicyViolation, "%s", strings.Join(violations, "; "))
+	}
+	if len(violations) > 0 {
+		logger.Info("PermsClusterRoleBinding violates a PermsPolicy", "PermsClusterRoleBinding.Name", permsclusterrolebinding.Name, "Violations", violations)
+		if err = revokeBindings(ctx, r.Client, r.Recorder, permsclusterrolebinding, permsclusterrolebinding.Status.Bindings); err != nil {
+			logger.Error(err, "Failed to delete bindings which violate a PermsPolicy", "ClusterRolebinding.Name", permsclusterrolebinding.Name)
+			recordError(r.Recorder, permsclusterrolebinding, nil, "Deleting the bindings which violate a PermsPolicy", err)
+			setErrorStatus(ctx, &permsclusterrolebinding.Status.Conditions, err)
+			if updateErr := r.updateStatus(ctx, permsclusterrolebinding); updateErr != nil {
+				logger.Error(updateErr, "Update rolebinding status failed")
+			}
+			return ctrl.Result{}, err
+		}
+		permsclusterrolebinding.Status.Bindings = nil
+		setHoustonWeHaveAProblemStatus(ctx, &permsclusterrolebinding.Status.Conditions, reasonPolicyViolation, strings.Join(violations, "; "))
+		if updateErr := r.updateStatus(ctx, permsclusterrolebinding); updateErr != nil {
+			logger.Error(updateErr, "Update rolebinding status failed")
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// Warn about a referenced ClusterRole which does not exist, the bindings are created anyway
+	missingRole, roleRules, err := r.checkClusterRole(ctx, permsclusterrolebinding)
+	if err != nil {
+		logger.Error(err, "Failed to get the referenced ClusterRole", "PermsClusterRoleBinding.Name", permsclusterrolebinding.Name)
+		return ctrl.Result{}, err
+	}
+	setRoleResolvedStatus(ctx, &permsclusterrolebinding.Status.Conditions, missingRole)
+
+	// Hold the reconcile after a change of the rules of a pinned role until it is acknowledged, a
+	// lockdown removes the subjects anyway
+	if reconcileRoleRules(r.Recorder, permsclusterrolebinding, permsclusterrolebinding.Spec.PinRoleRules, &permsclusterrolebinding.Status.Conditions,
+		&permsclusterrolebinding.Status.RoleRules, &permsclusterrolebinding.Status.RoleRulesHash, roleRules) && permsclusterrolebinding.Status.Lockdown == nil {
+		logger.Info("Rules of the referenced ClusterRole changed, waiting for the acknowledgment", "PermsClusterRoleBinding.Name", permsclusterrolebinding.Name)
+		if updateErr := r.updateStatus(ctx, permsclusterrolebinding); updateErr != nil {
+			logger.Error(updateErr, "Update rolebinding status failed")
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// Hold the change of the subjects when too many subjects were added to or removed from the
+	// bindings of all resources, the start and the end of a lockdown are not limited
+	if !locked && permsclusterrolebinding.Status.Lockdown == nil {
+		desired, err := r.desiredBindings(ctx, permsclusterrolebinding)
+		if err != nil {
+			logger.Error(err, "Failed to list the namespaces of the PermsClusterRoleBinding")
+			return ctrl.Result{}, err
+		}
+		held, err := limitChanges(ctx, r.Client, r.Recorder, r.ChangeLimiter, "PermsClusterRoleBinding", permsclusterrolebinding,
+			labelsForPermsClusterRoleBindings(permsclusterrolebinding.Name), desired, subjects, &permsclusterrolebinding.Status.Conditions, now)
+		if err != nil {
+			logger.Error(err, "Failed to check the change of the subjects", "PermsClusterRoleBinding.Name", permsclusterrolebinding.Name)
+			return ctrl.Result{}, err
+		}
+		if held {
+			logger.Info("Change of the subjects is held, waiting for the release", "PermsClusterRoleBinding.Name", permsclusterrolebinding.Name)
+			if updateErr := r.updateStatus(ctx, permsclusterrolebinding); updateErr != nil {
+				logger.Error(updateErr, "Update rolebinding status failed")
+			}
+			return ctrl.Result{RequeueAfter: changesHeldRequeueInterval}, nil
+		}
+	}
+
+	// Bind the ClusterRole per namespace instead of cluster-wide
+	if bindsNamespaces(permsclusterrolebinding) {
+		return r.reconcileNamespaces(ctx, permsclusterrolebinding, req, now, missingRole)
+	}
 
 	// Check if the binding already exists, if not create a new one
+	drifted := false
 	bindings := &rbacv1.ClusterRoleBinding{}
-	if clusterBindingExistsErr := r.Get(ctx, types.NamespacedName{Name: permsclusterrolebinding.Name}, bindings); clusterBindingExistsErr != nil {
+	clusterBindingExistsErr := r.Get(ctx, types.NamespacedName{Name: permsclusterrolebinding.Name}, bindings)
+	if clusterBindingExistsErr == nil {
+		// Refuse to touch a ClusterRolebinding which is not managed by this PermsClusterRoleBinding
+		if err = r.adoptClusterRoleBinding(ctx, permsclusterrolebinding, bindings); err != nil {
+			logger.Error(err, "ClusterRolebinding is not managed by the PermsClusterRoleBinding", "ClusterRolebinding.Name", permsclusterrolebinding.Name)
+			recordError(r.Recorder, permsclusterrolebinding, nil, "Adopting ClusterRoleBinding "+bindings.Name, err)
+			result, err := ownershipResult(ctx, &permsclusterrolebinding.Status.Conditions, err)
+			if updateErr := r.updateStatus(ctx, permsclusterrolebinding); updateErr != nil {
+				logger.Error(updateErr, "Update rolebinding status failed")
+			}
+			return result, err
+		}
+	}
+	if clusterBindingExistsErr != nil {
+		if isBound(permsclusterrolebinding.Status.Bindings, "ClusterRoleBinding", "", permsclusterrolebinding.Name) {
+			logger.Info("Restoring ClusterRolebinding deleted outside of the operator", "ClusterRolebinding.Name", permsclusterrolebinding.Name)
+			recordEvent(r.Recorder, permsclusterrolebinding, nil, corev1.EventTypeWarning, reasonDriftReverted, "Restoring ClusterRoleBinding %s deleted outside of the operator", permsclusterrolebinding.Name)
+			drifted = true
+		}
 		logger.Info("Creating a new ClusterRolebinding", "ClusterRolebinding.Namespace", permsclusterrolebinding.Namespace, "ClusterRolebinding.Name ", permsclusterrolebinding.Name)
 		// Define a new ClusterRoleBinding
-		rb := r.clusterRolebindingForPerms(permsclusterrolebinding, ctx)
+		rb := r.clusterRolebindingForPerms(permsclusterrolebinding, ctx, now)
 		setProgressingStatus(ctx, &permsclusterrolebinding.Status.Conditions)
 		if err = r.Create(ctx, rb); err != nil {
 			logger.Error(err, "Failed to create new ClusterRoleBinding. Check if role exists.", "ClusterRolebinding.Namespace", rb.Namespace, "ClusterRolebinding.Name", rb.Name)
-			setHoustonWeHaveAProblemStatus(ctx, &permsclusterrolebinding.Status.Conditions)
+			recordError(r.Recorder, permsclusterrolebinding, nil, "Creating ClusterRoleBinding "+rb.Name, err)
+			setErrorStatus(ctx, &permsclusterrolebinding.Status.Conditions, err)
+			if updateErr := r.updateStatus(ctx, permsclusterrolebinding); updateErr != nil {
+				logger.Error(updateErr, "Update rolebinding status failed")
+			}
 			return ctrl.Result{RequeueAfter: time.Minute}, err
 		}
+		recordEvent(r.Recorder, permsclusterrolebinding, rb, corev1.EventTypeNormal, reasonCreated,
+			"Created ClusterRoleBinding %s for ClusterRole %s with %s", rb.Name, rb.RoleRef.Name, strings.Join(subjectNames(rb.Subjects), ", "))
+		permsclusterrolebinding.Status.LastChange = mergeLastChange(permsclusterrolebinding.Status.LastChange, subjectNames(rb.Subjects), nil, now)
 
-	} else {
+	} else if bindings.RoleRef.Name != permsclusterrolebinding.Spec.Role {
 		// Check, if updates on immutable parts of rolebinding are configured
-		// if so - leave the reconcile loop
-		if bindings.RoleRef.Name != permsclusterrolebinding.Spec.Role {
+		// if the role switch is disabled - leave the reconcile loop
+		rb := r.clusterRolebindingForPerms(permsclusterrolebinding, ctx, now)
+		if permsclusterrolebinding.Spec.ImmutableRoleRef {
 			logger.Error(err, "Update immutable configuration (spec.Role)", "ClusterRolebinding.Namespace", permsclusterrolebinding.Namespace, "ClusterRolebinding.Name", permsclusterrolebinding.Name)
-			setHoustonWeHaveAProblemStatus(ctx, &permsclusterrolebinding.Status.Conditions)
-			if updateErr := r.Status().Update(ctx, permsclusterrolebinding); updateErr != nil {
+			recordEvent(r.Recorder, permsclusterrolebinding, nil, corev1.EventTypeWarning, reasonImmutableRoleRef,
+				"Role of ClusterRoleBinding %s is not switched from %s to %s, spec.immutableRoleRef is set", bindings.Name, bindings.RoleRef.Name, rb.RoleRef.Name)
+			immutableViolations.WithLabelValues("PermsClusterRoleBinding").Inc()
+			setHoustonWeHaveAProblemStatus(ctx, &permsclusterrolebinding.Status.Conditions, reasonImmutableRoleRef, immutableRoleRefMessage(bindings.RoleRef, rb.RoleRef))
+			setRoleSwitchDeniedStatus(ctx, &permsclusterrolebinding.Status.Conditions, bindings.RoleRef, rb.RoleRef)
+			if updateErr := r.updateStatus(ctx, permsclusterrolebinding); updateErr != nil {
 				logger.Error(updateErr, "Update rolebinding status failed")
 			}
 			return ctrl.Result{Requeue: false}, err
 		}
-		// Update ClusterRolebinding
-		subs := subsForPermsClusterRoleBindings(permsclusterrolebinding)
-
-		if !reflect.DeepEqual(bindings.Subjects, subs) {
+		// Switch the ClusterRolebinding to the new role
+		logger.Info("Switching ClusterRolebinding role", "ClusterRolebinding.Namespace", permsclusterrolebinding.Namespace, "ClusterRolebinding.Name", permsclusterrolebinding.Name, "From", bindings.RoleRef.Name, "To", rb.RoleRef.Name)
+		setProgressingStatus(ctx, &permsclusterrolebinding.Status.Conditions)
+		if err = r.switchClusterRoleBinding(ctx, permsclusterrolebinding, bindings, now); err != nil {
+			logger.Error(err, "Failed to switch ClusterRolebinding role", "ClusterRolebinding.Namespace", permsclusterrolebinding.Namespace, "ClusterRolebinding.Name", permsclusterrolebinding.Name)
+			recordError(r.Recorder, permsclusterrolebinding, nil, "Switching the role of ClusterRoleBinding "+bindings.Name, err)
+			result, err := ownershipResult(ctx, &permsclusterrolebinding.Status.Conditions, err)
+			if updateErr := r.updateStatus(ctx, permsclusterrolebinding); updateErr != nil {
+				logger.Error(updateErr, "Update rolebinding status failed")
+			}
+			return result, err
+		}
+		recordEvent(r.Recorder, permsclusterrolebinding, nil, corev1.EventTypeNormal, reasonRoleSwitched,
+			"Switched ClusterRoleBinding %s from ClusterRole %s to %s", bindings.Name, bindings.RoleRef.Name, rb.RoleRef.Name)
+		setRoleSwitchedStatus(ctx, &permsclusterrolebinding.Status.Conditions, bindings.RoleRef, rb.RoleRef)
+	} else {
+		// Update ClusterRolebinding, changes outside of the operator are reverted
+		subjects := bindings.Subjects
+		update, bindingDrifted := syncBinding(bindings, r.clusterRolebindingForPerms(permsclusterrolebinding, ctx, now))
+		if bindingDrifted {
+			logger.Info("Restoring ClusterRolebinding changed outside of the operator", "ClusterRolebinding.Name", permsclusterrolebinding.Name)
+			drifted = true
+		}
+		if update {
 			logger.Info("Updating ClusterRolebinding", "ClusterRolebinding.Namespace", permsclusterrolebinding.Namespace, "ClusterRolebinding.Name", permsclusterrolebinding.Name)
 			setProgressingStatus(ctx, &permsclusterrolebinding.Status.Conditions)
-			bindings.Subjects = subs
 			if err := r.Update(ctx, bindings); err != nil {
 				logger.Error(err, "Failed to update ClusterRolebinding", "ClusterRolebinding.Namespace", permsclusterrolebinding.Namespace, "ClusterRolebinding.Name", permsclusterrolebinding.Name)
-				setHoustonWeHaveAProblemStatus(ctx, &permsclusterrolebinding.Status.Conditions)
+				recordError(r.Recorder, permsclusterrolebinding, bindings, "Updating ClusterRoleBinding "+bindings.Name, err)
+				setErrorStatus(ctx, &permsclusterrolebinding.Status.Conditions, err)
+				if updateErr := r.updateStatus(ctx, permsclusterrolebinding); updateErr != nil {
+					logger.Error(updateErr, "Update rolebinding status failed")
+				}
 				return ctrl.Result{}, err
 			}
+			if bindingDrifted {
+				recordEvent(r.Recorder, permsclusterrolebinding, bindings, corev1.EventTypeWarning, reasonDriftReverted, "Reverted changes of ClusterRoleBinding %s made outside of the operator", bindings.Name)
+			}
+			added, removed := recordSubjectChanges(r.Recorder, permsclusterrolebinding, bindings, subjects, bindings.Subjects)
+			permsclusterrolebinding.Status.LastChange = mergeLastChange(permsclusterrolebinding.Status.LastChange, added, removed, now)
 		}
 	}
 
+	// Remove a leftover ClusterRolebinding of an interrupted role switch
+	if err = r.removeClusterRoleSwitchBinding(ctx, permsclusterrolebinding); err != nil {
+		logger.Error(err, "Failed to remove role switch ClusterRolebinding", "ClusterRolebinding.Name", roleSwitchBindingName(permsclusterrolebinding.Name))
+		recordError(r.Recorder, permsclusterrolebinding, nil, "Removing ClusterRoleBinding "+roleSwitchBindingName(permsclusterrolebinding.Name), err)
+		result, err := ownershipResult(ctx, &permsclusterrolebinding.Status.Conditions, err)
+		if updateErr := r.updateStatus(ctx, permsclusterrolebinding); updateErr != nil {
+			logger.Error(updateErr, "Update rolebinding status failed")
+		}
+		return result, err
+	}
+
+	// Remove the RoleBindings of a former namespace selection
+	if err = r.pruneNamespaceRoleBindings(ctx, permsclusterrolebinding, nil); err != nil {
+		logger.Error(err, "Failed to remove RoleBindings of unselected namespaces", "PermsClusterRoleBinding.Name", permsclusterrolebinding.Name)
+		recordError(r.Recorder, permsclusterrolebinding, nil, "Removing the RoleBindings of unselected namespaces", err)
+		return ctrl.Result{}, err
+	}
+
 	// update the Resource Status
+	if drifted {
+		permsclusterrolebinding.Status.DriftCount++
+		driftRepairs.WithLabelValues("PermsClusterRoleBinding").Inc()
+		permsclusterrolebinding.Status.LastDriftTime = &metav1.Time{Time: now}
+	}
+	permsclusterrolebinding.Status.Bindings = []permsv1.BindingReference{{
+		Kind:    "ClusterRoleBinding",
+		Name:    permsclusterrolebinding.Name,
+		RoleRef: permsv1.RoleReference{Kind: "ClusterRole", Name: permsclusterrolebinding.Spec.Role},
+	}}
 	r.updateCountsPermsClusterRoleBinding(ctx, permsclusterrolebinding, req)
-	setEverythingIsFineStatus(ctx, &permsclusterrolebinding.Status.Conditions)
-	if updateErr := r.Status().Update(ctx, permsclusterrolebinding); updateErr != nil {
+	permsclusterrolebinding.Status.Expirations, permsclusterrolebinding.Status.NextTransitionTime = expirationsForSubjects(permsclusterrolebinding.Spec.Validity,
+		permsclusterrolebinding.Spec.Groups, permsclusterrolebinding.Spec.Users, permsclusterrolebinding.Spec.Serviceaccounts, "", now)
+	if missingRole != "" {
+		setHoustonWeHaveAProblemStatus(ctx, &permsclusterrolebinding.Status.Conditions, reasonRoleNotFound, missingRole)
+	} else {
+		setEverythingIsFineStatus(ctx, &permsclusterrolebinding.Status.Conditions)
+	}
+	setNoConflictStatus(ctx, &permsclusterrolebinding.Status.Conditions)
+	setGrantActiveStatus(ctx, &permsclusterrolebinding.Status.Conditions, validityReason(permsclusterrolebinding.Spec.Validity, now))
+	if updateErr := r.updateStatus(ctx, permsclusterrolebinding); updateErr != nil {
 		logger.Error(updateErr, "Update rolebinding status failed")
 	}
-	// return nil to stop reconcile loop
-	return ctrl.Result{}, nil
+	observeApplied("PermsClusterRoleBinding", permsclusterrolebinding, time.Now())
+	// requeue at the next start or end of a grant or to check the ClusterRolebinding for drift
+	return withResync(requeueAtTransition(permsclusterrolebinding.Status.NextTransitionTime, now), r.ResyncInterval), nil
+}
+
+// desiredBindings returns the references of the RoleBindings in the selected namespaces, or of
+// the ClusterRoleBinding if the ClusterRole is bound cluster-wide
+func (r *PermsClusterRoleBindingReconciler) desiredBindings(ctx context.Context, p *permsv1.PermsClusterRoleBinding) ([]permsv1.BindingReference, error) {
+	if !bindsNamespaces(p) {
+		return []permsv1.BindingReference{{Kind: "ClusterRoleBinding", Name: p.Name}}, nil
+	}
+	namespaces, err := r.namespacesForPerms(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	var refs []permsv1.BindingReference
+	for _, namespace := range namespaces {
+		refs = append(refs, permsv1.BindingReference{Kind: "RoleBinding", Namespace: namespace, Name: p.Name})
+	}
+	return refs, nil
 }
 
 // ClusterrolebindingForPerms returns a ClusterRolebinding object
-func (r *PermsClusterRoleBindingReconciler) clusterRolebindingForPerms(p *permsv1beta1.PermsClusterRoleBinding, ctx context.Context) *rbacv1.ClusterRoleBinding {
+func (r *PermsClusterRoleBindingReconciler) clusterRolebindingForPerms(p *permsv1.PermsClusterRoleBinding, ctx context.Context, now time.Time) *rbacv1.ClusterRoleBinding {
 	//logger := log.FromContext(ctx)
 
 	// define labels
 	labels := labelsForPermsClusterRoleBindings(p.Name)
-	subs := subsForPermsClusterRoleBindings(p)
+	subs := lockedSubjects(p.Status.Lockdown, subsForPermsClusterRoleBindings(p, now))
 
 	rb := &rbacv1.ClusterRoleBinding{
 		ObjectMeta: metav1.ObjectMeta{
@@ -138,66 +406,158 @@ func (r *PermsClusterRoleBindingReconciler) clusterRolebindingForPerms(p *permsv
 		},
 	}
 	rb.Subjects = subs
+	setAppliedHash(rb, rb.RoleRef, rb.Subjects)
 
 	// Set ClusterRolebindingForPermissions instance as the owner and controller
 	ctrl.SetControllerReference(p, rb, r.Scheme)
 	return rb
 }
 
+// adoptClusterRoleBinding checks that a ClusterRolebinding is managed by the PermsClusterRoleBinding
+// and adopts it with the adoption policy Adopt
+func (r *PermsClusterRoleBindingReconciler) adoptClusterRoleBinding(ctx context.Context, p *permsv1.PermsClusterRoleBinding, crb *rbacv1.ClusterRoleBinding) error {
+	adopted, err := adoptBinding(crb, "ClusterRoleBinding", p, p.Spec.AdoptionPolicy, labelsForPermsClusterRoleBindings(p.Name), r.Scheme)
+	if err != nil || !adopted {
+		return err
+	}
+	logger.Info("Adopting ClusterRolebinding", "ClusterRolebinding.Name", crb.Name)
+	if err := r.Update(ctx, crb); err != nil {
+		return err
+	}
+	recordEvent(r.Recorder, p, crb, corev1.EventTypeNormal, reasonAdopted, "Adopted ClusterRoleBinding %s", crb.Name)
+	return nil
+}
+
+// checkClusterRole emits a warning if the referenced ClusterRole does not exist and returns
+// the message of the missing role and the summary of its rules, a missing ClusterRole keeps
+// its summary in status
+func (r *PermsClusterRoleBindingReconciler) checkClusterRole(ctx context.Context, p *permsv1.PermsClusterRoleBinding) (string, []permsv1.RoleRulesSummary, error) {
+	role := &rbacv1.ClusterRole{}
+	err := r.Get(ctx, types.NamespacedName{Name: p.Spec.Role}, role)
+	if errors.IsNotFound(err) {
+		recordEvent(r.Recorder, p, nil, corev1.EventTypeWarning, reasonRoleNotFound,
+			"ClusterRole %s referenced by PermsClusterRoleBinding %s does not exist", p.Spec.Role, p.Name)
+		return "Referenced roles do not exist: ClusterRole/" + p.Spec.Role, unresolvedRoleRules(p.Status.RoleRules, "ClusterRole", p.Spec.Role), nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return "", []permsv1.RoleRulesSummary{roleRulesSummary("ClusterRole", role.Name, role.Rules)}, nil
+}
+
+// updateStatus writes the status computed for the current generation
+func (r *PermsClusterRoleBindingReconciler) updateStatus(ctx context.Context, p *permsv1.PermsClusterRoleBinding) error {
+	p.Status.ObservedGeneration = p.Generation
+	setObservedGeneration(p.Status.Conditions, p.Generation)
+	orderConditions(p.Status.Conditions)
+	return r.Status().Update(ctx, p)
+}
+
+// switchClusterRoleBinding replaces a ClusterRolebinding with a ClusterRolebinding for the new role.
+// A temporary ClusterRolebinding grants the new role until the replacement is created,
+// so the subjects do not lose their permissions during the switch.
+func (r *PermsClusterRoleBindingReconciler) switchClusterRoleBinding(ctx context.Context, p *permsv1.PermsClusterRoleBinding, current *rbacv1.ClusterRoleBinding, now time.Time) error {
+	if err := r.removeClusterRoleSwitchBinding(ctx, p); err != nil {
+		return err
+	}
+	switchBinding := r.clusterRolebindingForPerms(p, ctx, now)
+	switchBinding.Name = roleSwitchBindingName(p.Name)
+	if err := r.Create(ctx, switchBinding); errors.IsAlreadyExists(err) {
+		// only a ClusterRolebinding which is not managed by the PermsClusterRoleBinding is left
+		return &bindingConflict{kind: "ClusterRoleBinding", key: client.ObjectKeyFromObject(switchBinding)}
+	} else if err != nil {
+		return err
+	}
+	if err := r.Delete(ctx, current); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if err := r.Create(ctx, r.clusterRolebindingForPerms(p, ctx, now)); err != nil {
+		return err
+	}
+	return r.removeClusterRoleSwitchBinding(ctx, p)
+}
+
+// removeClusterRoleSwitchBinding deletes the temporary ClusterRolebinding of a role switch if it exists,
+// a ClusterRolebinding of the same name which is not managed by the PermsClusterRoleBinding is ignored
+func (r *PermsClusterRoleBindingReconciler) removeClusterRoleSwitchBinding(ctx context.Context, p *permsv1.PermsClusterRoleBinding) error {
+	switchBinding := &rbacv1.ClusterRoleBinding{}
+	if err := r.Get(ctx, types.NamespacedName{Name: roleSwitchBindingName(p.Name)}, switchBinding); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !metav1.IsControlledBy(switchBinding, p) {
+		return nil
+	}
+	return client.IgnoreNotFound(r.Delete(ctx, switchBinding))
+}
+
 // Function returns the labels for selecting the resources
 func labelsForPermsClusterRoleBindings(name string) map[string]string {
 	return map[string]string{"crd": "PermsClusterRoleBinding", "permsclusterrolebinding_cr": name}
 }
 
-// Function returns the subjects for ClusterRolebinding
-func subsForPermsClusterRoleBindings(p *permsv1beta1.PermsClusterRoleBinding) []rbacv1.Subject {
-	subs := make([]rbacv1.Subject, len(p.Spec.Groups))
-	for i, group := range p.Spec.Groups {
-		subs[i] = rbacv1.Subject{
+// Function returns the subjects for ClusterRolebinding which have the role assigned at now
+func subsForPermsClusterRoleBindings(p *permsv1.PermsClusterRoleBinding, now time.Time) []rbacv1.Subject {
+	if validityReason(p.Spec.Validity, now) != "Active" {
+		return nil
+	}
+
+	var subs []rbacv1.Subject
+	for _, group := range p.Spec.Groups {
+		if expired(expiryOf(p.Spec.GroupExpiry, group), now) {
+			continue
+		}
+		subs = append(subs, rbacv1.Subject{
 			APIGroup: "rbac.authorization.k8s.io",
 			Kind:     "Group",
 			Name:     group,
-		}
+		})
 	}
 
-	subUsers := make([]rbacv1.Subject, len(p.Spec.Users))
-	for i, user := range p.Spec.Users {
-		subUsers[i] = rbacv1.Subject{
+	for _, user := range p.Spec.Users {
+		if expired(expiryOf(p.Spec.UserExpiry, user), now) {
+			continue
+		}
+		subs = append(subs, rbacv1.Subject{
 			APIGroup: "rbac.authorization.k8s.io",
 			Kind:     "User",
 			Name:     user,
-		}
-		subs = append(subs, subUsers[i])
+		})
 	}
 
-	subServiceaccounts := make([]rbacv1.Subject, len(p.Spec.Serviceaccounts))
-	for i, serviceaccounts := range p.Spec.Serviceaccounts {
-		subServiceaccounts[i] = rbacv1.Subject{
+	for _, serviceaccounts := range p.Spec.Serviceaccounts {
+		if expired(serviceaccounts.ExpiresAt, now) {
+			continue
+		}
+		namespace := serviceaccounts.Namespace
+		subs = append(subs, rbacv1.Subject{
 			Kind:      "ServiceAccount",
 			Name:      serviceaccounts.Name,
-			Namespace: serviceaccounts.Namespace,
-		}
-		subs = append(subs, subServiceaccounts[i])
+			Namespace: namespace,
+		})
 	}
 	return subs
 }
 
-func (r *PermsClusterRoleBindingReconciler) updateCountsPermsClusterRoleBinding(ctx context.Context, p *permsv1beta1.PermsClusterRoleBinding, req ctrl.Request) {
-	if p.Status.Count.Users != strconv.Itoa(len(p.Spec.Users)) ||
-		p.Status.Count.Groups != strconv.Itoa(len(p.Spec.Groups)) ||
-		p.Status.Count.Serviceaccounts != strconv.Itoa(len(p.Spec.Serviceaccounts)) {
-
-		p.Status.Count.Users = strconv.Itoa(len(p.Spec.Users))
-		p.Status.Count.Groups = strconv.Itoa(len(p.Spec.Groups))
-		p.Status.Count.Serviceaccounts = strconv.Itoa(len(p.Spec.Serviceaccounts))
-
+func (r *PermsClusterRoleBindingReconciler) updateCountsPermsClusterRoleBinding(ctx context.Context, p *permsv1.PermsClusterRoleBinding, req ctrl.Request) {
+	p.Status.Count = permsv1.SubjectCount{
+		Users:           int32(len(p.Spec.Users)),
+		Groups:          int32(len(p.Spec.Groups)),
+		Serviceaccounts: int32(len(p.Spec.Serviceaccounts)),
 	}
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *PermsClusterRoleBindingReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &permsv1.PermsClusterRoleBinding{}, roleRefIndex, permsClusterRoleBindingRoleRefs); err != nil {
+		return err
+	}
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&permsv1beta1.PermsClusterRoleBinding{}).
+		For(&permsv1.PermsClusterRoleBinding{}).
 		Owns(&rbacv1.ClusterRoleBinding{}).
+		Owns(&rbacv1.RoleBinding{}).
+		Watches(&source.Kind{Type: &corev1.Namespace{}}, handler.EnqueueRequestsFromMapFunc(r.permsClusterRoleBindingsForNamespace)).
+		Watches(&source.Kind{Type: &rbacv1.ClusterRole{}}, handler.EnqueueRequestsFromMapFunc(r.permsClusterRoleBindingsForClusterRole)).
+		Watches(&source.Kind{Type: &permsv1.PermsPolicy{}}, handler.EnqueueRequestsFromMapFunc(r.permsClusterRoleBindingsForPolicy)).
+		Watches(&source.Kind{Type: &permsv1.PermsLockdown{}}, handler.EnqueueRequestsFromMapFunc(r.permsClusterRoleBindingsForLockdown)).
 		Complete(r)
 }
@@ -149,4 +149,143 @@ var _ = Describe("permsclusterrolebinding", func() {
 		})
 	})
 
+	Context("ensure that the operator switches the role of existing resources", func() {
+
+		It("should successfully run the perms operator", func() {
+			projectDir, _ := GetProjectDir()
+
+			testNamespace := "testing7"
+
+			By("creating test namespace")
+			cmd = exec.Command("kubectl", "create", "ns", testNamespace)
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("creating an instance of the PermsClusterRoleBinding CRD in a different namespace")
+			EventuallyWithOffset(1, func() error {
+				cmd = exec.Command("kubectl", "apply", "-f", filepath.Join(projectDir,
+					"config/samples/perms_v1beta1_permsclusterrolebinding_demo1.yaml"), "-n", testNamespace)
+				_, err = Run(cmd)
+				return err
+			}, 15*time.Second, time.Second).Should(Succeed())
+
+			time.Sleep(5 * time.Second)
+
+			By("updating the role of the PermClustersRoleBinding CRD")
+			EventuallyWithOffset(1, func() error {
+				cmd = exec.Command("kubectl", "patch", "pcrb", "demo1", "--patch-file", filepath.Join(projectDir,
+					"config/samples/perms_v1beta1_permsclusterrolebinding_patch_switch.yaml"), "--type", "merge", "-n", testNamespace)
+				_, err = Run(cmd)
+				return err
+			}, 15*time.Second, time.Second).Should(Succeed())
+
+			By("validating that the ClusterRolebinding references the new role")
+			getRoleRef := func() error {
+				cmd = exec.Command("kubectl", "get", "clusterrolebinding",
+					"demo1", "-o", "jsonpath={.roleRef.name}",
+				)
+				roleRef, err := Run(cmd)
+				fmt.Println(string(roleRef))
+				ExpectWithOffset(2, err).NotTo(HaveOccurred())
+				if string(roleRef) != "view" {
+					return fmt.Errorf("ClusterRolebinding should reference the role view")
+				}
+				return nil
+			}
+			Eventually(getRoleRef, 15*time.Second, time.Second).Should(Succeed())
+
+			By("validating that the status of the CR patched is role switched")
+			getStatus := func() error {
+				cmd = exec.Command("kubectl", "get", "pcrb",
+					"demo1", "-o", "jsonpath={.status.conditions[?(@.type==\"RoleSwitched\")].status}",
+					"-n", testNamespace,
+				)
+				status, err := Run(cmd)
+				fmt.Println(string(status))
+				ExpectWithOffset(2, err).NotTo(HaveOccurred())
+				if !strings.Contains(string(status), "True") {
+					return fmt.Errorf("status condition with type RoleSwitched should be set")
+				}
+				return nil
+			}
+			Eventually(getStatus, 15*time.Second, time.Second).Should(Succeed())
+
+			By("removing test namespace")
+			cmd = exec.Command("kubectl", "delete", "ns", testNamespace)
+			_, _ = Run(cmd)
+
+		})
+	})
+
+	Context("ensure that the operator binds the ClusterRole in the selected namespaces", func() {
+
+		It("should create and remove rolebindings when namespaces are labelled and unlabelled", func() {
+			projectDir, _ := GetProjectDir()
+			testNamespace := "testing9"
+			labelledNamespace := "testing10"
+
+			By("creating the test namespaces")
+			cmd = exec.Command("kubectl", "create", "ns", testNamespace)
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+			cmd = exec.Command("kubectl", "create", "ns", labelledNamespace)
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("creating an instance of the PermsClusterRoleBinding CRD with a namespace selector")
+			EventuallyWithOffset(1, func() error {
+				cmd = exec.Command("kubectl", "apply", "-f", filepath.Join(projectDir,
+					"config/samples/perms_v1_permsclusterrolebinding_namespaces.yaml"))
+				_, err = Run(cmd)
+				return err
+			}, 15*time.Second, time.Second).Should(Succeed())
+
+			getRoleBinding := func(namespace string, exists bool) func() error {
+				return func() error {
+					cmd = exec.Command("kubectl", "get", "rolebinding",
+						"-l", "permsclusterrolebinding_cr=demo4", "-o", "jsonpath={.items[*].roleRef.name}",
+						"-n", namespace,
+					)
+					roleRef, err := Run(cmd)
+					fmt.Println(string(roleRef))
+					ExpectWithOffset(2, err).NotTo(HaveOccurred())
+					if exists && string(roleRef) != "view" {
+						return fmt.Errorf("rolebinding in namespace %s should reference the role view", namespace)
+					}
+					if !exists && string(roleRef) != "" {
+						return fmt.Errorf("rolebinding in namespace %s should be removed", namespace)
+					}
+					return nil
+				}
+			}
+
+			By("validating that the listed namespace gets a rolebinding")
+			Eventually(getRoleBinding(testNamespace, true), 15*time.Second, time.Second).Should(Succeed())
+
+			By("validating that no ClusterRolebinding is created")
+			cmd = exec.Command("kubectl", "get", "clusterrolebinding", "demo4")
+			_, err = Run(cmd)
+			Expect(err).To(HaveOccurred())
+
+			By("labelling a namespace")
+			cmd = exec.Command("kubectl", "label", "ns", labelledNamespace, "perms.infra-mgmt.io/team=demo4")
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+			Eventually(getRoleBinding(labelledNamespace, true), 15*time.Second, time.Second).Should(Succeed())
+
+			By("unlabelling the namespace")
+			cmd = exec.Command("kubectl", "label", "ns", labelledNamespace, "perms.infra-mgmt.io/team-")
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+			Eventually(getRoleBinding(labelledNamespace, false), 15*time.Second, time.Second).Should(Succeed())
+
+			By("removing the PermsClusterRoleBinding and the test namespaces")
+			cmd = exec.Command("kubectl", "delete", "pcrb", "demo4")
+			_, _ = Run(cmd)
+			cmd = exec.Command("kubectl", "delete", "ns", testNamespace, labelledNamespace)
+			_, _ = Run(cmd)
+
+		})
+	})
+
 })
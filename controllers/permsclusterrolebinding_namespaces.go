@@ -0,0 +1,350 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	permsv1 "github.com/infra-mgmt-io/perms/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=get;list;watch;create;update;patch;delete;bind
+
+// bindsNamespaces returns true if the ClusterRole is bound per namespace instead of cluster-wide
+func bindsNamespaces(p *permsv1.PermsClusterRoleBinding) bool {
+	return p.Spec.NamespaceSelector != nil || len(p.Spec.Namespaces) > 0
+}
+
+// reconcileNamespaces binds the ClusterRole by a RoleBinding in every selected namespace,
+// removes the RoleBindings of namespaces which are not selected anymore and the ClusterRoleBinding
+func (r *PermsClusterRoleBindingReconciler) reconcileNamespaces(ctx context.Context, p *permsv1.PermsClusterRoleBinding, req ctrl.Request, now time.Time, missingRole string) (ctrl.Result, error) {
+	namespaces, err := r.namespacesForPerms(ctx, p)
+	if err != nil {
+		logger.Error(err, "Failed to select namespaces", "PermsClusterRoleBinding.Name", p.Name)
+		recordError(r.Recorder, p, nil, "Selecting the namespaces", err)
+		setErrorStatus(ctx, &p.Status.Conditions, err)
+		if updateErr := r.updateStatus(ctx, p); updateErr != nil {
+			logger.Error(updateErr, "Update rolebinding status failed")
+		}
+		return ctrl.Result{}, err
+	}
+
+	drifted := false
+	bound := p.Status.Bindings
+	p.Status.Bindings = nil
+	for _, namespace := range namespaces {
+		rb := r.rolebindingForPermsNamespace(p, namespace, p.Name, now)
+		current := &rbacv1.RoleBinding{}
+		if err := r.Get(ctx, types.NamespacedName{Name: rb.Name, Namespace: rb.Namespace}, current); err != nil {
+			if !errors.IsNotFound(err) {
+				return ctrl.Result{}, err
+			}
+			if isBound(bound, "RoleBinding", rb.Namespace, rb.Name) {
+				logger.Info("Restoring rolebinding deleted outside of the operator", "Rolebinding.Namespace", rb.Namespace, "Rolebinding.Name", rb.Name)
+				recordEvent(r.Recorder, p, nil, corev1.EventTypeWarning, reasonDriftReverted, "Restoring RoleBinding %s/%s deleted outside of the operator", rb.Namespace, rb.Name)
+				drifted = true
+			}
+			logger.Info("Creating a new Rolebinding", "Rolebinding.Namespace", rb.Namespace, "Rolebinding.Name", rb.Name)
+			setProgressingStatus(ctx, &p.Status.Conditions)
+			if err := r.Create(ctx, rb); err != nil {
+				logger.Error(err, "Failed to create RoleBinding", "Rolebinding.Namespace", rb.Namespace, "Rolebinding.Name", rb.Name)
+				recordError(r.Recorder, p, nil, "Creating RoleBinding "+rb.Namespace+"/"+rb.Name, err)
+				setErrorStatus(ctx, &p.Status.Conditions, err)
+				if updateErr := r.updateStatus(ctx, p); updateErr != nil {
+					logger.Error(updateErr, "Update rolebinding status failed")
+				}
+				return ctrl.Result{}, err
+			}
+			recordEvent(r.Recorder, p, rb, corev1.EventTypeNormal, reasonCreated,
+				"Created RoleBinding %s/%s for ClusterRole %s with %s", rb.Namespace, rb.Name, rb.RoleRef.Name, strings.Join(subjectNames(rb.Subjects), ", "))
+			p.Status.LastChange = mergeLastChange(p.Status.LastChange, subjectNames(rb.Subjects), nil, now)
+		} else if err := r.adoptNamespaceRoleBinding(ctx, p, current); err != nil {
+			// Refuse to touch a rolebinding which is not managed by this PermsClusterRoleBinding
+			logger.Error(err, "RoleBinding is not managed by the PermsClusterRoleBinding", "Rolebinding.Namespace", rb.Namespace, "Rolebinding.Name", rb.Name)
+			recordError(r.Recorder, p, nil, "Adopting RoleBinding "+rb.Namespace+"/"+rb.Name, err)
+			result, err := ownershipResult(ctx, &p.Status.Conditions, err)
+			if updateErr := r.updateStatus(ctx, p); updateErr != nil {
+				logger.Error(updateErr, "Update rolebinding status failed")
+			}
+			return result, err
+		} else if current.RoleRef.Name != rb.RoleRef.Name {
+			// Check, if updates on immutable parts of rolebinding are configured
+			if p.Spec.ImmutableRoleRef {
+				logger.Error(nil, "Update immutable configuration (spec.Role)", "Rolebinding.Namespace", rb.Namespace, "Rolebinding.Name", rb.Name)
+				recordEvent(r.Recorder, p, nil, corev1.EventTypeWarning, reasonImmutableRoleRef,
+					"Role of RoleBinding %s/%s is not switched from %s to %s, spec.immutableRoleRef is set", rb.Namespace, rb.Name, current.RoleRef.Name, rb.RoleRef.Name)
+				immutableViolations.WithLabelValues("PermsClusterRoleBinding").Inc()
+				setHoustonWeHaveAProblemStatus(ctx, &p.Status.Conditions, reasonImmutableRoleRef, immutableRoleRefMessage(current.RoleRef, rb.RoleRef))
+				setRoleSwitchDeniedStatus(ctx, &p.Status.Conditions, current.RoleRef, rb.RoleRef)
+				if updateErr := r.updateStatus(ctx, p); updateErr != nil {
+					logger.Error(updateErr, "Update rolebinding status failed")
+				}
+				return ctrl.Result{Requeue: false}, nil
+			}
+			logger.Info("Switching rolebinding role", "Rolebinding.Namespace", rb.Namespace, "Rolebinding.Name", rb.Name, "From", current.RoleRef.Name, "To", rb.RoleRef.Name)
+			setProgressingStatus(ctx, &p.Status.Conditions)
+			if err := r.switchNamespaceRoleBinding(ctx, p, current, now); err != nil {
+				logger.Error(err, "Failed to switch RoleBinding role", "Rolebinding.Namespace", rb.Namespace, "Rolebinding.Name", rb.Name)
+				recordError(r.Recorder, p, nil, "Switching the role of RoleBinding "+rb.Namespace+"/"+rb.Name, err)
+				setErrorStatus(ctx, &p.Status.Conditions, err)
+				if updateErr := r.updateStatus(ctx, p); updateErr != nil {
+					logger.Error(updateErr, "Update rolebinding status failed")
+				}
+				return ctrl.Result{}, err
+			}
+			recordEvent(r.Recorder, p, nil, corev1.EventTypeNormal, reasonRoleSwitched,
+				"Switched RoleBinding %s/%s from ClusterRole %s to %s", rb.Namespace, rb.Name, current.RoleRef.Name, rb.RoleRef.Name)
+			setRoleSwitchedStatus(ctx, &p.Status.Conditions, current.RoleRef, rb.RoleRef)
+		} else {
+			// Update rolebinding, changes outside of the operator are reverted
+			subjects := current.Subjects
+			update, bindingDrifted := syncBinding(current, rb)
+			if bindingDrifted {
+				logger.Info("Restoring rolebinding changed outside of the operator", "Rolebinding.Namespace", rb.Namespace, "Rolebinding.Name", rb.Name)
+				drifted = true
+			}
+			if update {
+				logger.Info("Updating rolebinding", "Rolebinding.Namespace", rb.Namespace, "Rolebinding.Name", rb.Name)
+				setProgressingStatus(ctx, &p.Status.Conditions)
+				if err := r.Update(ctx, current); err != nil {
+					logger.Error(err, "Failed to update RoleBinding", "Rolebinding.Namespace", rb.Namespace, "Rolebinding.Name", rb.Name)
+					recordError(r.Recorder, p, current, "Updating RoleBinding "+rb.Namespace+"/"+rb.Name, err)
+					setErrorStatus(ctx, &p.Status.Conditions, err)
+					if updateErr := r.updateStatus(ctx, p); updateErr != nil {
+						logger.Error(updateErr, "Update rolebinding status failed")
+					}
+					return ctrl.Result{}, err
+				}
+				if bindingDrifted {
+					recordEvent(r.Recorder, p, current, corev1.EventTypeWarning, reasonDriftReverted, "Reverted changes of RoleBinding %s/%s made outside of the operator", rb.Namespace, rb.Name)
+				}
+				added, removed := recordSubjectChanges(r.Recorder, p, current, subjects, current.Subjects)
+				p.Status.LastChange = mergeLastChange(p.Status.LastChange, added, removed, now)
+			}
+		}
+		p.Status.Bindings = append(p.Status.Bindings, permsv1.BindingReference{
+			Kind:      "RoleBinding",
+			Namespace: rb.Namespace,
+			Name:      rb.Name,
+			RoleRef:   permsv1.RoleReference{Kind: rb.RoleRef.Kind, Name: rb.RoleRef.Name},
+		})
+	}
+
+	// Remove the RoleBindings of namespaces which are not selected anymore
+	if err := r.pruneNamespaceRoleBindings(ctx, p, namespaces); err != nil {
+		logger.Error(err, "Failed to remove RoleBindings of unselected namespaces", "PermsClusterRoleBinding.Name", p.Name)
+		recordError(r.Recorder, p, nil, "Removing the RoleBindings of unselected namespaces", err)
+		return ctrl.Result{}, err
+	}
+
+	// Remove the ClusterRoleBinding, the ClusterRole is bound per namespace
+	clusterBinding := &rbacv1.ClusterRoleBinding{}
+	if err := r.Get(ctx, types.NamespacedName{Name: p.Name}, clusterBinding); err == nil && metav1.IsControlledBy(clusterBinding, p) {
+		logger.Info("Deleting ClusterRolebinding", "ClusterRolebinding.Name", clusterBinding.Name)
+		if err := r.Delete(ctx, clusterBinding); err != nil && !errors.IsNotFound(err) {
+			recordError(r.Recorder, p, nil, "Deleting ClusterRoleBinding "+clusterBinding.Name, err)
+			return ctrl.Result{}, err
+		}
+		recordEvent(r.Recorder, p, nil, corev1.EventTypeNormal, reasonDeleted,
+			"Deleted ClusterRoleBinding %s, the ClusterRole is bound per namespace", clusterBinding.Name)
+	}
+	if err := r.removeClusterRoleSwitchBinding(ctx, p); err != nil {
+		logger.Error(err, "Failed to remove role switch ClusterRolebinding", "ClusterRolebinding.Name", roleSwitchBindingName(p.Name))
+		recordError(r.Recorder, p, nil, "Removing ClusterRoleBinding "+roleSwitchBindingName(p.Name), err)
+		return ctrl.Result{}, err
+	}
+
+	// update the Resource Status
+	if drifted {
+		p.Status.DriftCount++
+		driftRepairs.WithLabelValues("PermsClusterRoleBinding").Inc()
+		p.Status.LastDriftTime = &metav1.Time{Time: now}
+	}
+	r.updateCountsPermsClusterRoleBinding(ctx, p, req)
+	p.Status.Expirations, p.Status.NextTransitionTime = expirationsForSubjects(p.Spec.Validity,
+		p.Spec.Groups, p.Spec.Users, p.Spec.Serviceaccounts, "", now)
+	if missingRole != "" {
+		setHoustonWeHaveAProblemStatus(ctx, &p.Status.Conditions, reasonRoleNotFound, missingRole)
+	} else {
+		setEverythingIsFineStatus(ctx, &p.Status.Conditions)
+	}
+	setNoConflictStatus(ctx, &p.Status.Conditions)
+	setGrantActiveStatus(ctx, &p.Status.Conditions, validityReason(p.Spec.Validity, now))
+	if updateErr := r.updateStatus(ctx, p); updateErr != nil {
+		logger.Error(updateErr, "Update rolebinding status failed")
+	}
+	observeApplied("PermsClusterRoleBinding", p, time.Now())
+	return withResync(requeueAtTransition(p.Status.NextTransitionTime, now), r.ResyncInterval), nil
+}
+
+// namespacesForPerms returns the sorted names of the namespaces selected by namespaceSelector
+// or listed in namespaces, terminating namespaces are skipped
+func (r *PermsClusterRoleBindingReconciler) namespacesForPerms(ctx context.Context, p *permsv1.PermsClusterRoleBinding) ([]string, error) {
+	selector := labels.Nothing()
+	if p.Spec.NamespaceSelector != nil {
+		var err error
+		if selector, err = metav1.LabelSelectorAsSelector(p.Spec.NamespaceSelector); err != nil {
+			return nil, err
+		}
+	}
+	listed := map[string]bool{}
+	for _, namespace := range p.Spec.Namespaces {
+		listed[namespace] = true
+	}
+
+	namespaceList := &corev1.NamespaceList{}
+	if err := r.List(ctx, namespaceList); err != nil {
+		return nil, err
+	}
+	var namespaces []string
+	for _, namespace := range namespaceList.Items {
+		if namespace.Status.Phase == corev1.NamespaceTerminating {
+			continue
+		}
+		if listed[namespace.Name] || selector.Matches(labels.Set(namespace.Labels)) {
+			namespaces = append(namespaces, namespace.Name)
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+// rolebindingForPermsNamespace returns a Rolebinding object which binds the ClusterRole in a namespace
+func (r *PermsClusterRoleBindingReconciler) rolebindingForPermsNamespace(p *permsv1.PermsClusterRoleBinding, namespace string, name string, now time.Time) *rbacv1.RoleBinding {
+	rb := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labelsForPermsClusterRoleBindings(p.Name),
+			Annotations: map[string]string{
+				"infra-mgmt.io/perms": "operator-created",
+			},
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: "rbac.authorization.k8s.io",
+			Kind:     "ClusterRole",
+			Name:     p.Spec.Role,
+		},
+		Subjects: lockedSubjects(p.Status.Lockdown, subsForPermsClusterRoleBindings(p, now)),
+	}
+	setAppliedHash(rb, rb.RoleRef, rb.Subjects)
+
+	// Set PermsClusterRoleBinding instance as the owner and controller
+	if err := ctrl.SetControllerReference(p, rb, r.Scheme); err != nil {
+		logger.Error(err, "Failed to set as owner")
+	}
+	return rb
+}
+
+// adoptNamespaceRoleBinding checks that a rolebinding is managed by the PermsClusterRoleBinding
+// and adopts it with the adoption policy Adopt
+func (r *PermsClusterRoleBindingReconciler) adoptNamespaceRoleBinding(ctx context.Context, p *permsv1.PermsClusterRoleBinding, rb *rbacv1.RoleBinding) error {
+	adopted, err := adoptBinding(rb, "RoleBinding", p, p.Spec.AdoptionPolicy, labelsForPermsClusterRoleBindings(p.Name), r.Scheme)
+	if err != nil || !adopted {
+		return err
+	}
+	logger.Info("Adopting rolebinding", "Rolebinding.Namespace", rb.Namespace, "Rolebinding.Name", rb.Name)
+	if err := r.Update(ctx, rb); err != nil {
+		return err
+	}
+	recordEvent(r.Recorder, p, rb, corev1.EventTypeNormal, reasonAdopted, "Adopted RoleBinding %s/%s", rb.Namespace, rb.Name)
+	return nil
+}
+
+// switchNamespaceRoleBinding replaces a rolebinding with a rolebinding for the new role.
+// A temporary rolebinding grants the new role until the replacement is created.
+func (r *PermsClusterRoleBindingReconciler) switchNamespaceRoleBinding(ctx context.Context, p *permsv1.PermsClusterRoleBinding, current *rbacv1.RoleBinding, now time.Time) error {
+	switchBinding := r.rolebindingForPermsNamespace(p, current.Namespace, roleSwitchBindingName(p.Name), now)
+	leftover := &rbacv1.RoleBinding{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(switchBinding), leftover); err == nil {
+		if !metav1.IsControlledBy(leftover, p) {
+			return &bindingConflict{kind: "RoleBinding", key: client.ObjectKeyFromObject(leftover)}
+		}
+		if err := r.Delete(ctx, leftover); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	} else if !errors.IsNotFound(err) {
+		return err
+	}
+	if err := r.Create(ctx, switchBinding); err != nil {
+		return err
+	}
+	if err := r.Delete(ctx, current); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if err := r.Create(ctx, r.rolebindingForPermsNamespace(p, current.Namespace, p.Name, now)); err != nil {
+		return err
+	}
+	return client.IgnoreNotFound(r.Delete(ctx, switchBinding))
+}
+
+// pruneNamespaceRoleBindings deletes the owned rolebindings outside of the selected namespaces
+// and leftover rolebindings of an interrupted role switch
+func (r *PermsClusterRoleBindingReconciler) pruneNamespaceRoleBindings(ctx context.Context, p *permsv1.PermsClusterRoleBinding, namespaces []string) error {
+	selected := map[string]bool{}
+	for _, namespace := range namespaces {
+		selected[namespace] = true
+	}
+	owned := &rbacv1.RoleBindingList{}
+	if err := r.List(ctx, owned, client.MatchingLabels(labelsForPermsClusterRoleBindings(p.Name))); err != nil {
+		return err
+	}
+	for i := range owned.Items {
+		rb := &owned.Items[i]
+		if !metav1.IsControlledBy(rb, p) || (selected[rb.Namespace] && rb.Name == p.Name) {
+			continue
+		}
+		logger.Info("Deleting rolebinding", "Rolebinding.Namespace", rb.Namespace, "Rolebinding.Name", rb.Name)
+		if err := r.Delete(ctx, rb); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		recordEvent(r.Recorder, p, nil, corev1.EventTypeNormal, reasonDeleted, "Deleted RoleBinding %s/%s", rb.Namespace, rb.Name)
+	}
+	return nil
+}
+
+// permsClusterRoleBindingsForNamespace maps a namespace to the PermsClusterRoleBindings which
+// bind per namespace, a new or relabelled namespace may be selected by them
+func (r *PermsClusterRoleBindingReconciler) permsClusterRoleBindingsForNamespace(obj client.Object) []reconcile.Request {
+	ctx := context.Background()
+	list := &permsv1.PermsClusterRoleBindingList{}
+	if err := r.List(ctx, list); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list PermsClusterRoleBindings", "Namespace", obj.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range list.Items {
+		if bindsNamespaces(&list.Items[i]) {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: list.Items[i].Name}})
+		}
+	}
+	return requests
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	permsv1 "github.com/infra-mgmt-io/perms/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// PermsConstraintReconciler reports the users and groups which hold mutually exclusive roles
+type PermsConstraintReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=perms.infra-mgmt.io,resources=permsconstraints,verbs=get;list;watch
+//+kubebuilder:rbac:groups=perms.infra-mgmt.io,resources=permsconstraints/status,verbs=get;update;patch
+
+// Reconcile evaluates the PermsConstraint over the subjects which currently have the roles
+// of all PermsRoleBindings and PermsClusterRoleBindings assigned
+func (r *PermsConstraintReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	now := time.Now()
+
+	constraint := &permsv1.PermsConstraint{}
+	if err := r.Get(ctx, req.NamespacedName, constraint); err != nil {
+		if errors.IsNotFound(err) {
+			logger.Info("Resource PermsConstraint not found.")
+			return ctrl.Result{}, nil
+		}
+		logger.Error(err, "Failed to get PermsConstraint")
+		return ctrl.Result{}, err
+	}
+
+	assignments, err := r.roleAssignments(ctx, now)
+	if err != nil {
+		logger.Error(err, "Failed to list the role assignments", "PermsConstraint.Name", constraint.Name)
+		return ctrl.Result{}, err
+	}
+	violations := constraint.Violations(assignments)
+
+	reported := map[string]bool{}
+	for _, violation := range constraint.Status.Violations {
+		reported[violation.String()] = true
+	}
+	for _, violation := range violations {
+		if !reported[violation.String()] {
+			recordEvent(r.Recorder, constraint, nil, corev1.EventTypeWarning, reasonConstraintViolation, "%s", violation.String())
+		}
+	}
+	constraint.Status.Violations = violations
+	setSatisfiedStatus(ctx, &constraint.Status.Conditions, violations)
+
+	constraint.Status.ObservedGeneration = constraint.Generation
+	setObservedGeneration(constraint.Status.Conditions, constraint.Generation)
+	if err := r.Status().Update(ctx, constraint); err != nil {
+		logger.Error(err, "Update constraint status failed")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// roleAssignments returns the roles the users and groups currently hold through all
+// PermsRoleBindings and PermsClusterRoleBindings
+func (r *PermsConstraintReconciler) roleAssignments(ctx context.Context, now time.Time) ([]permsv1.RoleAssignment, error) {
+	var assignments []permsv1.RoleAssignment
+	prbs := &permsv1.PermsRoleBindingList{}
+	if err := r.List(ctx, prbs); err != nil {
+		return nil, err
+	}
+	for i := range prbs.Items {
+		users, groups := userAndGroupNames(subsForPermsRoleBindings(&prbs.Items[i], now))
+		assignments = append(assignments, prbs.Items[i].RoleAssignments(users, groups)...)
+	}
+	pcrbs := &permsv1.PermsClusterRoleBindingList{}
+	if err := r.List(ctx, pcrbs); err != nil {
+		return nil, err
+	}
+	for i := range pcrbs.Items {
+		users, groups := userAndGroupNames(subsForPermsClusterRoleBindings(&pcrbs.Items[i], now))
+		assignments = append(assignments, pcrbs.Items[i].RoleAssignments(users, groups)...)
+	}
+	return assignments, nil
+}
+
+// userAndGroupNames returns the names of the users and groups of the subjects
+func userAndGroupNames(subjects []rbacv1.Subject) (users []string, groups []string) {
+	for _, subject := range subjects {
+		switch subject.Kind {
+		case "User":
+			users = append(users, subject.Name)
+		case "Group":
+			groups = append(groups, subject.Name)
+		}
+	}
+	return users, groups
+}
+
+// helper to set the "Satisfied" status of a PermsConstraint
+func setSatisfiedStatus(ctx context.Context, conditions *[]metav1.Condition, violations []permsv1.ConstraintViolation) {
+	condition := metav1.Condition{
+		Type:    "Satisfied",
+		Status:  metav1.ConditionTrue,
+		Reason:  "Satisfied",
+		Message: "No user or group holds more than one role of a set",
+	}
+	if len(violations) > 0 {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = reasonConstraintViolation
+		condition.Message = fmt.Sprintf("%d users and groups hold more than one role of a set", len(violations))
+	}
+	meta.SetStatusCondition(conditions, condition)
+}
+
+// permsConstraintsForBinding maps a PermsRoleBinding or PermsClusterRoleBinding to all
+// PermsConstraints, a changed subject or role may violate any of them
+func (r *PermsConstraintReconciler) permsConstraintsForBinding(obj client.Object) []reconcile.Request {
+	ctx := context.Background()
+	list := &permsv1.PermsConstraintList{}
+	if err := r.List(ctx, list); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list PermsConstraints", "Name", obj.GetName(), "Namespace", obj.GetNamespace())
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range list.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: list.Items[i].Name}})
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PermsConstraintReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&permsv1.PermsConstraint{}).
+		Watches(&source.Kind{Type: &permsv1.PermsRoleBinding{}}, handler.EnqueueRequestsFromMapFunc(r.permsConstraintsForBinding)).
+		Watches(&source.Kind{Type: &permsv1.PermsClusterRoleBinding{}}, handler.EnqueueRequestsFromMapFunc(r.permsConstraintsForBinding)).
+		Complete(r)
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	permsv1 "github.com/infra-mgmt-io/perms/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// PermsLockdownReconciler reports the progress of a PermsLockdown, the subjects are removed and
+// restored by the reconcilers of the PermsRoleBindings, PermsClusterRoleBindings and PermsBreakGlasses
+type PermsLockdownReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=perms.infra-mgmt.io,resources=permslockdowns/status,verbs=get;update;patch
+
+// Reconcile counts the resources whose subjects are removed and sets the phase of the PermsLockdown
+func (r *PermsLockdownReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	now := time.Now()
+
+	lockdown := &permsv1.PermsLockdown{}
+	if err := r.Get(ctx, req.NamespacedName, lockdown); err != nil {
+		if errors.IsNotFound(err) {
+			logger.Info("Resource PermsLockdown not found.")
+			return ctrl.Result{}, nil
+		}
+		logger.Error(err, "Failed to get PermsLockdown")
+		return ctrl.Result{}, err
+	}
+
+	err := r.reconcileProgress(ctx, lockdown, now)
+	if err != nil {
+		logger.Error(err, "Failed to count the locked resources", "PermsLockdown.Name", lockdown.Name)
+		recordError(r.Recorder, lockdown, nil, "Counting the locked resources", err)
+		setErrorStatus(ctx, &lockdown.Status.Conditions, err)
+	} else {
+		setEverythingIsFineStatus(ctx, &lockdown.Status.Conditions)
+		setLockdownProgressStatus(ctx, &lockdown.Status.Conditions, lockdown)
+	}
+
+	lockdown.Status.ObservedGeneration = lockdown.Generation
+	setObservedGeneration(lockdown.Status.Conditions, lockdown.Generation)
+	if updateErr := r.Status().Update(ctx, lockdown); updateErr != nil {
+		logger.Error(updateErr, "Update lockdown status failed")
+		if err == nil {
+			err = updateErr
+		}
+	}
+	return ctrl.Result{}, err
+}
+
+// reconcileProgress sets the phase from the number of PermsRoleBindings and PermsClusterRoleBindings
+// whose subjects are removed
+func (r *PermsLockdownReconciler) reconcileProgress(ctx context.Context, lockdown *permsv1.PermsLockdown, now time.Time) error {
+	prbs := &permsv1.PermsRoleBindingList{}
+	if err := r.List(ctx, prbs); err != nil {
+		return err
+	}
+	pcrbs := &permsv1.PermsClusterRoleBindingList{}
+	if err := r.List(ctx, pcrbs); err != nil {
+		return err
+	}
+	var locked int32
+	for i := range prbs.Items {
+		if prbs.Items[i].Status.Lockdown != nil {
+			locked++
+		}
+	}
+	for i := range pcrbs.Items {
+		if pcrbs.Items[i].Status.Lockdown != nil {
+			locked++
+		}
+	}
+	lockdown.Status.Resources = int32(len(prbs.Items) + len(pcrbs.Items))
+	lockdown.Status.LockedResources = locked
+
+	wasActive := lockdown.Status.Phase == permsv1.LockdownLocking || lockdown.Status.Phase == permsv1.LockdownLocked
+	if lockdown.Spec.Active {
+		if !wasActive {
+			lockedAt := metav1.NewTime(now)
+			lockdown.Status.LockedAt, lockdown.Status.LiftedAt = &lockedAt, nil
+			recordEvent(r.Recorder, lockdown, nil, corev1.EventTypeWarning, reasonLockedDown, "Lockdown activated, removing the subjects of %d resources: %s",
+				lockdown.Status.Resources, lockdown.Spec.Reason)
+		}
+		lockdown.Status.Phase = permsv1.LockdownLocking
+		if locked == lockdown.Status.Resources {
+			lockdown.Status.Phase = permsv1.LockdownLocked
+		}
+		return nil
+	}
+
+	if wasActive {
+		liftedAt := metav1.NewTime(now)
+		lockdown.Status.LiftedAt = &liftedAt
+		recordEvent(r.Recorder, lockdown, nil, corev1.EventTypeNormal, reasonLockdownLifted, "Lockdown lifted, restoring the subjects of %d resources", locked)
+	}
+	switch {
+	case lockdown.Status.LockedAt == nil:
+		lockdown.Status.Phase = permsv1.LockdownInactive
+	case locked > 0:
+		// resources stay locked while another lockdown is active
+		lockdown.Status.Phase = permsv1.LockdownRestoring
+	default:
+		lockdown.Status.Phase = permsv1.LockdownRestored
+	}
+	return nil
+}
+
+// helper to set the "LockedDown" and "Progressing" status of a PermsLockdown
+func setLockdownProgressStatus(ctx context.Context, conditions *[]metav1.Condition, lockdown *permsv1.PermsLockdown) {
+	lockedDown := metav1.Condition{
+		Type:    "LockedDown",
+		Status:  metav1.ConditionFalse,
+		Reason:  lockdown.Status.Phase,
+		Message: "The subjects of the managed bindings are assigned",
+	}
+	if lockdown.Spec.Active {
+		lockedDown.Status = metav1.ConditionTrue
+		lockedDown.Message = "The subjects of the managed bindings are removed"
+	}
+	meta.SetStatusCondition(conditions, lockedDown)
+	if lockdown.Status.Phase == permsv1.LockdownLocking || lockdown.Status.Phase == permsv1.LockdownRestoring {
+		meta.SetStatusCondition(conditions, metav1.Condition{
+			Type:    "Progressing",
+			Status:  metav1.ConditionTrue,
+			Reason:  lockdown.Status.Phase,
+			Message: fmt.Sprintf("%d of %d resources are locked", lockdown.Status.LockedResources, lockdown.Status.Resources),
+		})
+	}
+}
+
+// permsLockdownsForBinding maps a PermsRoleBinding or PermsClusterRoleBinding to all PermsLockdowns,
+// their progress changes with the status of the resource
+func (r *PermsLockdownReconciler) permsLockdownsForBinding(obj client.Object) []reconcile.Request {
+	ctx := context.Background()
+	list := &permsv1.PermsLockdownList{}
+	if err := r.List(ctx, list); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list PermsLockdowns", "Resource", obj.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range list.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: list.Items[i].Name}})
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PermsLockdownReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&permsv1.PermsLockdown{}).
+		Watches(&source.Kind{Type: &permsv1.PermsRoleBinding{}}, handler.EnqueueRequestsFromMapFunc(r.permsLockdownsForBinding)).
+		Watches(&source.Kind{Type: &permsv1.PermsClusterRoleBinding{}}, handler.EnqueueRequestsFromMapFunc(r.permsLockdownsForBinding)).
+		Complete(r)
+}
@@ -18,27 +18,40 @@ package controllers
 
 import (
 	"context"
-	"reflect"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
-	permsv1beta1 "github.com/infra-mgmt-io/perms/api/v1beta1"
+	permsv1 "github.com/infra-mgmt-io/perms/api/v1"
+	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 // PermsRoleBindingReconciler reconciles a Perms object
 type PermsRoleBindingReconciler struct {
 	client.Client
-	Log    logr.Logger
-	Scheme *runtime.Scheme
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// ResyncInterval is the interval in which the managed rolebindings are checked for changes
+	// outside of the operator, disabled with 0
+	ResyncInterval time.Duration
+	// ChangeLimiter holds the changes of the subjects when too many subjects are added or removed
+	// across all resources, disabled with nil
+	ChangeLimiter *ChangeLimiter
+	// WebhooksEnabled is set with --enable-webhooks, approvals of sensitive changes are only
+	// honoured if the webhooks record the authors and the approver
+	WebhooksEnabled bool
 }
 
 var logger logr.Logger
@@ -47,80 +60,332 @@ var logger logr.Logger
 //+kubebuilder:rbac:groups=perms.infra-mgmt.io,resources=permsrolebindings/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=perms.infra-mgmt.io,resources=permsrolebindings/finalizers,verbs=update
 //+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=get;list;watch;create;update;patch;delete;bind
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles,verbs=get;list;watch
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles,verbs=get;list;watch
 
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.11.2/pkg/reconcile
 func (r *PermsRoleBindingReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger = log.FromContext(ctx)
+	// the status is computed at the reconcile start, a grant which ends while the
+	// bindings are updated is requeued right away
+	now := time.Now()
 
 	// Verify if a CRD of Permissions exists
-	permsrolebinding := &permsv1beta1.PermsRoleBinding{}
+	permsrolebinding := &permsv1.PermsRoleBinding{}
 	err := r.Get(ctx, req.NamespacedName, permsrolebinding)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			logger.Info("Resource PermsRoleBinding not found.")
+			forgetApplied("PermsRoleBinding", req.NamespacedName)
 			return ctrl.Result{}, nil
 		}
 		logger.Error(err, "Failed to get PermsRoleBinding")
 		return ctrl.Result{}, err
 	}
+	// Count the subjects of a deleted PermsRoleBinding as removed, its bindings are garbage
+	// collected once the finalizer is removed
+	if !permsrolebinding.DeletionTimestamp.IsZero() {
+		held, err := limitDeletion(ctx, r.Client, r.Recorder, r.ChangeLimiter, "PermsRoleBinding", permsrolebinding,
+			labelsForPermsRoleBindings(permsrolebinding.Name), &permsrolebinding.Status.Conditions, now)
+		if err != nil {
+			logger.Error(err, "Failed to check the removal of the subjects of the deleted PermsRoleBinding", "PermsRoleBinding.Namespace", permsrolebinding.Namespace, "PermsRoleBinding.Name", permsrolebinding.Name)
+			return ctrl.Result{}, err
+		}
+		if held {
+			logger.Info("Removal of the subjects is held, waiting for the release", "PermsRoleBinding.Namespace", permsrolebinding.Namespace, "PermsRoleBinding.Name", permsrolebinding.Name)
+			if updateErr := r.updateStatus(ctx, permsrolebinding); updateErr != nil {
+				logger.Error(updateErr, "Update rolebinding status failed")
+			}
+			return ctrl.Result{RequeueAfter: changesHeldRequeueInterval}, nil
+		}
+		return ctrl.Result{}, nil
+	}
+	if err = addChangeLimitFinalizer(ctx, r.Client, r.ChangeLimiter, permsrolebinding); err != nil {
+		logger.Error(err, "Failed to add the finalizer", "PermsRoleBinding.Namespace", permsrolebinding.Namespace, "PermsRoleBinding.Name", permsrolebinding.Name)
+		return ctrl.Result{}, err
+	}
+	// with --impersonate-authors the bindings are written as the author of the resource
+	ctx = withAuthor(ctx, permsrolebinding)
+
+	// Apply the last approved spec while a change of a sensitive PermsRoleBinding waits for the
+	// approval of a second user
+	hold, err := r.reconcileApproval(ctx, permsrolebinding)
+	if err != nil {
+		logger.Error(err, "Failed to check the approval of the PermsRoleBinding")
+		return ctrl.Result{}, err
+	}
+
+	// Remove the subjects of the rolebindings while a PermsLockdown is active, a PermsRoleBinding
+	// which waits for its first approval has none
+	locked := permsrolebinding.Status.Lockdown != nil
+	var subjects []rbacv1.Subject
+	if !hold {
+		subjects = subsForPermsRoleBindings(permsrolebinding, now)
+	}
+	if err = reconcileLockdown(ctx, r.Client, r.Recorder, permsrolebinding, &permsrolebinding.Status.Lockdown,
+		subjects, &permsrolebinding.Status.Conditions, now); err != nil {
+		logger.Error(err, "Failed to check the PermsLockdowns")
+		return ctrl.Result{}, err
+	}
+	if hold {
+		logger.Info("PermsRoleBinding waits for the approval", "PermsRoleBinding.Namespace", permsrolebinding.Namespace, "PermsRoleBinding.Name", permsrolebinding.Name)
+		if updateErr := r.updateStatus(ctx, permsrolebinding); updateErr != nil {
+			logger.Error(updateErr, "Update rolebinding status failed")
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// Revoke the rolebindings of a PermsRoleBinding which violates a PermsPolicy or is not allowed by
+	// the PermsDelegations of its namespace, it may have been created before the policy or delegation
+	violations, err := policyViolations(ctx, r.Client, permsrolebinding.PolicyRequest())
+	if err != nil {
+		logger.Error(err, "Failed to check the PermsPolicies")
+		return ctrl.Result{}, err
+	}
+	notDelegated, err := delegationViolations(ctx, r.Client, permsrolebinding)
+	if err != nil {
+		logger.Error(err, "Failed to check the PermsDelegations")
+		return ctrl.Result{}, err
+	}
+	violations = append(violations, notDelegated...)
+	permsrolebinding.Status.PolicyViolations = violations
+	if setPolicyViolationStatus(ctx, &permsrolebinding.Status.Conditions, violations) && len(violations) > 0 {
+		recordEvent(r.Recorder, permsrolebinding, nil, corev1.EventTypeWarning, reasonPolicyViolation, "%s", strings.Join(violations, "; "))
+	}
+	if len(violations) > 0 {
+		logger.Info("PermsRoleBinding violates a PermsPolicy", "PermsRoleBinding.Namespace", permsrolebinding.Namespace, "PermsRoleBinding.Name", permsrolebinding.Name, "Violations", violations)
+		if err = revokeBindings(ctx, r.Client, r.Recorder, permsrolebinding, permsrolebinding.Status.Bindings); err != nil {
+			logger.Error(err, "Failed to delete RoleBindings which violate a PermsPolicy", "Rolebinding.Namespace", permsrolebinding.Namespace, "Rolebinding.Name", permsrolebinding.Name)
+			recordError(r.Recorder, permsrolebinding, nil, "Deleting the RoleBindings which violate a PermsPolicy", err)
+			setErrorStatus(ctx, &permsrolebinding.Status.Conditions, err)
+			if updateErr := r.updateStatus(ctx, permsrolebinding); updateErr != nil {
+				logger.Error(updateErr, "Update rolebinding status failed")
+			}
+			return ctrl.Result{}, err
+		}
+		permsrolebinding.Status.Bindings = nil
+		setHoustonWeHaveAProblemStatus(ctx, &permsrolebinding.Status.Conditions, reasonPolicyViolation, strings.Join(violations, "; "))
+		if updateErr := r.updateStatus(ctx, permsrolebinding); updateErr != nil {
+			logger.Error(updateErr, "Update rolebinding status failed")
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// Warn about referenced roles which do not exist, the rolebindings are created anyway
+	missingRoles, roleRules, err := r.checkRoles(ctx, permsrolebinding, now)
+	if err != nil {
+		logger.Error(err, "Failed to get the referenced roles", "PermsRoleBinding.Namespace", permsrolebinding.Namespace, "PermsRoleBinding.Name", permsrolebinding.Name)
+		return ctrl.Result{}, err
+	}
+	setRoleResolvedStatus(ctx, &permsrolebinding.Status.Conditions, missingRoles)
+
+	// Hold the reconcile after a change of the rules of a pinned role until it is acknowledged, a
+	// lockdown removes the subjects anyway
+	if reconcileRoleRules(r.Recorder, permsrolebinding, permsrolebinding.Spec.PinRoleRules, &permsrolebinding.Status.Conditions,
+		&permsrolebinding.Status.RoleRules, &permsrolebinding.Status.RoleRulesHash, roleRules) && permsrolebinding.Status.Lockdown == nil {
+		logger.Info("Rules of the referenced roles changed, waiting for the acknowledgment", "PermsRoleBinding.Namespace", permsrolebinding.Namespace, "PermsRoleBinding.Name", permsrolebinding.Name)
+		if updateErr := r.updateStatus(ctx, permsrolebinding); updateErr != nil {
+			logger.Error(updateErr, "Update rolebinding status failed")
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// Hold the change of the subjects when too many subjects were added to or removed from the
+	// bindings of all resources, the start and the end of a lockdown are not limited
+	if !locked && permsrolebinding.Status.Lockdown == nil {
+		held, err := limitChanges(ctx, r.Client, r.Recorder, r.ChangeLimiter, "PermsRoleBinding", permsrolebinding,
+			labelsForPermsRoleBindings(permsrolebinding.Name), r.desiredBindings(permsrolebinding, ctx, now), subjects, &permsrolebinding.Status.Conditions, now)
+		if err != nil {
+			logger.Error(err, "Failed to check the change of the subjects", "PermsRoleBinding.Namespace", permsrolebinding.Namespace, "PermsRoleBinding.Name", permsrolebinding.Name)
+			return ctrl.Result{}, err
+		}
+		if held {
+			logger.Info("Change of the subjects is held, waiting for the release", "PermsRoleBinding.Namespace", permsrolebinding.Namespace, "PermsRoleBinding.Name", permsrolebinding.Name)
+			if updateErr := r.updateStatus(ctx, permsrolebinding); updateErr != nil {
+				logger.Error(updateErr, "Update rolebinding status failed")
+			}
+			return ctrl.Result{RequeueAfter: changesHeldRequeueInterval}, nil
+		}
+	}
 
 	// Check if the binding already exists, if not create a new one
+	drifted := false
 	bindings := &rbacv1.RoleBinding{}
-	if bindingExistsErr := r.Get(ctx, types.NamespacedName{Name: permsrolebinding.Name, Namespace: permsrolebinding.Namespace}, bindings); bindingExistsErr != nil {
+	bindingExistsErr := r.Get(ctx, types.NamespacedName{Name: permsrolebinding.Name, Namespace: permsrolebinding.Namespace}, bindings)
+	if bindingExistsErr == nil {
+		// Refuse to touch a rolebinding which is not managed by this PermsRoleBinding
+		if err = r.adoptRoleBinding(ctx, permsrolebinding, bindings); err != nil {
+			logger.Error(err, "RoleBinding is not managed by the PermsRoleBinding", "Rolebinding.Namespace", permsrolebinding.Namespace, "Rolebinding.Name", permsrolebinding.Name)
+			recordError(r.Recorder, permsrolebinding, nil, "Adopting RoleBinding "+bindings.Name, err)
+			result, err := ownershipResult(ctx, &permsrolebinding.Status.Conditions, err)
+			if updateErr := r.updateStatus(ctx, permsrolebinding); updateErr != nil {
+				logger.Error(updateErr, "Update rolebinding status failed")
+			}
+			return result, err
+		}
+	}
+	if bindingExistsErr != nil {
+		if isBound(permsrolebinding.Status.Bindings, "RoleBinding", permsrolebinding.Namespace, permsrolebinding.Name) {
+			logger.Info("Restoring rolebinding deleted outside of the operator", "Rolebinding.Namespace", permsrolebinding.Namespace, "Rolebinding.Name", permsrolebinding.Name)
+			recordEvent(r.Recorder, permsrolebinding, nil, corev1.EventTypeWarning, reasonDriftReverted, "Restoring RoleBinding %s deleted outside of the operator", permsrolebinding.Name)
+			drifted = true
+		}
 		logger.Info("Creating a new Rolebinding", "Rolebinding.Namespace", permsrolebinding.Namespace, "Rolebinding.Name ", permsrolebinding.Name)
 		// Define a new RoleBinding
-		rb := r.rolebindingForPerms(permsrolebinding, ctx)
+		rb := r.rolebindingForPerms(permsrolebinding, ctx, now)
 		setProgressingStatus(ctx, &permsrolebinding.Status.Conditions)
 		if err = r.Create(ctx, rb); err != nil {
 			logger.Error(err, "Failed to create RoleBinding", "Rolebinding.Namespace", permsrolebinding.Namespace, "Rolebinding.Name", permsrolebinding.Name)
-			setHoustonWeHaveAProblemStatus(ctx, &permsrolebinding.Status.Conditions)
+			recordError(r.Recorder, permsrolebinding, nil, "Creating RoleBinding "+rb.Name, err)
+			setErrorStatus(ctx, &permsrolebinding.Status.Conditions, err)
+			if updateErr := r.updateStatus(ctx, permsrolebinding); updateErr != nil {
+				logger.Error(updateErr, "Update rolebinding status failed")
+			}
 			return ctrl.Result{RequeueAfter: time.Minute}, err
 		}
-	} else {
+		recordEvent(r.Recorder, permsrolebinding, rb, corev1.EventTypeNormal, reasonCreated,
+			"Created RoleBinding %s for %s %s with %s", rb.Name, rb.RoleRef.Kind, rb.RoleRef.Name, strings.Join(subjectNames(rb.Subjects), ", "))
+		permsrolebinding.Status.LastChange = mergeLastChange(permsrolebinding.Status.LastChange, subjectNames(rb.Subjects), nil, now)
+	} else if bindings.RoleRef.Kind != permsrolebinding.Spec.Kind || bindings.RoleRef.Name != permsrolebinding.Spec.Role {
 		// Check, if updates on immutable parts of rolebinding are configured
-		// if so - leave the reconcile loop
-		if bindings.RoleRef.Kind != permsrolebinding.Spec.Kind || bindings.RoleRef.Name != permsrolebinding.Spec.Role {
+		// if the role switch is disabled - leave the reconcile loop
+		rb := r.rolebindingForPerms(permsrolebinding, ctx, now)
+		if permsrolebinding.Spec.ImmutableRoleRef {
 			logger.Error(err, "Update immutable configuration (spec.kind || spec.Role)", "Rolebinding.Namespace", permsrolebinding.Namespace, "Rolebinding.Name", permsrolebinding.Name)
-			setHoustonWeHaveAProblemStatus(ctx, &permsrolebinding.Status.Conditions)
-			if updateErr := r.Status().Update(ctx, permsrolebinding); updateErr != nil {
+			recordEvent(r.Recorder, permsrolebinding, nil, corev1.EventTypeWarning, reasonImmutableRoleRef,
+				"Role of RoleBinding %s is not switched from %s %s to %s %s, spec.immutableRoleRef is set", bindings.Name, bindings.RoleRef.Kind, bindings.RoleRef.Name, rb.RoleRef.Kind, rb.RoleRef.Name)
+			immutableViolations.WithLabelValues("PermsRoleBinding").Inc()
+			setHoustonWeHaveAProblemStatus(ctx, &permsrolebinding.Status.Conditions, reasonImmutableRoleRef, immutableRoleRefMessage(bindings.RoleRef, rb.RoleRef))
+			setRoleSwitchDeniedStatus(ctx, &permsrolebinding.Status.Conditions, bindings.RoleRef, rb.RoleRef)
+			if updateErr := r.updateStatus(ctx, permsrolebinding); updateErr != nil {
 				logger.Error(updateErr, "Update rolebinding status failed")
 			}
 			return ctrl.Result{Requeue: false}, err
 		}
-		// Update rolebinding if possible
-		subs := subsForPermsRoleBindings(permsrolebinding)
-		if !reflect.DeepEqual(bindings.Subjects, subs) {
+		// Switch the rolebinding to the new role
+		logger.Info("Switching rolebinding role", "Rolebinding.Namespace", permsrolebinding.Namespace, "Rolebinding.Name", permsrolebinding.Name, "From", bindings.RoleRef.Name, "To", rb.RoleRef.Name)
+		setProgressingStatus(ctx, &permsrolebinding.Status.Conditions)
+		if err = r.switchRoleBinding(ctx, permsrolebinding, bindings, now); err != nil {
+			logger.Error(err, "Failed to switch RoleBinding role", "Rolebinding.Namespace", permsrolebinding.Namespace, "Rolebinding.Name", permsrolebinding.Name)
+			recordError(r.Recorder, permsrolebinding, nil, "Switching the role of RoleBinding "+bindings.Name, err)
+			result, err := ownershipResult(ctx, &permsrolebinding.Status.Conditions, err)
+			if updateErr := r.updateStatus(ctx, permsrolebinding); updateErr != nil {
+				logger.Error(updateErr, "Update rolebinding status failed")
+			}
+			return result, err
+		}
+		recordEvent(r.Recorder, permsrolebinding, nil, corev1.EventTypeNormal, reasonRoleSwitched,
+			"Switched RoleBinding %s from %s %s to %s %s", bindings.Name, bindings.RoleRef.Kind, bindings.RoleRef.Name, rb.RoleRef.Kind, rb.RoleRef.Name)
+		setRoleSwitchedStatus(ctx, &permsrolebinding.Status.Conditions, bindings.RoleRef, rb.RoleRef)
+	} else {
+		// Update rolebinding if possible, changes outside of the operator are reverted
+		subjects := bindings.Subjects
+		update, bindingDrifted := syncBinding(bindings, r.rolebindingForPerms(permsrolebinding, ctx, now))
+		if bindingDrifted {
+			logger.Info("Restoring rolebinding changed outside of the operator", "Rolebinding.Namespace", permsrolebinding.Namespace, "Rolebinding.Name", permsrolebinding.Name)
+			drifted = true
+		}
+		if update {
 			logger.Info("Updating rolebinding", "Rolebinding.Namespace", permsrolebinding.Namespace, "Rolebinding.Name", permsrolebinding.Name)
 			setProgressingStatus(ctx, &permsrolebinding.Status.Conditions)
-			bindings.Subjects = subs
 			if err := r.Update(ctx, bindings); err != nil {
 				logger.Error(err, "Failed to update RoleBinding", "Rolebinding.Namespace", permsrolebinding.Namespace, "Rolebinding.Name", permsrolebinding.Name)
-				setHoustonWeHaveAProblemStatus(ctx, &permsrolebinding.Status.Conditions)
+				recordError(r.Recorder, permsrolebinding, bindings, "Updating RoleBinding "+bindings.Name, err)
+				setErrorStatus(ctx, &permsrolebinding.Status.Conditions, err)
+				if updateErr := r.updateStatus(ctx, permsrolebinding); updateErr != nil {
+					logger.Error(updateErr, "Update rolebinding status failed")
+				}
 				return ctrl.Result{}, err
 			}
+			if bindingDrifted {
+				recordEvent(r.Recorder, permsrolebinding, bindings, corev1.EventTypeWarning, reasonDriftReverted, "Reverted changes of RoleBinding %s made outside of the operator", bindings.Name)
+			}
+			added, removed := recordSubjectChanges(r.Recorder, permsrolebinding, bindings, subjects, bindings.Subjects)
+			permsrolebinding.Status.LastChange = mergeLastChange(permsrolebinding.Status.LastChange, added, removed, now)
 		}
 	}
 
+	// Remove a leftover rolebinding of an interrupted role switch
+	if err = r.removeRoleSwitchBinding(ctx, permsrolebinding); err != nil {
+		logger.Error(err, "Failed to remove role switch RoleBinding", "Rolebinding.Namespace", permsrolebinding.Namespace, "Rolebinding.Name", roleSwitchBindingName(permsrolebinding.Name))
+		recordError(r.Recorder, permsrolebinding, nil, "Removing RoleBinding "+roleSwitchBindingName(permsrolebinding.Name), err)
+		result, err := ownershipResult(ctx, &permsrolebinding.Status.Conditions, err)
+		if updateErr := r.updateStatus(ctx, permsrolebinding); updateErr != nil {
+			logger.Error(updateErr, "Update rolebinding status failed")
+		}
+		return result, err
+	}
+
+	// Reconcile the rolebindings of the additional roles
+	rolesDrifted, err := r.reconcileRoleBindings(ctx, permsrolebinding, now)
+	if err != nil {
+		logger.Error(err, "Failed to reconcile RoleBindings of spec.roles", "Rolebinding.Namespace", permsrolebinding.Namespace, "Rolebinding.Name", permsrolebinding.Name)
+		recordError(r.Recorder, permsrolebinding, nil, "Reconciling the RoleBindings of spec.roles", err)
+		result, err := ownershipResult(ctx, &permsrolebinding.Status.Conditions, err)
+		if updateErr := r.updateStatus(ctx, permsrolebinding); updateErr != nil {
+			logger.Error(updateErr, "Update rolebinding status failed")
+		}
+		return result, err
+	}
+
 	// update the Resource Status
-	r.updateCountsPermsRoleBinding(ctx, permsrolebinding, req)
-	setEverythingIsFineStatus(ctx, &permsrolebinding.Status.Conditions)
-	if updateErr := r.Status().Update(ctx, permsrolebinding); updateErr != nil {
+	if drifted || rolesDrifted {
+		permsrolebinding.Status.DriftCount++
+		driftRepairs.WithLabelValues("PermsRoleBinding").Inc()
+		permsrolebinding.Status.LastDriftTime = &metav1.Time{Time: now}
+	}
+	r.updateCountsPermsRoleBinding(ctx, permsrolebinding, req, now)
+	permsrolebinding.Status.Expirations, permsrolebinding.Status.NextTransitionTime = expirationsForSubjects(permsrolebinding.Spec.Validity,
+		permsrolebinding.Spec.Groups, permsrolebinding.Spec.Users, permsrolebinding.Spec.Serviceaccounts, permsrolebinding.Namespace, now)
+	if missingRoles != "" {
+		setHoustonWeHaveAProblemStatus(ctx, &permsrolebinding.Status.Conditions, reasonRoleNotFound, missingRoles)
+	} else {
+		setEverythingIsFineStatus(ctx, &permsrolebinding.Status.Conditions)
+	}
+	setNoConflictStatus(ctx, &permsrolebinding.Status.Conditions)
+	setGrantActiveStatus(ctx, &permsrolebinding.Status.Conditions, validityReason(permsrolebinding.Spec.Validity, now))
+	if updateErr := r.updateStatus(ctx, permsrolebinding); updateErr != nil {
 		logger.Error(updateErr, "Update rolebinding status failed")
 	}
-	// return nil to stop reconcile loop
-	return ctrl.Result{}, nil
+	observeApplied("PermsRoleBinding", permsrolebinding, time.Now())
+	// requeue at the next start or end of a grant or to check the rolebindings for drift
+	return withResync(requeueAtTransition(permsrolebinding.Status.NextTransitionTime, now), r.ResyncInterval), nil
 }
 
-// rolebindingForPerms returns a Rolebinding object
-func (r *PermsRoleBindingReconciler) rolebindingForPerms(p *permsv1beta1.PermsRoleBinding, ctx context.Context) *rbacv1.RoleBinding {
+// rolebindingForPerms returns the Rolebinding object of the role in spec.role
+func (r *PermsRoleBindingReconciler) rolebindingForPerms(p *permsv1.PermsRoleBinding, ctx context.Context, now time.Time) *rbacv1.RoleBinding {
+	return r.rolebindingForPermsRole(p, p.Name, p.Spec.Kind, p.Spec.Role, now)
+}
+
+// rolebindingsForPerms returns the Rolebinding objects of all roles, the rolebinding of
+// the role in spec.role comes first
+func (r *PermsRoleBindingReconciler) rolebindingsForPerms(p *permsv1.PermsRoleBinding, ctx context.Context, now time.Time) []*rbacv1.RoleBinding {
+	rbs := []*rbacv1.RoleBinding{r.rolebindingForPerms(p, ctx, now)}
+	for _, role := range p.Spec.Roles {
+		rbs = append(rbs, r.rolebindingForPermsRole(p, rolebindingNameForRole(p.Name, role), role.Kind, role.Name, now))
+	}
+	return rbs
+}
+
+// desiredBindings returns the references of the rolebindings of all roles
+func (r *PermsRoleBindingReconciler) desiredBindings(p *permsv1.PermsRoleBinding, ctx context.Context, now time.Time) []permsv1.BindingReference {
+	var refs []permsv1.BindingReference
+	for _, rb := range r.rolebindingsForPerms(p, ctx, now) {
+		refs = append(refs, permsv1.BindingReference{Kind: "RoleBinding", Namespace: rb.Namespace, Name: rb.Name})
+	}
+	return refs
+}
+
+// rolebindingForPermsRole returns a Rolebinding object for a role
+func (r *PermsRoleBindingReconciler) rolebindingForPermsRole(p *permsv1.PermsRoleBinding, name string, kind string, role string, now time.Time) *rbacv1.RoleBinding {
 	// define labels
 	labels := labelsForPermsRoleBindings(p.Name)
-	subs := subsForPermsRoleBindings(p)
+	subs := lockedSubjects(p.Status.Lockdown, subsForPermsRoleBindings(p, now))
 
 	rb := &rbacv1.RoleBinding{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      p.Name,
+			Name:      name,
 			Namespace: p.Namespace,
 			Labels:    labels,
 			Annotations: map[string]string{
@@ -129,11 +394,12 @@ func (r *PermsRoleBindingReconciler) rolebindingForPerms(p *permsv1beta1.PermsRo
 		},
 		RoleRef: rbacv1.RoleRef{
 			APIGroup: "rbac.authorization.k8s.io",
-			Kind:     p.Spec.Kind,
-			Name:     p.Spec.Role,
+			Kind:     kind,
+			Name:     role,
 		},
 	}
 	rb.Subjects = subs
+	setAppliedHash(rb, rb.RoleRef, rb.Subjects)
 
 	// Set rolebindingForPermissions instance as the owner and controller
 	if err := ctrl.SetControllerReference(p, rb, r.Scheme); err != nil {
@@ -142,61 +408,275 @@ func (r *PermsRoleBindingReconciler) rolebindingForPerms(p *permsv1beta1.PermsRo
 	return rb
 }
 
+// rolebindingNameForRole returns the name of the rolebinding of an additional role,
+// the rolebinding is replaced if the role of an entry changes
+func rolebindingNameForRole(name string, role permsv1.RoleSpec) string {
+	return name + "-" + strings.ToLower(role.Kind) + "-" + role.Name
+}
+
+// reconcileRoleBindings creates and updates the rolebindings of the additional roles and
+// deletes the owned rolebindings of removed roles. It returns true if a rolebinding was
+// changed outside of the operator.
+func (r *PermsRoleBindingReconciler) reconcileRoleBindings(ctx context.Context, p *permsv1.PermsRoleBinding, now time.Time) (bool, error) {
+	drifted := false
+	desired := map[string]bool{roleSwitchBindingName(p.Name): true}
+	for i, rb := range r.rolebindingsForPerms(p, ctx, now) {
+		desired[rb.Name] = true
+		// the rolebinding of spec.role is reconciled with the role switch
+		if i == 0 {
+			continue
+		}
+		current := &rbacv1.RoleBinding{}
+		if err := r.Get(ctx, types.NamespacedName{Name: rb.Name, Namespace: rb.Namespace}, current); err != nil {
+			if !errors.IsNotFound(err) {
+				return drifted, err
+			}
+			if isBound(p.Status.Bindings, "RoleBinding", rb.Namespace, rb.Name) {
+				logger.Info("Restoring rolebinding deleted outside of the operator", "Rolebinding.Namespace", rb.Namespace, "Rolebinding.Name", rb.Name)
+				recordEvent(r.Recorder, p, nil, corev1.EventTypeWarning, reasonDriftReverted, "Restoring RoleBinding %s deleted outside of the operator", rb.Name)
+				drifted = true
+			}
+			logger.Info("Creating a new Rolebinding", "Rolebinding.Namespace", rb.Namespace, "Rolebinding.Name", rb.Name)
+			if err := r.Create(ctx, rb); err != nil {
+				return drifted, err
+			}
+			recordEvent(r.Recorder, p, rb, corev1.EventTypeNormal, reasonCreated,
+				"Created RoleBinding %s for %s %s with %s", rb.Name, rb.RoleRef.Kind, rb.RoleRef.Name, strings.Join(subjectNames(rb.Subjects), ", "))
+			p.Status.LastChange = mergeLastChange(p.Status.LastChange, subjectNames(rb.Subjects), nil, now)
+			continue
+		}
+		if err := r.adoptRoleBinding(ctx, p, current); err != nil {
+			return drifted, err
+		}
+		if current.RoleRef != rb.RoleRef {
+			// the roleRef of an adopted rolebinding is immutable, the rolebinding is recreated
+			logger.Info("Recreating rolebinding", "Rolebinding.Namespace", rb.Namespace, "Rolebinding.Name", rb.Name)
+			if err := r.Delete(ctx, current); err != nil && !errors.IsNotFound(err) {
+				return drifted, err
+			}
+			if err := r.Create(ctx, rb); err != nil {
+				return drifted, err
+			}
+			recordEvent(r.Recorder, p, rb, corev1.EventTypeNormal, reasonCreated,
+				"Created RoleBinding %s for %s %s with %s", rb.Name, rb.RoleRef.Kind, rb.RoleRef.Name, strings.Join(subjectNames(rb.Subjects), ", "))
+			p.Status.LastChange = mergeLastChange(p.Status.LastChange, subjectNames(rb.Subjects), nil, now)
+			continue
+		}
+		subjects := current.Subjects
+		update, bindingDrifted := syncBinding(current, rb)
+		if bindingDrifted {
+			logger.Info("Restoring rolebinding changed outside of the operator", "Rolebinding.Namespace", rb.Namespace, "Rolebinding.Name", rb.Name)
+			drifted = true
+		}
+		if update {
+			logger.Info("Updating rolebinding", "Rolebinding.Namespace", rb.Namespace, "Rolebinding.Name", rb.Name)
+			if err := r.Update(ctx, current); err != nil {
+				return drifted, err
+			}
+			if bindingDrifted {
+				recordEvent(r.Recorder, p, current, corev1.EventTypeWarning, reasonDriftReverted, "Reverted changes of RoleBinding %s made outside of the operator", current.Name)
+			}
+			added, removed := recordSubjectChanges(r.Recorder, p, current, subjects, current.Subjects)
+			p.Status.LastChange = mergeLastChange(p.Status.LastChange, added, removed, now)
+		}
+	}
+
+	owned := &rbacv1.RoleBindingList{}
+	if err := r.List(ctx, owned, client.InNamespace(p.Namespace), client.MatchingLabels(labelsForPermsRoleBindings(p.Name))); err != nil {
+		return drifted, err
+	}
+	for i := range owned.Items {
+		rb := &owned.Items[i]
+		if desired[rb.Name] || !metav1.IsControlledBy(rb, p) {
+			continue
+		}
+		logger.Info("Deleting rolebinding of a removed role", "Rolebinding.Namespace", rb.Namespace, "Rolebinding.Name", rb.Name)
+		if err := r.Delete(ctx, rb); err != nil && !errors.IsNotFound(err) {
+			return drifted, err
+		}
+		recordEvent(r.Recorder, p, nil, corev1.EventTypeNormal, reasonDeleted,
+			"Deleted RoleBinding %s of the removed role %s %s", rb.Name, rb.RoleRef.Kind, rb.RoleRef.Name)
+	}
+	return drifted, nil
+}
+
+// switchRoleBinding replaces a rolebinding with a rolebinding for the new role.
+// A temporary rolebinding grants the new role until the replacement is created,
+// so the subjects do not lose their permissions during the switch.
+func (r *PermsRoleBindingReconciler) switchRoleBinding(ctx context.Context, p *permsv1.PermsRoleBinding, current *rbacv1.RoleBinding, now time.Time) error {
+	if err := r.removeRoleSwitchBinding(ctx, p); err != nil {
+		return err
+	}
+	switchBinding := r.rolebindingForPerms(p, ctx, now)
+	switchBinding.Name = roleSwitchBindingName(p.Name)
+	if err := r.Create(ctx, switchBinding); errors.IsAlreadyExists(err) {
+		// only a rolebinding which is not managed by the PermsRoleBinding is left
+		return &bindingConflict{kind: "RoleBinding", key: client.ObjectKeyFromObject(switchBinding)}
+	} else if err != nil {
+		return err
+	}
+	if err := r.Delete(ctx, current); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if err := r.Create(ctx, r.rolebindingForPerms(p, ctx, now)); err != nil {
+		return err
+	}
+	return r.removeRoleSwitchBinding(ctx, p)
+}
+
+// removeRoleSwitchBinding deletes the temporary rolebinding of a role switch if it exists,
+// a rolebinding of the same name which is not managed by the PermsRoleBinding is ignored
+func (r *PermsRoleBindingReconciler) removeRoleSwitchBinding(ctx context.Context, p *permsv1.PermsRoleBinding) error {
+	switchBinding := &rbacv1.RoleBinding{}
+	if err := r.Get(ctx, types.NamespacedName{Name: roleSwitchBindingName(p.Name), Namespace: p.Namespace}, switchBinding); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !metav1.IsControlledBy(switchBinding, p) {
+		return nil
+	}
+	return client.IgnoreNotFound(r.Delete(ctx, switchBinding))
+}
+
+// adoptRoleBinding checks that a rolebinding is managed by the PermsRoleBinding and adopts
+// it with the adoption policy Adopt
+func (r *PermsRoleBindingReconciler) adoptRoleBinding(ctx context.Context, p *permsv1.PermsRoleBinding, rb *rbacv1.RoleBinding) error {
+	adopted, err := adoptBinding(rb, "RoleBinding", p, p.Spec.AdoptionPolicy, labelsForPermsRoleBindings(p.Name), r.Scheme)
+	if err != nil || !adopted {
+		return err
+	}
+	logger.Info("Adopting rolebinding", "Rolebinding.Namespace", rb.Namespace, "Rolebinding.Name", rb.Name)
+	if err := r.Update(ctx, rb); err != nil {
+		return err
+	}
+	recordEvent(r.Recorder, p, rb, corev1.EventTypeNormal, reasonAdopted, "Adopted RoleBinding %s", rb.Name)
+	return nil
+}
+
+// checkRoles emits a warning for each referenced role which does not exist and returns
+// the message of the missing roles and the summaries of the rules of the roles, a missing
+// role keeps its summary in status
+func (r *PermsRoleBindingReconciler) checkRoles(ctx context.Context, p *permsv1.PermsRoleBinding, now time.Time) (string, []permsv1.RoleRulesSummary, error) {
+	var missing []string
+	var summaries []permsv1.RoleRulesSummary
+	for _, rb := range r.rolebindingsForPerms(p, ctx, now) {
+		var err error
+		var rules []rbacv1.PolicyRule
+		if rb.RoleRef.Kind == "Role" {
+			role := &rbacv1.Role{}
+			err = r.Get(ctx, types.NamespacedName{Name: rb.RoleRef.Name, Namespace: p.Namespace}, role)
+			rules = role.Rules
+		} else {
+			role := &rbacv1.ClusterRole{}
+			err = r.Get(ctx, types.NamespacedName{Name: rb.RoleRef.Name}, role)
+			rules = role.Rules
+		}
+		if errors.IsNotFound(err) {
+			recordEvent(r.Recorder, p, nil, corev1.EventTypeWarning, reasonRoleNotFound,
+				"%s %s referenced by RoleBinding %s does not exist", rb.RoleRef.Kind, rb.RoleRef.Name, rb.Name)
+			missing = append(missing, rb.RoleRef.Kind+"/"+rb.RoleRef.Name)
+			summaries = append(summaries, unresolvedRoleRules(p.Status.RoleRules, rb.RoleRef.Kind, rb.RoleRef.Name)...)
+		} else if err != nil {
+			return "", nil, err
+		} else {
+			summaries = append(summaries, roleRulesSummary(rb.RoleRef.Kind, rb.RoleRef.Name, rules))
+		}
+	}
+	if len(missing) == 0 {
+		return "", summaries, nil
+	}
+	return "Referenced roles do not exist: " + strings.Join(missing, ", "), summaries, nil
+}
+
+// updateStatus writes the status computed for the current generation
+func (r *PermsRoleBindingReconciler) updateStatus(ctx context.Context, p *permsv1.PermsRoleBinding) error {
+	p.Status.ObservedGeneration = p.Generation
+	setObservedGeneration(p.Status.Conditions, p.Generation)
+	orderConditions(p.Status.Conditions)
+	return r.Status().Update(ctx, p)
+}
+
 // Function returns the labels for selecting the resources
 func labelsForPermsRoleBindings(name string) map[string]string {
 	return map[string]string{"crd": "PermsRoleBinding", "permsrolebinding_cr": name}
 }
 
-// Function returns the subjects for rolebinding
-func subsForPermsRoleBindings(p *permsv1beta1.PermsRoleBinding) []rbacv1.Subject {
-	subs := make([]rbacv1.Subject, len(p.Spec.Groups))
-	for i, group := range p.Spec.Groups {
-		subs[i] = rbacv1.Subject{
+// Function returns the subjects for rolebinding which have the role assigned at now
+func subsForPermsRoleBindings(p *permsv1.PermsRoleBinding, now time.Time) []rbacv1.Subject {
+	if validityReason(p.Spec.Validity, now) != "Active" {
+		return nil
+	}
+
+	var subs []rbacv1.Subject
+	for _, group := range p.Spec.Groups {
+		if expired(expiryOf(p.Spec.GroupExpiry, group), now) {
+			continue
+		}
+		subs = append(subs, rbacv1.Subject{
 			APIGroup: "rbac.authorization.k8s.io",
 			Kind:     "Group",
 			Name:     group,
-		}
+		})
 	}
 
-	subUsers := make([]rbacv1.Subject, len(p.Spec.Users))
-	for i, user := range p.Spec.Users {
-		subUsers[i] = rbacv1.Subject{
+	for _, user := range p.Spec.Users {
+		if expired(expiryOf(p.Spec.UserExpiry, user), now) {
+			continue
+		}
+		subs = append(subs, rbacv1.Subject{
 			APIGroup: "rbac.authorization.k8s.io",
 			Kind:     "User",
 			Name:     user,
-		}
-		subs = append(subs, subUsers[i])
+		})
 	}
 
-	subServiceaccounts := make([]rbacv1.Subject, len(p.Spec.Serviceaccounts))
-	for i, serviceaccounts := range p.Spec.Serviceaccounts {
-		subServiceaccounts[i] = rbacv1.Subject{
+	for _, serviceaccounts := range p.Spec.Serviceaccounts {
+		if expired(serviceaccounts.ExpiresAt, now) {
+			continue
+		}
+		// serviceaccounts without namespace belong to the namespace of the PermsRoleBinding
+		namespace := serviceaccounts.Namespace
+		if namespace == "" {
+			namespace = p.Namespace
+		}
+		subs = append(subs, rbacv1.Subject{
 			Kind:      "ServiceAccount",
 			Name:      serviceaccounts.Name,
-			Namespace: serviceaccounts.Namespace,
-		}
-		subs = append(subs, subServiceaccounts[i])
+			Namespace: namespace,
+		})
 	}
 	return subs
 }
 
 // compare "Status" with "Spec" and update the Status if needed
-func (r *PermsRoleBindingReconciler) updateCountsPermsRoleBinding(ctx context.Context, p *permsv1beta1.PermsRoleBinding, req ctrl.Request) {
-	if p.Status.Count.Users != strconv.Itoa(len(p.Spec.Users)) ||
-		p.Status.Count.Groups != strconv.Itoa(len(p.Spec.Groups)) ||
-		p.Status.Count.Serviceaccounts != strconv.Itoa(len(p.Spec.Serviceaccounts)) {
-
-		p.Status.Count.Users = strconv.Itoa(len(p.Spec.Users))
-		p.Status.Count.Groups = strconv.Itoa(len(p.Spec.Groups))
-		p.Status.Count.Serviceaccounts = strconv.Itoa(len(p.Spec.Serviceaccounts))
-
+func (r *PermsRoleBindingReconciler) updateCountsPermsRoleBinding(ctx context.Context, p *permsv1.PermsRoleBinding, req ctrl.Request, now time.Time) {
+	p.Status.Count = permsv1.SubjectCount{
+		Users:           int32(len(p.Spec.Users)),
+		Groups:          int32(len(p.Spec.Groups)),
+		Serviceaccounts: int32(len(p.Spec.Serviceaccounts)),
+	}
+	p.Status.Bindings = nil
+	for _, rb := range r.rolebindingsForPerms(p, ctx, now) {
+		p.Status.Bindings = append(p.Status.Bindings, permsv1.BindingReference{
+			Kind:      "RoleBinding",
+			Namespace: rb.Namespace,
+			Name:      rb.Name,
+			RoleRef:   permsv1.RoleReference{Kind: rb.RoleRef.Kind, Name: rb.RoleRef.Name},
+		})
 	}
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *PermsRoleBindingReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &permsv1.PermsRoleBinding{}, roleRefIndex, permsRoleBindingRoleRefs); err != nil {
+		return err
+	}
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&permsv1beta1.PermsRoleBinding{}).
+		For(&permsv1.PermsRoleBinding{}).
 		Owns(&rbacv1.RoleBinding{}).
+		Watches(&source.Kind{Type: &rbacv1.Role{}}, handler.EnqueueRequestsFromMapFunc(r.permsRoleBindingsForRole)).
+		Watches(&source.Kind{Type: &rbacv1.ClusterRole{}}, handler.EnqueueRequestsFromMapFunc(r.permsRoleBindingsForRole)).
+		Watches(&source.Kind{Type: &permsv1.PermsPolicy{}}, handler.EnqueueRequestsFromMapFunc(r.permsRoleBindingsForPolicy)).
+		Watches(&source.Kind{Type: &permsv1.PermsDelegation{}}, handler.EnqueueRequestsFromMapFunc(r.permsRoleBindingsForDelegation)).
+		Watches(&source.Kind{Type: &permsv1.PermsLockdown{}}, handler.EnqueueRequestsFromMapFunc(r.permsRoleBindingsForLockdown)).
 		Complete(r)
 }
@@ -131,12 +131,1055 @@ var _ = Describe("permsrolebinding", func() {
 			}
 			Eventually(getStatus, 15*time.Second, time.Second).Should(Succeed())
 
+			By("validating that the reason and observed generation of the degraded status are set")
+			Eventually(func() string {
+				cmd = exec.Command("kubectl", "get", "prb.v1.perms.infra-mgmt.io",
+					"demo2", "-o", "jsonpath={.status.conditions[?(@.type==\"Degraded\")].reason}",
+					"-n", testNamespace,
+				)
+				reason, _ := Run(cmd)
+				return string(reason)
+			}, 15*time.Second, time.Second).Should(Equal("ImmutableRoleRef"))
+			cmd = exec.Command("kubectl", "get", "prb.v1.perms.infra-mgmt.io",
+				"demo2", "-o", "jsonpath={.metadata.generation} {.status.observedGeneration}",
+				"-n", testNamespace,
+			)
+			generations, err := Run(cmd)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(strings.Fields(string(generations))).To(HaveLen(2))
+			Expect(strings.Fields(string(generations))[1]).To(Equal(strings.Fields(string(generations))[0]))
+
+			By("removing testing namespace")
+			cmd = exec.Command("kubectl", "delete", "ns", testNamespace)
+			_, _ = Run(cmd)
+
+		})
+
+		It("it should switch the role when patching the role of a PermsRoleBinding", func() {
+			projectDir, _ := GetProjectDir()
+
+			testNamespace := "testing6"
+
+			By("creating test namespace")
+			cmd = exec.Command("kubectl", "create", "ns", testNamespace)
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("creating an instance of the PermsRoleBinding CRD in the testing namespace")
+			EventuallyWithOffset(1, func() error {
+				cmd = exec.Command("kubectl", "apply", "-f", filepath.Join(projectDir,
+					"config/samples/perms_v1beta1_permsrolebinding_demo2.yaml"), "-n", testNamespace)
+				_, err = Run(cmd)
+				return err
+			}, 15*time.Second, time.Second).Should(Succeed())
+
+			time.Sleep(5 * time.Second)
+
+			By("updating the role of the PermsRoleBinding CRD in the testing namespace")
+			EventuallyWithOffset(1, func() error {
+				cmd = exec.Command("kubectl", "patch", "prb", "demo2", "--patch-file", filepath.Join(projectDir,
+					"config/samples/perms_v1beta1_permsrolebinding_patch_switch.yaml"), "--type", "merge", "-n", testNamespace)
+				_, err = Run(cmd)
+				return err
+			}, 15*time.Second, time.Second).Should(Succeed())
+
+			By("validating that the rolebinding references the new role")
+			getRoleRef := func() error {
+				cmd = exec.Command("kubectl", "get", "rolebinding",
+					"demo2", "-o", "jsonpath={.roleRef.name}",
+					"-n", testNamespace,
+				)
+				roleRef, err := Run(cmd)
+				fmt.Println(string(roleRef))
+				ExpectWithOffset(2, err).NotTo(HaveOccurred())
+				if string(roleRef) != "edit" {
+					return fmt.Errorf("rolebinding should reference the role edit")
+				}
+				return nil
+			}
+			Eventually(getRoleRef, 15*time.Second, time.Second).Should(Succeed())
+
+			By("validating that the status of the CR is updated to role switched")
+			getStatus := func() error {
+				cmd = exec.Command("kubectl", "get", "prb",
+					"demo2", "-o", "jsonpath={.status.conditions[?(@.type==\"RoleSwitched\")].status}",
+					"-n", testNamespace,
+				)
+				status, err := Run(cmd)
+				fmt.Println(string(status))
+				ExpectWithOffset(2, err).NotTo(HaveOccurred())
+				if !strings.Contains(string(status), "True") {
+					return fmt.Errorf("status condition with type RoleSwitched should be set to true")
+				}
+				return nil
+			}
+			Eventually(getStatus, 15*time.Second, time.Second).Should(Succeed())
+
+			By("removing testing namespace")
+			cmd = exec.Command("kubectl", "delete", "ns", testNamespace)
+			_, _ = Run(cmd)
+
+		})
+
+		It("it should create a rolebinding per role and prune removed roles of a PermsRoleBinding", func() {
+			projectDir, _ := GetProjectDir()
+
+			testNamespace := "testing8"
+
+			By("creating test namespace")
+			cmd = exec.Command("kubectl", "create", "ns", testNamespace)
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("creating an instance of the PermsRoleBinding CRD with additional roles in the testing namespace")
+			EventuallyWithOffset(1, func() error {
+				cmd = exec.Command("kubectl", "apply", "-f", filepath.Join(projectDir,
+					"config/samples/perms_v1_permsrolebinding_roles.yaml"), "-n", testNamespace)
+				_, err = Run(cmd)
+				return err
+			}, 15*time.Second, time.Second).Should(Succeed())
+
+			By("validating that a rolebinding per role is created")
+			getRoleBindings := func(expected string) func() error {
+				return func() error {
+					cmd = exec.Command("kubectl", "get", "rolebinding",
+						"-l", "permsrolebinding_cr=demo3", "-o", "jsonpath={.items[*].metadata.name}",
+						"-n", testNamespace,
+					)
+					names, err := Run(cmd)
+					fmt.Println(string(names))
+					ExpectWithOffset(2, err).NotTo(HaveOccurred())
+					if string(names) != expected {
+						return fmt.Errorf("rolebindings %s should be %s", names, expected)
+					}
+					return nil
+				}
+			}
+			Eventually(getRoleBindings("demo3 demo3-clusterrole-admin demo3-clusterrole-edit"), 15*time.Second, time.Second).Should(Succeed())
+
+			By("removing an additional role of the PermsRoleBinding CRD in the testing namespace")
+			EventuallyWithOffset(1, func() error {
+				cmd = exec.Command("kubectl", "patch", "prb", "demo3", "--patch-file", filepath.Join(projectDir,
+					"config/samples/perms_v1_permsrolebinding_patch_roles.yaml"), "--type", "merge", "-n", testNamespace)
+				_, err = Run(cmd)
+				return err
+			}, 15*time.Second, time.Second).Should(Succeed())
+
+			By("validating that the rolebinding of the removed role is deleted")
+			Eventually(getRoleBindings("demo3 demo3-clusterrole-edit"), 15*time.Second, time.Second).Should(Succeed())
+
+			By("removing testing namespace")
+			cmd = exec.Command("kubectl", "delete", "ns", testNamespace)
+			_, _ = Run(cmd)
+
+		})
+
+		It("it should remove a user from the rolebinding when its expiry is reached", func() {
+			projectDir, _ := GetProjectDir()
+
+			testNamespace := "testing11"
+
+			By("creating test namespace")
+			cmd = exec.Command("kubectl", "create", "ns", testNamespace)
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("creating an instance of the PermsRoleBinding CRD in the testing namespace")
+			EventuallyWithOffset(1, func() error {
+				cmd = exec.Command("kubectl", "apply", "-f", filepath.Join(projectDir,
+					"config/samples/perms_v1beta1_permsrolebinding_demo2.yaml"), "-n", testNamespace)
+				_, err = Run(cmd)
+				return err
+			}, 15*time.Second, time.Second).Should(Succeed())
+
+			By("setting an expiry for user1")
+			expiresAt := time.Now().Add(20 * time.Second).UTC().Format(time.RFC3339)
+			EventuallyWithOffset(1, func() error {
+				cmd = exec.Command("kubectl", "patch", "prb.v1.perms.infra-mgmt.io", "demo2", "--patch",
+					fmt.Sprintf(`{"spec":{"userExpiry":{"user1":"%s"}}}`, expiresAt), "--type", "merge", "-n", testNamespace)
+				_, err = Run(cmd)
+				return err
+			}, 15*time.Second, time.Second).Should(Succeed())
+
+			getUsers := func() []string {
+				cmd = exec.Command("kubectl", "get", "rolebinding",
+					"demo2", "-o", "jsonpath={.subjects[?(@.kind==\"User\")].name}",
+					"-n", testNamespace,
+				)
+				users, err := Run(cmd)
+				fmt.Println(string(users))
+				ExpectWithOffset(2, err).NotTo(HaveOccurred())
+				return strings.Fields(string(users))
+			}
+
+			By("validating that the expiry is listed in the status")
+			Eventually(func() string {
+				cmd = exec.Command("kubectl", "get", "prb.v1.perms.infra-mgmt.io",
+					"demo2", "-o", "jsonpath={.status.expirations[0].name}",
+					"-n", testNamespace,
+				)
+				name, _ := Run(cmd)
+				return string(name)
+			}, 15*time.Second, time.Second).Should(Equal("user1"))
+
+			By("validating that user1 is removed from the rolebinding after the expiry")
+			Eventually(getUsers, 45*time.Second, time.Second).ShouldNot(ContainElement("user1"))
+			Expect(getUsers()).To(ContainElement("user2"))
+
+			By("removing testing namespace")
+			cmd = exec.Command("kubectl", "delete", "ns", testNamespace)
+			_, _ = Run(cmd)
+
+		})
+
+		It("it should not touch an existing rolebinding until it is adopted", func() {
+			projectDir, _ := GetProjectDir()
+
+			testNamespace := "testing12"
+
+			By("creating test namespace")
+			cmd = exec.Command("kubectl", "create", "ns", testNamespace)
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("creating an unmanaged rolebinding")
+			cmd = exec.Command("kubectl", "create", "rolebinding", "demo2",
+				"--clusterrole", "view", "--user", "someone", "-n", testNamespace)
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("creating an instance of the PermsRoleBinding CRD with the same name")
+			EventuallyWithOffset(1, func() error {
+				cmd = exec.Command("kubectl", "apply", "-f", filepath.Join(projectDir,
+					"config/samples/perms_v1beta1_permsrolebinding_demo2.yaml"), "-n", testNamespace)
+				_, err = Run(cmd)
+				return err
+			}, 15*time.Second, time.Second).Should(Succeed())
+
+			getConflict := func() string {
+				cmd = exec.Command("kubectl", "get", "prb.v1.perms.infra-mgmt.io",
+					"demo2", "-o", "jsonpath={.status.conditions[?(@.type==\"Conflict\")].status}",
+					"-n", testNamespace,
+				)
+				status, _ := Run(cmd)
+				return string(status)
+			}
+			getUsers := func() string {
+				cmd = exec.Command("kubectl", "get", "rolebinding",
+					"demo2", "-o", "jsonpath={.subjects[?(@.kind==\"User\")].name}",
+					"-n", testNamespace,
+				)
+				users, _ := Run(cmd)
+				return string(users)
+			}
+
+			By("validating that the conflict is reported and the rolebinding is untouched")
+			Eventually(getConflict, 15*time.Second, time.Second).Should(Equal("True"))
+			Expect(getUsers()).To(Equal("someone"))
+
+			By("adopting the rolebinding")
+			EventuallyWithOffset(1, func() error {
+				cmd = exec.Command("kubectl", "patch", "prb.v1.perms.infra-mgmt.io", "demo2", "--patch",
+					`{"spec":{"adoptionPolicy":"Adopt"}}`, "--type", "merge", "-n", testNamespace)
+				_, err = Run(cmd)
+				return err
+			}, 15*time.Second, time.Second).Should(Succeed())
+
+			By("validating that the rolebinding is managed by the PermsRoleBinding")
+			Eventually(getConflict, 90*time.Second, time.Second).Should(Equal("False"))
+			Expect(getUsers()).To(ContainSubstring("user1"))
+			Expect(getUsers()).NotTo(ContainSubstring("someone"))
+
+			By("removing testing namespace")
+			cmd = exec.Command("kubectl", "delete", "ns", testNamespace)
+			_, _ = Run(cmd)
+
+		})
+
+		It("it should revert manual changes of the rolebinding", func() {
+			projectDir, _ := GetProjectDir()
+
+			testNamespace := "testing13"
+
+			By("creating test namespace")
+			cmd = exec.Command("kubectl", "create", "ns", testNamespace)
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("creating an instance of the PermsRoleBinding CRD in the testing namespace")
+			EventuallyWithOffset(1, func() error {
+				cmd = exec.Command("kubectl", "apply", "-f", filepath.Join(projectDir,
+					"config/samples/perms_v1beta1_permsrolebinding_demo2.yaml"), "-n", testNamespace)
+				_, err = Run(cmd)
+				return err
+			}, 15*time.Second, time.Second).Should(Succeed())
+
+			getUsers := func() string {
+				cmd = exec.Command("kubectl", "get", "rolebinding",
+					"demo2", "-o", "jsonpath={.subjects[?(@.kind==\"User\")].name}",
+					"-n", testNamespace,
+				)
+				users, _ := Run(cmd)
+				return string(users)
+			}
+			Eventually(getUsers, 15*time.Second, time.Second).Should(ContainSubstring("user1"))
+
+			By("changing the subjects and labels of the rolebinding")
+			cmd = exec.Command("kubectl", "patch", "rolebinding", "demo2", "--patch",
+				`{"metadata":{"labels":{"crd":"edited"}},"subjects":[{"apiGroup":"rbac.authorization.k8s.io","kind":"User","name":"intruder"}]}`,
+				"--type", "merge", "-n", testNamespace)
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("validating that the change is reverted and counted")
+			Eventually(getUsers, 15*time.Second, time.Second).Should(ContainSubstring("user1"))
+			Expect(getUsers()).NotTo(ContainSubstring("intruder"))
+			Eventually(func() string {
+				cmd = exec.Command("kubectl", "get", "prb.v1.perms.infra-mgmt.io",
+					"demo2", "-o", "jsonpath={.status.driftCount}",
+					"-n", testNamespace,
+				)
+				count, _ := Run(cmd)
+				return string(count)
+			}, 15*time.Second, time.Second).Should(Equal("1"))
+
+			By("validating that the revert is reported as event")
+			Eventually(func() string {
+				cmd = exec.Command("kubectl", "get", "events",
+					"--field-selector", "involvedObject.kind=PermsRoleBinding,involvedObject.name=demo2,reason=DriftReverted",
+					"-o", "jsonpath={.items[*].reason}", "-n", testNamespace,
+				)
+				reasons, _ := Run(cmd)
+				return string(reasons)
+			}, 15*time.Second, time.Second).Should(ContainSubstring("DriftReverted"))
+
+			By("removing testing namespace")
+			cmd = exec.Command("kubectl", "delete", "ns", testNamespace)
+			_, _ = Run(cmd)
+
+		})
+
+		It("it should resolve the referenced role when it is created and deleted", func() {
+			projectDir, _ := GetProjectDir()
+
+			testNamespace := "testing14"
+
+			By("creating test namespace")
+			cmd = exec.Command("kubectl", "create", "ns", testNamespace)
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("creating a PermsRoleBinding for a role which does not exist")
+			EventuallyWithOffset(1, func() error {
+				cmd = exec.Command("kubectl", "apply", "-f", filepath.Join(projectDir,
+					"config/samples/perms_v1_permsrolebinding_role.yaml"), "-n", testNamespace)
+				_, err = Run(cmd)
+				return err
+			}, 15*time.Second, time.Second).Should(Succeed())
+
+			getResolved := func() string {
+				cmd = exec.Command("kubectl", "get", "prb.v1.perms.infra-mgmt.io",
+					"demo7", "-o", "jsonpath={.status.conditions[?(@.type==\"RoleResolved\")].status}",
+					"-n", testNamespace,
+				)
+				status, _ := Run(cmd)
+				return string(status)
+			}
+			getDegraded := func() string {
+				cmd = exec.Command("kubectl", "get", "prb.v1.perms.infra-mgmt.io",
+					"demo7", "-o", "jsonpath={.status.conditions[?(@.type==\"Degraded\")].reason}",
+					"-n", testNamespace,
+				)
+				reason, _ := Run(cmd)
+				return string(reason)
+			}
+			Eventually(getResolved, 15*time.Second, time.Second).Should(Equal("False"))
+			Expect(getDegraded()).To(Equal("RoleNotFound"))
+
+			By("creating the role")
+			cmd = exec.Command("kubectl", "create", "role", "reader", "--verb", "get", "--resource", "pods", "-n", testNamespace)
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("validating that the role is resolved without a change of the PermsRoleBinding")
+			Eventually(getResolved, 15*time.Second, time.Second).Should(Equal("True"))
+			Expect(getDegraded()).To(Equal("Degraded"))
+
+			By("deleting the role")
+			cmd = exec.Command("kubectl", "delete", "role", "reader", "-n", testNamespace)
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("validating that the PermsRoleBinding is degraded again")
+			Eventually(getResolved, 15*time.Second, time.Second).Should(Equal("False"))
+			Expect(getDegraded()).To(Equal("RoleNotFound"))
+
+			By("removing testing namespace")
+			cmd = exec.Command("kubectl", "delete", "ns", testNamespace)
+			_, _ = Run(cmd)
+
+		})
+
+		It("it should hold a pinned PermsRoleBinding after a change of the role rules until it is acknowledged", func() {
+			projectDir, _ := GetProjectDir()
+
+			testNamespace := "testing15"
+
+			By("creating test namespace")
+			cmd = exec.Command("kubectl", "create", "ns", testNamespace)
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("creating the role and a PermsRoleBinding which pins its rules")
+			cmd = exec.Command("kubectl", "create", "role", "reader", "--verb", "get", "--resource", "pods", "-n", testNamespace)
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+			EventuallyWithOffset(1, func() error {
+				cmd = exec.Command("kubectl", "apply", "-f", filepath.Join(projectDir,
+					"config/samples/perms_v1_permsrolebinding_pinned.yaml"), "-n", testNamespace)
+				_, err = Run(cmd)
+				return err
+			}, 15*time.Second, time.Second).Should(Succeed())
+
+			getRulesChanged := func() string {
+				cmd = exec.Command("kubectl", "get", "prb.v1.perms.infra-mgmt.io",
+					"demo8", "-o", "jsonpath={.status.conditions[?(@.type==\"RoleRulesChanged\")].reason}",
+					"-n", testNamespace,
+				)
+				reason, _ := Run(cmd)
+				return string(reason)
+			}
+			getUsers := func() string {
+				cmd = exec.Command("kubectl", "get", "rolebinding",
+					"demo8", "-o", "jsonpath={.subjects[?(@.kind==\"User\")].name}",
+					"-n", testNamespace,
+				)
+				users, _ := Run(cmd)
+				return string(users)
+			}
+			Eventually(getRulesChanged, 15*time.Second, time.Second).Should(Equal("RulesUnchanged"))
+			Expect(getUsers()).To(ContainSubstring("user1"))
+
+			By("broadening the rules of the role")
+			cmd = exec.Command("kubectl", "patch", "role", "reader", "--patch",
+				`[{"op":"add","path":"/rules/0/verbs/-","value":"list"}]`,
+				"--type", "json", "-n", testNamespace)
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+			Eventually(getRulesChanged, 15*time.Second, time.Second).Should(Equal("RulesPinned"))
+
+			By("validating that a change of the PermsRoleBinding is not applied")
+			cmd = exec.Command("kubectl", "patch", "prb.v1.perms.infra-mgmt.io", "demo8", "--patch",
+				`{"spec":{"users":["user1","user2"]}}`, "--type", "merge", "-n", testNamespace)
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+			Consistently(getUsers, 5*time.Second, time.Second).ShouldNot(ContainSubstring("user2"))
+
+			By("acknowledging the change of the rules")
+			cmd = exec.Command("kubectl", "get", "prb.v1.perms.infra-mgmt.io",
+				"demo8", "-o", "jsonpath={.status.roleRulesHash}", "-n", testNamespace)
+			hash, err := Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+			cmd = exec.Command("kubectl", "annotate", "prb.v1.perms.infra-mgmt.io", "demo8",
+				"perms.infra-mgmt.io/role-rules-ack="+string(hash), "-n", testNamespace)
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("validating that the PermsRoleBinding is reconciled again")
+			Eventually(getRulesChanged, 15*time.Second, time.Second).Should(Equal("Acknowledged"))
+			Eventually(getUsers, 15*time.Second, time.Second).Should(ContainSubstring("user2"))
+
+			By("removing testing namespace")
+			cmd = exec.Command("kubectl", "delete", "ns", testNamespace)
+			_, _ = Run(cmd)
+
+		})
+
+		It("it should enforce a PermsPolicy at admission and for existing PermsRoleBindings", func() {
+			projectDir, _ := GetProjectDir()
+
+			testNamespace := "testing16"
+
+			By("creating test namespace")
+			cmd = exec.Command("kubectl", "create", "ns", testNamespace)
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("creating a PermsRoleBinding before the policy exists")
+			EventuallyWithOffset(1, func() error {
+				cmd = exec.Command("kubectl", "apply", "-f", filepath.Join(projectDir,
+					"config/samples/perms_v1beta1_permsrolebinding_demo2.yaml"), "-n", testNamespace)
+				_, err = Run(cmd)
+				return err
+			}, 15*time.Second, time.Second).Should(Succeed())
+			getRoleBinding := func() error {
+				cmd = exec.Command("kubectl", "get", "rolebinding", "demo2", "-n", testNamespace)
+				_, err := Run(cmd)
+				return err
+			}
+			Eventually(getRoleBinding, 15*time.Second, time.Second).Should(Succeed())
+
+			By("protecting the test namespace with a PermsPolicy")
+			cmd = exec.Command("kubectl", "apply", "-f", "-")
+			cmd.Stdin = strings.NewReader(`{"apiVersion":"perms.infra-mgmt.io/v1","kind":"PermsPolicy",` +
+				`"metadata":{"name":"` + testNamespace + `"},"spec":{"protectedNamespaces":["` + testNamespace + `"]}}`)
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("validating that the rolebinding of the existing PermsRoleBinding is revoked")
+			Eventually(func() string {
+				cmd = exec.Command("kubectl", "get", "prb.v1.perms.infra-mgmt.io",
+					"demo2", "-o", "jsonpath={.status.conditions[?(@.type==\"PolicyViolation\")].status}",
+					"-n", testNamespace,
+				)
+				status, _ := Run(cmd)
+				return string(status)
+			}, 15*time.Second, time.Second).Should(Equal("True"))
+			Eventually(getRoleBinding, 15*time.Second, time.Second).ShouldNot(Succeed())
+
+			By("validating that a new PermsRoleBinding is rejected")
+			cmd = exec.Command("kubectl", "apply", "-f", filepath.Join(projectDir,
+				"config/samples/perms_v1_permsrolebinding_adopt.yaml"), "-n", testNamespace)
+			output, err := Run(cmd)
+			Expect(err).To(HaveOccurred())
+			Expect(string(output)).To(ContainSubstring("namespace " + testNamespace + " is protected"))
+
+			By("removing the PermsPolicy")
+			cmd = exec.Command("kubectl", "delete", "permspolicy", testNamespace)
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("validating that the rolebinding is restored")
+			Eventually(getRoleBinding, 15*time.Second, time.Second).Should(Succeed())
+
+			By("removing testing namespace")
+			cmd = exec.Command("kubectl", "delete", "ns", testNamespace)
+			_, _ = Run(cmd)
+
+		})
+
+		It("it should enforce the CEL rules of a PermsPolicy", func() {
+			projectDir, _ := GetProjectDir()
+			testNamespace := "testing17"
+
+			By("creating a prod namespace")
+			cmd = exec.Command("kubectl", "create", "ns", testNamespace)
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+			cmd = exec.Command("kubectl", "label", "ns", testNamespace, "env=prod")
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("creating a PermsRoleBinding before the policy exists")
+			EventuallyWithOffset(1, func() error {
+				cmd = exec.Command("kubectl", "apply", "-f", filepath.Join(projectDir,
+					"config/samples/perms_v1beta1_permsrolebinding_demo2.yaml"), "-n", testNamespace)
+				_, err = Run(cmd)
+				return err
+			}, 15*time.Second, time.Second).Should(Succeed())
+
+			By("creating the PermsPolicy with CEL rules")
+			cmd = exec.Command("kubectl", "apply", "-f", filepath.Join(projectDir,
+				"config/samples/perms_v1_permspolicy_rules.yaml"))
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("validating that the violated rules are listed in the status")
+			Eventually(func() string {
+				cmd = exec.Command("kubectl", "get", "prb.v1.perms.infra-mgmt.io",
+					"demo2", "-o", "jsonpath={.status.policyViolations}",
+					"-n", testNamespace,
+				)
+				violations, _ := Run(cmd)
+				return string(violations)
+			}, 15*time.Second, time.Second).Should(And(
+				ContainSubstring("rule team-groups: groups in prod namespaces must start with team-"),
+				ContainSubstring("rule own-serviceaccounts: serviceaccounts may only be bound inside their own namespace"),
+			))
+
+			By("validating that a new PermsRoleBinding is rejected")
+			cmd = exec.Command("kubectl", "apply", "-f", filepath.Join(projectDir,
+				"config/samples/perms_v1_permsrolebinding_adopt.yaml"), "-n", testNamespace)
+			output, err := Run(cmd)
+			Expect(err).To(HaveOccurred())
+			Expect(string(output)).To(ContainSubstring("rule team-groups"))
+
+			By("removing the PermsPolicy")
+			cmd = exec.Command("kubectl", "delete", "-f", filepath.Join(projectDir,
+				"config/samples/perms_v1_permspolicy_rules.yaml"))
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("removing testing namespace")
+			cmd = exec.Command("kubectl", "delete", "ns", testNamespace)
+			_, _ = Run(cmd)
+
+		})
+
+		It("it should report and reject mutually exclusive roles", func() {
+			projectDir, _ := GetProjectDir()
+			testNamespace := "testing18"
+			prbFor := func(name string, role string) string {
+				return `{"apiVersion":"perms.infra-mgmt.io/v1","kind":"PermsRoleBinding",` +
+					`"metadata":{"name":"` + name + `","namespace":"` + testNamespace + `"},` +
+					`"spec":{"kind":"ClusterRole","role":"` + role + `","users":["user1"]}}`
+			}
+
+			By("creating test namespace")
+			cmd = exec.Command("kubectl", "create", "ns", testNamespace)
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("assigning the deployer and approver roles to the same user")
+			for name, role := range map[string]string{"deployers": "deployer", "approvers": "approver"} {
+				cmd = exec.Command("kubectl", "apply", "-f", "-")
+				cmd.Stdin = strings.NewReader(prbFor(name, role))
+				_, err = Run(cmd)
+				Expect(err).To(Not(HaveOccurred()))
+			}
+
+			By("creating the PermsConstraint")
+			cmd = exec.Command("kubectl", "apply", "-f", filepath.Join(projectDir,
+				"config/samples/perms_v1_permsconstraint.yaml"))
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("validating that the existing violation is reported")
+			Eventually(func() string {
+				cmd = exec.Command("kubectl", "get", "permsconstraint", "permsconstraint-sample",
+					"-o", "jsonpath={.status.violations[*].name}")
+				names, _ := Run(cmd)
+				return string(names)
+			}, 15*time.Second, time.Second).Should(Equal("user1"))
+
+			By("validating that another exclusive role is rejected")
+			cmd = exec.Command("kubectl", "apply", "-f", "-")
+			cmd.Stdin = strings.NewReader(prbFor("approvers2", "approver"))
+			output, err := Run(cmd)
+			Expect(err).To(HaveOccurred())
+			Expect(string(output)).To(ContainSubstring("holds the exclusive roles"))
+
+			By("removing the violating PermsRoleBinding")
+			cmd = exec.Command("kubectl", "delete", "prb.v1.perms.infra-mgmt.io", "approvers", "-n", testNamespace)
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+			Eventually(func() string {
+				cmd = exec.Command("kubectl", "get", "permsconstraint", "permsconstraint-sample",
+					"-o", "jsonpath={.status.conditions[?(@.type==\"Satisfied\")].status}")
+				status, _ := Run(cmd)
+				return string(status)
+			}, 15*time.Second, time.Second).Should(Equal("True"))
+
+			By("removing the PermsConstraint")
+			cmd = exec.Command("kubectl", "delete", "-f", filepath.Join(projectDir,
+				"config/samples/perms_v1_permsconstraint.yaml"))
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("removing testing namespace")
+			cmd = exec.Command("kubectl", "delete", "ns", testNamespace)
+			_, _ = Run(cmd)
+
+		})
+
+		It("it should reject roles which the author may not bind", func() {
+			testNamespace := "testing19"
+			author := "system:serviceaccount:" + testNamespace + ":author"
+
+			By("creating test namespace")
+			cmd = exec.Command("kubectl", "create", "ns", testNamespace)
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("allowing a serviceaccount to manage PermsRoleBindings")
+			for _, args := range [][]string{
+				{"create", "serviceaccount", "author"},
+				{"create", "role", "prb-author", "--verb", "get,create,update,patch", "--resource", "permsrolebindings.perms.infra-mgmt.io"},
+				{"create", "rolebinding", "prb-author", "--role", "prb-author", "--serviceaccount", testNamespace + ":author"},
+			} {
+				cmd = exec.Command("kubectl", append(args, "-n", testNamespace)...)
+				_, err = Run(cmd)
+				Expect(err).To(Not(HaveOccurred()))
+			}
+
+			By("validating that the serviceaccount may not bind cluster-admin")
+			cmd = exec.Command("kubectl", "apply", "--as", author, "-f", "-")
+			cmd.Stdin = strings.NewReader(`{"apiVersion":"perms.infra-mgmt.io/v1","kind":"PermsRoleBinding",` +
+				`"metadata":{"name":"escalation","namespace":"` + testNamespace + `"},` +
+				`"spec":{"kind":"ClusterRole","role":"cluster-admin","users":["user1"]}}`)
+			output, err := Run(cmd)
+			Expect(err).To(HaveOccurred())
+			Expect(string(output)).To(ContainSubstring("user " + author + " may not bind ClusterRole cluster-admin"))
+
+			By("removing testing namespace")
+			cmd = exec.Command("kubectl", "delete", "ns", testNamespace)
+			_, _ = Run(cmd)
+
+		})
+
+		It("it should scope the PermsRoleBindings of a namespace to its PermsDelegations", func() {
+			testNamespace := "testing20"
+			owner := "system:serviceaccount:" + testNamespace + ":owner"
+
+			By("creating test namespace")
+			cmd = exec.Command("kubectl", "create", "ns", testNamespace)
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("allowing a serviceaccount to manage PermsRoleBindings without the bind permission")
+			for _, args := range [][]string{
+				{"create", "serviceaccount", "owner"},
+				{"create", "role", "prb-owner", "--verb", "get,create,update,patch", "--resource", "permsrolebindings.perms.infra-mgmt.io"},
+				{"create", "rolebinding", "prb-owner", "--role", "prb-owner", "--serviceaccount", testNamespace + ":owner"},
+			} {
+				cmd = exec.Command("kubectl", append(args, "-n", testNamespace)...)
+				_, err = Run(cmd)
+				Expect(err).To(Not(HaveOccurred()))
+			}
+
+			By("delegating the view role for users of the organisation to the serviceaccount")
+			EventuallyWithOffset(1, func() error {
+				cmd = exec.Command("kubectl", "apply", "-n", testNamespace, "-f", "-")
+				cmd.Stdin = strings.NewReader(`{"apiVersion":"perms.infra-mgmt.io/v1","kind":"PermsDelegation",` +
+					`"metadata":{"name":"view"},` +
+					`"spec":{"admins":{"users":["` + owner + `"]},"roles":[{"kind":"ClusterRole","name":"view"}],"users":["*@example.com"]}}`)
+				_, err = Run(cmd)
+				return err
+			}, 15*time.Second, time.Second).Should(Succeed())
+
+			By("validating that the serviceaccount may grant the delegated role")
+			cmd = exec.Command("kubectl", "apply", "--as", owner, "-f", "-")
+			cmd.Stdin = strings.NewReader(`{"apiVersion":"perms.infra-mgmt.io/v1","kind":"PermsRoleBinding",` +
+				`"metadata":{"name":"delegated","namespace":"` + testNamespace + `"},` +
+				`"spec":{"kind":"ClusterRole","role":"view","users":["dev@example.com"]}}`)
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+			getBinding := func() error {
+				cmd = exec.Command("kubectl", "get", "rolebinding", "delegated", "-n", testNamespace)
+				_, err := Run(cmd)
+				return err
+			}
+			Eventually(getBinding, 15*time.Second, time.Second).Should(Succeed())
+
+			By("validating that roles and subjects which are not delegated are rejected")
+			cmd = exec.Command("kubectl", "apply", "--as", owner, "-f", "-")
+			cmd.Stdin = strings.NewReader(`{"apiVersion":"perms.infra-mgmt.io/v1","kind":"PermsRoleBinding",` +
+				`"metadata":{"name":"not-delegated","namespace":"` + testNamespace + `"},` +
+				`"spec":{"kind":"ClusterRole","role":"edit","users":["mallory@example.org"]}}`)
+			output, err := Run(cmd)
+			Expect(err).To(HaveOccurred())
+			Expect(string(output)).To(ContainSubstring("ClusterRole edit is not delegated"))
+
+			By("removing testing namespace")
+			cmd = exec.Command("kubectl", "delete", "ns", testNamespace)
+			_, _ = Run(cmd)
+
+		})
+
+		It("it should bind the role of an approved PermsAccessRequest until it expires", func() {
+			testNamespace := "testing21"
+			requester := "system:serviceaccount:" + testNamespace + ":requester"
+
+			By("creating test namespace")
+			cmd = exec.Command("kubectl", "create", "ns", testNamespace)
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("allowing a serviceaccount to request access")
+			for _, args := range [][]string{
+				{"create", "serviceaccount", "requester"},
+				{"create", "role", "requester", "--verb", "get,create", "--resource", "permsaccessrequests.perms.infra-mgmt.io"},
+				{"create", "rolebinding", "requester", "--role", "requester", "--serviceaccount", testNamespace + ":requester"},
+			} {
+				cmd = exec.Command("kubectl", append(args, "-n", testNamespace)...)
+				_, err = Run(cmd)
+				Expect(err).To(Not(HaveOccurred()))
+			}
+
+			By("requesting the view role as the serviceaccount")
+			EventuallyWithOffset(1, func() error {
+				cmd = exec.Command("kubectl", "apply", "--as", requester, "-f", "-")
+				cmd.Stdin = strings.NewReader(`{"apiVersion":"perms.infra-mgmt.io/v1","kind":"PermsAccessRequest",` +
+					`"metadata":{"name":"incident","namespace":"` + testNamespace + `"},` +
+					`"spec":{"role":"view","duration":"20s","justification":"testing21"}}`)
+				_, err = Run(cmd)
+				return err
+			}, 15*time.Second, time.Second).Should(Succeed())
+
+			By("validating that the requester may not approve its own request")
+			cmd = exec.Command("kubectl", "patch", "par", "incident", "-n", testNamespace, "--as", requester,
+				"--type", "merge", "-p", `{"spec":{"approval":{"decision":"Approved"}}}`)
+			_, err = Run(cmd)
+			Expect(err).To(HaveOccurred())
+
+			By("approving the request as cluster admin")
+			cmd = exec.Command("kubectl", "patch", "par", "incident", "-n", testNamespace,
+				"--type", "merge", "-p", `{"spec":{"approval":{"decision":"Approved","comment":"testing21"}}}`)
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+
+			getPhase := func(phase string) func() error {
+				return func() error {
+					cmd = exec.Command("kubectl", "get", "par", "incident", "-n", testNamespace, "-o", "jsonpath={.status.phase}")
+					output, err := Run(cmd)
+					if err != nil {
+						return err
+					}
+					if string(output) != phase {
+						return fmt.Errorf("phase %s, expected %s", output, phase)
+					}
+					return nil
+				}
+			}
+			By("validating that the role is bound to the requester")
+			Eventually(getPhase("Active"), 15*time.Second, time.Second).Should(Succeed())
+			cmd = exec.Command("kubectl", "get", "rolebinding", "accessrequest-incident", "-n", testNamespace, "-o", "jsonpath={.subjects[*].name}")
+			output, err := Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(string(output)).To(Equal(requester))
+
+			By("validating that the role is revoked at the expiry")
+			Eventually(getPhase("Expired"), 60*time.Second, time.Second).Should(Succeed())
+			getBinding := func() error {
+				cmd = exec.Command("kubectl", "get", "prb", "accessrequest-incident", "-n", testNamespace)
+				if _, err := Run(cmd); err == nil {
+					return fmt.Errorf("PermsRoleBinding accessrequest-incident still exists")
+				}
+				return nil
+			}
+			Eventually(getBinding, 30*time.Second, time.Second).Should(Succeed())
+
+			By("removing testing namespace")
+			cmd = exec.Command("kubectl", "delete", "ns", testNamespace)
+			_, _ = Run(cmd)
+
+		})
+
+		It("it should apply changes of sensitive PermsRoleBindings after a second user approved them", func() {
+			testNamespace := "testing22"
+			author := "system:serviceaccount:" + testNamespace + ":author"
+
+			By("creating test namespace")
+			cmd = exec.Command("kubectl", "create", "ns", testNamespace)
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("allowing a serviceaccount to grant the view role")
+			for _, args := range [][]string{
+				{"create", "serviceaccount", "author"},
+				{"create", "role", "prb-author", "--verb", "get,create,update,patch", "--resource", "permsrolebindings.perms.infra-mgmt.io"},
+				{"create", "rolebinding", "prb-author", "--role", "prb-author", "--serviceaccount", testNamespace + ":author"},
+				{"create", "role", "bind-view", "--verb", "bind", "--resource", "clusterroles.rbac.authorization.k8s.io", "--resource-name", "view"},
+				{"create", "rolebinding", "bind-view", "--role", "bind-view", "--serviceaccount", testNamespace + ":author"},
+			} {
+				cmd = exec.Command("kubectl", append(args, "-n", testNamespace)...)
+				_, err = Run(cmd)
+				Expect(err).To(Not(HaveOccurred()))
+			}
+
+			By("flagging the view role in the test namespace as sensitive")
+			EventuallyWithOffset(1, func() error {
+				cmd = exec.Command("kubectl", "apply", "-f", "-")
+				cmd.Stdin = strings.NewReader(`{"apiVersion":"perms.infra-mgmt.io/v1","kind":"PermsPolicy",` +
+					`"metadata":{"name":"` + testNamespace + `"},` +
+					`"spec":{"twoPersonApproval":{"roles":[{"kind":"ClusterRole","name":"view"}],"namespaces":["` + testNamespace + `"]}}}`)
+				_, err = Run(cmd)
+				return err
+			}, 15*time.Second, time.Second).Should(Succeed())
+
+			By("validating that a new sensitive PermsRoleBinding waits for the approval")
+			cmd = exec.Command("kubectl", "apply", "--as", author, "-f", "-")
+			cmd.Stdin = strings.NewReader(`{"apiVersion":"perms.infra-mgmt.io/v1","kind":"PermsRoleBinding",` +
+				`"metadata":{"name":"sensitive","namespace":"` + testNamespace + `"},` +
+				`"spec":{"kind":"ClusterRole","role":"view","users":["user1"]}}`)
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+			getPendingGeneration := func() (string, error) {
+				cmd = exec.Command("kubectl", "get", "prb", "sensitive", "-n", testNamespace, "-o", "jsonpath={.status.pendingChange.generation}")
+				output, err := Run(cmd)
+				return string(output), err
+			}
+			Eventually(getPendingGeneration, 15*time.Second, time.Second).Should(Equal("1"))
+			cmd = exec.Command("kubectl", "get", "rolebinding", "sensitive", "-n", testNamespace)
+			_, err = Run(cmd)
+			Expect(err).To(HaveOccurred())
+
+			By("validating that the author may not approve its own change")
+			cmd = exec.Command("kubectl", "annotate", "prb", "sensitive", "-n", testNamespace, "--as", author,
+				"perms.infra-mgmt.io/approve=1")
+			output, err := Run(cmd)
+			Expect(err).To(HaveOccurred())
+			Expect(string(output)).To(ContainSubstring("authored the spec and may not approve it"))
+
+			By("approving the change as cluster admin")
+			cmd = exec.Command("kubectl", "annotate", "prb", "sensitive", "-n", testNamespace, "perms.infra-mgmt.io/approve=1")
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+			getSubjects := func() (string, error) {
+				cmd = exec.Command("kubectl", "get", "rolebinding", "sensitive", "-n", testNamespace, "-o", "jsonpath={.subjects[*].name}")
+				output, err := Run(cmd)
+				return string(output), err
+			}
+			Eventually(getSubjects, 15*time.Second, time.Second).Should(Equal("user1"))
+
+			By("validating that a further change keeps the approved subjects")
+			cmd = exec.Command("kubectl", "patch", "prb", "sensitive", "-n", testNamespace, "--as", author,
+				"--type", "merge", "-p", `{"spec":{"users":["user1","user2"]}}`)
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+			Eventually(getPendingGeneration, 15*time.Second, time.Second).Should(Equal("2"))
+			Consistently(getSubjects, 5*time.Second, time.Second).Should(Equal("user1"))
+
+			By("removing the PermsPolicy")
+			cmd = exec.Command("kubectl", "delete", "permspolicy", testNamespace)
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+
 			By("removing testing namespace")
 			cmd = exec.Command("kubectl", "delete", "ns", testNamespace)
 			_, _ = Run(cmd)
 
 		})
 
+		It("it should bind the role of an activated PermsBreakGlass until it expires", func() {
+			testNamespace := "testing23"
+			activator := "system:serviceaccount:" + testNamespace + ":oncall"
+
+			By("creating test namespace")
+			cmd = exec.Command("kubectl", "create", "ns", testNamespace)
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("allowing a serviceaccount to activate break-glasses")
+			for _, args := range [][]string{
+				{"create", "serviceaccount", "oncall", "-n", testNamespace},
+				{"create", "clusterrole", testNamespace, "--verb", "get,patch", "--resource", "permsbreakglasses.perms.infra-mgmt.io"},
+				{"create", "clusterrolebinding", testNamespace, "--clusterrole", testNamespace, "--serviceaccount", testNamespace + ":oncall"},
+			} {
+				cmd = exec.Command("kubectl", args...)
+				_, err = Run(cmd)
+				Expect(err).To(Not(HaveOccurred()))
+			}
+
+			By("creating a break-glass for the serviceaccount")
+			EventuallyWithOffset(1, func() error {
+				cmd = exec.Command("kubectl", "apply", "-f", "-")
+				cmd.Stdin = strings.NewReader(`{"apiVersion":"perms.infra-mgmt.io/v1","kind":"PermsBreakGlass",` +
+					`"metadata":{"name":"` + testNamespace + `"},` +
+					`"spec":{"role":"view","users":["user1"],"activators":{"users":["` + activator + `"]},"maxDuration":"20s"}}`)
+				_, err = Run(cmd)
+				return err
+			}, 15*time.Second, time.Second).Should(Succeed())
+			getBinding := func() error {
+				cmd = exec.Command("kubectl", "get", "clusterrolebinding", "breakglass-"+testNamespace)
+				_, err := Run(cmd)
+				return err
+			}
+			Consistently(getBinding, 5*time.Second, time.Second).Should(HaveOccurred())
+
+			By("validating that other users may not activate the break-glass")
+			cmd = exec.Command("kubectl", "annotate", "pbg", testNamespace, "perms.infra-mgmt.io/break-glass=testing23")
+			output, err := Run(cmd)
+			Expect(err).To(HaveOccurred())
+			Expect(string(output)).To(ContainSubstring("may not activate PermsBreakGlass " + testNamespace))
+
+			By("activating the break-glass as the serviceaccount")
+			cmd = exec.Command("kubectl", "annotate", "pbg", testNamespace, "--as", activator, "perms.infra-mgmt.io/break-glass=testing23")
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+			Eventually(getBinding, 15*time.Second, time.Second).Should(Succeed())
+
+			By("validating the warning event of the activation")
+			cmd = exec.Command("kubectl", "get", "events", "-n", "default", "--field-selector",
+				"involvedObject.name="+testNamespace+",reason=BreakGlassActivated", "-o", "jsonpath={.items[*].type}")
+			output, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(string(output)).To(ContainSubstring("Warning"))
+
+			By("validating that the break-glass deactivates itself")
+			Eventually(getBinding, 60*time.Second, time.Second).Should(HaveOccurred())
+			cmd = exec.Command("kubectl", "get", "pbg", testNamespace, "-o", "jsonpath={.status.active}/{.metadata.annotations}")
+			output, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(string(output)).To(Not(ContainSubstring("true")))
+			Expect(string(output)).To(Not(ContainSubstring("perms.infra-mgmt.io/break-glass")))
+
+			By("removing the break-glass and testing namespace")
+			for _, args := range [][]string{
+				{"delete", "pbg", testNamespace},
+				{"delete", "clusterrolebinding", testNamespace},
+				{"delete", "clusterrole", testNamespace},
+				{"delete", "ns", testNamespace},
+			} {
+				cmd = exec.Command("kubectl", args...)
+				_, _ = Run(cmd)
+			}
+
+		})
+
+		It("it should remove the subjects during a PermsLockdown and restore them", func() {
+			testNamespace := "testing24"
+
+			By("creating test namespace")
+			cmd = exec.Command("kubectl", "create", "ns", testNamespace)
+			_, err = Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("creating a PermsRoleBinding")
+			EventuallyWithOffset(1, func() error {
+				cmd = exec.Command("kubectl", "apply", "-f", "-")
+				cmd.Stdin = strings.NewReader(`{"apiVersion":"perms.infra-mgmt.io/v1","kind":"PermsRoleBinding",` +
+					`"metadata":{"name":"locked","namespace":"` + testNamespace + `"},` +
+					`"spec":{"kind":"ClusterRole","role":"view","users":["user1","user2"]}}`)
+				_, err = Run(cmd)
+				return err
+			}, 15*time.Second, time.Second).Should(Succeed())
+			getSubjects := func() (string, error) {
+				cmd = exec.Command("kubectl", "get", "rolebinding", "locked", "-n", testNamespace, "-o", "jsonpath={.subjects[*].name}")
+				output, err := Run(cmd)
+				return string(output), err
+			}
+			Eventually(getSubjects, 15*time.Second, time.Second).Should(Equal("user1 user2"))
+
+			By("activating a lockdown")
+			applyLockdown := func(active bool) {
+				cmd = exec.Command("kubectl", "apply", "-f", "-")
+				cmd.Stdin = strings.NewReader(`{"apiVersion":"perms.infra-mgmt.io/v1","kind":"PermsLockdown",` +
+					`"metadata":{"name":"` + testNamespace + `"},` +
+					`"spec":{"active":` + fmt.Sprint(active) + `,"reason":"testing24"}}`)
+				_, err = Run(cmd)
+				ExpectWithOffset(1, err).To(Not(HaveOccurred()))
+			}
+			applyLockdown(true)
+			Eventually(getSubjects, 15*time.Second, time.Second).Should(BeEmpty())
+			cmd = exec.Command("kubectl", "get", "prb", "locked", "-n", testNamespace, "-o", "jsonpath={.status.lockdown.subjects[*]}")
+			output, err := Run(cmd)
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(string(output)).To(Equal("User/user1 User/user2"))
+
+			By("validating the progress of the lockdown")
+			getPhase := func() (string, error) {
+				cmd = exec.Command("kubectl", "get", "plock", testNamespace, "-o", "jsonpath={.status.phase}")
+				output, err := Run(cmd)
+				return string(output), err
+			}
+			Eventually(getPhase, 30*time.Second, time.Second).Should(Equal("Locked"))
+
+			By("lifting the lockdown")
+			applyLockdown(false)
+			Eventually(getSubjects, 15*time.Second, time.Second).Should(Equal("user1 user2"))
+			Eventually(getPhase, 30*time.Second, time.Second).Should(Equal("Restored"))
+
+			By("removing the lockdown and testing namespace")
+			for _, args := range [][]string{
+				{"delete", "plock", testNamespace},
+				{"delete", "ns", testNamespace},
+			} {
+				cmd = exec.Command("kubectl", args...)
+				_, _ = Run(cmd)
+			}
+
+		})
 	})
 
 	Context("ensure that the operator can handle resource in different namespaces", func() {
@@ -0,0 +1,132 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	permsv1 "github.com/infra-mgmt-io/perms/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+//+kubebuilder:rbac:groups=perms.infra-mgmt.io,resources=permspolicies,verbs=get;list;watch
+
+// policyViolations returns the violations of the PermsPolicies by the roles and subjects of a request,
+// the rules which depend on the requesting user are skipped
+func policyViolations(ctx context.Context, c client.Reader, req permsv1.PolicyRequest) ([]string, error) {
+	policies := &permsv1.PermsPolicyList{}
+	if err := c.List(ctx, policies); err != nil {
+		return nil, err
+	}
+	req.NamespaceLabels = map[string]map[string]string{}
+	for _, name := range req.Namespaces {
+		ns := &corev1.Namespace{}
+		if err := c.Get(ctx, types.NamespacedName{Name: name}, ns); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		req.NamespaceLabels[name] = ns.Labels
+	}
+	return permsv1.CheckPolicies(policies.Items, req), nil
+}
+
+// helper to set the "PolicyViolation" status, it returns true if the violations changed
+func setPolicyViolationStatus(ctx context.Context, conditions *[]metav1.Condition, violations []string) bool {
+	condition := metav1.Condition{
+		Type:    "PolicyViolation",
+		Status:  metav1.ConditionFalse,
+		Reason:  "PolicyCompliant",
+		Message: "Roles and subjects are allowed by all PermsPolicies",
+	}
+	if len(violations) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = reasonPolicyViolation
+		condition.Message = strings.Join(violations, "; ")
+	}
+	previous := meta.FindStatusCondition(*conditions, "PolicyViolation")
+	meta.SetStatusCondition(conditions, condition)
+	return previous == nil || previous.Status != condition.Status || previous.Message != condition.Message
+}
+
+// revokeBindings deletes the managed bindings of a resource which violates a PermsPolicy,
+// bindings which are not controlled by it are left untouched
+func revokeBindings(ctx context.Context, c client.Client, recorder record.EventRecorder, owner client.Object, bindings []permsv1.BindingReference) error {
+	for _, ref := range bindings {
+		var binding client.Object = &rbacv1.RoleBinding{}
+		if ref.Kind == "ClusterRoleBinding" {
+			binding = &rbacv1.ClusterRoleBinding{}
+		}
+		if err := c.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: ref.Namespace}, binding); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		if !metav1.IsControlledBy(binding, owner) {
+			continue
+		}
+		if err := c.Delete(ctx, binding); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		recordEvent(recorder, owner, nil, corev1.EventTypeWarning, reasonPolicyViolation, "Deleted %s %s which violates a PermsPolicy", ref.Kind, ref.Name)
+	}
+	return nil
+}
+
+// permsRoleBindingsForPolicy maps a PermsPolicy to all PermsRoleBindings, a changed policy
+// may allow or deny any of them
+func (r *PermsRoleBindingReconciler) permsRoleBindingsForPolicy(obj client.Object) []reconcile.Request {
+	ctx := context.Background()
+	list := &permsv1.PermsRoleBindingList{}
+	if err := r.List(ctx, list); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list PermsRoleBindings", "PermsPolicy", obj.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range list.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: list.Items[i].Name, Namespace: list.Items[i].Namespace}})
+	}
+	return requests
+}
+
+// permsClusterRoleBindingsForPolicy maps a PermsPolicy to all PermsClusterRoleBindings, a changed
+// policy may allow or deny any of them
+func (r *PermsClusterRoleBindingReconciler) permsClusterRoleBindingsForPolicy(obj client.Object) []reconcile.Request {
+	ctx := context.Background()
+	list := &permsv1.PermsClusterRoleBindingList{}
+	if err := r.List(ctx, list); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list PermsClusterRoleBindings", "PermsPolicy", obj.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range list.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: list.Items[i].Name}})
+	}
+	return requests
+}
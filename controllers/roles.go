@@ -0,0 +1,232 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	permsv1 "github.com/infra-mgmt-io/perms/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// roleRefIndex indexes PermsRoleBindings and PermsClusterRoleBindings by their referenced roles
+const roleRefIndex = "spec.roleRefs"
+
+// roleRulesAckAnnotation acknowledges a change of the rules of the referenced roles, its value is
+// the hash in status.roleRulesHash
+const roleRulesAckAnnotation = "perms.infra-mgmt.io/role-rules-ack"
+
+// roleRefKey returns the index value of a referenced role
+func roleRefKey(kind string, name string) string {
+	return kind + "/" + name
+}
+
+// permsRoleBindingRoleRefs returns the index values of the roles referenced by a PermsRoleBinding
+func permsRoleBindingRoleRefs(obj client.Object) []string {
+	p, ok := obj.(*permsv1.PermsRoleBinding)
+	if !ok {
+		return nil
+	}
+	refs := []string{roleRefKey(p.Spec.Kind, p.Spec.Role)}
+	for _, role := range p.Spec.Roles {
+		refs = append(refs, roleRefKey(role.Kind, role.Name))
+	}
+	return refs
+}
+
+// permsClusterRoleBindingRoleRefs returns the index value of the ClusterRole referenced by a
+// PermsClusterRoleBinding
+func permsClusterRoleBindingRoleRefs(obj client.Object) []string {
+	p, ok := obj.(*permsv1.PermsClusterRoleBinding)
+	if !ok {
+		return nil
+	}
+	return []string{roleRefKey("ClusterRole", p.Spec.Role)}
+}
+
+// permsRoleBindingsForRole maps a Role or ClusterRole to the PermsRoleBindings which reference it,
+// they are reconciled when the role is created, changed or deleted
+func (r *PermsRoleBindingReconciler) permsRoleBindingsForRole(obj client.Object) []reconcile.Request {
+	ctx := context.Background()
+	opts := []client.ListOption{}
+	switch obj.(type) {
+	case *rbacv1.Role:
+		// a Role can only be referenced from its namespace
+		opts = append(opts, client.InNamespace(obj.GetNamespace()), client.MatchingFields{roleRefIndex: roleRefKey("Role", obj.GetName())})
+	default:
+		opts = append(opts, client.MatchingFields{roleRefIndex: roleRefKey("ClusterRole", obj.GetName())})
+	}
+	list := &permsv1.PermsRoleBindingList{}
+	if err := r.List(ctx, list, opts...); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list PermsRoleBindings", "Role", obj.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range list.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: list.Items[i].Name, Namespace: list.Items[i].Namespace}})
+	}
+	return requests
+}
+
+// permsClusterRoleBindingsForClusterRole maps a ClusterRole to the PermsClusterRoleBindings which
+// reference it, they are reconciled when the ClusterRole is created, changed or deleted
+func (r *PermsClusterRoleBindingReconciler) permsClusterRoleBindingsForClusterRole(obj client.Object) []reconcile.Request {
+	ctx := context.Background()
+	list := &permsv1.PermsClusterRoleBindingList{}
+	if err := r.List(ctx, list, client.MatchingFields{roleRefIndex: roleRefKey("ClusterRole", obj.GetName())}); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list PermsClusterRoleBindings", "ClusterRole", obj.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range list.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: list.Items[i].Name}})
+	}
+	return requests
+}
+
+// roleRulesSummary returns the summary of the rules of a role
+func roleRulesSummary(kind string, name string, rules []rbacv1.PolicyRule) permsv1.RoleRulesSummary {
+	if len(rules) == 0 {
+		rules = nil
+	}
+	data, _ := json.Marshal(rules)
+	sum := sha256.Sum256(data)
+	var resources, verbs []string
+	for _, rule := range rules {
+		for _, group := range rule.APIGroups {
+			for _, resource := range rule.Resources {
+				if group != "" {
+					resource += "." + group
+				}
+				resources = append(resources, resource)
+			}
+		}
+		resources = append(resources, rule.NonResourceURLs...)
+		verbs = append(verbs, rule.Verbs...)
+	}
+	return permsv1.RoleRulesSummary{
+		RoleRef:   permsv1.RoleReference{Kind: kind, Name: name},
+		Hash:      hex.EncodeToString(sum[:8]),
+		Rules:     int32(len(rules)),
+		Resources: mergeNames(nil, resources),
+		Verbs:     mergeNames(nil, verbs),
+	}
+}
+
+// roleRulesHash returns the hash of the rules of all referenced roles
+func roleRulesHash(summaries []permsv1.RoleRulesSummary) string {
+	if len(summaries) == 0 {
+		return ""
+	}
+	var hashes []string
+	for _, summary := range summaries {
+		hashes = append(hashes, roleRefKey(summary.RoleRef.Kind, summary.RoleRef.Name)+"="+summary.Hash)
+	}
+	sort.Strings(hashes)
+	sum := sha256.Sum256([]byte(strings.Join(hashes, "\n")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// unresolvedRoleRules returns the summary in status of a referenced role which does not exist, it is
+// kept so a role which is deleted and recreated with other rules is a change
+func unresolvedRoleRules(previous []permsv1.RoleRulesSummary, kind string, name string) []permsv1.RoleRulesSummary {
+	for _, summary := range previous {
+		if summary.RoleRef.Kind == kind && summary.RoleRef.Name == name {
+			return []permsv1.RoleRulesSummary{summary}
+		}
+	}
+	return nil
+}
+
+// changedRoleRules returns the roles whose rules differ from the rules in status, roles which
+// were not referenced before are not changed
+func changedRoleRules(previous []permsv1.RoleRulesSummary, current []permsv1.RoleRulesSummary) []string {
+	hashes := map[permsv1.RoleReference]string{}
+	for _, summary := range previous {
+		hashes[summary.RoleRef] = summary.Hash
+	}
+	var changed []string
+	for _, summary := range current {
+		if hash, ok := hashes[summary.RoleRef]; ok && hash != summary.Hash {
+			changed = append(changed, roleRefKey(summary.RoleRef.Kind, summary.RoleRef.Name))
+		}
+	}
+	return changed
+}
+
+// reconcileRoleRules compares the rules of the referenced roles with the rules in status and sets
+// the RoleRulesChanged condition. With pin a change holds the reconcile until it is acknowledged,
+// the rules in status are kept and true is returned.
+func reconcileRoleRules(recorder record.EventRecorder, obj client.Object, pin bool, conditions *[]metav1.Condition,
+	status *[]permsv1.RoleRulesSummary, hash *string, current []permsv1.RoleRulesSummary) bool {
+	*hash = roleRulesHash(current)
+	acknowledged := *hash != "" && obj.GetAnnotations()[roleRulesAckAnnotation] == *hash
+	changed := changedRoleRules(*status, current)
+	condition := meta.FindStatusCondition(*conditions, "RoleRulesChanged")
+
+	switch {
+	case len(changed) > 0 && !acknowledged:
+		message := "Rules of " + strings.Join(changed, ", ") + " changed, acknowledge with the annotation " +
+			roleRulesAckAnnotation + "=" + *hash
+		reason := "RulesChanged"
+		if pin {
+			reason = "RulesPinned"
+			message += ", the bindings are not reconciled until then"
+		}
+		if condition == nil || condition.Status != metav1.ConditionTrue || condition.Message != message {
+			recordEvent(recorder, obj, nil, corev1.EventTypeWarning, reasonRoleRulesChanged, "%s", message)
+		}
+		meta.SetStatusCondition(conditions, metav1.Condition{
+			Type:    "RoleRulesChanged",
+			Status:  metav1.ConditionTrue,
+			Reason:  reason,
+			Message: message,
+		})
+		if pin {
+			return true
+		}
+	case acknowledged && condition != nil && condition.Status == metav1.ConditionTrue:
+		meta.SetStatusCondition(conditions, metav1.Condition{
+			Type:    "RoleRulesChanged",
+			Status:  metav1.ConditionFalse,
+			Reason:  "Acknowledged",
+			Message: "Change of the rules of the referenced roles is acknowledged",
+		})
+	case condition == nil:
+		meta.SetStatusCondition(conditions, metav1.Condition{
+			Type:    "RoleRulesChanged",
+			Status:  metav1.ConditionFalse,
+			Reason:  "RulesUnchanged",
+			Message: "Rules of the referenced roles did not change",
+		})
+	}
+	*status = current
+	return false
+}
@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"testing"
+
+	permsv1 "github.com/infra-mgmt-io/perms/api/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReconcileRoleRulesRecreatedRole(t *testing.T) {
+	p := &permsv1.PermsRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "testing"}}
+	view := roleRulesSummary("ClusterRole", "view", []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}}})
+	wider := roleRulesSummary("ClusterRole", "view", []rbacv1.PolicyRule{{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}}})
+	reconcile := func(current []permsv1.RoleRulesSummary) bool {
+		return reconcileRoleRules(nil, p, true, &p.Status.Conditions, &p.Status.RoleRules, &p.Status.RoleRulesHash, current)
+	}
+
+	if reconcile([]permsv1.RoleRulesSummary{view}) {
+		t.Fatal("the first rules are held")
+	}
+	// the deleted role keeps its summary
+	if reconcile(unresolvedRoleRules(p.Status.RoleRules, "ClusterRole", "view")) {
+		t.Fatal("the deleted role is held")
+	}
+	if len(p.Status.RoleRules) != 1 || p.Status.RoleRules[0].Hash != view.Hash {
+		t.Fatalf("status.roleRules = %v, want the summary of the deleted role", p.Status.RoleRules)
+	}
+	if !reconcile([]permsv1.RoleRulesSummary{wider}) {
+		t.Error("the role recreated with other rules is not held")
+	}
+	if !meta.IsStatusConditionTrue(p.Status.Conditions, "RoleRulesChanged") {
+		t.Error("RoleRulesChanged is not set")
+	}
+}
+
+func TestUnresolvedRoleRules(t *testing.T) {
+	previous := []permsv1.RoleRulesSummary{
+		{RoleRef: permsv1.RoleReference{Kind: "Role", Name: "reader"}, Hash: "1"},
+		{RoleRef: permsv1.RoleReference{Kind: "ClusterRole", Name: "reader"}, Hash: "2"},
+	}
+	if got := unresolvedRoleRules(previous, "ClusterRole", "reader"); len(got) != 1 || got[0].Hash != "2" {
+		t.Errorf("unresolvedRoleRules() = %v, want the ClusterRole", got)
+	}
+	if got := unresolvedRoleRules(previous, "Role", "writer"); got != nil {
+		t.Errorf("unresolvedRoleRules() = %v, want none for a role which was never resolved", got)
+	}
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+
+	permsv1 "github.com/infra-mgmt-io/perms/api/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+//+kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get
+//+kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions/status,verbs=get;update;patch
+
+// StorageVersionMigrator rewrites all PermsRoleBindings and PermsClusterRoleBindings in the
+// storage version (v1) and removes the older versions from the stored versions of the CRDs,
+// so v1beta1 can be removed from the CRDs later on.
+type StorageVersionMigrator struct {
+	client.Client
+	// APIReader reads directly from the API server, the CRDs are not cached by the manager.
+	APIReader client.Reader
+	// Backoff of the retries of a failed migration, storageVersionBackoff if not set
+	Backoff wait.Backoff
+}
+
+// storageVersionBackoff retries a failed migration after 5 seconds up to every 5 minutes
+var storageVersionBackoff = wait.Backoff{Duration: 5 * time.Second, Factor: 2, Jitter: 0.1, Steps: math.MaxInt32, Cap: 5 * time.Minute}
+
+// Start migrates the stored objects once, it implements manager.Runnable. A failed migration is
+// retried with backoff until it succeeds or the manager stops, the updates pass the webhooks of
+// the operator which may reject an object or not be reachable yet.
+func (m *StorageVersionMigrator) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("storage-version-migration")
+
+	backoff := m.Backoff
+	if backoff.Steps == 0 {
+		backoff = storageVersionBackoff
+	}
+	for {
+		err := m.migrate(ctx, "permsrolebindings."+permsv1.GroupVersion.Group, &permsv1.PermsRoleBindingList{})
+		if err != nil {
+			logger.Error(err, "Failed to migrate PermsRoleBindings to the storage version")
+		} else if err = m.migrate(ctx, "permsclusterrolebindings."+permsv1.GroupVersion.Group, &permsv1.PermsClusterRoleBindingList{}); err != nil {
+			logger.Error(err, "Failed to migrate PermsClusterRoleBindings to the storage version")
+		}
+		if err == nil {
+			logger.Info("Storage version migration finished", "StorageVersion", permsv1.GroupVersion.Version)
+			return nil
+		}
+		delay := backoff.Step()
+		logger.Info("Retrying the storage version migration", "RetryAfter", delay)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+	}
+}
+
+// NeedLeaderElection runs the migration on the leader only
+func (m *StorageVersionMigrator) NeedLeaderElection() bool {
+	return true
+}
+
+// migrate rewrites the objects of a CRD, a no-op update stores them in the storage version
+func (m *StorageVersionMigrator) migrate(ctx context.Context, crdName string, list client.ObjectList) error {
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	if err := m.APIReader.Get(ctx, types.NamespacedName{Name: crdName}, crd); err != nil {
+		return err
+	}
+	storedVersions := []string{permsv1.GroupVersion.Version}
+	if reflect.DeepEqual(crd.Status.StoredVersions, storedVersions) {
+		return nil
+	}
+
+	if err := m.APIReader.List(ctx, list); err != nil {
+		return err
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+	// the stored versions are kept until every object is rewritten
+	var errs []error
+	for _, item := range items {
+		obj := item.(client.Object)
+		key := client.ObjectKeyFromObject(obj)
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			if err := m.APIReader.Get(ctx, key, obj); err != nil {
+				return err
+			}
+			return m.Update(ctx, obj)
+		})
+		if client.IgnoreNotFound(err) != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+	if len(errs) > 0 {
+		return utilerrors.NewAggregate(errs)
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := m.APIReader.Get(ctx, types.NamespacedName{Name: crdName}, crd); err != nil {
+			return err
+		}
+		crd.Status.StoredVersions = storedVersions
+		return m.Status().Update(ctx, crd)
+	})
+}
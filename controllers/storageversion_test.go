@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	permsv1 "github.com/infra-mgmt-io/perms/api/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// rejectingClient fails the first updates of an object like a webhook which is not reachable yet
+type rejectingClient struct {
+	client.Client
+	name     string
+	failures int
+	// storedVersions are the stored versions of the CRD at each rejected update
+	storedVersions [][]string
+}
+
+func (c *rejectingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if obj.GetName() == c.name && c.failures > 0 {
+		c.failures--
+		crd := &apiextensionsv1.CustomResourceDefinition{}
+		if err := c.Get(ctx, types.NamespacedName{Name: "permsrolebindings." + permsv1.GroupVersion.Group}, crd); err != nil {
+			return err
+		}
+		c.storedVersions = append(c.storedVersions, crd.Status.StoredVersions)
+		return errors.New("webhook is not reachable")
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func TestStorageVersionMigratorRetries(t *testing.T) {
+	scheme := newLockdownScheme(t)
+	if err := apiextensionsv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	crd := func(name string) *apiextensionsv1.CustomResourceDefinition {
+		return &apiextensionsv1.CustomResourceDefinition{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status:     apiextensionsv1.CustomResourceDefinitionStatus{StoredVersions: []string{"v1beta1", "v1"}},
+		}
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		crd("permsrolebindings."+permsv1.GroupVersion.Group), crd("permsclusterrolebindings."+permsv1.GroupVersion.Group),
+		&permsv1.PermsRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "testing"}},
+		&permsv1.PermsRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "rejected", Namespace: "testing"}},
+	).Build()
+	rejecting := &rejectingClient{Client: c, name: "rejected", failures: 2}
+	m := &StorageVersionMigrator{Client: rejecting, APIReader: c,
+		Backoff: wait.Backoff{Duration: time.Millisecond, Factor: 1, Steps: 10}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := m.Start(ctx); err != nil {
+		t.Fatalf("Start() = %v, want the migration to be retried", err)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("migration was not finished after the webhook accepted the update")
+	}
+	for i, versions := range rejecting.storedVersions {
+		if !reflect.DeepEqual(versions, []string{"v1beta1", "v1"}) {
+			t.Errorf("stored versions at failure %d = %v, want them unchanged", i, versions)
+		}
+	}
+	for _, name := range []string{"permsrolebindings.", "permsclusterrolebindings."} {
+		migrated := &apiextensionsv1.CustomResourceDefinition{}
+		if err := c.Get(context.Background(), types.NamespacedName{Name: name + permsv1.GroupVersion.Group}, migrated); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(migrated.Status.StoredVersions, []string{"v1"}) {
+			t.Errorf("stored versions of %s = %v, want [v1]", migrated.Name, migrated.Status.StoredVersions)
+		}
+	}
+
+	// a migration which never succeeds ends with the manager
+	rejecting.failures = 1000
+	for _, name := range []string{"permsrolebindings.", "permsclusterrolebindings."} {
+		reset := &apiextensionsv1.CustomResourceDefinition{}
+		if err := c.Get(context.Background(), types.NamespacedName{Name: name + permsv1.GroupVersion.Group}, reset); err != nil {
+			t.Fatal(err)
+		}
+		reset.Status.StoredVersions = []string{"v1beta1", "v1"}
+		if err := c.Status().Update(context.Background(), reset); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stopped, stop := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer stop()
+	if err := m.Start(stopped); err != nil {
+		t.Errorf("Start() = %v after the manager stopped, want nil", err)
+	}
+}
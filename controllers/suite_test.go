@@ -35,7 +35,7 @@ import (
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
-	permsv1beta1 "github.com/infra-mgmt-io/perms/api/v1beta1"
+	permsv1 "github.com/infra-mgmt-io/perms/api/v1"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -49,6 +49,7 @@ var testEnv *envtest.Environment
 const operatorImage = "example.com/infra-mgmt-io-perms:0.0.1"
 const podAppLabel = "app.kubernetes.io/name=perms-controller-manager"
 const operatorNamespace = "perms-system"
+const certmanagerVersion = "v1.8.0"
 
 var err error
 var cmd *exec.Cmd
@@ -76,7 +77,7 @@ var _ = BeforeSuite(func() {
 	Expect(err).NotTo(HaveOccurred())
 	Expect(cfg).NotTo(BeNil())
 
-	err = permsv1beta1.AddToScheme(scheme.Scheme)
+	err = permsv1.AddToScheme(scheme.Scheme)
 	Expect(err).NotTo(HaveOccurred())
 
 	//+kubebuilder:scaffold:scheme
@@ -94,6 +95,10 @@ var _ = BeforeSuite(func() {
 	err = loadImageToClusterWithName(operatorImage)
 	ExpectWithOffset(1, err).NotTo(HaveOccurred())
 
+	By("installing cert-manager")
+	err = installCertManager()
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+
 	By("creating operator namespace")
 	cmd = exec.Command("kubectl", "create", "ns", operatorNamespace)
 	_, err = Run(cmd)
@@ -120,7 +125,7 @@ var _ = BeforeSuite(func() {
 	}
 	EventuallyWithOffset(1, getPodStatus, 15*time.Second, time.Second).Should(Succeed())
 
-}, 60)
+}, 360)
 
 var _ = AfterSuite(func() {
 	By("removing operator namespace")
@@ -176,3 +181,19 @@ func loadImageToClusterWithName(name string) error {
 	_, err := Run(cmd)
 	return err
 }
+
+// installCertManager installs cert-manager, the webhooks of the operator require it
+func installCertManager() error {
+	url := fmt.Sprintf("https://github.com/cert-manager/cert-manager/releases/download/%s/cert-manager.yaml", certmanagerVersion)
+	cmd := exec.Command("kubectl", "apply", "-f", url)
+	if _, err := Run(cmd); err != nil {
+		return err
+	}
+	cmd = exec.Command("kubectl", "wait", "deployment.apps/cert-manager-webhook",
+		"--for", "condition=Available",
+		"--namespace", "cert-manager",
+		"--timeout", "5m",
+	)
+	_, err := Run(cmd)
+	return err
+}
@@ -2,9 +2,24 @@ package controllers
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
 
+	permsv1 "github.com/infra-mgmt-io/perms/api/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
 // helper to set the "Available" status
@@ -29,13 +44,14 @@ func setEverythingIsFineStatus(ctx context.Context, conditions *[]metav1.Conditi
 	})
 }
 
-// helper to set the "Degraded" status
-func setHoustonWeHaveAProblemStatus(ctx context.Context, conditions *[]metav1.Condition) {
+// helper to set the "Degraded" status with the reason RoleNotFound, ImmutableRoleRef, Conflict,
+// EscalationDenied, AuthorUnknown or APIError and the message of the problem
+func setHoustonWeHaveAProblemStatus(ctx context.Context, conditions *[]metav1.Condition, reason string, message string) {
 	meta.SetStatusCondition(conditions, metav1.Condition{
 		Type:    "Available",
 		Status:  metav1.ConditionFalse,
-		Reason:  "Unavailable",
-		Message: "Permissions Operator is unavailable",
+		Reason:  reason,
+		Message: message,
 	})
 	meta.SetStatusCondition(conditions, metav1.Condition{
 		Type:    "Progressing",
@@ -46,11 +62,73 @@ func setHoustonWeHaveAProblemStatus(ctx context.Context, conditions *[]metav1.Co
 	meta.SetStatusCondition(conditions, metav1.Condition{
 		Type:    "Degraded",
 		Status:  metav1.ConditionTrue,
-		Reason:  "Degraded",
-		Message: "Permissions Operator task are degraded",
+		Reason:  reason,
+		Message: message,
+	})
+}
+
+// helper to set the "Degraded" status for an error
+func setErrorStatus(ctx context.Context, conditions *[]metav1.Condition, err error) {
+	setHoustonWeHaveAProblemStatus(ctx, conditions, errorReason(err), err.Error())
+}
+
+// immutableRoleRefMessage returns the message of a role switch denied by spec.immutableRoleRef
+func immutableRoleRefMessage(from rbacv1.RoleRef, to rbacv1.RoleRef) string {
+	return "Role switch from " + from.Kind + "/" + from.Name + " to " + to.Kind + "/" + to.Name + " denied by spec.immutableRoleRef"
+}
+
+// setObservedGeneration sets the generation the conditions were computed for
+func setObservedGeneration(conditions []metav1.Condition, generation int64) {
+	for i := range conditions {
+		conditions[i].ObservedGeneration = generation
+	}
+}
+
+// orderConditions keeps the Available, Progressing and Degraded conditions in front of the
+// other conditions, clients read them by index
+func orderConditions(conditions []metav1.Condition) {
+	rank := map[string]int{"Available": 0, "Progressing": 1, "Degraded": 2}
+	sort.SliceStable(conditions, func(i, j int) bool {
+		ri, ok := rank[conditions[i].Type]
+		if !ok {
+			ri = len(rank)
+		}
+		rj, ok := rank[conditions[j].Type]
+		if !ok {
+			rj = len(rank)
+		}
+		return ri < rj
 	})
 }
 
+// mergeLastChange adds the subjects added and removed at now to the last change summary,
+// changes of the same reconcile are merged
+func mergeLastChange(last *permsv1.SubjectChange, added []string, removed []string, now time.Time) *permsv1.SubjectChange {
+	if len(added) == 0 && len(removed) == 0 {
+		return last
+	}
+	if last == nil || !last.Time.Time.Equal(now) {
+		last = &permsv1.SubjectChange{Time: metav1.Time{Time: now}}
+	}
+	last.Added = mergeNames(last.Added, added)
+	last.Removed = mergeNames(last.Removed, removed)
+	return last
+}
+
+// mergeNames returns the sorted union of two lists of names
+func mergeNames(names []string, more []string) []string {
+	seen := map[string]bool{}
+	var merged []string
+	for _, name := range append(append([]string{}, names...), more...) {
+		if !seen[name] {
+			seen[name] = true
+			merged = append(merged, name)
+		}
+	}
+	sort.Strings(merged)
+	return merged
+}
+
 // helper to set the "Progressing" status
 func setProgressingStatus(ctx context.Context, conditions *[]metav1.Condition) {
 	meta.SetStatusCondition(conditions, metav1.Condition{
@@ -72,3 +150,345 @@ func setProgressingStatus(ctx context.Context, conditions *[]metav1.Condition) {
 		Message: "No Permissions Operator task are degraded",
 	})
 }
+
+// helper to set the "RoleSwitched" status
+func setRoleSwitchedStatus(ctx context.Context, conditions *[]metav1.Condition, from rbacv1.RoleRef, to rbacv1.RoleRef) {
+	meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:    "RoleSwitched",
+		Status:  metav1.ConditionTrue,
+		Reason:  "RoleRefChanged",
+		Message: "Role switched from " + from.Kind + "/" + from.Name + " to " + to.Kind + "/" + to.Name,
+	})
+}
+
+// helper to set the "RoleSwitched" status if a role switch is not allowed
+func setRoleSwitchDeniedStatus(ctx context.Context, conditions *[]metav1.Condition, from rbacv1.RoleRef, to rbacv1.RoleRef) {
+	meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:    "RoleSwitched",
+		Status:  metav1.ConditionFalse,
+		Reason:  "ImmutableRoleRef",
+		Message: "Role switch from " + from.Kind + "/" + from.Name + " to " + to.Kind + "/" + to.Name + " denied by spec.immutableRoleRef",
+	})
+}
+
+// helper to set the "RoleResolved" status, missing is the message of the referenced roles which
+// do not exist
+func setRoleResolvedStatus(ctx context.Context, conditions *[]metav1.Condition, missing string) {
+	condition := metav1.Condition{
+		Type:    "RoleResolved",
+		Status:  metav1.ConditionTrue,
+		Reason:  "RoleFound",
+		Message: "Referenced roles exist",
+	}
+	if missing != "" {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = reasonRoleNotFound
+		condition.Message = missing
+	}
+	meta.SetStatusCondition(conditions, condition)
+}
+
+// roleSwitchBindingName returns the name of the temporary binding which keeps
+// the new role granted while the original binding is recreated
+func roleSwitchBindingName(name string) string {
+	return name + "-role-switch"
+}
+
+// helper to set the "GrantActive" status of the validity window
+func setGrantActiveStatus(ctx context.Context, conditions *[]metav1.Condition, reason string) {
+	condition := metav1.Condition{
+		Type:    "GrantActive",
+		Status:  metav1.ConditionTrue,
+		Reason:  reason,
+		Message: "Subjects have the role assigned",
+	}
+	switch reason {
+	case "NotYetValid":
+		condition.Status = metav1.ConditionFalse
+		condition.Message = "Subjects get the role assigned at spec.notBefore"
+	case "Expired":
+		condition.Status = metav1.ConditionFalse
+		condition.Message = "Subjects lost the role at spec.expiresAt"
+	}
+	meta.SetStatusCondition(conditions, condition)
+}
+
+// validityReason returns "NotYetValid", "Expired" or "Active" for the validity window at now
+func validityReason(v permsv1.Validity, now time.Time) string {
+	if v.NotBefore != nil && now.Before(v.NotBefore.Time) {
+		return "NotYetValid"
+	}
+	if v.ExpiresAt != nil && !now.Before(v.ExpiresAt.Time) {
+		return "Expired"
+	}
+	return "Active"
+}
+
+// expired returns true if an expiry is set and reached at now
+func expired(expiresAt *metav1.Time, now time.Time) bool {
+	return expiresAt != nil && !now.Before(expiresAt.Time)
+}
+
+// expiryOf returns the expiry of a user or group, nil if it does not expire
+func expiryOf(expiry map[string]metav1.Time, name string) *metav1.Time {
+	if t, ok := expiry[name]; ok {
+		return &t
+	}
+	return nil
+}
+
+// expirationsForSubjects returns the subjects with an expiry which have the role assigned at now,
+// the next expiration first, and the next time the assigned subjects change
+func expirationsForSubjects(v permsv1.Validity, groups []string, users []string, serviceaccounts []permsv1.Serviceaccount, namespace string, now time.Time) ([]permsv1.SubjectExpiration, *metav1.Time) {
+	var expirations []permsv1.SubjectExpiration
+	if validityReason(v, now) == "Active" {
+		for _, group := range groups {
+			if t := expiryOf(v.GroupExpiry, group); t != nil && !expired(t, now) {
+				expirations = append(expirations, permsv1.SubjectExpiration{Kind: "Group", Name: group, ExpiresAt: *t})
+			}
+		}
+		for _, user := range users {
+			if t := expiryOf(v.UserExpiry, user); t != nil && !expired(t, now) {
+				expirations = append(expirations, permsv1.SubjectExpiration{Kind: "User", Name: user, ExpiresAt: *t})
+			}
+		}
+		for _, sa := range serviceaccounts {
+			if sa.ExpiresAt != nil && !expired(sa.ExpiresAt, now) {
+				saNamespace := sa.Namespace
+				if saNamespace == "" {
+					saNamespace = namespace
+				}
+				expirations = append(expirations, permsv1.SubjectExpiration{Kind: "ServiceAccount", Name: sa.Name, Namespace: saNamespace, ExpiresAt: *sa.ExpiresAt})
+			}
+		}
+		sort.SliceStable(expirations, func(i, j int) bool {
+			return expirations[i].ExpiresAt.Before(&expirations[j].ExpiresAt)
+		})
+	}
+
+	// the next transition is the next start or end of the window or the next expiration
+	var next *metav1.Time
+	candidates := []*metav1.Time{v.NotBefore, v.ExpiresAt}
+	if len(expirations) > 0 {
+		candidates = append(candidates, &expirations[0].ExpiresAt)
+	}
+	for _, t := range candidates {
+		if t != nil && now.Before(t.Time) && (next == nil || t.Before(next)) {
+			next = t.DeepCopy()
+		}
+	}
+	return expirations, next
+}
+
+// requeueAtTransition requeues the resource at the next time the assigned subjects change
+func requeueAtTransition(next *metav1.Time, now time.Time) ctrl.Result {
+	if next == nil {
+		return ctrl.Result{}
+	}
+	return ctrl.Result{RequeueAfter: next.Sub(now)}
+}
+
+// helper to set the "Conflict" status of bindings which are not managed by the operator
+func setConflictStatus(ctx context.Context, conditions *[]metav1.Condition, message string) {
+	meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:    "Conflict",
+		Status:  metav1.ConditionTrue,
+		Reason:  "UnmanagedBinding",
+		Message: message,
+	})
+}
+
+// helper to set the "Conflict" status if all bindings are managed by the operator
+func setNoConflictStatus(ctx context.Context, conditions *[]metav1.Condition) {
+	meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:    "Conflict",
+		Status:  metav1.ConditionFalse,
+		Reason:  "NoConflict",
+		Message: "All bindings are managed by the Permissions Operator",
+	})
+}
+
+// bindingConflict is returned for a binding which exists but is not managed by the resource
+type bindingConflict struct {
+	kind string
+	key  client.ObjectKey
+}
+
+func (c *bindingConflict) Error() string {
+	if c.key.Namespace == "" {
+		return fmt.Sprintf("%s %s exists and is not managed by the operator, set spec.adoptionPolicy to Adopt to take it over", c.kind, c.key.Name)
+	}
+	return fmt.Sprintf("%s %s exists and is not managed by the operator, set spec.adoptionPolicy to Adopt to take it over", c.kind, c.key)
+}
+
+// adoptBinding checks that an existing binding is controlled by the owner. A binding without
+// controller is adopted with the adoption policy Adopt: the owner reference, labels and the
+// operator annotation are set and the caller has to update the binding. Otherwise a
+// bindingConflict is returned.
+func adoptBinding(binding client.Object, kind string, owner client.Object, policy string, bindingLabels map[string]string, scheme *runtime.Scheme) (bool, error) {
+	if metav1.IsControlledBy(binding, owner) {
+		return false, nil
+	}
+	// a managed binding which lost its owner reference is restored by syncBinding
+	if metav1.GetControllerOf(binding) == nil && lostOwner(binding, bindingLabels) {
+		return false, nil
+	}
+	if policy != "Adopt" || metav1.GetControllerOf(binding) != nil {
+		return false, &bindingConflict{kind: kind, key: client.ObjectKeyFromObject(binding)}
+	}
+	if err := controllerutil.SetControllerReference(owner, binding, scheme); err != nil {
+		return false, err
+	}
+	currentLabels := binding.GetLabels()
+	if currentLabels == nil {
+		currentLabels = map[string]string{}
+	}
+	for key, value := range bindingLabels {
+		currentLabels[key] = value
+	}
+	binding.SetLabels(currentLabels)
+	annotations := binding.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations["infra-mgmt.io/perms"] = "operator-created"
+	binding.SetAnnotations(annotations)
+	return true, nil
+}
+
+// lostOwner returns true if a binding without controller was written by the operator: it has the
+// labels of the owner, the operator annotation and the applied hash matches its roleRef and
+// subjects. Labels alone can be set by anyone who may create bindings.
+func lostOwner(binding client.Object, bindingLabels map[string]string) bool {
+	if !labels.SelectorFromSet(bindingLabels).Matches(labels.Set(binding.GetLabels())) {
+		return false
+	}
+	annotations := binding.GetAnnotations()
+	if annotations["infra-mgmt.io/perms"] != "operator-created" {
+		return false
+	}
+	roleRef, subjects := bindingContent(binding)
+	hash, ok := annotations[appliedHashAnnotation]
+	return ok && hash == bindingHash(roleRef, *subjects)
+}
+
+// ownershipResult sets the status for an error of adoptBinding, a conflict is retried
+// after a minute as unmanaged bindings are not watched
+func ownershipResult(ctx context.Context, conditions *[]metav1.Condition, err error) (ctrl.Result, error) {
+	setErrorStatus(ctx, conditions, err)
+	var conflict *bindingConflict
+	if errors.As(err, &conflict) {
+		setConflictStatus(ctx, conditions, conflict.Error())
+		return ctrl.Result{RequeueAfter: time.Minute}, nil
+	}
+	return ctrl.Result{}, err
+}
+
+// appliedHashAnnotation records the hash of the roleRef and subjects the operator applied to a
+// binding, a binding which does not match it was changed outside of the operator
+const appliedHashAnnotation = "perms.infra-mgmt.io/applied-hash"
+
+// bindingHash returns the hash of the roleRef and subjects of a binding
+func bindingHash(roleRef rbacv1.RoleRef, subjects []rbacv1.Subject) string {
+	if len(subjects) == 0 {
+		subjects = nil
+	}
+	data, _ := json.Marshal(struct {
+		RoleRef  rbacv1.RoleRef   `json:"roleRef"`
+		Subjects []rbacv1.Subject `json:"subjects"`
+	}{roleRef, subjects})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// setAppliedHash records the roleRef and subjects of a binding which is written by the operator
+func setAppliedHash(binding metav1.Object, roleRef rbacv1.RoleRef, subjects []rbacv1.Subject) {
+	annotations := binding.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[appliedHashAnnotation] = bindingHash(roleRef, subjects)
+	binding.SetAnnotations(annotations)
+}
+
+// bindingContent returns the roleRef and subjects of a RoleBinding or ClusterRoleBinding
+func bindingContent(binding client.Object) (rbacv1.RoleRef, *[]rbacv1.Subject) {
+	switch b := binding.(type) {
+	case *rbacv1.RoleBinding:
+		return b.RoleRef, &b.Subjects
+	case *rbacv1.ClusterRoleBinding:
+		return b.RoleRef, &b.Subjects
+	}
+	panic(fmt.Sprintf("unsupported binding type %T", binding))
+}
+
+// syncBinding applies the subjects, labels, annotations and owner references of the desired
+// binding to the current binding with the same roleRef. It returns true if the current binding
+// has to be updated and drifted is true if it was changed outside of the operator.
+func syncBinding(current client.Object, desired client.Object) (update bool, drifted bool) {
+	roleRef, subjects := bindingContent(current)
+	_, desiredSubjects := bindingContent(desired)
+
+	annotations := current.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	// bindings written before the hash was recorded are not checked for changed subjects
+	if hash, ok := annotations[appliedHashAnnotation]; ok && hash != bindingHash(roleRef, *subjects) {
+		drifted = true
+	}
+	if !reflect.DeepEqual(*subjects, *desiredSubjects) {
+		*subjects = *desiredSubjects
+		update = true
+	}
+
+	currentLabels := current.GetLabels()
+	if currentLabels == nil {
+		currentLabels = map[string]string{}
+	}
+	for key, value := range desired.GetLabels() {
+		if old, ok := currentLabels[key]; !ok || old != value {
+			currentLabels[key] = value
+			update, drifted = true, true
+		}
+	}
+	current.SetLabels(currentLabels)
+
+	// the applied hash of the desired binding covers the subjects applied by this update
+	for key, value := range desired.GetAnnotations() {
+		if old, ok := annotations[key]; !ok || old != value {
+			annotations[key] = value
+			update = true
+			if key != appliedHashAnnotation {
+				drifted = true
+			}
+		}
+	}
+	current.SetAnnotations(annotations)
+
+	if owner := metav1.GetControllerOf(desired); owner != nil && !metav1.IsControlledBy(current, &metav1.ObjectMeta{UID: owner.UID}) {
+		current.SetOwnerReferences(append(current.GetOwnerReferences(), *owner))
+		update, drifted = true, true
+	}
+	return update, drifted
+}
+
+// isBound returns true if a binding is listed in the status, a listed binding which does not
+// exist was deleted outside of the operator
+func isBound(bindings []permsv1.BindingReference, kind string, namespace string, name string) bool {
+	for _, b := range bindings {
+		if b.Kind == kind && b.Namespace == namespace && b.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// withResync requeues a result at the latest after the resync interval, so bindings which
+// are not watched anymore are checked for drift
+func withResync(result ctrl.Result, interval time.Duration) ctrl.Result {
+	if interval > 0 && (result.RequeueAfter == 0 || interval < result.RequeueAfter) {
+		result.RequeueAfter = interval
+	}
+	return result
+}
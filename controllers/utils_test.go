@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	permsv1 "github.com/infra-mgmt-io/perms/api/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestLostOwner(t *testing.T) {
+	roleRef := rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: "view"}
+	subjects := []rbacv1.Subject{{Kind: "User", Name: "user1"}}
+	bindingLabels := labelsForPermsRoleBindings("demo")
+	binding := func(annotations map[string]string) *rbacv1.RoleBinding {
+		return &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "testing", Labels: bindingLabels, Annotations: annotations},
+			RoleRef:    roleRef,
+			Subjects:   subjects,
+		}
+	}
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        bool
+	}{
+		{"applied by the operator", map[string]string{
+			"infra-mgmt.io/perms": "operator-created", appliedHashAnnotation: bindingHash(roleRef, subjects)}, true},
+		{"labels only", nil, false},
+		{"without applied hash", map[string]string{"infra-mgmt.io/perms": "operator-created"}, false},
+		{"changed subjects", map[string]string{
+			"infra-mgmt.io/perms": "operator-created", appliedHashAnnotation: bindingHash(roleRef, nil)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lostOwner(binding(tt.annotations), bindingLabels); got != tt.want {
+				t.Errorf("lostOwner() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoleSwitchBindingNotManaged(t *testing.T) {
+	now := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+	prb := &permsv1.PermsRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "testing", UID: "1234"},
+		Spec:       permsv1.PermsRoleBindingSpec{Kind: "ClusterRole", Role: "edit", Users: []string{"user1"}},
+	}
+	// a rolebinding which happens to have the name of the role switch binding
+	foreign := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: roleSwitchBindingName(prb.Name), Namespace: "testing"},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "view"},
+	}
+	scheme := newLockdownScheme(t)
+	r := &PermsRoleBindingReconciler{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(foreign).Build(), Scheme: scheme}
+	if err := r.removeRoleSwitchBinding(context.Background(), prb); err != nil {
+		t.Fatalf("removeRoleSwitchBinding() = %v, want the rolebinding to be ignored", err)
+	}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: foreign.Name, Namespace: foreign.Namespace}, &rbacv1.RoleBinding{}); err != nil {
+		t.Fatalf("rolebinding which is not managed was removed: %v", err)
+	}
+
+	current := r.rolebindingForPerms(prb, context.Background(), now)
+	current.RoleRef.Name = "view"
+	if err := r.Create(context.Background(), current); err != nil {
+		t.Fatal(err)
+	}
+	var conflict *bindingConflict
+	if err := r.switchRoleBinding(context.Background(), prb, current, now); !errors.As(err, &conflict) {
+		t.Fatalf("switchRoleBinding() = %v, want a binding conflict", err)
+	}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: current.Name, Namespace: current.Namespace}, &rbacv1.RoleBinding{}); err != nil {
+		t.Errorf("rolebinding was removed although the switch did not start: %v", err)
+	}
+}
@@ -19,6 +19,7 @@ package main
 import (
 	"flag"
 	"os"
+	"strings"
 	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
@@ -31,13 +32,17 @@ import (
 
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 
+	permsv1 "github.com/infra-mgmt-io/perms/api/v1"
 	permsv1beta1 "github.com/infra-mgmt-io/perms/api/v1beta1"
 	"github.com/infra-mgmt-io/perms/controllers"
 	//+kubebuilder:scaffold:imports
@@ -48,10 +53,15 @@ var (
 	setupLog = ctrl.Log.WithName("setup")
 )
 
+// serviceAccountNamespaceFile contains the namespace of the operator pod
+const serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(apiextensionsv1.AddToScheme(scheme))
 
 	utilruntime.Must(permsv1beta1.AddToScheme(scheme))
+	utilruntime.Must(permsv1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
@@ -59,11 +69,42 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var enableWebhooks bool
+	var migrateStorageVersion bool
+	var resyncInterval time.Duration
+	var impersonateAuthors bool
+	var maxSubjectAdditions int
+	var maxSubjectRemovals int
+	var changeWindow time.Duration
+	var changeLimitNamespace string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false,
+		"Enable the admission webhooks. "+
+			"Enabling this requires a serving certificate for the webhook server.")
+	flag.BoolVar(&migrateStorageVersion, "migrate-storage-version", true,
+		"Rewrite the stored resources in the storage version and remove old versions from the stored versions of the CRDs.")
+	flag.DurationVar(&resyncInterval, "resync-interval", 10*time.Minute,
+		"The interval in which the managed bindings are checked for changes outside of the operator. "+
+			"Changes of owned bindings are reverted right away, 0 disables the resync.")
+	flag.BoolVar(&impersonateAuthors, "impersonate-authors", false,
+		"Create and update the bindings as the author of the resource instead of with the bind permission of the operator. "+
+			"Enabling this requires the permission to impersonate users, groups and serviceaccounts and --enable-webhooks.")
+	flag.IntVar(&maxSubjectAdditions, "max-subject-additions", 0,
+		"The number of subjects which may be added to the bindings of all resources within the change window. "+
+			"Once a limit is exceeded further changes of the subjects are held until they are released, 0 disables the limit.")
+	flag.IntVar(&maxSubjectRemovals, "max-subject-removals", 0,
+		"The number of subjects which may be removed from the bindings of all resources, including deleted ones, within the change window. "+
+			"Once a limit is exceeded further changes of the subjects are held until they are released, 0 disables the limit.")
+	flag.DurationVar(&changeWindow, "change-window", 10*time.Minute,
+		"The window in which the subjects added and removed for --max-subject-additions and --max-subject-removals are counted.")
+	flag.StringVar(&changeLimitNamespace, "change-limit-namespace", "",
+		"The namespace of the ConfigMap perms-change-limit which keeps the counted changes and the hold of the limits across restarts. "+
+			"Defaults to the namespace of the operator pod.")
+	flag.Parse()
 
 	// Human readable time format
 	configLog := uzap.NewProductionEncoderConfig()
@@ -76,7 +117,26 @@ func main() {
 	logger := zap.New(zap.UseDevMode(true), zap.WriteTo(os.Stdout), zap.Encoder(logfmtEncoder))
 	logf.SetLogger(logger)
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	// without the webhook anyone who may edit a resource can set the author annotations
+	if impersonateAuthors && !enableWebhooks {
+		setupLog.Error(nil, "--impersonate-authors requires --enable-webhooks, the webhook records the author of the resources")
+		os.Exit(1)
+	}
+
+	// the counted changes of the subjects are kept in a ConfigMap in the namespace of the operator
+	if (maxSubjectAdditions > 0 || maxSubjectRemovals > 0) && changeLimitNamespace == "" {
+		namespace, err := os.ReadFile(serviceAccountNamespaceFile)
+		if err != nil {
+			setupLog.Error(err, "--max-subject-additions and --max-subject-removals require --change-limit-namespace outside of a pod")
+			os.Exit(1)
+		}
+		changeLimitNamespace = strings.TrimSpace(string(namespace))
+	}
+
+	// the user agent tells the changes of the operator from the changes of clients in the managed fields
+	restConfig := ctrl.GetConfigOrDie()
+	restConfig.UserAgent = controllers.FieldManager
+	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
 		Port:                   9443,
@@ -100,20 +160,141 @@ func main() {
 		os.Exit(1)
 	}
 
+	var reconcilerClient client.Client = mgr.GetClient()
+	if impersonateAuthors {
+		reconcilerClient = controllers.NewImpersonatingClient(mgr.GetClient(), mgr.GetConfig(),
+			client.Options{Scheme: mgr.GetScheme(), Mapper: mgr.GetRESTMapper()})
+	}
+	changeLimiter := &controllers.ChangeLimiter{
+		MaxAdditions: maxSubjectAdditions,
+		MaxRemovals:  maxSubjectRemovals,
+		Window:       changeWindow,
+		Client:       mgr.GetClient(),
+		APIReader:    mgr.GetAPIReader(),
+		Namespace:    changeLimitNamespace,
+	}
 	if err = (&controllers.PermsRoleBindingReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:          reconcilerClient,
+		Scheme:          mgr.GetScheme(),
+		Recorder:        mgr.GetEventRecorderFor("permsrolebinding-controller"),
+		ResyncInterval:  resyncInterval,
+		ChangeLimiter:   changeLimiter,
+		WebhooksEnabled: enableWebhooks,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "PermsRoleBinding")
 		os.Exit(1)
 	}
 	if err = (&controllers.PermsClusterRoleBindingReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:          reconcilerClient,
+		Scheme:          mgr.GetScheme(),
+		Recorder:        mgr.GetEventRecorderFor("permsclusterrolebinding-controller"),
+		ResyncInterval:  resyncInterval,
+		ChangeLimiter:   changeLimiter,
+		WebhooksEnabled: enableWebhooks,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "PermsClusterRoleBinding")
 		os.Exit(1)
 	}
+	if err = (&controllers.PermsConstraintReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("permsconstraint-controller"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PermsConstraint")
+		os.Exit(1)
+	}
+	// without the webhook anyone who may edit a PermsAccessRequest can approve it
+	if enableWebhooks {
+		if err = (&controllers.PermsAccessRequestReconciler{
+			Client:          mgr.GetClient(),
+			Scheme:          mgr.GetScheme(),
+			Recorder:        mgr.GetEventRecorderFor("permsaccessrequest-controller"),
+			WebhooksEnabled: enableWebhooks,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "PermsAccessRequest")
+			os.Exit(1)
+		}
+	} else {
+		setupLog.Info("PermsAccessRequests are not reconciled without --enable-webhooks, the webhook verifies the approver")
+	}
+	// without the webhook anyone who may edit a PermsBreakGlass can activate it
+	if enableWebhooks {
+		if err = (&controllers.PermsBreakGlassReconciler{
+			Client:          mgr.GetClient(),
+			Scheme:          mgr.GetScheme(),
+			Recorder:        mgr.GetEventRecorderFor("permsbreakglass-controller"),
+			WebhooksEnabled: enableWebhooks,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "PermsBreakGlass")
+			os.Exit(1)
+		}
+	} else {
+		setupLog.Info("PermsBreakGlasses are not activated without --enable-webhooks, the webhook verifies the activator")
+	}
+	if err = (&controllers.PermsLockdownReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("permslockdown-controller"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PermsLockdown")
+		os.Exit(1)
+	}
+	if enableWebhooks {
+		if err = (&permsv1.PermsRoleBinding{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "PermsRoleBinding")
+			os.Exit(1)
+		}
+		if err = (&permsv1.PermsClusterRoleBinding{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "PermsClusterRoleBinding")
+			os.Exit(1)
+		}
+		if err = (&permsv1.PermsPolicy{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "PermsPolicy")
+			os.Exit(1)
+		}
+		if err = (&permsv1.PermsConstraint{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "PermsConstraint")
+			os.Exit(1)
+		}
+		if err = (&permsv1.PermsDelegation{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "PermsDelegation")
+			os.Exit(1)
+		}
+		if err = (&permsv1.PermsAccessRequest{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "PermsAccessRequest")
+			os.Exit(1)
+		}
+		if err = (&permsv1.PermsBreakGlass{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "PermsBreakGlass")
+			os.Exit(1)
+		}
+		if err = (&permsv1.PermsLockdown{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "PermsLockdown")
+			os.Exit(1)
+		}
+		if err = permsv1.SetupEscalationWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "escalation")
+			os.Exit(1)
+		}
+		if err = permsv1.SetupAuthorWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "author")
+			os.Exit(1)
+		}
+		if err = permsv1.SetupApprovalWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "approval")
+			os.Exit(1)
+		}
+	}
+	if migrateStorageVersion {
+		if err = mgr.Add(&controllers.StorageVersionMigrator{
+			Client:    mgr.GetClient(),
+			APIReader: mgr.GetAPIReader(),
+		}); err != nil {
+			setupLog.Error(err, "unable to add storage version migration")
+			os.Exit(1)
+		}
+	}
+	metrics.Registry.MustRegister(&controllers.MetricsCollector{Reader: mgr.GetClient()})
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {